@@ -1,19 +1,54 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 
 	configtypes "github.com/daszybak/prediction_markets/internal/config"
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/execution"
+	"github.com/daszybak/prediction_markets/internal/polymarket"
+	"github.com/daszybak/prediction_markets/internal/price"
 	"go.yaml.in/yaml/v4"
 )
 
 type config struct {
 	LogLevel string `yaml:"log_level"` // debug, info, warn, error
-	Engine   struct {
-		SnapshotInterval configtypes.Duration `yaml:"snapshot_interval"`
-		SnapshotDepth    int                  `yaml:"snapshot_depth"`
+	// LogMessages logs every websocket message at debug level.
+	LogMessages bool `yaml:"log_messages"`
+	Engine      struct {
+		SnapshotInterval   configtypes.Duration `yaml:"snapshot_interval"`
+		SnapshotDepth      int                  `yaml:"snapshot_depth"`
+		SnapshotFormat     string               `yaml:"snapshot_format"` // rows (default), document
+		SnapshotTime       string               `yaml:"snapshot_time"`   // event (default), ingest
+		SnapshotVerifyRate float64              `yaml:"snapshot_verify_rate"`
+		// SnapshotEmptySides writes a level -1 row for empty sides.
+		SnapshotEmptySides bool `yaml:"snapshot_empty_sides"`
+		// SnapshotSkipUnchanged only writes books that changed since their
+		// last write.
+		SnapshotSkipUnchanged bool `yaml:"snapshot_skip_unchanged"`
+		// CrossedBookGrace is how long a book may stay crossed before it is
+		// logged and counted. 0 flags every cross.
+		CrossedBookGrace configtypes.Duration `yaml:"crossed_book_grace"`
+		// EvictIdleAfter removes books without updates for this long. 0
+		// keeps every book.
+		EvictIdleAfter configtypes.Duration `yaml:"evict_idle_after"`
+		// UpdateBufferSize and WorkerBufferSize size the engine's buffers.
+		// 0 uses the default.
+		UpdateBufferSize int `yaml:"update_buffer_size"`
+		WorkerBufferSize int `yaml:"worker_buffer_size"`
+		// TradeBufferSize is how many trades are buffered between writes.
+		// 0 uses the default.
+		TradeBufferSize int `yaml:"trade_buffer_size"`
+		// TradeFlushInterval is how often trades are written. 0 uses
+		// SnapshotInterval.
+		TradeFlushInterval configtypes.Duration `yaml:"trade_flush_interval"`
 	} `yaml:"engine"`
+	Metrics struct {
+		ListenAddr string `yaml:"listen_addr"` // Empty disables the metrics endpoint.
+	} `yaml:"metrics"`
 	Database struct {
 		Host     string `yaml:"host"`
 		Port     int    `yaml:"port"`
@@ -22,24 +57,118 @@ type config struct {
 		Database string `yaml:"database"`
 		PoolSize int    `yaml:"pool_size"`
 		SSLMode  string `yaml:"ssl_mode"`
+		// ReadReplica, if Host is set, serves analytical reads. It uses the
+		// primary's credentials and database.
+		ReadReplica struct {
+			Host string `yaml:"host"`
+			Port int    `yaml:"port"`
+		} `yaml:"read_replica"`
 	} `yaml:"database"`
 	Platforms struct {
 		PolyMarket struct {
 			WS struct {
 				WebsocketURL   string `yaml:"url"`
 				MarketEndpoint string `yaml:"market_endpoint"`
+				// SilenceTimeout redials the websocket when no frame arrived
+				// for this long. 0 disables it.
+				SilenceTimeout configtypes.Duration `yaml:"silence_timeout"`
+				// ActivityEndpoint is the activity channel, read when the
+				// activity handler is configured.
+				ActivityEndpoint string `yaml:"activity_endpoint"`
+				// SubscribeConfirmTimeout is how long a market sync waits
+				// for a frame after subscribing. 0 doesn't wait.
+				SubscribeConfirmTimeout configtypes.Duration `yaml:"subscribe_confirm_timeout"`
+				// ReconnectBaseDelay and ReconnectMaxDelay bound the backoff
+				// between failed reconnects. 0 uses the defaults.
+				ReconnectBaseDelay configtypes.Duration `yaml:"reconnect_base_delay"`
+				ReconnectMaxDelay  configtypes.Duration `yaml:"reconnect_max_delay"`
 			}
+			// HTTP bounds the phases of CLOB and Gamma requests. 0 uses the
+			// defaults. Reading a response isn't bounded.
+			HTTP struct {
+				DialTimeout           configtypes.Duration `yaml:"dial_timeout"`
+				TLSHandshakeTimeout   configtypes.Duration `yaml:"tls_handshake_timeout"`
+				ResponseHeaderTimeout configtypes.Duration `yaml:"response_header_timeout"`
+			} `yaml:"http"`
 			GammaURL           string               `yaml:"gamma_url"`
 			ClobURL            string               `yaml:"clob_url"`
 			MarketSyncInterval configtypes.Duration `yaml:"market_sync_interval"`
+			MinExpectedMarkets int                  `yaml:"min_expected_markets"`
+			IncrementalSync    bool                 `yaml:"incremental_sync"`
+			Tiers              struct {
+				FullMinVolume    float64 `yaml:"full_min_volume"`
+				ReducedMinVolume float64 `yaml:"reduced_min_volume"`
+				ReducedDepth     int     `yaml:"reduced_depth"`
+			} `yaml:"tiers"`
+			// Files of token IDs, one per line, overriding the subscribed tokens.
+			TokenAllowlistFile string `yaml:"token_allowlist_file"`
+			TokenBlocklistFile string `yaml:"token_blocklist_file"`
+			// Handlers names the message handlers, e.g. [engine, metrics].
+			Handlers []string `yaml:"handlers"`
+			// IdleUnsubscribeAfter unsubscribes from tokens without messages
+			// for this long until the next sync. 0 disables it.
+			IdleUnsubscribeAfter configtypes.Duration `yaml:"idle_unsubscribe_after"`
+			// MaxMarkets caps the markets stored per sync and subscribed
+			// to, for a staged rollout. 0 is unlimited.
+			MaxMarkets int `yaml:"max_markets"`
+			// FailOnNoTokens stops the collector when a sync leaves no
+			// tokens to subscribe to, instead of warning and syncing on.
+			FailOnNoTokens bool       `yaml:"fail_on_no_tokens"`
+			Fees           feesConfig `yaml:"fees"`
+			// AdaptiveSync moves the market sync interval between its
+			// bounds depending on how many markets change. Both intervals
+			// 0 disables it.
+			AdaptiveSync struct {
+				MinInterval    configtypes.Duration `yaml:"min_interval"`
+				MaxInterval    configtypes.Duration `yaml:"max_interval"`
+				ChurnThreshold int                  `yaml:"churn_threshold"`
+			} `yaml:"adaptive_sync"`
 		} `yaml:"polymarket"`
 		Kalshi struct {
 			APIURL        string                    `yaml:"api_url"`
 			WSURL         string                    `yaml:"ws_url"`
 			APIKeyID      string                    `yaml:"api_key_id"`
 			APIPrivateKey configtypes.RSAPrivateKey `yaml:"api_private_key"`
+			Fees          feesConfig                `yaml:"fees"`
 		} `yaml:"kalshi"`
 	} `yaml:"platforms"`
+	// OutcomeLabels maps extra outcome spellings to canonical labels on top of
+	// store.DefaultOutcomeLabels.
+	OutcomeLabels map[string]string `yaml:"outcome_labels"`
+}
+
+// feesConfig is a platform's fee model, see execution.FeeModel. Omitted, it
+// charges nothing.
+type feesConfig struct {
+	MakerBPS int64 `yaml:"maker_bps"`
+	TakerBPS int64 `yaml:"taker_bps"`
+	// Basis is what the rates apply to: notional, min_price or variance.
+	Basis string `yaml:"basis"`
+	// PerContract is a flat fee per share, e.g. "0.01".
+	PerContract string `yaml:"per_contract"`
+}
+
+// model returns the fee model the config describes.
+func (f feesConfig) model() (execution.FeeModel, error) {
+	basis, err := execution.ParseFeeBasis(f.Basis)
+	if err != nil {
+		return execution.FeeModel{}, err
+	}
+	var perContract price.Price
+	if f.PerContract != "" {
+		if perContract, err = price.Parse(f.PerContract); err != nil {
+			return execution.FeeModel{}, fmt.Errorf("per_contract: %w", err)
+		}
+	}
+	if f.MakerBPS < 0 || f.TakerBPS < 0 || perContract < 0 {
+		return execution.FeeModel{}, errors.New("fees must not be negative")
+	}
+	return execution.FeeModel{
+		MakerBPS:    f.MakerBPS,
+		TakerBPS:    f.TakerBPS,
+		Basis:       basis,
+		PerContract: perContract,
+	}, nil
 }
 
 func readConfig(configPath *string) (*config, error) {
@@ -62,61 +191,142 @@ func readConfig(configPath *string) (*config, error) {
 }
 
 func validateConfig(cfg *config) error {
+	var errs []error
+
 	// Engine
 	if cfg.Engine.SnapshotInterval.Duration() <= 0 {
-		return fmt.Errorf("engine.snapshot_interval must be positive")
+		errs = append(errs, errors.New("engine.snapshot_interval must be positive"))
 	}
 	if cfg.Engine.SnapshotDepth <= 0 {
-		return fmt.Errorf("engine.snapshot_depth must be positive")
+		errs = append(errs, errors.New("engine.snapshot_depth must be positive"))
+	}
+	switch engine.SnapshotFormat(cfg.Engine.SnapshotFormat) {
+	case "", engine.SnapshotFormatRows, engine.SnapshotFormatDocument:
+	default:
+		errs = append(errs, fmt.Errorf("engine.snapshot_format must be %q or %q", engine.SnapshotFormatRows, engine.SnapshotFormatDocument))
+	}
+	switch engine.SnapshotTime(cfg.Engine.SnapshotTime) {
+	case "", engine.SnapshotTimeEvent, engine.SnapshotTimeIngest:
+	default:
+		errs = append(errs, fmt.Errorf("engine.snapshot_time must be %q or %q", engine.SnapshotTimeEvent, engine.SnapshotTimeIngest))
+	}
+	if cfg.Engine.SnapshotVerifyRate < 0 || cfg.Engine.SnapshotVerifyRate > 1 {
+		errs = append(errs, errors.New("engine.snapshot_verify_rate must be between 0 and 1"))
+	}
+	if cfg.Engine.CrossedBookGrace.Duration() < 0 {
+		errs = append(errs, errors.New("engine.crossed_book_grace must not be negative"))
+	}
+	if cfg.Engine.EvictIdleAfter.Duration() < 0 {
+		errs = append(errs, errors.New("engine.evict_idle_after must not be negative"))
+	}
+	if cfg.Engine.UpdateBufferSize < 0 || cfg.Engine.WorkerBufferSize < 0 || cfg.Engine.TradeBufferSize < 0 {
+		errs = append(errs, errors.New("engine buffer sizes must not be negative"))
+	}
+	if cfg.Engine.TradeFlushInterval.Duration() < 0 {
+		errs = append(errs, errors.New("engine.trade_flush_interval must not be negative"))
 	}
 
 	// Database
 	if cfg.Database.Host == "" {
-		return fmt.Errorf("database.host is required")
+		errs = append(errs, errors.New("database.host is required"))
 	}
 	if cfg.Database.Port <= 0 || cfg.Database.Port > 65535 {
-		return fmt.Errorf("database.port must be between 1 and 65535")
+		errs = append(errs, errors.New("database.port must be between 1 and 65535"))
 	}
 	if cfg.Database.User == "" {
-		return fmt.Errorf("database.user is required")
+		errs = append(errs, errors.New("database.user is required"))
 	}
 	if cfg.Database.Password == "" {
-		return fmt.Errorf("database.password is required")
+		errs = append(errs, errors.New("database.password is required"))
 	}
 	if cfg.Database.Database == "" {
-		return fmt.Errorf("database.database is required")
+		errs = append(errs, errors.New("database.database is required"))
 	}
 	if cfg.Database.PoolSize <= 0 {
-		return fmt.Errorf("database.pool_size must be greater than 0")
+		errs = append(errs, errors.New("database.pool_size must be greater than 0"))
 	}
 	if cfg.Database.SSLMode == "" {
-		return fmt.Errorf("database.ssl_mode is required")
+		errs = append(errs, errors.New("database.ssl_mode is required"))
+	}
+	if cfg.Database.ReadReplica.Host != "" && cfg.Database.ReadReplica.Port <= 0 {
+		errs = append(errs, errors.New("database.read_replica.port is required with a read replica host"))
 	}
 
 	// Polymarket
 	if cfg.Platforms.PolyMarket.WS.WebsocketURL == "" {
-		return fmt.Errorf("platforms.polymarket.ws.url is required")
+		errs = append(errs, errors.New("platforms.polymarket.ws.url is required"))
 	}
 	if cfg.Platforms.PolyMarket.WS.MarketEndpoint == "" {
-		return fmt.Errorf("platforms.polymarket.ws.market_endpoint is required")
+		errs = append(errs, errors.New("platforms.polymarket.ws.market_endpoint is required"))
+	}
+	if cfg.Platforms.PolyMarket.WS.SilenceTimeout < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.ws.silence_timeout must not be negative"))
+	}
+	if ws := cfg.Platforms.PolyMarket.WS; ws.ReconnectBaseDelay < 0 || ws.ReconnectMaxDelay < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.ws reconnect delays must not be negative"))
+	} else if ws.ReconnectBaseDelay > 0 && ws.ReconnectMaxDelay > 0 && ws.ReconnectBaseDelay > ws.ReconnectMaxDelay {
+		errs = append(errs, errors.New("platforms.polymarket.ws.reconnect_base_delay must not exceed reconnect_max_delay"))
+	}
+	if cfg.Platforms.PolyMarket.WS.SubscribeConfirmTimeout < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.ws.subscribe_confirm_timeout must not be negative"))
+	}
+	httpTimeouts := cfg.Platforms.PolyMarket.HTTP
+	if httpTimeouts.DialTimeout < 0 || httpTimeouts.TLSHandshakeTimeout < 0 || httpTimeouts.ResponseHeaderTimeout < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.http timeouts must not be negative"))
 	}
 	if cfg.Platforms.PolyMarket.GammaURL == "" {
-		return fmt.Errorf("platforms.polymarket.gamma_url is required")
+		errs = append(errs, errors.New("platforms.polymarket.gamma_url is required"))
 	}
 	if cfg.Platforms.PolyMarket.ClobURL == "" {
-		return fmt.Errorf("platforms.polymarket.clob_url is required")
+		errs = append(errs, errors.New("platforms.polymarket.clob_url is required"))
+	}
+	tiers := cfg.Platforms.PolyMarket.Tiers
+	if tiers.FullMinVolume < 0 || tiers.ReducedMinVolume < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.tiers volumes must not be negative"))
+	}
+	if tiers.ReducedMinVolume > tiers.FullMinVolume {
+		errs = append(errs, errors.New("platforms.polymarket.tiers.reduced_min_volume must not exceed full_min_volume"))
+	}
+
+	if cfg.Platforms.PolyMarket.IdleUnsubscribeAfter < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.idle_unsubscribe_after must not be negative"))
+	}
+	if cfg.Platforms.PolyMarket.MaxMarkets < 0 {
+		errs = append(errs, errors.New("platforms.polymarket.max_markets must not be negative"))
+	}
+	if err := polymarket.ValidateHandlers(cfg.Platforms.PolyMarket.Handlers); err != nil {
+		errs = append(errs, fmt.Errorf("platforms.polymarket.handlers: %w", err))
+	}
+	if slices.Contains(cfg.Platforms.PolyMarket.Handlers, polymarket.HandlerActivity) && cfg.Platforms.PolyMarket.WS.ActivityEndpoint == "" {
+		errs = append(errs, errors.New("platforms.polymarket.ws.activity_endpoint is required by the activity handler"))
+	}
+	if _, err := cfg.Platforms.PolyMarket.Fees.model(); err != nil {
+		errs = append(errs, fmt.Errorf("platforms.polymarket.fees: %w", err))
+	}
+	if adaptive := cfg.Platforms.PolyMarket.AdaptiveSync; adaptive.MinInterval != 0 || adaptive.MaxInterval != 0 {
+		if adaptive.MinInterval <= 0 || adaptive.MaxInterval <= 0 {
+			errs = append(errs, errors.New("platforms.polymarket.adaptive_sync intervals must both be positive, or both 0 to disable it"))
+		} else if adaptive.MinInterval > adaptive.MaxInterval {
+			errs = append(errs, errors.New("platforms.polymarket.adaptive_sync.min_interval must not exceed max_interval"))
+		}
+		if adaptive.ChurnThreshold <= 0 {
+			errs = append(errs, errors.New("platforms.polymarket.adaptive_sync.churn_threshold must be positive"))
+		}
 	}
 
 	// Kalshi
 	if cfg.Platforms.Kalshi.APIURL == "" {
-		return fmt.Errorf("platforms.kalshi.api_url is required")
+		errs = append(errs, errors.New("platforms.kalshi.api_url is required"))
 	}
 	if cfg.Platforms.Kalshi.WSURL == "" {
-		return fmt.Errorf("platforms.kalshi.ws_url is required")
+		errs = append(errs, errors.New("platforms.kalshi.ws_url is required"))
 	}
 	if cfg.Platforms.Kalshi.APIKeyID == "" {
-		return fmt.Errorf("platforms.kalshi.api_key_id is required")
+		errs = append(errs, errors.New("platforms.kalshi.api_key_id is required"))
+	}
+	if _, err := cfg.Platforms.Kalshi.Fees.model(); err != nil {
+		errs = append(errs, fmt.Errorf("platforms.kalshi.fees: %w", err))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
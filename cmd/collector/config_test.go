@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v4"
+
+	"github.com/daszybak/prediction_markets/internal/execution"
+)
+
+const validConfig = `
+engine:
+  snapshot_interval: 1s
+  snapshot_depth: 10
+database:
+  host: localhost
+  port: 5432
+  user: collector
+  password: secret
+  database: markets
+  pool_size: 4
+  ssl_mode: disable
+platforms:
+  polymarket:
+    ws:
+      url: wss://example.com/ws
+      market_endpoint: /market
+    gamma_url: https://gamma.example.com
+    clob_url: https://clob.example.com
+  kalshi:
+    api_url: https://kalshi.example.com
+    ws_url: wss://kalshi.example.com
+    api_key_id: key
+`
+
+func parseConfig(t *testing.T, raw string) *config {
+	t.Helper()
+	cfg := &config{}
+	if err := yaml.Unmarshal([]byte(raw), cfg); err != nil {
+		t.Fatalf("couldn't parse config: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateConfigValid(t *testing.T) {
+	if err := validateConfig(parseConfig(t, validConfig)); err != nil {
+		t.Errorf("validateConfig() error = %v, want nil", err)
+	}
+}
+
+func TestValidateConfigReportsAllProblems(t *testing.T) {
+	cfg := parseConfig(t, validConfig)
+	cfg.Database.Host = ""
+	cfg.Database.Password = ""
+	cfg.Platforms.PolyMarket.ClobURL = ""
+	cfg.Platforms.Kalshi.APIKeyID = ""
+	cfg.Engine.SnapshotFormat = "csv"
+
+	err := validateConfig(cfg)
+	if err == nil {
+		t.Fatal("validateConfig() error = nil, want an error")
+	}
+
+	want := []string{
+		"database.host is required",
+		"database.password is required",
+		"platforms.polymarket.clob_url is required",
+		"platforms.kalshi.api_key_id is required",
+		"engine.snapshot_format must be",
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("error %q doesn't report %q", err, w)
+		}
+	}
+	if got := len(strings.Split(err.Error(), "\n")); got != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", got, len(want), err)
+	}
+}
+
+func TestValidateConfigRejectsUnknownHandlers(t *testing.T) {
+	cfg := parseConfig(t, validConfig)
+	cfg.Platforms.PolyMarket.Handlers = []string{"engine", "archive"}
+
+	err := validateConfig(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unknown handler "archive"`) {
+		t.Errorf("validateConfig() error = %v, want it to reject handler archive", err)
+	}
+}
+
+func TestFeesConfig(t *testing.T) {
+	cfg := parseConfig(t, validConfig+`
+    fees:
+      maker_bps: 175
+      taker_bps: 700
+      basis: variance
+      per_contract: "0.01"
+`)
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("validateConfig() error = %v, want nil", err)
+	}
+
+	got, err := cfg.Platforms.Kalshi.Fees.model()
+	if err != nil {
+		t.Fatalf("model() error = %v", err)
+	}
+	want := execution.FeeModel{MakerBPS: 175, TakerBPS: 700, Basis: execution.FeeBasisVariance, PerContract: 10_000}
+	if got != want {
+		t.Errorf("kalshi fees = %+v, want %+v", got, want)
+	}
+	if got, _ := cfg.Platforms.PolyMarket.Fees.model(); got != (execution.FeeModel{Basis: execution.FeeBasisNotional}) {
+		t.Errorf("polymarket fees = %+v, want none", got)
+	}
+
+	cfg.Platforms.PolyMarket.Fees.Basis = "flat"
+	cfg.Platforms.Kalshi.Fees.TakerBPS = -1
+	err = validateConfig(cfg)
+	for _, w := range []string{"platforms.polymarket.fees: unknown fee basis", "platforms.kalshi.fees: fees must not be negative"} {
+		if err == nil || !strings.Contains(err.Error(), w) {
+			t.Errorf("validateConfig() error = %v, want it to report %q", err, w)
+		}
+	}
+}
@@ -2,18 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/metrics"
 	"github.com/daszybak/prediction_markets/internal/platform"
 	"github.com/daszybak/prediction_markets/internal/polymarket"
 	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/pkg/httpclient"
 )
 
+// shutdownTimeout bounds how long platforms get to close their connections.
+const shutdownTimeout = 10 * time.Second
+
+// queueStatsInterval is how often the engine's queue gauges are sampled.
+const queueStatsInterval = 5 * time.Second
+
 type collector struct {
 	platforms map[string]platform.Platform
 	engine    *engine.Client
@@ -67,32 +78,124 @@ func main() {
 	dbLogger.Info("connected to database")
 
 	collector.store = store.NewStore(pool)
+	if replica := cfg.Database.ReadReplica; replica.Host != "" {
+		replicaPool, err := store.NewPool(ctx, store.PoolConfig{
+			Host:     replica.Host,
+			Port:     replica.Port,
+			User:     cfg.Database.User,
+			Password: cfg.Database.Password,
+			Database: cfg.Database.Database,
+			PoolSize: cfg.Database.PoolSize,
+			SSLMode:  cfg.Database.SSLMode,
+		})
+		if err != nil {
+			dbLogger.Error("couldn't connect to read replica", "error", err)
+			os.Exit(1)
+		}
+		defer replicaPool.Close()
+		collector.store.SetReadReplica(replicaPool)
+		dbLogger.Info("connected to read replica", "host", replica.Host)
+	}
+	collector.store.SetOutcomeLabels(cfg.OutcomeLabels)
+
+	if cfg.Metrics.ListenAddr != "" {
+		metricsServer := startMetricsServer(cfg.Metrics.ListenAddr, collector.logger.With("component", "metrics"))
+		defer metricsServer.Close()
+	}
 
 	// Initialize the engine.
-	collector.engine = engine.New(collector.logger)
+	collector.engine = engine.New(engine.Config{
+		UpdateBufferSize: cfg.Engine.UpdateBufferSize,
+		WorkerBufferSize: cfg.Engine.WorkerBufferSize,
+		TradeBufferSize:  cfg.Engine.TradeBufferSize,
+	}, collector.logger)
+	collector.engine.SetCrossedBookGrace(cfg.Engine.CrossedBookGrace.Duration())
 	go collector.engine.Start(ctx)
 	collector.logger.Info("started engine")
+	if maxIdle := cfg.Engine.EvictIdleAfter.Duration(); maxIdle > 0 {
+		go collector.engine.EvictStaleLoop(ctx, maxIdle)
+	}
+	go collector.engine.QueueStatsLoop(ctx, queueStatsInterval)
 
 	// Start the snapshot writer.
 	snapshotWriter := engine.NewSnapshotWriter(
 		collector.engine,
 		collector.store,
-		cfg.Engine.SnapshotInterval.Duration(),
-		cfg.Engine.SnapshotDepth,
+		engine.SnapshotConfig{
+			Interval:   cfg.Engine.SnapshotInterval.Duration(),
+			Depth:      cfg.Engine.SnapshotDepth,
+			Format:     engine.SnapshotFormat(cfg.Engine.SnapshotFormat),
+			Time:       engine.SnapshotTime(cfg.Engine.SnapshotTime),
+			VerifyRate: cfg.Engine.SnapshotVerifyRate,
+			EmptySides: cfg.Engine.SnapshotEmptySides,
+
+			SkipUnchanged: cfg.Engine.SnapshotSkipUnchanged,
+		},
 		collector.logger,
 	)
 	go snapshotWriter.Start(ctx)
 
+	// Start the trade writer.
+	tradeInterval := cfg.Engine.TradeFlushInterval.Duration()
+	if tradeInterval == 0 {
+		tradeInterval = cfg.Engine.SnapshotInterval.Duration()
+	}
+	tradeWriter := engine.NewTradeWriter(collector.engine, collector.store, tradeInterval, collector.logger)
+	go tradeWriter.Start(ctx)
+
 	polymarketLogger := collector.logger.With("component", "polymarket")
+	var tokenFilter polymarket.TokenFilter
+	if path := cfg.Platforms.PolyMarket.TokenAllowlistFile; path != "" {
+		if tokenFilter.Allow, err = polymarket.LoadTokenList(path); err != nil {
+			polymarketLogger.Error("couldn't load token allowlist", "error", err)
+			os.Exit(1)
+		}
+		polymarketLogger.Info("subscribing to allowlisted tokens only", "count", len(tokenFilter.Allow))
+	}
+	if path := cfg.Platforms.PolyMarket.TokenBlocklistFile; path != "" {
+		if tokenFilter.Block, err = polymarket.LoadTokenList(path); err != nil {
+			polymarketLogger.Error("couldn't load token blocklist", "error", err)
+			os.Exit(1)
+		}
+	}
 	collector.platforms["polymarket"] = polymarket.New(polymarket.Config{
 		ClobURL:  cfg.Platforms.PolyMarket.ClobURL,
 		GammaURL: cfg.Platforms.PolyMarket.GammaURL,
+		HTTPTimeouts: httpclient.Timeouts{
+			Dial:           cfg.Platforms.PolyMarket.HTTP.DialTimeout.Duration(),
+			TLSHandshake:   cfg.Platforms.PolyMarket.HTTP.TLSHandshakeTimeout.Duration(),
+			ResponseHeader: cfg.Platforms.PolyMarket.HTTP.ResponseHeaderTimeout.Duration(),
+		},
 		Websocket: polymarket.Websocket{
-			URL:            cfg.Platforms.PolyMarket.WS.WebsocketURL,
-			MarketEndpoint: cfg.Platforms.PolyMarket.WS.MarketEndpoint,
+			URL:              cfg.Platforms.PolyMarket.WS.WebsocketURL,
+			MarketEndpoint:   cfg.Platforms.PolyMarket.WS.MarketEndpoint,
+			SilenceTimeout:   cfg.Platforms.PolyMarket.WS.SilenceTimeout.Duration(),
+			ActivityEndpoint: cfg.Platforms.PolyMarket.WS.ActivityEndpoint,
+
+			SubscribeConfirmTimeout: cfg.Platforms.PolyMarket.WS.SubscribeConfirmTimeout.Duration(),
+			ReconnectBaseDelay:      cfg.Platforms.PolyMarket.WS.ReconnectBaseDelay.Duration(),
+			ReconnectMaxDelay:       cfg.Platforms.PolyMarket.WS.ReconnectMaxDelay.Duration(),
 		},
 		MarketSyncInterval: cfg.Platforms.PolyMarket.MarketSyncInterval.Duration(),
-	}, collector.store, polymarketLogger)
+		AdaptiveSync: polymarket.AdaptiveSyncConfig{
+			MinInterval:    cfg.Platforms.PolyMarket.AdaptiveSync.MinInterval.Duration(),
+			MaxInterval:    cfg.Platforms.PolyMarket.AdaptiveSync.MaxInterval.Duration(),
+			ChurnThreshold: cfg.Platforms.PolyMarket.AdaptiveSync.ChurnThreshold,
+		},
+		MinExpectedMarkets: cfg.Platforms.PolyMarket.MinExpectedMarkets,
+		IncrementalSync:    cfg.Platforms.PolyMarket.IncrementalSync,
+		Tiers: polymarket.TierConfig{
+			FullMinVolume:    cfg.Platforms.PolyMarket.Tiers.FullMinVolume,
+			ReducedMinVolume: cfg.Platforms.PolyMarket.Tiers.ReducedMinVolume,
+			ReducedDepth:     cfg.Platforms.PolyMarket.Tiers.ReducedDepth,
+		},
+		Tokens:               tokenFilter,
+		Handlers:             cfg.Platforms.PolyMarket.Handlers,
+		IdleUnsubscribeAfter: cfg.Platforms.PolyMarket.IdleUnsubscribeAfter.Duration(),
+		MaxMarkets:           cfg.Platforms.PolyMarket.MaxMarkets,
+		LogMessages:          cfg.LogMessages,
+		FailOnNoTokens:       cfg.Platforms.PolyMarket.FailOnNoTokens,
+	}, collector.store, collector.engine, polymarketLogger)
 
 	for platformName, platform := range collector.platforms {
 		err = platform.Start(ctx)
@@ -100,4 +203,33 @@ func main() {
 			collector.logger.Error("starting platform", "platform", platformName, "error", err)
 		}
 	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	for platformName, platform := range collector.platforms {
+		if err := platform.Stop(shutdownCtx); err != nil {
+			collector.logger.Error("stopping platform", "platform", platformName, "error", err)
+		}
+	}
+	collector.engine.Stop()
+	collector.logger.Info("stopped engine")
+}
+
+// startMetricsServer serves Prometheus metrics on /metrics in the background.
+func startMetricsServer(addr string, logger *slog.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("serving metrics", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("couldn't serve metrics", "error", err)
+		}
+	}()
+	return server
 }
@@ -0,0 +1,79 @@
+// Command match pairs Polymarket markets with equivalent Kalshi markets.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/kalshi/api"
+	"github.com/daszybak/prediction_markets/internal/matcher"
+	"github.com/daszybak/prediction_markets/internal/polymarket/clob"
+	"github.com/daszybak/prediction_markets/pkg/httpclient"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print candidate pairs without writing them")
+	minScore := flag.Float64("min-score", 0.5, "minimum similarity score (0-1) of printed pairs")
+	limit := flag.Int("limit", 50, "maximum number of pairs to print, 0 for all")
+	clobURL := flag.String("clob-url", "https://clob.polymarket.com", "Polymarket CLOB API URL")
+	kalshiURL := flag.String("kalshi-url", "https://api.elections.kalshi.com/trade-api/v2", "Kalshi API URL")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, *dryRun, *minScore, *limit, *clobURL, *kalshiURL)
+	stop()
+	if err != nil {
+		slog.Error("couldn't match markets", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dryRun bool, minScore float64, limit int, clobURL, kalshiURL string) error {
+	if !dryRun {
+		return errors.New("writing pairs isn't supported yet, run with --dry-run")
+	}
+
+	polymarkets, err := clob.New(clobURL, httpclient.Timeouts{}).GetAllMarkets(ctx, time.Time{})
+	if err != nil {
+		return fmt.Errorf("couldn't get Polymarket markets: %w", err)
+	}
+	kalshiMarkets, err := api.New(kalshiURL, "").GetAllMarkets(ctx, api.MarketStatusOpen)
+	if err != nil {
+		return fmt.Errorf("couldn't get Kalshi markets: %w", err)
+	}
+
+	a := make([]matcher.Market, 0, len(polymarkets))
+	for _, m := range polymarkets {
+		a = append(a, matcher.Market{ID: m.ConditionID, Platform: "polymarket", Question: m.Question})
+	}
+	b := make([]matcher.Market, 0, len(kalshiMarkets))
+	for _, m := range kalshiMarkets {
+		b = append(b, matcher.Market{ID: m.Ticker, Platform: "kalshi", Question: m.Title})
+	}
+
+	return printReport(os.Stdout, matcher.Match(a, b, minScore), limit)
+}
+
+// printReport writes up to limit candidates, best first, with both questions
+// side by side.
+func printReport(w io.Writer, candidates []matcher.Candidate, limit int) error {
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCORE\tA\tQUESTION A\tB\tQUESTION B")
+	for _, c := range candidates {
+		fmt.Fprintf(tw, "%.3f\t%s\t%s\t%s\t%s\n", c.Score, c.A.ID, c.A.Question, c.B.ID, c.B.Question)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/matcher"
+)
+
+func TestPrintReportOrdering(t *testing.T) {
+	polymarkets := []matcher.Market{
+		{ID: "pm-fed", Question: "Will the Fed cut rates in March 2026?"},
+		{ID: "pm-btc", Question: "Will Bitcoin reach $150k by December 31?"},
+		{ID: "pm-nba", Question: "Will the Lakers win the 2026 NBA Finals?"},
+	}
+	kalshiMarkets := []matcher.Market{
+		{ID: "KX-BTC", Question: "Bitcoin above $150k by December 31?"},
+		{ID: "KX-FED", Question: "Fed cut rates in March 2026?"},
+		{ID: "KX-RAIN", Question: "Rain in NYC tomorrow?"},
+	}
+
+	var out bytes.Buffer
+	if err := printReport(&out, matcher.Match(polymarkets, kalshiMarkets, 0.3), 10); err != nil {
+		t.Fatalf("printReport() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want header and 2 pairs:\n%s", len(lines), out.String())
+	}
+	if !strings.HasPrefix(lines[0], "SCORE") {
+		t.Errorf("first line = %q, want header", lines[0])
+	}
+	for i, want := range [][2]string{{"pm-fed", "KX-FED"}, {"pm-btc", "KX-BTC"}} {
+		line := lines[i+1]
+		if !strings.Contains(line, want[0]) || !strings.Contains(line, want[1]) {
+			t.Errorf("line %d = %q, want pair %s/%s", i+1, line, want[0], want[1])
+		}
+	}
+}
+
+func TestPrintReportLimit(t *testing.T) {
+	candidates := []matcher.Candidate{
+		{A: matcher.Market{ID: "a1"}, B: matcher.Market{ID: "b1"}, Score: 0.9},
+		{A: matcher.Market{ID: "a2"}, B: matcher.Market{ID: "b2"}, Score: 0.8},
+		{A: matcher.Market{ID: "a3"}, B: matcher.Market{ID: "b3"}, Score: 0.7},
+	}
+
+	var out bytes.Buffer
+	if err := printReport(&out, candidates, 2); err != nil {
+		t.Fatalf("printReport() error = %v", err)
+	}
+	if strings.Contains(out.String(), "a3") {
+		t.Errorf("report includes pairs past the limit:\n%s", out.String())
+	}
+}
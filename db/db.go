@@ -0,0 +1,10 @@
+// Package db embeds the SQL migrations so tests can apply them without the
+// migrate image.
+package db
+
+import "embed"
+
+// Migrations holds the up migrations, under migrations/.
+//
+//go:embed migrations/*.up.sql
+var Migrations embed.FS
@@ -0,0 +1,3 @@
+SELECT remove_retention_policy('order_book_documents', if_exists => true);
+SELECT remove_compression_policy('order_book_documents', if_exists => true);
+DROP TABLE IF EXISTS order_book_documents;
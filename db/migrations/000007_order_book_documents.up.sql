@@ -0,0 +1,31 @@
+-- Order book documents (alternative to the per-level order_book_snapshots rows)
+-- One row per token per snapshot interval holding the whole top-N book as JSONB:
+--   {"bids": [{"price": 750000, "size": 1000000, "updated_at": "..."}], "asks": [...]}
+-- Prices/sizes inside the document use scale 10^6 like the other tables.
+CREATE TABLE IF NOT EXISTS order_book_documents (
+    time        TIMESTAMPTZ NOT NULL,
+    token_id    TEXT NOT NULL,
+    book        JSONB NOT NULL,
+    ingested_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Convert to hypertable
+SELECT create_hypertable('order_book_documents', 'time');
+
+-- Indexes
+CREATE INDEX idx_obd_token_time ON order_book_documents(token_id, time DESC);
+
+-- Enable compression after 7 days
+ALTER TABLE order_book_documents SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'token_id',
+    timescaledb.compress_orderby = 'time DESC'
+);
+
+SELECT add_compression_policy('order_book_documents', INTERVAL '7 days');
+
+-- Retention: same as raw snapshots
+SELECT add_retention_policy('order_book_documents', INTERVAL '90 days');
+
+COMMENT ON COLUMN order_book_documents.time IS 'When the snapshot was captured';
+COMMENT ON COLUMN order_book_documents.ingested_at IS 'When data was stored in our DB';
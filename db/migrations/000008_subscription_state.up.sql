@@ -0,0 +1,14 @@
+-- Websocket subscriptions that were active, per platform.
+-- Lets the collector resubscribe to the confirmed-active set after a restart
+-- instead of the whole catalog, and detect gaps from the last seen sequence.
+CREATE TABLE IF NOT EXISTS subscription_state (
+    platform        TEXT NOT NULL,
+    token_id        TEXT NOT NULL REFERENCES tokens(id) ON DELETE CASCADE,
+    last_sequence   BIGINT,             -- last sequence number seen, NULL if the platform has none
+    last_seen_at    TIMESTAMPTZ,        -- when last_sequence was seen
+    subscribed_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (platform, token_id)
+);
+
+COMMENT ON COLUMN subscription_state.last_sequence IS 'Last sequence number seen for the token, NULL if the platform has none';
+COMMENT ON COLUMN subscription_state.subscribed_at IS 'When the token was first saved as subscribed';
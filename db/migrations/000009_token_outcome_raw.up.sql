@@ -0,0 +1,8 @@
+-- Outcome labels are canonicalized on upsert ('Yes', 'y ' -> 'YES').
+-- The label as received from the platform is kept in outcome_raw.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS outcome_raw TEXT;
+
+UPDATE tokens SET outcome_raw = outcome WHERE outcome_raw IS NULL;
+
+COMMENT ON COLUMN tokens.outcome IS 'Canonical outcome label, e.g. YES or NO';
+COMMENT ON COLUMN tokens.outcome_raw IS 'Outcome label as received from the platform';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_markets_needs_enrichment;
+ALTER TABLE markets DROP COLUMN IF EXISTS needs_enrichment;
+ALTER TABLE markets DROP COLUMN IF EXISTS slug;
+ALTER TABLE markets DROP COLUMN IF EXISTS question;
@@ -0,0 +1,9 @@
+-- Details only Gamma has. A sync that couldn't reach Gamma still upserts the
+-- CLOB markets and sets needs_enrichment so a later sync fills them in.
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS question TEXT;
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS slug TEXT;
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS needs_enrichment BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_markets_needs_enrichment ON markets(platform) WHERE needs_enrichment;
+
+COMMENT ON COLUMN markets.needs_enrichment IS 'Set when a sync couldn''t fetch the Gamma details of the market';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tokens_resolved_at;
+ALTER TABLE tokens DROP COLUMN IF EXISTS resolved_at;
@@ -0,0 +1,7 @@
+-- When a token's resolution was first recorded, for settling positions over
+-- a time range.
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_resolved_at ON tokens(resolved_at) WHERE winning;
+
+COMMENT ON COLUMN tokens.resolved_at IS 'When the resolution was first recorded, NULL until resolved';
@@ -0,0 +1,3 @@
+-- Looking markets up by their Gamma slug, the identifier in Polymarket's
+-- website URLs.
+CREATE INDEX IF NOT EXISTS idx_markets_slug ON markets(slug) WHERE slug IS NOT NULL;
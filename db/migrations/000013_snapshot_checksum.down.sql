@@ -0,0 +1 @@
+ALTER TABLE order_book_snapshots DROP COLUMN IF EXISTS checksum;
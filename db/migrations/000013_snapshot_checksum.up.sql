@@ -0,0 +1,7 @@
+-- Checksum of the levels of the snapshot a row was written with, repeated on
+-- every row of that snapshot. The rows of one snapshot share token_id and
+-- ingested_at, so recomputing the checksum over them detects rows that were
+-- lost or changed after the write.
+ALTER TABLE order_book_snapshots ADD COLUMN IF NOT EXISTS checksum BIGINT;
+
+COMMENT ON COLUMN order_book_snapshots.checksum IS 'First 8 bytes of the MD5 of the snapshot levels, NULL for rows written before checksums';
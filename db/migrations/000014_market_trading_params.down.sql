@@ -0,0 +1,4 @@
+ALTER TABLE markets DROP COLUMN IF EXISTS taker_base_fee_bps;
+ALTER TABLE markets DROP COLUMN IF EXISTS maker_base_fee_bps;
+ALTER TABLE markets DROP COLUMN IF EXISTS minimum_order_size;
+ALTER TABLE markets DROP COLUMN IF EXISTS minimum_tick_size;
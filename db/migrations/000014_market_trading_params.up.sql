@@ -0,0 +1,12 @@
+-- Trading parameters of a market as listed by the platform, for snapping
+-- prices to the grid, sizing orders and fee-aware arbitrage. NULL when the
+-- platform doesn't report them.
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS minimum_tick_size BIGINT;
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS minimum_order_size BIGINT;
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS maker_base_fee_bps INTEGER;
+ALTER TABLE markets ADD COLUMN IF NOT EXISTS taker_base_fee_bps INTEGER;
+
+COMMENT ON COLUMN markets.minimum_tick_size IS 'Price increment, scaled by 10^6';
+COMMENT ON COLUMN markets.minimum_order_size IS 'Smallest order in shares, scaled by 10^6';
+COMMENT ON COLUMN markets.maker_base_fee_bps IS 'Maker fee in basis points';
+COMMENT ON COLUMN markets.taker_base_fee_bps IS 'Taker fee in basis points';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_obs_token_level_time;
+DROP INDEX IF EXISTS idx_obs_token_ingested;
@@ -0,0 +1,26 @@
+-- Indexes for the snapshot read paths. Indexes on a hypertable are created on
+-- every chunk, and the time predicates below let TimescaleDB exclude chunks
+-- before the index is used.
+--
+-- Latest snapshot (GetLatestOrderBookSnapshot) is already served by
+-- idx_obs_token_time from 000002:
+--   Index Scan using <chunk>_idx_obs_token_time on <chunk>
+--     Index Cond: (token_id = $1) AND (time = $0)   -- $0: InitPlan MAX(time)
+--
+-- Last ingested snapshot (GetLastIngestedOrderBookSnapshot) filters on
+-- ingested_at, which no index covered, so it scanned every chunk. Expected:
+--   Index Scan using <chunk>_idx_obs_token_ingested on <chunk>
+--     Index Cond: (token_id = $1) AND (ingested_at = $0)
+CREATE INDEX IF NOT EXISTS idx_obs_token_ingested ON order_book_snapshots(token_id, ingested_at DESC);
+
+-- Inside quotes and series (GetInsideQuoteRows) read level 0 of both sides
+-- over a range. Leading with side would need a scan per side, so the level
+-- comes right after the token. Since the query bounds ingested_at instead of
+-- time, 000019 replaces this index with idx_obs_token_level_ingested.
+CREATE INDEX IF NOT EXISTS idx_obs_token_level_time ON order_book_snapshots(token_id, level, time DESC);
+
+-- Trades per token and range (GetTradesByToken, GetTradesRange,
+-- SumTokenTradeSize) are served by idx_trades_token_time from 000004, and the
+-- volume leaderboard's time range by the hypertable's default trades_time_idx.
+-- Per-market sums (SumMarketTradeSize) join tokens first and then use
+-- idx_trades_token_time per token.
@@ -0,0 +1 @@
+COMMENT ON COLUMN order_book_snapshots.level IS NULL;
@@ -0,0 +1,2 @@
+-- Documents the empty side convention of engine.SnapshotConfig.EmptySides.
+COMMENT ON COLUMN order_book_snapshots.level IS 'Level from the best price, 0 is the best. -1 marks an empty side (price and size 0) when empty side rows are enabled';
@@ -0,0 +1,9 @@
+-- How far each token's history has been backfilled, so an interrupted
+-- backfill resumes where it stopped instead of starting over.
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    token_id            TEXT PRIMARY KEY REFERENCES tokens(id) ON DELETE CASCADE,
+    last_backfilled_ts  TIMESTAMPTZ NOT NULL,   -- history before this time is backfilled
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN backfill_progress.last_backfilled_ts IS 'End of the last backfilled window, exclusive. The cursor only moves forward';
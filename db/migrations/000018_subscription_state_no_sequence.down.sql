@@ -0,0 +1,5 @@
+ALTER TABLE subscription_state
+    ADD COLUMN IF NOT EXISTS last_sequence BIGINT,
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN subscription_state.last_sequence IS 'Last sequence number seen for the token, NULL if the platform has none';
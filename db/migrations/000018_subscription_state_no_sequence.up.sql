@@ -0,0 +1,5 @@
+-- Polymarket frames carry no sequence number, so no gap can be detected from
+-- a stored one. The subscription set alone is kept.
+ALTER TABLE subscription_state
+    DROP COLUMN IF EXISTS last_sequence,
+    DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_obs_token_level_time ON order_book_snapshots(token_id, level, time DESC);
+
+DROP INDEX IF EXISTS idx_obs_token_level_ingested;
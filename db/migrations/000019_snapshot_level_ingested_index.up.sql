@@ -0,0 +1,11 @@
+-- Inside quotes (GetInsideQuoteRows) and realized volatility
+-- (GetRealizedVolatilityRow) read level 0 of both sides per snapshot, over a
+-- range of ingested_at rather than time. idx_obs_token_level_time from 000015
+-- only bounds time, so it no longer serves them. ingested_at isn't the
+-- hypertable's time column, so no chunk is excluded and the index is scanned
+-- on every chunk. Expected:
+--   Index Scan using <chunk>_idx_obs_token_level_ingested on <chunk>
+--     Index Cond: (token_id = $1) AND (level = 0) AND (ingested_at >= $2) AND (ingested_at < $3)
+CREATE INDEX IF NOT EXISTS idx_obs_token_level_ingested ON order_book_snapshots(token_id, level, ingested_at DESC);
+
+DROP INDEX IF EXISTS idx_obs_token_level_time;
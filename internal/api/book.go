@@ -0,0 +1,57 @@
+package api
+
+import (
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// Price is a price.Price as it appears in API responses: a decimal string in
+// JSON and a scaled integer in MessagePack.
+type Price price.Price
+
+// MarshalJSON formats the price as a decimal string with trailing zeros
+// trimmed, e.g. "0.55".
+func (p Price) MarshalJSON() ([]byte, error) {
+	return price.Price(p).MarshalJSON()
+}
+
+func (p *Price) UnmarshalJSON(data []byte) error {
+	return (*price.Price)(p).UnmarshalJSON(data)
+}
+
+// Level is a price level of a Book. Size is scaled by price.PriceScale in
+// both formats.
+type Level struct {
+	Price     Price `json:"price"`
+	Size      int64 `json:"size"`
+	UpdatedAt int64 `json:"updated_at"` // Unix milliseconds.
+}
+
+// Book is the order book of a token, best levels first.
+type Book struct {
+	TokenID string  `json:"token_id"`
+	Bids    []Level `json:"bids"`
+	Asks    []Level `json:"asks"`
+}
+
+// NewBook converts an engine snapshot to its API representation.
+func NewBook(snap engine.Snapshot) Book {
+	return Book{
+		TokenID: snap.TokenID,
+		Bids:    levels(snap.Bids),
+		Asks:    levels(snap.Asks),
+	}
+}
+
+func levels(in []orderbook.Level) []Level {
+	out := make([]Level, 0, len(in))
+	for _, l := range in {
+		out = append(out, Level{
+			Price:     Price(l.Price),
+			Size:      int64(l.Size),
+			UpdatedAt: l.UpdatedAt.UnixMilli(),
+		})
+	}
+	return out
+}
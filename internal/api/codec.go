@@ -0,0 +1,89 @@
+// Package api holds what the HTTP and websocket APIs share: response types
+// and their serialization.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec serializes API responses in one format.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	// JSON is the default codec. Prices are decimal strings such as "0.55".
+	JSON Codec = jsonCodec{}
+	// MessagePack encodes prices as integers scaled by price.PriceScale
+	// (10^6), so 0.55 is 550000.
+	MessagePack Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string             { return "application/json" }
+func (jsonCodec) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+func (jsonCodec) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// msgpackTypes are the media types clients use to ask for MessagePack.
+var msgpackTypes = map[string]bool{
+	"application/msgpack":       true,
+	"application/x-msgpack":     true,
+	"application/vnd.msgpack":   true,
+	"application/x-messagepack": true,
+}
+
+// Negotiate picks the codec for an Accept header. The first supported media
+// type wins, quality values are ignored. Anything else gets JSON.
+func Negotiate(accept string) Codec {
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch {
+		case msgpackTypes[mediaType]:
+			return MessagePack
+		case mediaType == "application/json":
+			return JSON
+		}
+	}
+	return JSON
+}
+
+// Write encodes v with the codec negotiated from r's Accept header.
+func Write(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	codec := Negotiate(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if err := codec.Encode(w, v); err != nil {
+		return fmt.Errorf("couldn't encode %s response: %w", codec.ContentType(), err)
+	}
+	return nil
+}
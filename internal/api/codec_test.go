@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func testBook() Book {
+	at := time.UnixMilli(1_767_225_600_000)
+	return NewBook(engine.Snapshot{
+		TokenID: "t1",
+		Bids: []orderbook.Level{
+			{Price: 550_000, Size: 10_000_000, UpdatedAt: at},
+			{Price: 500_000, Size: 2_500_000, UpdatedAt: at},
+		},
+		Asks: []orderbook.Level{
+			{Price: 1_000_000, Size: 1, UpdatedAt: at},
+		},
+	})
+}
+
+func TestCodecsProduceEquivalentBooks(t *testing.T) {
+	want := testBook()
+
+	for _, codec := range []Codec{JSON, MessagePack} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := codec.Encode(&buf, want); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			var got Book
+			if err := codec.Decode(&buf, &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONPricesAreDecimalStrings(t *testing.T) {
+	var buf bytes.Buffer
+	if err := JSON.Encode(&buf, testBook()); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for _, want := range []string{`"price":"0.55"`, `"price":"0.5"`, `"price":"1"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("JSON %s doesn't contain %s", buf.String(), want)
+		}
+	}
+}
+
+func TestMessagePackPricesAreScaledIntegers(t *testing.T) {
+	var buf bytes.Buffer
+	if err := MessagePack.Encode(&buf, testBook()); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var raw map[string]any
+	if err := msgpack.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	bid := raw["bids"].([]any)[0].(map[string]any)
+	if got, ok := bid["price"].(int64); !ok || got != 550_000 {
+		t.Errorf("price = %#v, want int64 550000", bid["price"])
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]Codec{
+		"":                                      JSON,
+		"*/*":                                   JSON,
+		"application/json":                      JSON,
+		"application/msgpack":                   MessagePack,
+		"application/x-msgpack; q=0.9":          MessagePack,
+		"text/html, application/msgpack":        MessagePack,
+		"application/json, application/msgpack": JSON,
+	}
+	for accept, want := range tests {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %s, want %s", accept, got.ContentType(), want.ContentType())
+		}
+	}
+}
+
+func TestWriteSetsContentType(t *testing.T) {
+	r := httptest.NewRequest("GET", "/books/t1", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+
+	if err := Write(w, r, 200, testBook()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/msgpack" {
+		t.Errorf("Content-Type = %q, want application/msgpack", got)
+	}
+}
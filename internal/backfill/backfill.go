@@ -0,0 +1,83 @@
+// Package backfill fetches the history of tokens in resumable windows.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+// Progress stores how far each token is backfilled. *store.Store implements
+// it with the backfill_progress table.
+type Progress interface {
+	// GetBackfillProgress returns pgx.ErrNoRows for tokens without progress.
+	GetBackfillProgress(ctx context.Context, tokenID string) (time.Time, error)
+	UpsertBackfillProgress(ctx context.Context, arg store.UpsertBackfillProgressParams) error
+}
+
+// FetchFunc fetches and stores the token's history in [from, to).
+type FetchFunc func(ctx context.Context, tokenID string, from, to time.Time) error
+
+// Config is the range to backfill.
+type Config struct {
+	From time.Time
+	To   time.Time
+	// Window is the span fetched at once. Progress is saved after each
+	// window, so at most one window is fetched again after an interruption.
+	Window time.Duration
+}
+
+// Run backfills [cfg.From, cfg.To) of every token, one window at a time.
+// A token resumes from its saved progress if that is later than cfg.From,
+// so a restarted run doesn't fetch completed windows again. Run stops at the
+// first error, after the progress up to it was saved.
+func Run(ctx context.Context, progress Progress, tokenIDs []string, cfg Config, fetch FetchFunc) error {
+	if cfg.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	for _, tokenID := range tokenIDs {
+		from, err := resumeFrom(ctx, progress, tokenID, cfg.From)
+		if err != nil {
+			return err
+		}
+		for from.Before(cfg.To) {
+			to := from.Add(cfg.Window)
+			if to.After(cfg.To) {
+				to = cfg.To
+			}
+			if err := fetch(ctx, tokenID, from, to); err != nil {
+				return fmt.Errorf("backfill %s from %v: %w", tokenID, from, err)
+			}
+			if err := progress.UpsertBackfillProgress(ctx, store.UpsertBackfillProgressParams{
+				TokenID:          tokenID,
+				LastBackfilledTs: to,
+			}); err != nil {
+				return fmt.Errorf("save progress of %s: %w", tokenID, err)
+			}
+			from = to
+		}
+	}
+	return nil
+}
+
+// resumeFrom returns where the token's backfill continues: its saved
+// progress, or from if there is none or it is earlier.
+func resumeFrom(ctx context.Context, progress Progress, tokenID string, from time.Time) (time.Time, error) {
+	last, err := progress.GetBackfillProgress(ctx, tokenID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return from, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get progress of %s: %w", tokenID, err)
+	}
+	if last.After(from) {
+		return last, nil
+	}
+	return from, nil
+}
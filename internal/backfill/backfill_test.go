@@ -0,0 +1,114 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+// memProgress is a Progress kept in memory.
+type memProgress map[string]time.Time
+
+func (m memProgress) GetBackfillProgress(_ context.Context, tokenID string) (time.Time, error) {
+	last, ok := m[tokenID]
+	if !ok {
+		return time.Time{}, pgx.ErrNoRows
+	}
+	return last, nil
+}
+
+func (m memProgress) UpsertBackfillProgress(_ context.Context, arg store.UpsertBackfillProgressParams) error {
+	if arg.LastBackfilledTs.After(m[arg.TokenID]) {
+		m[arg.TokenID] = arg.LastBackfilledTs
+	}
+	return nil
+}
+
+type window struct {
+	tokenID string
+	from    time.Time
+}
+
+func TestRunResumesAfterInterruption(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := Config{From: start, To: start.Add(4 * time.Hour), Window: time.Hour}
+	tokens := []string{"a", "b"}
+	progress := memProgress{}
+	errInterrupted := errors.New("interrupted")
+
+	// The first run is interrupted in b's third window.
+	var first []window
+	err := Run(context.Background(), progress, tokens, cfg, func(_ context.Context, tokenID string, from, _ time.Time) error {
+		if tokenID == "b" && from.Equal(start.Add(2*time.Hour)) {
+			return errInterrupted
+		}
+		first = append(first, window{tokenID, from})
+		return nil
+	})
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("first Run() error = %v, want %v", err, errInterrupted)
+	}
+	if len(first) != 6 {
+		t.Fatalf("first run fetched %d windows, want 6", len(first))
+	}
+	if got := progress["b"]; !got.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("b progress = %v, want %v", got, start.Add(2*time.Hour))
+	}
+
+	// The second run only fetches b's last two windows.
+	var second []window
+	if err := Run(context.Background(), progress, tokens, cfg, func(_ context.Context, tokenID string, from, to time.Time) error {
+		if to.Sub(from) != time.Hour {
+			t.Errorf("window [%v, %v) isn't an hour", from, to)
+		}
+		second = append(second, window{tokenID, from})
+		return nil
+	}); err != nil {
+		t.Fatalf("second Run() error = %v", err)
+	}
+	want := []window{{"b", start.Add(2 * time.Hour)}, {"b", start.Add(3 * time.Hour)}}
+	if len(second) != len(want) {
+		t.Fatalf("second run fetched %v, want %v", second, want)
+	}
+	for i := range want {
+		if second[i].tokenID != want[i].tokenID || !second[i].from.Equal(want[i].from) {
+			t.Errorf("window %d = %v, want %v", i, second[i], want[i])
+		}
+	}
+	for _, tokenID := range tokens {
+		if got := progress[tokenID]; !got.Equal(cfg.To) {
+			t.Errorf("%s progress = %v, want %v", tokenID, got, cfg.To)
+		}
+	}
+}
+
+func TestRunClampsLastWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := Config{From: start, To: start.Add(90 * time.Minute), Window: time.Hour}
+
+	var ends []time.Time
+	if err := Run(context.Background(), memProgress{}, []string{"a"}, cfg, func(_ context.Context, _ string, _, to time.Time) error {
+		ends = append(ends, to)
+		return nil
+	}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(ends) != 2 || !ends[1].Equal(cfg.To) {
+		t.Errorf("window ends = %v, want the last one at %v", ends, cfg.To)
+	}
+}
+
+func TestRunRejectsEmptyWindow(t *testing.T) {
+	err := Run(context.Background(), memProgress{}, []string{"a"}, Config{}, func(context.Context, string, time.Time, time.Time) error {
+		t.Fatal("fetch called with an empty window")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Run() with no window succeeded, want an error")
+	}
+}
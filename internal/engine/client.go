@@ -2,38 +2,166 @@
 package engine
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/metrics"
 	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/pkg/ratelog"
 )
 
-const maximumUpdates = 100
+// defaultBufferSize is the default of the Config buffer sizes.
+const defaultBufferSize = 100
 
+// staleLevelAge is how long a level may go without an update before a prune
+// removes it.
+const staleLevelAge = 10 * time.Minute
+
+// maxEvictInterval bounds how often EvictStaleLoop looks for idle books.
+const maxEvictInterval = time.Minute
+
+// removedGrace is how long after RemoveToken the token's updates are dropped
+// unless they are an initial dump. Updates in flight arrive well within it,
+// and removed tokens aren't remembered past it.
+const removedGrace = time.Minute
+
+// dropLogInterval is how often each dropped-update warning is logged while
+// buffers stay full.
+const dropLogInterval = 10 * time.Second
+
+// BookKey identifies an order book. Token IDs are only unique within a
+// platform, so books are keyed on both.
+type BookKey struct {
+	Platform string
+	TokenID  string
+}
+
+func (k BookKey) compare(other BookKey) int {
+	return cmp.Or(
+		strings.Compare(k.Platform, other.Platform),
+		strings.Compare(k.TokenID, other.TokenID),
+	)
+}
+
+// Client maintains an order book per token. Each book has its own worker, so
+// the updates of one token are applied in the order they were sent, including
+// resets and prunes. An update that doesn't fit a full buffer is dropped, and
+// since that is always the one being sent or routed, the updates that are
+// applied keep their order and an older update never overwrites a newer one.
 type Client struct {
-	// tokenid:orderbook_worker
-	orderbookWorkers map[string]*OrderbookWorker
-	mu               sync.RWMutex
-	updates          chan Update
-	logger           *slog.Logger
+	orderbookWorkers map[BookKey]*OrderbookWorker
+	// Max snapshot depth, for tokens captured at less than the requested depth
+	depthLimits map[BookKey]int
+	// Tokens removed by RemoveToken that haven't had an initial dump since,
+	// with when they were removed. See removedGrace.
+	removed map[BookKey]time.Time
+	// Tokens evicted by EvictStale that haven't had an initial dump since.
+	// Their deltas alone would only rebuild the changed levels.
+	evicted    map[BookKey]struct{}
+	mu         sync.RWMutex
+	updates    chan Update
+	dedup      *deduper // Only used by Start, or under inlineMu.
+	logger     *slog.Logger
+	dropLogger *ratelog.Logger
+
+	// workerBuffer is the buffer size of each worker's updates.
+	workerBuffer int
+
+	// dropped and processed count updates, see Stats.
+	dropped   atomic.Uint64
+	processed atomic.Uint64
+
+	// trades are recorded by RecordTrade until TakeTrades takes them, up to
+	// tradeBuffer of them.
+	trades        []Trade
+	tradesMu      sync.Mutex
+	tradeBuffer   int
+	droppedTrades atomic.Uint64
+
+	// snapshotSeqs holds the Orderbook.Seq of each book when
+	// TakeSnapshotsChanged last returned it.
+	snapshotSeqs map[BookKey]uint64
+
+	// crossedGrace is how long a book may stay crossed before it is flagged.
+	crossedGrace time.Duration
+
+	// workers tracks the worker goroutines started by Start.
+	workers sync.WaitGroup
+	// cancel stops Start, done is closed once Start returned. Set by Start
+	// under mu.
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// inline makes Send apply updates itself, see NewInline. inlineMu
+	// serializes those Sends.
+	inline   bool
+	inlineMu sync.Mutex
 }
 
 type OrderbookWorker struct {
-	ob *orderbook.Orderbook
+	// ob is written by the worker under mu and read by snapshots under
+	// mu.RLock.
+	ob      *orderbook.Orderbook
+	mu      sync.RWMutex
 	updates chan Update
-	logger *slog.Logger
+	logger  *slog.Logger
+	cancel  context.CancelFunc // Stops the worker, set by Start.
+
+	processed *atomic.Uint64 // Client.processed.
+
+	// resyncing is set while the book waits for a fresh dump, see
+	// ResyncToken.
+	resyncing atomic.Bool
+
+	// lastUpdate is when an update was last routed to the worker, in Unix
+	// nanoseconds. See EvictStale.
+	lastUpdate atomic.Int64
+
+	crossedGrace   time.Duration
+	crossedSince   time.Time // Zero while the book isn't crossed.
+	crossedFlagged bool      // Whether the current cross was reported.
+}
+
+// Config configures a Client.
+type Config struct {
+	// UpdateBufferSize is how many updates Send buffers before it drops
+	// them. Defaults to 100.
+	UpdateBufferSize int
+	// WorkerBufferSize is how many updates the worker of each book buffers
+	// before they are dropped. Defaults to 100.
+	WorkerBufferSize int
+	// TradeBufferSize is how many trades RecordTrade buffers until they are
+	// taken before it drops them. Defaults to 10000.
+	TradeBufferSize int
 }
 
 type Update struct {
 	Price     price.Price
 	Size      price.Size
+	Platform  string
 	TokenID   string
 	Side      string
 	EventTime time.Time // Timestamp from source API (zero = use current time)
 	IsDelta   bool      // true = delta update, false = absolute set
+	Reset     bool      // true = clear the whole book, other fields except Platform and TokenID are ignored
+	Prune     bool      // true = remove stale levels, other fields except Platform and TokenID are ignored
+	Resync    bool      // true = skip the book in snapshots until Resynced, other fields except Platform and TokenID are ignored
+	Resynced  bool      // true = end Resync, other fields except Platform and TokenID are ignored
+	Dump      bool      // true = level is part of an initial dump (full book snapshot)
+	ID        string    // Source identifier of the update (hash or sequence number), used to drop redeliveries
+}
+
+// Key returns the key of the book the update applies to.
+func (u Update) Key() BookKey {
+	return BookKey{Platform: u.Platform, TokenID: u.TokenID}
 }
 
 type Level struct {
@@ -41,25 +169,112 @@ type Level struct {
 	size  int64
 }
 
-func New(l *slog.Logger) *Client {
+func New(cfg Config, l *slog.Logger) *Client {
+	if cfg.UpdateBufferSize <= 0 {
+		cfg.UpdateBufferSize = defaultBufferSize
+	}
+	if cfg.WorkerBufferSize <= 0 {
+		cfg.WorkerBufferSize = defaultBufferSize
+	}
+	if cfg.TradeBufferSize <= 0 {
+		cfg.TradeBufferSize = defaultTradeBufferSize
+	}
+
+	logger := l.With("component", "engine")
 	return &Client{
-		logger:           l.With("component", "engine"),
-		orderbookWorkers: make(map[string]*OrderbookWorker),
-		updates:          make(chan Update, maximumUpdates),
+		logger:           logger,
+		dropLogger:       ratelog.New(logger, dropLogInterval),
+		orderbookWorkers: make(map[BookKey]*OrderbookWorker),
+		depthLimits:      make(map[BookKey]int),
+		snapshotSeqs:     make(map[BookKey]uint64),
+		removed:          make(map[BookKey]time.Time),
+		evicted:          make(map[BookKey]struct{}),
+		updates:          make(chan Update, cfg.UpdateBufferSize),
+		workerBuffer:     cfg.WorkerBufferSize,
+		tradeBuffer:      cfg.TradeBufferSize,
+		dedup:            newDeduper(dedupWindow),
+		done:             make(chan struct{}),
 	}
 }
 
-// Send queues an update for processing. Returns false if the buffer is full.
+// NewInline returns a client whose Send applies each update to its book
+// before returning, without buffers or worker goroutines, so a snapshot taken
+// right after Send reflects it. It is meant for tests and single-threaded
+// replay. Start isn't needed, and concurrent Sends are serialized.
+func NewInline(l *slog.Logger) *Client {
+	c := New(Config{}, l)
+	c.inline = true
+	return c
+}
+
+// Send queues an update for processing. Returns false if the buffer is full,
+// in which case u is dropped and updates already queued are unaffected.
+// Updates of a token sent from one goroutine are applied in Send order.
+// An inline client applies the update before returning instead.
 func (c *Client) Send(u Update) bool {
+	if c.inline {
+		c.applyInline(u)
+		return true
+	}
+
 	select {
 	case c.updates <- u:
 		return true
 	default:
-		c.logger.Warn("engine buffer full, dropping update", "token", u.TokenID)
+		c.drop()
+		c.dropLogger.Warn("engine buffer full, dropping update", "platform", u.Platform, "token", u.TokenID)
 		return false
 	}
 }
 
+// PruneToken queues the removal of the token's levels that haven't been
+// updated for staleLevelAge. Like ResetToken, it is ordered with respect to
+// regular updates.
+func (c *Client) PruneToken(platform, tokenID string) bool {
+	return c.Send(Update{Platform: platform, TokenID: tokenID, Prune: true})
+}
+
+// ResetToken queues a reset of the token's order book, e.g. to resync a single
+// token that drifted. The reset goes through the same channels as regular
+// updates, so it is ordered with respect to them. Other books aren't touched
+// and it is a no-op for tokens the engine doesn't track.
+func (c *Client) ResetToken(platform, tokenID string) bool {
+	return c.Send(Update{Platform: platform, TokenID: tokenID, Reset: true})
+}
+
+// ResyncToken queues marking the token's book as resyncing, e.g. while its
+// connection is re-established. TakeSnapshots and TakeSnapshotsChanged skip
+// the book and Snapshot flags it until FinishResync, so a stale book isn't
+// written in the meantime. Like ResetToken, it is ordered with respect to
+// regular updates and a no-op for tokens the engine doesn't track.
+func (c *Client) ResyncToken(platform, tokenID string) bool {
+	return c.Send(Update{Platform: platform, TokenID: tokenID, Resync: true})
+}
+
+// FinishResync queues the end of ResyncToken, once the book was replaced by
+// a fresh dump. It is a no-op for books that aren't resyncing.
+func (c *Client) FinishResync(platform, tokenID string) bool {
+	return c.Send(Update{Platform: platform, TokenID: tokenID, Resynced: true})
+}
+
+// applyInline routes and applies an update on the calling goroutine, see
+// NewInline.
+func (c *Client) applyInline(u Update) {
+	c.inlineMu.Lock()
+	defer c.inlineMu.Unlock()
+
+	if c.dedup.duplicate(u, time.Now()) {
+		metrics.EngineDuplicateUpdates.Inc()
+		return
+	}
+	worker, ok := c.worker(context.Background(), u)
+	if !ok {
+		return
+	}
+	worker.lastUpdate.Store(time.Now().UnixNano())
+	worker.process(u)
+}
+
 func (obw *OrderbookWorker) start(ctx context.Context) {
 	for {
 		select {
@@ -67,79 +282,401 @@ func (obw *OrderbookWorker) start(ctx context.Context) {
 			obw.logger.Info("context stopped engine", "error", ctx.Err())
 			return
 		case update := <-obw.updates:
-			// Use event time from source, fall back to now if not provided.
-			eventTime := update.EventTime
-			if eventTime.IsZero() {
-				eventTime = time.Now()
-			}
+			obw.process(update)
+		}
+	}
+}
 
-			if update.IsDelta {
-				obw.ob.Update(update.Price, update.Size, update.Side, eventTime)
-			} else {
-				obw.ob.Set(update.Price, update.Size, update.Side, eventTime)
-			}
+// process applies an update and checks whether it crossed the book.
+func (obw *OrderbookWorker) process(update Update) {
+	// Use event time from source, fall back to now if not provided.
+	eventTime := update.EventTime
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+
+	obw.mu.Lock()
+	obw.apply(update, eventTime)
+	obw.mu.Unlock()
+	// Only the worker writes the book, so it reads it without the lock.
+	obw.checkCrossed(time.Now())
+	obw.processed.Add(1)
+}
+
+// EngineStats counts the updates and trades a Client handled.
+type EngineStats struct {
+	// DroppedUpdates were dropped because the engine's buffer or the
+	// buffer of the book's worker was full.
+	DroppedUpdates uint64
+	// ProcessedUpdates were applied to a book, including resets and prunes.
+	ProcessedUpdates uint64
+	// DroppedTrades were dropped by RecordTrade because the trade buffer
+	// was full.
+	DroppedTrades uint64
+}
+
+// Stats returns the counts since the client was created. It is safe to
+// call concurrently with updates.
+func (c *Client) Stats() EngineStats {
+	return EngineStats{
+		DroppedUpdates:   c.dropped.Load(),
+		ProcessedUpdates: c.processed.Load(),
+		DroppedTrades:    c.droppedTrades.Load(),
+	}
+}
+
+// drop counts an update dropped because a buffer was full.
+func (c *Client) drop() {
+	c.dropped.Add(1)
+	metrics.EngineDroppedUpdates.Inc()
+}
+
+func (obw *OrderbookWorker) apply(update Update, eventTime time.Time) {
+	if update.Reset {
+		obw.ob.Reset()
+		return
+	}
+	if update.Prune {
+		if n := obw.ob.PruneStale(time.Now().Add(-staleLevelAge)); n > 0 {
+			obw.logger.Debug("pruned stale levels", "count", n)
 		}
+		return
+	}
+	if update.Resync || update.Resynced {
+		obw.resyncing.Store(update.Resync)
+		return
+	}
+
+	// An initial dump received after a long gap can carry old source times.
+	// The book it describes is current, so don't let a prune remove it.
+	if update.Dump {
+		eventTime = clampDumpTime(eventTime, time.Now())
+	}
+
+	var err error
+	if update.IsDelta {
+		err = obw.ob.Update(update.Price, update.Size, update.Side, eventTime)
+	} else {
+		err = obw.ob.Set(update.Price, update.Size, update.Side, eventTime)
+	}
+	if err != nil {
+		obw.logger.Error("couldn't apply update", "side", update.Side, "error", err)
 	}
 }
 
+// clampDumpTime returns now if eventTime is older than the prune window.
+func clampDumpTime(eventTime, now time.Time) time.Time {
+	if now.Sub(eventTime) >= staleLevelAge {
+		return now
+	}
+	return eventTime
+}
+
+// Start routes updates to per-token workers until ctx is cancelled or Stop
+// is called. It returns once every worker has exited. Start must only be
+// called once.
 func (c *Client) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+	defer close(c.done)
+	defer c.workers.Wait()
+	defer cancel()
+
 	for {
 		select {
 		case <-ctx.Done():
 			c.logger.Info("context stopped engine", "error", ctx.Err())
 			return
 		case update := <-c.updates:
-			c.mu.RLock()
-			worker, ok := c.orderbookWorkers[update.TokenID]
-			c.mu.RUnlock()
+			if c.dedup.duplicate(update, time.Now()) {
+				metrics.EngineDuplicateUpdates.Inc()
+				c.logger.Debug("dropping duplicate update", "platform", update.Platform, "token", update.TokenID, "id", update.ID)
+				continue
+			}
 
+			worker, ok := c.worker(ctx, update)
 			if !ok {
-				c.mu.Lock()
-				// Double-check after acquiring write lock.
-				worker, ok = c.orderbookWorkers[update.TokenID]
-				if !ok {
-					worker = &OrderbookWorker{
-						ob:      orderbook.New(),
-						updates: make(chan Update, maximumUpdates),
-						logger:  c.logger.With("tokenID", update.TokenID),
-					}
-					c.orderbookWorkers[update.TokenID] = worker
-					go worker.start(ctx)
-				}
-				c.mu.Unlock()
+				continue
 			}
 
+			// Drop the update rather than block or displace queued ones, so
+			// the worker still applies what it gets in order.
 			select {
 			case worker.updates <- update:
-				// Sent.
+				worker.lastUpdate.Store(time.Now().UnixNano())
 			default:
-				c.logger.Warn("worker buffer full", "token", update.TokenID)
+				c.drop()
+				c.dropLogger.Warn("worker buffer full", "platform", update.Platform, "token", update.TokenID)
 			}
 		}
 	}
 }
 
+// worker returns the worker of the update's book, starting one if the update
+// may create the book. It returns false if the update should be dropped.
+// Workers of an inline client get no goroutine.
+func (c *Client) worker(ctx context.Context, update Update) (*OrderbookWorker, bool) {
+	key := update.Key()
+	c.mu.RLock()
+	worker, ok := c.orderbookWorkers[key]
+	c.mu.RUnlock()
+	if ok {
+		return worker, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Double-check after acquiring write lock.
+	if worker, ok := c.orderbookWorkers[key]; ok {
+		return worker, true
+	}
+	if update.Reset || update.Prune || update.Resync || update.Resynced {
+		// There is no book to clear, don't start one.
+		return nil, false
+	}
+	if removedAt, ok := c.removed[key]; ok && !update.Dump && time.Since(removedAt) < removedGrace {
+		c.logger.Debug("dropping update for removed token", "platform", update.Platform, "token", update.TokenID)
+		return nil, false
+	}
+	if _, ok := c.evicted[key]; ok && !update.Dump {
+		c.logger.Debug("dropping update for evicted token", "platform", update.Platform, "token", update.TokenID)
+		return nil, false
+	}
+
+	delete(c.removed, key)
+	delete(c.evicted, key)
+	worker = &OrderbookWorker{
+		ob:      orderbook.New(),
+		updates: make(chan Update, c.workerBuffer),
+		logger:  c.logger.With("platform", update.Platform, "tokenID", update.TokenID),
+
+		processed:    &c.processed,
+		crossedGrace: c.crossedGrace,
+	}
+	worker.lastUpdate.Store(time.Now().UnixNano())
+	workerCtx, cancel := context.WithCancel(ctx)
+	worker.cancel = cancel
+	c.orderbookWorkers[key] = worker
+	if !c.inline {
+		c.workers.Go(func() { worker.start(workerCtx) })
+	}
+	return worker, true
+}
+
+// Stop stops Start and blocks until it and every worker have exited. It
+// returns immediately if Start wasn't called.
+func (c *Client) Stop() {
+	c.mu.RLock()
+	cancel := c.cancel
+	c.mu.RUnlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-c.done
+}
+
 // Snapshot captures the current state of an orderbook for a token.
 type Snapshot struct {
-	TokenID string
-	Bids    []orderbook.Level
-	Asks    []orderbook.Level
+	Platform string
+	TokenID  string
+	Bids     []orderbook.Level
+	Asks     []orderbook.Level
+	Seq      uint64 // Orderbook.Seq of the book when the snapshot was taken.
+	// Resyncing is set if the book may be stale, see ResyncToken.
+	Resyncing bool
+}
+
+// Snapshot returns the top N levels of a single token's orderbook, or false if
+// the engine doesn't track the token.
+func (c *Client) Snapshot(platform, tokenID string, depth int) (Snapshot, bool) {
+	c.mu.RLock()
+	worker, ok := c.orderbookWorkers[BookKey{Platform: platform, TokenID: tokenID}]
+	c.mu.RUnlock()
+	if !ok {
+		return Snapshot{}, false
+	}
+
+	worker.mu.RLock()
+	defer worker.mu.RUnlock()
+	bids, _ := worker.ob.GetTopN("bids", depth)
+	asks, _ := worker.ob.GetTopN("asks", depth)
+	return Snapshot{
+		Platform:  platform,
+		TokenID:   tokenID,
+		Bids:      bids,
+		Asks:      asks,
+		Seq:       worker.ob.Seq(),
+		Resyncing: worker.resyncing.Load(),
+	}, true
+}
+
+// TrackedTokens returns the books the engine has, sorted by platform and
+// token ID.
+func (c *Client) TrackedTokens() []BookKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return slices.SortedFunc(maps.Keys(c.orderbookWorkers), BookKey.compare)
+}
+
+// RemoveToken stops the token's worker and drops its order book and depth
+// limit. Updates still in flight for the token, e.g. sent before the
+// platform processed an unsubscribe, are dropped rather than starting a
+// partial book. The token gets a worker again with its next initial dump
+// (Update.Dump), or with any update once removedGrace passed.
+func (c *Client) RemoveToken(platform, tokenID string) {
+	key := BookKey{Platform: platform, TokenID: tokenID}
+	c.mu.Lock()
+	worker, ok := c.orderbookWorkers[key]
+	delete(c.orderbookWorkers, key)
+	delete(c.depthLimits, key)
+	delete(c.snapshotSeqs, key)
+	delete(c.evicted, key)
+	now := time.Now()
+	for removedKey, removedAt := range c.removed {
+		if now.Sub(removedAt) >= removedGrace {
+			delete(c.removed, removedKey)
+		}
+	}
+	c.removed[key] = now
+	c.mu.Unlock()
+
+	if ok {
+		worker.cancel()
+	}
+}
+
+// EvictStale removes the books that got no update for maxIdle, e.g. those of
+// resolved markets, and stops their workers. The token usually stays
+// subscribed, but its updates are dropped until its next initial dump
+// (Update.Dump) starts a complete book. Since the engine no longer tracks it,
+// the next market sync asks for that dump. It returns the number of books
+// evicted. A maxIdle <= 0 evicts nothing.
+func (c *Client) EvictStale(maxIdle time.Duration) int {
+	if maxIdle <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-maxIdle).UnixNano()
+
+	var evicted []*OrderbookWorker
+	c.mu.Lock()
+	for key, worker := range c.orderbookWorkers {
+		if worker.lastUpdate.Load() > cutoff {
+			continue
+		}
+		delete(c.orderbookWorkers, key)
+		delete(c.depthLimits, key)
+		delete(c.snapshotSeqs, key)
+		c.evicted[key] = struct{}{}
+		evicted = append(evicted, worker)
+	}
+	c.mu.Unlock()
+
+	for _, worker := range evicted {
+		worker.cancel()
+	}
+	if len(evicted) > 0 {
+		c.logger.Debug("evicted idle books", "count", len(evicted), "max_idle", maxIdle)
+	}
+	return len(evicted)
+}
+
+// EvictStaleLoop calls EvictStale with maxIdle periodically until ctx is
+// cancelled. maxIdle must be positive.
+func (c *Client) EvictStaleLoop(ctx context.Context, maxIdle time.Duration) {
+	ticker := time.NewTicker(min(maxEvictInterval, maxIdle))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.EvictStale(maxIdle)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SetDepthLimit caps the depth TakeSnapshots captures for a token.
+// A depth <= 0 removes the cap.
+func (c *Client) SetDepthLimit(platform, tokenID string, depth int) {
+	key := BookKey{Platform: platform, TokenID: tokenID}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if depth <= 0 {
+		delete(c.depthLimits, key)
+		return
+	}
+	c.depthLimits[key] = depth
+}
+
+// SetCrossedBookGrace sets how long a book may stay crossed before the engine
+// logs it and counts it in metrics.EngineCrossedBooks. It applies to workers
+// started afterwards, so call it before Start.
+func (c *Client) SetCrossedBookGrace(grace time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.crossedGrace = grace
 }
 
 // TakeSnapshots returns a snapshot of the top N levels for all active orderbooks.
-// This is safe to call concurrently with updates.
+// Tokens with a depth limit are captured at the lower of N and their limit,
+// resyncing books are skipped. This is safe to call concurrently with updates.
 func (c *Client) TakeSnapshots(depth int) []Snapshot {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	return c.takeSnapshots(depth, false)
+}
+
+// TakeSnapshotsChanged is like TakeSnapshots, but only returns the books that
+// changed (see Orderbook.Seq) since the previous TakeSnapshotsChanged call
+// returned them. Every book is returned by the first call.
+func (c *Client) TakeSnapshotsChanged(depth int) []Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.takeSnapshots(depth, true)
+}
+
+// takeSnapshots implements TakeSnapshots and TakeSnapshotsChanged. c.mu must
+// be held, for writing if changedOnly.
+func (c *Client) takeSnapshots(depth int, changedOnly bool) []Snapshot {
 	snapshots := make([]Snapshot, 0, len(c.orderbookWorkers))
-	for tokenID, worker := range c.orderbookWorkers {
-		bids, _ := worker.ob.GetTopN("bids", depth)
-		asks, _ := worker.ob.GetTopN("asks", depth)
+	for key, worker := range c.orderbookWorkers {
+		if worker.resyncing.Load() {
+			continue
+		}
+		tokenDepth := depth
+		if limit, ok := c.depthLimits[key]; ok {
+			tokenDepth = min(tokenDepth, limit)
+		}
+
+		worker.mu.RLock()
+		seq := worker.ob.Seq()
+		if changedOnly {
+			if seen, ok := c.snapshotSeqs[key]; ok && seen == seq {
+				worker.mu.RUnlock()
+				continue
+			}
+			c.snapshotSeqs[key] = seq
+		}
+		bids, _ := worker.ob.GetTopN("bids", tokenDepth)
+		asks, _ := worker.ob.GetTopN("asks", tokenDepth)
+		worker.mu.RUnlock()
+
 		snapshots = append(snapshots, Snapshot{
-			TokenID: tokenID,
-			Bids:    bids,
-			Asks:    asks,
+			Platform: key.Platform,
+			TokenID:  key.TokenID,
+			Bids:     bids,
+			Asks:     asks,
+			Seq:      seq,
 		})
 	}
 	return snapshots
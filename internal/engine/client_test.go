@@ -0,0 +1,787 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOrderbookWorkerLogsInvalidSide(t *testing.T) {
+	var buf bytes.Buffer
+	obw := &OrderbookWorker{
+		ob:     orderbook.New(),
+		logger: slog.New(slog.NewTextHandler(&buf, nil)),
+	}
+
+	obw.apply(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bid"}, time.Now())
+
+	out := buf.String()
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "invalid side") {
+		t.Errorf("expected error log for invalid side, got %q", out)
+	}
+	if obw.ob.Len("bids") != 0 {
+		t.Errorf("invalid update must not be applied")
+	}
+}
+
+func TestOldInitialDumpSurvivesPrune(t *testing.T) {
+	obw := &OrderbookWorker{
+		ob:     orderbook.New(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	old := time.Now().Add(-2 * staleLevelAge)
+
+	obw.apply(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids", Dump: true}, old)
+	obw.apply(Update{TokenID: "t1", Price: 510_000, Size: 10, Side: "asks", Dump: true}, old)
+	// A regular update with the same old time is stale and gets pruned.
+	obw.apply(Update{TokenID: "t1", Price: 490_000, Size: 10, Side: "bids"}, old)
+
+	obw.apply(Update{TokenID: "t1", Prune: true}, time.Now())
+
+	bids, _ := obw.ob.GetTopN("bids", 10)
+	if len(bids) != 1 || bids[0].Price != 500_000 {
+		t.Errorf("bids after prune = %v, want only the dumped 500000 level", bids)
+	}
+	if obw.ob.Len("asks") != 1 {
+		t.Errorf("asks after prune = %d levels, want 1", obw.ob.Len("asks"))
+	}
+}
+
+func TestClampDumpTime(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+
+	if got := clampDumpTime(recent, now); !got.Equal(recent) {
+		t.Errorf("clampDumpTime(recent) = %v, want %v", got, recent)
+	}
+	if got := clampDumpTime(now.Add(-staleLevelAge), now); !got.Equal(now) {
+		t.Errorf("clampDumpTime(old) = %v, want now", got)
+	}
+}
+
+// waitForLevel polls until the token's book has a level at p on side. The
+// token is looked up without a platform.
+func waitForLevel(t *testing.T, c *Client, tokenID, side string, p int64) orderbook.Level {
+	t.Helper()
+	return waitForBookLevel(t, c, BookKey{TokenID: tokenID}, side, p)
+}
+
+// waitForBookLevel polls until the book has a level at p on side.
+func waitForBookLevel(t *testing.T, c *Client, key BookKey, side string, p int64) orderbook.Level {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if snap, ok := c.Snapshot(key.Platform, key.TokenID, 10); ok {
+			levels := snap.Bids
+			if side == "asks" {
+				levels = snap.Asks
+			}
+			for _, l := range levels {
+				if int64(l.Price) == p {
+					return l
+				}
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("level %d on %s of %v never appeared", p, side, key)
+	return orderbook.Level{}
+}
+
+func TestInlineSendAppliesImmediately(t *testing.T) {
+	c := NewInline(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if !c.Send(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids"}) {
+		t.Fatal("Send() = false, want true")
+	}
+	snaps := c.TakeSnapshots(10)
+	if len(snaps) != 1 || len(snaps[0].Bids) != 1 || snaps[0].Bids[0].Size != 10 {
+		t.Fatalf("snapshots after Send = %+v, want one book with a 10 bid", snaps)
+	}
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 5, Side: "bids", IsDelta: true})
+	c.Send(Update{TokenID: "t1", Price: 600_000, Size: 1, Side: "asks"})
+	snap, ok := c.Snapshot("", "t1", 10)
+	if !ok || len(snap.Bids) != 1 || snap.Bids[0].Size != 15 || len(snap.Asks) != 1 {
+		t.Errorf("Snapshot() = %+v, %v, want a 15 bid and one ask", snap, ok)
+	}
+
+	c.ResetToken("", "t1")
+	if snap, _ := c.Snapshot("", "t1", 10); len(snap.Bids) != 0 || len(snap.Asks) != 0 {
+		t.Errorf("Snapshot() after reset = %+v, want an empty book", snap)
+	}
+
+	c.RemoveToken("", "t1")
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids"})
+	if _, ok := c.Snapshot("", "t1", 10); ok {
+		t.Error("update for a removed token started a book")
+	}
+}
+
+func TestSnapshotSeq(t *testing.T) {
+	c := NewInline(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids"})
+	first, _ := c.Snapshot("", "t1", 10)
+	again, _ := c.Snapshot("", "t1", 10)
+	if first.Seq == 0 || again.Seq != first.Seq {
+		t.Errorf("Seq of repeated snapshots = %d, %d, want the same non-zero value", first.Seq, again.Seq)
+	}
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 5, Side: "bids", IsDelta: true})
+	snaps := c.TakeSnapshots(10)
+	if len(snaps) != 1 || snaps[0].Seq <= first.Seq {
+		t.Errorf("TakeSnapshots() = %+v, want a Seq above %d", snaps, first.Seq)
+	}
+}
+
+func TestTakeSnapshotsChanged(t *testing.T) {
+	c := NewInline(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids"})
+	c.Send(Update{TokenID: "t2", Price: 500_000, Size: 10, Side: "bids"})
+
+	if got := c.TakeSnapshotsChanged(10); len(got) != 2 {
+		t.Fatalf("first TakeSnapshotsChanged() = %d books, want 2", len(got))
+	}
+	if got := c.TakeSnapshotsChanged(10); len(got) != 0 {
+		t.Errorf("TakeSnapshotsChanged() without updates = %+v, want no books", got)
+	}
+	// TakeSnapshots still returns every book and doesn't affect the above.
+	if got := c.TakeSnapshots(10); len(got) != 2 {
+		t.Errorf("TakeSnapshots() = %d books, want 2", len(got))
+	}
+
+	c.Send(Update{TokenID: "t2", Price: 500_000, Size: 5, Side: "bids", IsDelta: true})
+	got := c.TakeSnapshotsChanged(10)
+	if len(got) != 1 || got[0].TokenID != "t2" || got[0].Bids[0].Size != 15 {
+		t.Errorf("TakeSnapshotsChanged() after updating t2 = %+v, want only t2", got)
+	}
+
+	// A book started again after RemoveToken counts as changed.
+	c.RemoveToken("", "t1")
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids", Dump: true})
+	got = c.TakeSnapshotsChanged(10)
+	if len(got) != 1 || got[0].TokenID != "t1" {
+		t.Errorf("TakeSnapshotsChanged() after restarting t1 = %+v, want only t1", got)
+	}
+}
+
+func TestDuplicateDeltaAppliedOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	before := testutil.ToFloat64(metrics.EngineDuplicateUpdates)
+	eventTime := time.Unix(1_700_000_000, 0)
+	delta := Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "bids", EventTime: eventTime, IsDelta: true, ID: "0xabc"}
+	c.Send(delta)
+	c.Send(delta)
+	// Updates for a token are applied in order, so once the marker shows up
+	// both deltas have been handled.
+	c.Send(Update{TokenID: "t1", Price: 600_000, Size: 1, Side: "asks", EventTime: eventTime})
+	waitForLevel(t, c, "t1", "asks", 600_000)
+
+	if got := waitForLevel(t, c, "t1", "bids", 500_000).Size; got != 10 {
+		t.Errorf("size = %d, want 10", got)
+	}
+	if got := testutil.ToFloat64(metrics.EngineDuplicateUpdates) - before; got != 1 {
+		t.Errorf("duplicates counted = %v, want 1", got)
+	}
+}
+
+func TestUpdatesAppliedInSendOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	// Absolute sets of one level: only the last one may win. The buffers
+	// hold all of them, so none is dropped.
+	const n = defaultBufferSize / 2
+	for i := range n {
+		if !c.Send(Update{TokenID: "t1", Price: 500_000, Size: price.Size(i + 1), Side: "bids"}) {
+			t.Fatalf("update %d dropped", i)
+		}
+	}
+	c.Send(Update{TokenID: "t1", Price: 600_000, Size: 1, Side: "asks"})
+	waitForLevel(t, c, "t1", "asks", 600_000)
+
+	if got := waitForLevel(t, c, "t1", "bids", 500_000).Size; got != n {
+		t.Errorf("size = %d, want the last sent %d", got, n)
+	}
+}
+
+func TestUpdatesAppliedInOrderUnderLoad(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	const (
+		tokens  = 8
+		updates = 5000
+		final   = updates + 1
+	)
+	// size reads the token's bid size, 0 before the book exists.
+	size := func(tokenID string) price.Size {
+		snap, ok := c.Snapshot("", tokenID, 1)
+		if !ok || len(snap.Bids) == 0 {
+			return 0
+		}
+		return snap.Bids[0].Size
+	}
+
+	var wg sync.WaitGroup
+	for tok := range tokens {
+		tokenID := fmt.Sprintf("t%d", tok)
+		// Sizes grow with every update, so the applied size must never go
+		// down, however many updates get dropped on the way. The final
+		// update is resent until it is applied, so the reader can stop.
+		wg.Go(func() {
+			for i := range updates {
+				c.Send(Update{TokenID: tokenID, Price: 500_000, Size: price.Size(i + 1), Side: "bids"})
+			}
+			for ctx.Err() == nil && size(tokenID) != final {
+				c.Send(Update{TokenID: tokenID, Price: 500_000, Size: final, Side: "bids"})
+				time.Sleep(time.Millisecond)
+			}
+		})
+		wg.Go(func() {
+			var last price.Size
+			for ctx.Err() == nil && last != final {
+				got := size(tokenID)
+				if got < last {
+					t.Errorf("%s: size went from %d back to %d", tokenID, last, got)
+					return
+				}
+				last = got
+				runtime.Gosched()
+			}
+		})
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		t.Fatal("final updates weren't applied in time")
+	}
+}
+
+func TestStatsCountsDroppedUpdates(t *testing.T) {
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	before := testutil.ToFloat64(metrics.EngineDroppedUpdates)
+
+	// Nothing drains the buffer before Start, so everything beyond it is
+	// dropped.
+	const sent = defaultBufferSize + 50
+	for i := range sent {
+		c.Send(Update{TokenID: "t1", Price: 500_000, Size: price.Size(i + 1), Side: "bids"})
+	}
+	if got := c.Stats(); got.DroppedUpdates != sent-defaultBufferSize || got.ProcessedUpdates != 0 {
+		t.Errorf("Stats() = %+v, want %d dropped and none processed", got, sent-defaultBufferSize)
+	}
+	if got := testutil.ToFloat64(metrics.EngineDroppedUpdates) - before; got != sent-defaultBufferSize {
+		t.Errorf("dropped updates metric = %v, want %d", got, sent-defaultBufferSize)
+	}
+
+	// Once started, every buffered update is either processed or dropped by
+	// the worker.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats := c.Stats()
+		if stats.DroppedUpdates+stats.ProcessedUpdates == sent {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want %d updates accounted for", stats, sent)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConfigBufferSizes(t *testing.T) {
+	const n = 10 * defaultBufferSize
+	c := New(Config{UpdateBufferSize: n, WorkerBufferSize: n}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Before Start nothing drains the buffer, so it has to hold them all.
+	for i := range n {
+		if !c.Send(Update{TokenID: "t1", Price: price.Price(i + 1), Size: 1, Side: "bids"}) {
+			t.Fatalf("update %d dropped", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().ProcessedUpdates+c.Stats().DroppedUpdates < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want %d updates accounted for", c.Stats(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := c.Stats(); got.DroppedUpdates != 0 || got.ProcessedUpdates != n {
+		t.Errorf("Stats() = %+v, want %d processed and none dropped", got, n)
+	}
+}
+
+func TestDeduperForgetsAfterWindow(t *testing.T) {
+	d := newDeduper(time.Minute)
+	u := Update{TokenID: "t1", IsDelta: true, ID: "0xabc"}
+	now := time.Now()
+
+	if d.duplicate(u, now) {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	if !d.duplicate(u, now.Add(time.Second)) {
+		t.Error("redelivery within the window not reported as duplicate")
+	}
+	if d.duplicate(u, now.Add(2*time.Minute)) {
+		t.Error("delivery after the window reported as duplicate")
+	}
+
+	absolute := Update{TokenID: "t2", Price: 500_000, Size: 10, Side: "bids", ID: "0xdef"}
+	if d.duplicate(absolute, now) || !d.duplicate(absolute, now.Add(time.Second)) {
+		t.Error("redelivered absolute update not reported as duplicate")
+	}
+	if d.duplicate(Update{TokenID: "t1"}, now) || d.duplicate(Update{TokenID: "t1"}, now) {
+		t.Error("update without an ID reported as duplicate")
+	}
+}
+
+func TestStopWaitsForWorkers(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		c.Start(context.Background())
+	}()
+	<-started
+
+	for i := range 20 {
+		tokenID := fmt.Sprintf("t%d", i)
+		for !c.Send(Update{TokenID: tokenID, Price: 500_000, Size: 1, Side: "bids"}) {
+			time.Sleep(time.Millisecond)
+		}
+		waitForLevel(t, c, tokenID, "bids", 500_000)
+	}
+	c.Stop()
+
+	// Start and every worker have returned, only the goroutine that ran
+	// Start may still be exiting.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines after Stop, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEvictStale(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	c.Send(Update{TokenID: "t2", Price: 500_000, Size: 1, Side: "bids"})
+	waitForLevel(t, c, "t1", "bids", 500_000)
+	waitForLevel(t, c, "t2", "bids", 500_000)
+
+	const maxIdle = 50 * time.Millisecond
+	if got := c.EvictStale(maxIdle); got != 0 {
+		t.Fatalf("EvictStale() right after updates = %d, want 0", got)
+	}
+
+	time.Sleep(maxIdle)
+	c.Send(Update{TokenID: "t2", Price: 510_000, Size: 1, Side: "bids"})
+	waitForLevel(t, c, "t2", "bids", 510_000)
+
+	before := runtime.NumGoroutine()
+	if got := c.EvictStale(maxIdle); got != 1 {
+		t.Fatalf("EvictStale() = %d, want 1", got)
+	}
+	if got := c.TrackedTokens(); !slices.Equal(got, []BookKey{{TokenID: "t2"}}) {
+		t.Errorf("TrackedTokens() after eviction = %v, want only t2", got)
+	}
+
+	// The evicted worker's goroutine exits.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() >= before {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d goroutines after EvictStale, want fewer than %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := c.EvictStale(0); got != 0 {
+		t.Errorf("EvictStale(0) = %d, want 0", got)
+	}
+
+	// The token is still subscribed, but a delta alone would start an
+	// incomplete book, so only its next dump does. Start dispatches updates
+	// in order, so once the dump is applied the delta was dropped.
+	c.Send(Update{TokenID: "t1", Price: 520_000, Size: 1, Side: "bids", IsDelta: true})
+	c.Send(Update{TokenID: "t1", Price: 530_000, Size: 1, Side: "bids", Dump: true})
+	waitForLevel(t, c, "t1", "bids", 530_000)
+	if snap, _ := c.Snapshot("", "t1", 10); len(snap.Bids) != 1 {
+		t.Errorf("bids after eviction = %v, want only the dumped level", snap.Bids)
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Stop()
+}
+
+func TestRemoveToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	c.Send(Update{TokenID: "t2", Price: 500_000, Size: 1, Side: "bids"})
+	waitForLevel(t, c, "t1", "bids", 500_000)
+	waitForLevel(t, c, "t2", "bids", 500_000)
+
+	workers := func() int { return len(c.TrackedTokens()) }
+	c.RemoveToken("", "t1")
+	if got := workers(); got != 1 {
+		t.Fatalf("got %d workers after RemoveToken, want 1", got)
+	}
+
+	// A late delta is dropped. Start dispatches updates in order, so once the
+	// marker on t2 shows up the delta has been handled.
+	c.Send(Update{TokenID: "t1", Price: 510_000, Size: 1, Side: "bids", IsDelta: true})
+	c.Send(Update{TokenID: "t2", Price: 600_000, Size: 1, Side: "asks"})
+	waitForLevel(t, c, "t2", "asks", 600_000)
+	if got := workers(); got != 1 {
+		t.Errorf("got %d workers after a late update, want 1", got)
+	}
+
+	c.Send(Update{TokenID: "t1", Price: 520_000, Size: 1, Side: "bids", Dump: true})
+	waitForLevel(t, c, "t1", "bids", 520_000)
+	if got := workers(); got != 2 {
+		t.Errorf("got %d workers after an initial dump, want 2", got)
+	}
+}
+
+func TestRemoveTokenExpires(t *testing.T) {
+	c := NewInline(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	c.RemoveToken("", "t1")
+
+	// Within the grace window, late updates are dropped.
+	c.Send(Update{TokenID: "t1", Price: 510_000, Size: 1, Side: "bids"})
+	if _, ok := c.Snapshot("", "t1", 1); ok {
+		t.Fatal("late update within the grace window started a book")
+	}
+
+	// Past it, the token is forgotten and an update starts a book again.
+	c.mu.Lock()
+	c.removed[BookKey{TokenID: "t1"}] = time.Now().Add(-removedGrace)
+	c.mu.Unlock()
+	c.Send(Update{TokenID: "t1", Price: 520_000, Size: 1, Side: "bids"})
+	if _, ok := c.Snapshot("", "t1", 1); !ok {
+		t.Error("update after the grace window didn't start a book")
+	}
+
+	// Expired removals are swept by later ones, so the set stays bounded.
+	c.mu.Lock()
+	c.removed[BookKey{TokenID: "gone"}] = time.Now().Add(-removedGrace)
+	c.mu.Unlock()
+	c.RemoveToken("", "t1")
+	c.mu.RLock()
+	_, stillRemoved := c.removed[BookKey{TokenID: "gone"}]
+	removed := len(c.removed)
+	c.mu.RUnlock()
+	if stillRemoved || removed != 1 {
+		t.Errorf("removed tokens after a sweep = %d (expired one kept: %v), want only t1", removed, stillRemoved)
+	}
+}
+
+func TestTrackedTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	if got := c.TrackedTokens(); len(got) != 0 {
+		t.Errorf("TrackedTokens() before any update = %v, want none", got)
+	}
+
+	for _, tokenID := range []string{"t2", "t1", "t3"} {
+		c.Send(Update{TokenID: tokenID, Price: 500_000, Size: 1, Side: "bids"})
+		waitForLevel(t, c, tokenID, "bids", 500_000)
+	}
+	if got, want := c.TrackedTokens(), []BookKey{{TokenID: "t1"}, {TokenID: "t2"}, {TokenID: "t3"}}; !slices.Equal(got, want) {
+		t.Errorf("TrackedTokens() = %v, want %v", got, want)
+	}
+
+	c.RemoveToken("", "t2")
+	if got, want := c.TrackedTokens(), []BookKey{{TokenID: "t1"}, {TokenID: "t3"}}; !slices.Equal(got, want) {
+		t.Errorf("TrackedTokens() after RemoveToken = %v, want %v", got, want)
+	}
+}
+
+func TestResetToken(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	c.Send(Update{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	c.Send(Update{TokenID: "t2", Price: 500_000, Size: 1, Side: "bids"})
+	waitForLevel(t, c, "t1", "bids", 500_000)
+	waitForLevel(t, c, "t2", "bids", 500_000)
+
+	c.ResetToken("", "t1")
+	c.ResetToken("", "unknown")
+	// Updates of a token are applied in order, so once the marker shows up
+	// the reset has been applied.
+	c.Send(Update{TokenID: "t1", Price: 600_000, Size: 1, Side: "asks"})
+	waitForLevel(t, c, "t1", "asks", 600_000)
+
+	if snap, _ := c.Snapshot("", "t1", 10); len(snap.Bids) != 0 {
+		t.Errorf("t1 bids after reset = %v, want none", snap.Bids)
+	}
+	if snap, _ := c.Snapshot("", "t2", 10); len(snap.Bids) != 1 {
+		t.Errorf("t2 bids = %v, want its level untouched", snap.Bids)
+	}
+	if got, want := c.TrackedTokens(), []BookKey{{TokenID: "t1"}, {TokenID: "t2"}}; !slices.Equal(got, want) {
+		t.Errorf("TrackedTokens() = %v, want %v", got, want)
+	}
+}
+
+func TestCrossedBookGrace(t *testing.T) {
+	newCrossedWorker := func() *OrderbookWorker {
+		obw := &OrderbookWorker{
+			ob:           orderbook.New(),
+			logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			crossedGrace: 5 * time.Second,
+		}
+		obw.apply(Update{TokenID: "t1", Price: 500_000, Size: 10, Side: "asks"}, time.Now())
+		obw.apply(Update{TokenID: "t1", Price: 510_000, Size: 10, Side: "bids"}, time.Now())
+		return obw
+	}
+	start := time.Now()
+
+	t.Run("transient", func(t *testing.T) {
+		obw := newCrossedWorker()
+		before := testutil.ToFloat64(metrics.EngineCrossedBooks)
+
+		if obw.checkCrossed(start) {
+			t.Error("book flagged as soon as it crossed")
+		}
+		// The stale ask is replaced before the grace period ends.
+		obw.apply(Update{TokenID: "t1", Price: 500_000, Size: 0, Side: "asks"}, time.Now())
+		obw.apply(Update{TokenID: "t1", Price: 520_000, Size: 10, Side: "asks"}, time.Now())
+		if obw.checkCrossed(start.Add(3 * time.Second)) {
+			t.Error("uncrossed book flagged")
+		}
+		if !obw.crossedSince.IsZero() {
+			t.Errorf("crossedSince = %v after the book uncrossed, want zero", obw.crossedSince)
+		}
+		if got := testutil.ToFloat64(metrics.EngineCrossedBooks) - before; got != 0 {
+			t.Errorf("crossed books metric grew by %v, want 0", got)
+		}
+	})
+
+	t.Run("sustained", func(t *testing.T) {
+		obw := newCrossedWorker()
+		before := testutil.ToFloat64(metrics.EngineCrossedBooks)
+
+		if obw.checkCrossed(start) || obw.checkCrossed(start.Add(5*time.Second)) {
+			t.Error("book flagged within the grace period")
+		}
+		if !obw.checkCrossed(start.Add(6 * time.Second)) {
+			t.Error("book crossed past the grace period wasn't flagged")
+		}
+		// Further updates while still crossed don't count it again.
+		obw.checkCrossed(start.Add(7 * time.Second))
+		if got := testutil.ToFloat64(metrics.EngineCrossedBooks) - before; got != 1 {
+			t.Errorf("crossed books metric grew by %v, want 1", got)
+		}
+	})
+}
+
+func TestPlatformsHaveSeparateBooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go c.Start(ctx)
+
+	poly := BookKey{Platform: "polymarket", TokenID: "t1"}
+	kalshi := BookKey{Platform: "kalshi", TokenID: "t1"}
+	c.Send(Update{Platform: poly.Platform, TokenID: poly.TokenID, Price: 500_000, Size: 1, Side: "bids"})
+	c.Send(Update{Platform: kalshi.Platform, TokenID: kalshi.TokenID, Price: 300_000, Size: 2, Side: "bids"})
+	waitForBookLevel(t, c, poly, "bids", 500_000)
+	waitForBookLevel(t, c, kalshi, "bids", 300_000)
+
+	if got, want := c.TrackedTokens(), []BookKey{kalshi, poly}; !slices.Equal(got, want) {
+		t.Errorf("TrackedTokens() = %v, want %v", got, want)
+	}
+	for key, want := range map[BookKey]int64{poly: 500_000, kalshi: 300_000} {
+		snap, _ := c.Snapshot(key.Platform, key.TokenID, 10)
+		if snap.Platform != key.Platform || len(snap.Bids) != 1 || int64(snap.Bids[0].Price) != want {
+			t.Errorf("%v: snapshot = %+v, want one bid at %d", key, snap, want)
+		}
+	}
+
+	// Resetting one platform's book leaves the other alone.
+	c.ResetToken(kalshi.Platform, kalshi.TokenID)
+	c.Send(Update{Platform: kalshi.Platform, TokenID: kalshi.TokenID, Price: 700_000, Size: 1, Side: "asks"})
+	waitForBookLevel(t, c, kalshi, "asks", 700_000)
+	if snap, _ := c.Snapshot(poly.Platform, poly.TokenID, 10); len(snap.Bids) != 1 {
+		t.Errorf("polymarket bids after resetting kalshi = %v, want its level untouched", snap.Bids)
+	}
+
+	for _, snap := range c.TakeSnapshots(10) {
+		if snap.Platform == "" {
+			t.Errorf("TakeSnapshots() returned %s without a platform", snap.TokenID)
+		}
+	}
+}
+
+func TestLevelStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := New(Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if got := c.LevelStats(); got != (LevelStats{}) {
+		t.Errorf("LevelStats() of an empty engine = %+v, want zero", got)
+	}
+	go c.Start(ctx)
+
+	// t1: 3 bids and 2 asks, t2: 1 bid.
+	for _, u := range []Update{
+		{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"},
+		{TokenID: "t1", Price: 490_000, Size: 1, Side: "bids"},
+		{TokenID: "t1", Price: 480_000, Size: 1, Side: "bids"},
+		{TokenID: "t1", Price: 510_000, Size: 1, Side: "asks"},
+		{TokenID: "t1", Price: 520_000, Size: 1, Side: "asks"},
+		{TokenID: "t2", Price: 300_000, Size: 1, Side: "bids"},
+	} {
+		c.Send(u)
+	}
+	waitForLevel(t, c, "t1", "asks", 520_000)
+	waitForLevel(t, c, "t2", "bids", 300_000)
+
+	got := c.LevelStats()
+	want := LevelStats{Books: 2, Bids: 4, Asks: 2, MaxPerBook: 5, AvgPerBook: 3}
+	if got != want {
+		t.Errorf("LevelStats() = %+v, want %+v", got, want)
+	}
+	if got.Total() != 6 {
+		t.Errorf("Total() = %d, want 6", got.Total())
+	}
+
+	got.export()
+	if v := testutil.ToFloat64(metrics.EngineLevels.WithLabelValues("bids")); v != 4 {
+		t.Errorf("bid levels gauge = %v, want 4", v)
+	}
+	if v := testutil.ToFloat64(metrics.EngineMaxLevelsPerBook); v != 5 {
+		t.Errorf("max levels per book gauge = %v, want 5", v)
+	}
+}
+
+func TestQueueStats(t *testing.T) {
+	c := New(Config{UpdateBufferSize: 10, WorkerBufferSize: 4}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for range 3 {
+		c.Send(Update{TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	}
+	// Workers that aren't started leave their queues as filled.
+	for i, queued := range []int{1, 3} {
+		worker := &OrderbookWorker{updates: make(chan Update, c.workerBuffer)}
+		for range queued {
+			worker.updates <- Update{}
+		}
+		c.orderbookWorkers[BookKey{TokenID: fmt.Sprint("t", i)}] = worker
+	}
+
+	got := c.QueueStats()
+	want := QueueStats{Updates: 3, UpdatesCap: 10, Workers: 4, WorkersCap: 8, MaxWorker: 3}
+	if got != want {
+		t.Errorf("QueueStats() = %+v, want %+v", got, want)
+	}
+
+	got.export()
+	if v := testutil.ToFloat64(metrics.EngineQueueLength.WithLabelValues("updates")); v != 3 {
+		t.Errorf("updates queue length gauge = %v, want 3", v)
+	}
+	if v := testutil.ToFloat64(metrics.EngineQueueCapacity.WithLabelValues("workers")); v != 8 {
+		t.Errorf("workers queue capacity gauge = %v, want 8", v)
+	}
+	if v := testutil.ToFloat64(metrics.EngineMaxWorkerQueueLength); v != 3 {
+		t.Errorf("max worker queue length gauge = %v, want 3", v)
+	}
+}
+
+func TestResyncTokenSkipsSnapshots(t *testing.T) {
+	c := NewInline(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.Send(Update{Platform: "p", TokenID: "t1", Price: 500_000, Size: 1, Side: "bids"})
+	c.Send(Update{Platform: "p", TokenID: "t2", Price: 400_000, Size: 1, Side: "bids"})
+	c.TakeSnapshotsChanged(10)
+
+	c.ResyncToken("p", "t1")
+	c.ResyncToken("p", "untracked")
+	c.Send(Update{Platform: "p", TokenID: "t1", Price: 510_000, Size: 1, Side: "bids"})
+	c.Send(Update{Platform: "p", TokenID: "t2", Price: 410_000, Size: 1, Side: "bids"})
+
+	tokens := func(snaps []Snapshot) []string {
+		var ids []string
+		for _, s := range snaps {
+			ids = append(ids, s.TokenID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got := tokens(c.TakeSnapshots(10)); !slices.Equal(got, []string{"t2"}) {
+		t.Errorf("TakeSnapshots() while t1 resyncs returned %v, want [t2]", got)
+	}
+	if got := tokens(c.TakeSnapshotsChanged(10)); !slices.Equal(got, []string{"t2"}) {
+		t.Errorf("TakeSnapshotsChanged() while t1 resyncs returned %v, want [t2]", got)
+	}
+	if snap, ok := c.Snapshot("p", "t1", 10); !ok || !snap.Resyncing {
+		t.Errorf("Snapshot() of a resyncing book = %+v, %v, want it flagged", snap, ok)
+	}
+	if _, ok := c.Snapshot("p", "untracked", 10); ok {
+		t.Error("ResyncToken created a book for an untracked token")
+	}
+
+	c.FinishResync("p", "t1")
+	if got := tokens(c.TakeSnapshotsChanged(10)); !slices.Equal(got, []string{"t1"}) {
+		t.Errorf("TakeSnapshotsChanged() after the resync returned %v, want [t1]", got)
+	}
+	if snap, _ := c.Snapshot("p", "t1", 10); snap.Resyncing {
+		t.Error("Snapshot() after FinishResync is still flagged")
+	}
+}
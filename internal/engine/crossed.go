@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+)
+
+// checkCrossed tracks how long the book has been crossed (best bid at or above
+// best ask) and reports it once the cross outlasts obw.crossedGrace. Books
+// cross briefly while a burst of updates is applied level by level, so only a
+// sustained cross is worth an alert. It reports whether the book was flagged.
+func (obw *OrderbookWorker) checkCrossed(now time.Time) bool {
+	bid, hasBid := obw.ob.BestBid()
+	ask, hasAsk := obw.ob.BestAsk()
+	if !hasBid || !hasAsk || bid.Price < ask.Price {
+		obw.crossedSince = time.Time{}
+		obw.crossedFlagged = false
+		return false
+	}
+
+	if obw.crossedSince.IsZero() {
+		obw.crossedSince = now
+	}
+	if obw.crossedFlagged || now.Sub(obw.crossedSince) <= obw.crossedGrace {
+		return obw.crossedFlagged
+	}
+
+	obw.crossedFlagged = true
+	metrics.EngineCrossedBooks.Inc()
+	obw.logger.Warn("order book crossed",
+		"bid", bid.Price, "ask", ask.Price, "since", obw.crossedSince)
+	return true
+}
@@ -0,0 +1,54 @@
+package engine
+
+import "time"
+
+// dedupWindow is how long an update's identity is remembered. Redeliveries
+// after a reconnect arrive within seconds.
+const dedupWindow = time.Minute
+
+type dedupKey struct {
+	book      BookKey
+	id        string
+	eventTime int64
+}
+
+// deduper remembers recently applied updates so redelivered ones can be
+// dropped. Besides deltas, that includes absolute updates: applied again after
+// newer ones, a redelivered level would overwrite newer state. It is not safe
+// for concurrent use.
+type deduper struct {
+	window    time.Duration
+	seen      map[dedupKey]time.Time
+	lastPrune time.Time
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{
+		window: window,
+		seen:   make(map[dedupKey]time.Time),
+	}
+}
+
+// duplicate reports whether u was already seen within the window, and
+// records it otherwise. Updates without an ID are never duplicates.
+func (d *deduper) duplicate(u Update, now time.Time) bool {
+	if u.ID == "" {
+		return false
+	}
+
+	if now.Sub(d.lastPrune) >= d.window {
+		for k, seenAt := range d.seen {
+			if now.Sub(seenAt) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	key := dedupKey{book: u.Key(), id: u.ID, eventTime: u.EventTime.UnixNano()}
+	if seenAt, ok := d.seen[key]; ok && now.Sub(seenAt) < d.window {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
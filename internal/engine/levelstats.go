@@ -0,0 +1,48 @@
+package engine
+
+import "github.com/daszybak/prediction_markets/internal/metrics"
+
+// LevelStats summarizes the price levels held across all order books, to
+// size the engine's memory and buffers.
+type LevelStats struct {
+	Books int
+	Bids  int
+	Asks  int
+	// MaxPerBook and AvgPerBook count the levels of both sides of a book.
+	MaxPerBook int
+	AvgPerBook float64
+}
+
+// Total returns the number of levels across all books.
+func (s LevelStats) Total() int {
+	return s.Bids + s.Asks
+}
+
+// LevelStats counts the levels of every order book. Like TakeSnapshots, it is
+// safe to call concurrently with updates.
+func (c *Client) LevelStats() LevelStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := LevelStats{Books: len(c.orderbookWorkers)}
+	for _, worker := range c.orderbookWorkers {
+		worker.mu.RLock()
+		bids, asks := worker.ob.Len("bids"), worker.ob.Len("asks")
+		worker.mu.RUnlock()
+		stats.Bids += bids
+		stats.Asks += asks
+		stats.MaxPerBook = max(stats.MaxPerBook, bids+asks)
+	}
+	if stats.Books > 0 {
+		stats.AvgPerBook = float64(stats.Total()) / float64(stats.Books)
+	}
+	return stats
+}
+
+// export sets the engine's level gauges.
+func (s LevelStats) export() {
+	metrics.EngineBooks.Set(float64(s.Books))
+	metrics.EngineLevels.WithLabelValues("bids").Set(float64(s.Bids))
+	metrics.EngineLevels.WithLabelValues("asks").Set(float64(s.Asks))
+	metrics.EngineMaxLevelsPerBook.Set(float64(s.MaxPerBook))
+}
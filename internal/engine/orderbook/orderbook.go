@@ -2,7 +2,10 @@
 package orderbook
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/btree"
@@ -10,6 +13,9 @@ import (
 	"github.com/daszybak/prediction_markets/internal/price"
 )
 
+// ErrInvalidSide is returned when a side other than "bids" or "asks" is given.
+var ErrInvalidSide = errors.New("invalid side")
+
 // Level represents a price level in the order book.
 type Level struct {
 	Price     price.Price
@@ -27,12 +33,64 @@ func lessDesc(a, b Level) bool {
 	return a.Price > b.Price
 }
 
+// hotLevelsSize is how many recently updated levels are cached per side.
+const hotLevelsSize = 4
+
+// hotLevels caches the sizes of the most recently written levels of a side,
+// so deltas to them skip looking the level up in the tree. Entries must be
+// kept in sync with the tree: every write of a cached price updates it and
+// every removal drops it.
+type hotLevels struct {
+	prices [hotLevelsSize]price.Price
+	sizes  [hotLevelsSize]price.Size
+	valid  [hotLevelsSize]bool
+	next   int // Entry replaced by the next put of an uncached price.
+}
+
+func (h *hotLevels) get(p price.Price) (price.Size, bool) {
+	for i := range h.prices {
+		if h.valid[i] && h.prices[i] == p {
+			return h.sizes[i], true
+		}
+	}
+	return 0, false
+}
+
+func (h *hotLevels) put(p price.Price, size price.Size) {
+	for i := range h.prices {
+		if h.valid[i] && h.prices[i] == p {
+			h.sizes[i] = size
+			return
+		}
+	}
+	h.prices[h.next], h.sizes[h.next], h.valid[h.next] = p, size, true
+	h.next = (h.next + 1) % hotLevelsSize
+}
+
+func (h *hotLevels) remove(p price.Price) {
+	for i := range h.prices {
+		if h.prices[i] == p {
+			h.valid[i] = false
+		}
+	}
+}
+
+func (h *hotLevels) clear() {
+	*h = hotLevels{}
+}
+
 // Orderbook maintains sorted bid and ask levels using btrees.
 // Bids are sorted descending (highest price first).
 // Asks are sorted ascending (lowest price first).
+// An Orderbook is not safe for concurrent use, except for Seq.
 type Orderbook struct {
 	bids *btree.BTreeG[Level]
 	asks *btree.BTreeG[Level]
+
+	hotBids hotLevels
+	hotAsks hotLevels
+
+	seq atomic.Uint64 // See Seq.
 }
 
 // New creates a new empty order book.
@@ -47,17 +105,20 @@ func New() *Orderbook {
 // If size <= 0, the level is removed.
 // eventTime is the timestamp from the source API (use time.Now() if unavailable).
 func (ob *Orderbook) Set(p price.Price, size price.Size, side string, eventTime time.Time) error {
-	tree, err := ob.getTree(side)
+	tree, hot, err := ob.getSide(side)
 	if err != nil {
 		return err
 	}
+	ob.seq.Add(1)
 
 	if size <= 0 {
 		tree.Delete(Level{Price: p})
+		hot.remove(p)
 		return nil
 	}
 
 	tree.ReplaceOrInsert(Level{Price: p, Size: size, UpdatedAt: eventTime})
+	hot.put(p, size)
 	return nil
 }
 
@@ -65,35 +126,45 @@ func (ob *Orderbook) Set(p price.Price, size price.Size, side string, eventTime
 // If the resulting size <= 0, the level is removed.
 // eventTime is the timestamp from the source API (use time.Now() if unavailable).
 func (ob *Orderbook) Update(p price.Price, delta price.Size, side string, eventTime time.Time) error {
-	tree, err := ob.getTree(side)
+	tree, hot, err := ob.getSide(side)
 	if err != nil {
 		return err
 	}
+	ob.seq.Add(1)
 
-	// Find existing level
-	existing, found := tree.Get(Level{Price: p})
+	// Find the existing level, in the hot levels first to save a traversal.
 	newSize := delta
-	if found {
+	if size, ok := hot.get(p); ok {
+		newSize = size + delta
+	} else if existing, found := tree.Get(Level{Price: p}); found {
 		newSize = existing.Size + delta
 	}
 
 	if newSize <= 0 {
 		tree.Delete(Level{Price: p})
+		hot.remove(p)
 		return nil
 	}
 
 	tree.ReplaceOrInsert(Level{Price: p, Size: newSize, UpdatedAt: eventTime})
+	hot.put(p, newSize)
 	return nil
 }
 
 // GetTopN returns the top N price levels for a side.
 // Bids: highest prices first. Asks: lowest prices first.
+// It returns an empty slice if n <= 0.
 func (ob *Orderbook) GetTopN(side string, n int) ([]Level, error) {
 	tree, err := ob.getTree(side)
 	if err != nil {
 		return nil, err
 	}
+	if n <= 0 {
+		return []Level{}, nil
+	}
 
+	// Each tree is ordered best price first (bids by lessDesc, asks by
+	// lessAsc), so ascending walks both sides from the best price.
 	levels := make([]Level, 0, min(n, tree.Len()))
 	tree.Ascend(func(lvl Level) bool {
 		levels = append(levels, lvl)
@@ -103,6 +174,205 @@ func (ob *Orderbook) GetTopN(side string, n int) ([]Level, error) {
 	return levels, nil
 }
 
+// BestBid returns the highest bid without allocating, or false if there are
+// no bids.
+func (ob *Orderbook) BestBid() (Level, bool) {
+	return ob.bids.Min()
+}
+
+// BestAsk returns the lowest ask without allocating, or false if there are no
+// asks.
+func (ob *Orderbook) BestAsk() (Level, bool) {
+	return ob.asks.Min()
+}
+
+// IsCrossed reports whether the best bid is at or above the best ask. A book
+// only crosses when updates were lost or applied out of order.
+func (ob *Orderbook) IsCrossed() bool {
+	bid, hasBid := ob.BestBid()
+	ask, hasAsk := ob.BestAsk()
+	return hasBid && hasAsk && bid.Price >= ask.Price
+}
+
+// PruneCrossed uncrosses the book and returns how many levels it removed.
+// Of the best bid and best ask, the one updated less recently is taken to be
+// stale and removed until the book isn't crossed, so bids at or above the best
+// ask or asks at or below the best bid go. If both were updated at the same
+// time, both are removed.
+func (ob *Orderbook) PruneCrossed() int {
+	removed := 0
+	for ob.IsCrossed() {
+		bid, _ := ob.BestBid()
+		ask, _ := ob.BestAsk()
+		if !bid.UpdatedAt.After(ask.UpdatedAt) {
+			ob.bids.Delete(bid)
+			ob.hotBids.remove(bid.Price)
+			removed++
+		}
+		if !ask.UpdatedAt.After(bid.UpdatedAt) {
+			ob.asks.Delete(ask)
+			ob.hotAsks.remove(ask.Price)
+			removed++
+		}
+	}
+	if removed > 0 {
+		ob.seq.Add(1)
+	}
+	return removed
+}
+
+// Spread returns the best ask minus the best bid, or false if either side is
+// empty. A crossed book (best bid at or above best ask) has a spread <= 0,
+// which is returned as is.
+func (ob *Orderbook) Spread() (price.Price, bool) {
+	bid, hasBid := ob.BestBid()
+	ask, hasAsk := ob.BestAsk()
+	if !hasBid || !hasAsk {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+// MidPrice returns the average of the best bid and the best ask, rounded
+// down, or false if either side is empty. It is computed the same way for a
+// crossed book.
+func (ob *Orderbook) MidPrice() (price.Price, bool) {
+	bid, hasBid := ob.BestBid()
+	ask, hasAsk := ob.BestAsk()
+	if !hasBid || !hasAsk {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+// VWAP walks a side from the best price until size is reached and returns
+// the volume-weighted average price and the size it covers. The size is less
+// than requested if the side is thin, and 0 with a price of 0 if the side is
+// empty or size <= 0.
+func (ob *Orderbook) VWAP(side string, size price.Size) (price.Price, price.Size, error) {
+	tree, err := ob.getTree(side)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var filled price.Size
+	var notional Notional
+	tree.Ascend(func(lvl Level) bool {
+		if filled >= size {
+			return false
+		}
+		take := min(lvl.Size, size-filled)
+		filled += take
+		notional.Add(lvl.Price, take)
+		return true
+	})
+	if filled <= 0 {
+		return 0, 0, nil
+	}
+	return notional.Average(filled), filled, nil
+}
+
+// Notional sums price times size over levels. The products overflow int64
+// for large sizes, so they are summed exactly and divided once. The zero
+// value is an empty sum.
+type Notional struct {
+	sum big.Int
+}
+
+// Add adds size shares at p.
+func (n *Notional) Add(p price.Price, size price.Size) {
+	var term big.Int
+	n.sum.Add(&n.sum, term.Mul(big.NewInt(int64(p)), big.NewInt(int64(size))))
+}
+
+// Cost returns the sum scaled by price.PriceScale, rounded toward zero.
+func (n *Notional) Cost() int64 {
+	var cost big.Int
+	return cost.Quo(&n.sum, big.NewInt(price.PriceScale)).Int64()
+}
+
+// Average returns the sum divided by size, the volume-weighted average price
+// of size shares, rounded toward zero.
+func (n *Notional) Average(size price.Size) price.Price {
+	var avg big.Int
+	return price.Price(avg.Quo(&n.sum, big.NewInt(int64(size))).Int64())
+}
+
+// GetTopNAggregated returns the top N distinct price levels for a side,
+// summing the sizes of entries that share a price. This is the L2 view of a
+// book whose tree may hold several entries per price (e.g. individual orders).
+// UpdatedAt of an aggregated level is the latest of its entries.
+// It returns an empty slice if n <= 0.
+func (ob *Orderbook) GetTopNAggregated(side string, n int) ([]Level, error) {
+	tree, err := ob.getTree(side)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return []Level{}, nil
+	}
+
+	levels := make([]Level, 0, min(n, tree.Len()))
+	tree.Ascend(func(lvl Level) bool {
+		if last := len(levels) - 1; last >= 0 && levels[last].Price == lvl.Price {
+			levels[last].Size += lvl.Size
+			if lvl.UpdatedAt.After(levels[last].UpdatedAt) {
+				levels[last].UpdatedAt = lvl.UpdatedAt
+			}
+			return true
+		}
+		if len(levels) == n {
+			return false
+		}
+		levels = append(levels, lvl)
+		return true
+	})
+
+	return levels, nil
+}
+
+// Reset removes all levels from both sides.
+func (ob *Orderbook) Reset() {
+	ob.bids.Clear(false)
+	ob.asks.Clear(false)
+	ob.hotBids.clear()
+	ob.hotAsks.clear()
+	ob.seq.Add(1)
+}
+
+// PruneStale removes levels on both sides last updated before cutoff and
+// returns how many were removed.
+func (ob *Orderbook) PruneStale(cutoff time.Time) int {
+	removed := 0
+	for _, tree := range []*btree.BTreeG[Level]{ob.bids, ob.asks} {
+		var stale []Level
+		tree.Ascend(func(lvl Level) bool {
+			if lvl.UpdatedAt.Before(cutoff) {
+				stale = append(stale, lvl)
+			}
+			return true
+		})
+		for _, lvl := range stale {
+			tree.Delete(lvl)
+		}
+		removed += len(stale)
+	}
+	if removed > 0 {
+		ob.hotBids.clear()
+		ob.hotAsks.clear()
+		ob.seq.Add(1)
+	}
+	return removed
+}
+
+// Seq returns the book's sequence number. It grows with every Set and Update
+// and with every Reset or prune, and doesn't change on reads, so a snapshot
+// with the same Seq as an earlier one has the same levels. It is safe to call
+// concurrently with updates.
+func (ob *Orderbook) Seq() uint64 {
+	return ob.seq.Load()
+}
+
 // Len returns the number of levels on a side.
 func (ob *Orderbook) Len(side string) int {
 	tree, _ := ob.getTree(side)
@@ -112,6 +382,33 @@ func (ob *Orderbook) Len(side string) int {
 	return tree.Len()
 }
 
+// TotalSize returns the summed size of every level on a side, zero for an
+// empty side. It walks the whole side, so it is O(n) in the number of levels.
+func (ob *Orderbook) TotalSize(side string) (price.Size, error) {
+	tree, err := ob.getTree(side)
+	if err != nil {
+		return 0, err
+	}
+
+	var total price.Size
+	tree.Ascend(func(lvl Level) bool {
+		total += lvl.Size
+		return true
+	})
+	return total, nil
+}
+
+func (ob *Orderbook) getSide(side string) (*btree.BTreeG[Level], *hotLevels, error) {
+	switch side {
+	case "bids":
+		return ob.bids, &ob.hotBids, nil
+	case "asks":
+		return ob.asks, &ob.hotAsks, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidSide, side)
+	}
+}
+
 func (ob *Orderbook) getTree(side string) (*btree.BTreeG[Level], error) {
 	switch side {
 	case "bids":
@@ -119,6 +416,6 @@ func (ob *Orderbook) getTree(side string) (*btree.BTreeG[Level], error) {
 	case "asks":
 		return ob.asks, nil
 	default:
-		return nil, fmt.Errorf("invalid side: %s", side)
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSide, side)
 	}
 }
@@ -0,0 +1,534 @@
+package orderbook
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/btree"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+func TestInvalidSide(t *testing.T) {
+	ob := New()
+	now := time.Now()
+
+	if err := ob.Set(500_000, 100, "bid", now); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("Set: got %v, want ErrInvalidSide", err)
+	}
+	if err := ob.Update(500_000, 100, "ask", now); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("Update: got %v, want ErrInvalidSide", err)
+	}
+	if _, err := ob.GetTopN("", 5); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("GetTopN: got %v, want ErrInvalidSide", err)
+	}
+	if err := ob.Set(500_000, 100, "bids", now); err != nil {
+		t.Errorf("Set with valid side: %v", err)
+	}
+}
+
+func TestGetTopNOrder(t *testing.T) {
+	ob := New()
+	now := time.Now()
+	// Insert out of order so the result can't just reflect insertion order.
+	for _, p := range []price.Price{300_000, 100_000, 500_000, 200_000, 400_000} {
+		_ = ob.Set(p, 10, "bids", now)
+		_ = ob.Set(p+500_000, 10, "asks", now)
+	}
+
+	prices := func(levels []Level) []price.Price {
+		out := make([]price.Price, 0, len(levels))
+		for _, l := range levels {
+			out = append(out, l.Price)
+		}
+		return out
+	}
+	tests := []struct {
+		side string
+		n    int
+		want []price.Price
+	}{
+		{"bids", 3, []price.Price{500_000, 400_000, 300_000}},
+		{"asks", 3, []price.Price{600_000, 700_000, 800_000}},
+		{"bids", 10, []price.Price{500_000, 400_000, 300_000, 200_000, 100_000}},
+		{"asks", 10, []price.Price{600_000, 700_000, 800_000, 900_000, 1_000_000}},
+		{"bids", 0, []price.Price{}},
+		{"asks", -1, []price.Price{}},
+	}
+	for _, tt := range tests {
+		got, err := ob.GetTopN(tt.side, tt.n)
+		if err != nil {
+			t.Fatalf("GetTopN(%s, %d): %v", tt.side, tt.n, err)
+		}
+		if !slices.Equal(prices(got), tt.want) {
+			t.Errorf("GetTopN(%s, %d) = %v, want %v", tt.side, tt.n, prices(got), tt.want)
+		}
+	}
+
+	if got, err := ob.GetTopNAggregated("asks", -1); err != nil || len(got) != 0 {
+		t.Errorf("GetTopNAggregated(asks, -1) = %v, %v, want no levels", got, err)
+	}
+}
+
+func TestGetTopNAggregated(t *testing.T) {
+	ob := New()
+	// Order entries by price, then by time, so several entries can share a
+	// price like in an L3 book.
+	ob.bids = btree.NewG(32, func(a, b Level) bool {
+		if a.Price != b.Price {
+			return a.Price > b.Price
+		}
+		return a.UpdatedAt.Before(b.UpdatedAt)
+	})
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, lvl := range []Level{
+		{Price: 500_000, Size: 10},
+		{Price: 500_000, Size: 20},
+		{Price: 500_000, Size: 30},
+		{Price: 490_000, Size: 5},
+		{Price: 480_000, Size: 7},
+		{Price: 480_000, Size: 8},
+	} {
+		lvl.UpdatedAt = t0.Add(time.Duration(i) * time.Second)
+		ob.bids.ReplaceOrInsert(lvl)
+	}
+
+	got, err := ob.GetTopNAggregated("bids", 2)
+	if err != nil {
+		t.Fatalf("GetTopNAggregated: %v", err)
+	}
+	want := []Level{
+		{Price: 500_000, Size: 60, UpdatedAt: t0.Add(2 * time.Second)},
+		{Price: 490_000, Size: 5, UpdatedAt: t0.Add(3 * time.Second)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d levels, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("level %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	all, _ := ob.GetTopNAggregated("bids", 10)
+	if len(all) != 3 || all[2].Size != 15 {
+		t.Errorf("got %+v, want 3 levels with the last summing to 15", all)
+	}
+}
+
+func TestBestBidAndAsk(t *testing.T) {
+	ob := New()
+	now := time.Now()
+
+	if lvl, ok := ob.BestBid(); ok {
+		t.Errorf("BestBid() of an empty book = %+v, want none", lvl)
+	}
+	if lvl, ok := ob.BestAsk(); ok {
+		t.Errorf("BestAsk() of an empty book = %+v, want none", lvl)
+	}
+
+	_ = ob.Set(400_000, 10, "bids", now)
+	_ = ob.Set(600_000, 20, "asks", now)
+	if lvl, ok := ob.BestBid(); !ok || lvl.Price != 400_000 || lvl.Size != 10 {
+		t.Errorf("BestBid() = %+v, %t, want 10@400000", lvl, ok)
+	}
+	if lvl, ok := ob.BestAsk(); !ok || lvl.Price != 600_000 || lvl.Size != 20 {
+		t.Errorf("BestAsk() = %+v, %t, want 20@600000", lvl, ok)
+	}
+
+	_ = ob.Set(450_000, 5, "bids", now)
+	_ = ob.Set(550_000, 5, "asks", now)
+	_ = ob.Set(450_000, 0, "bids", now)
+	_ = ob.Update(550_000, -5, "asks", now)
+	if lvl, ok := ob.BestBid(); !ok || lvl.Price != 400_000 {
+		t.Errorf("BestBid() after removing the best bid = %+v, %t, want 400000", lvl, ok)
+	}
+	if lvl, ok := ob.BestAsk(); !ok || lvl.Price != 600_000 {
+		t.Errorf("BestAsk() after removing the best ask = %+v, %t, want 600000", lvl, ok)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		ob.BestBid()
+		ob.BestAsk()
+	}); allocs != 0 {
+		t.Errorf("BestBid and BestAsk allocate %v times, want 0", allocs)
+	}
+}
+
+func TestSpreadAndMidPrice(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		bid, ask   price.Price // 0 leaves the side empty.
+		wantOK     bool
+		wantSpread price.Price
+		wantMid    price.Price
+	}{
+		{name: "empty"},
+		{name: "bids only", bid: 400_000},
+		{name: "asks only", ask: 600_000},
+		{name: "normal", bid: 400_000, ask: 600_000, wantOK: true, wantSpread: 200_000, wantMid: 500_000},
+		{name: "rounds mid down", bid: 400_000, ask: 400_001, wantOK: true, wantSpread: 1, wantMid: 400_000},
+		{name: "locked", bid: 500_000, ask: 500_000, wantOK: true, wantSpread: 0, wantMid: 500_000},
+		{name: "crossed", bid: 600_000, ask: 400_000, wantOK: true, wantSpread: -200_000, wantMid: 500_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := New()
+			if tt.bid > 0 {
+				_ = ob.Set(tt.bid, 10, "bids", now)
+			}
+			if tt.ask > 0 {
+				_ = ob.Set(tt.ask, 10, "asks", now)
+			}
+
+			spread, ok := ob.Spread()
+			if ok != tt.wantOK || spread != tt.wantSpread {
+				t.Errorf("Spread() = %d, %t, want %d, %t", spread, ok, tt.wantSpread, tt.wantOK)
+			}
+			mid, ok := ob.MidPrice()
+			if ok != tt.wantOK || mid != tt.wantMid {
+				t.Errorf("MidPrice() = %d, %t, want %d, %t", mid, ok, tt.wantMid, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPruneCrossed(t *testing.T) {
+	now := time.Now()
+	older := now.Add(-time.Second)
+
+	tests := []struct {
+		name        string
+		bids, asks  []Level
+		wantRemoved int
+		wantBid     price.Price
+		wantAsk     price.Price
+	}{
+		{
+			name:        "not crossed",
+			bids:        []Level{{Price: 400_000, UpdatedAt: older}},
+			asks:        []Level{{Price: 600_000, UpdatedAt: now}},
+			wantRemoved: 0,
+			wantBid:     400_000,
+			wantAsk:     600_000,
+		},
+		{
+			name: "stale asks",
+			bids: []Level{{Price: 550_000, UpdatedAt: now}, {Price: 400_000, UpdatedAt: older}},
+			asks: []Level{
+				{Price: 500_000, UpdatedAt: older},
+				{Price: 550_000, UpdatedAt: older},
+				{Price: 600_000, UpdatedAt: older},
+			},
+			wantRemoved: 2,
+			wantBid:     550_000,
+			wantAsk:     600_000,
+		},
+		{
+			name:        "stale bids",
+			bids:        []Level{{Price: 650_000, UpdatedAt: older}, {Price: 500_000, UpdatedAt: older}},
+			asks:        []Level{{Price: 600_000, UpdatedAt: now}},
+			wantRemoved: 1,
+			wantBid:     500_000,
+			wantAsk:     600_000,
+		},
+		{
+			name:        "same time",
+			bids:        []Level{{Price: 600_000, UpdatedAt: now}, {Price: 400_000, UpdatedAt: now}},
+			asks:        []Level{{Price: 500_000, UpdatedAt: now}, {Price: 700_000, UpdatedAt: now}},
+			wantRemoved: 2,
+			wantBid:     400_000,
+			wantAsk:     700_000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ob := New()
+			for _, l := range tt.bids {
+				_ = ob.Set(l.Price, 10, "bids", l.UpdatedAt)
+			}
+			for _, l := range tt.asks {
+				_ = ob.Set(l.Price, 10, "asks", l.UpdatedAt)
+			}
+			if crossed := tt.wantRemoved > 0; ob.IsCrossed() != crossed {
+				t.Fatalf("IsCrossed() = %t before pruning, want %t", !crossed, crossed)
+			}
+
+			if n := ob.PruneCrossed(); n != tt.wantRemoved {
+				t.Errorf("PruneCrossed() = %d, want %d", n, tt.wantRemoved)
+			}
+			if ob.IsCrossed() {
+				t.Error("IsCrossed() = true after pruning")
+			}
+			bid, _ := ob.BestBid()
+			ask, _ := ob.BestAsk()
+			if bid.Price != tt.wantBid || ask.Price != tt.wantAsk {
+				t.Errorf("best bid/ask = %d/%d, want %d/%d", bid.Price, ask.Price, tt.wantBid, tt.wantAsk)
+			}
+		})
+	}
+}
+
+func TestIsCrossedOneSided(t *testing.T) {
+	ob := New()
+	_ = ob.Set(900_000, 10, "bids", time.Now())
+	if ob.IsCrossed() {
+		t.Error("a book without asks can't be crossed")
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	const share = price.Size(price.PriceScale)
+	ob := New()
+	now := time.Now()
+	_ = ob.Set(500_000, 100*share, "asks", now)
+	_ = ob.Set(600_000, 100*share, "asks", now)
+	_ = ob.Set(450_000, 50*share, "bids", now)
+	_ = ob.Set(400_000, 150*share, "bids", now)
+
+	tests := []struct {
+		name       string
+		side       string
+		size       price.Size
+		wantPrice  price.Price
+		wantFilled price.Size
+	}{
+		{"within best level", "asks", 40 * share, 500_000, 40 * share},
+		{"exact fill across levels", "asks", 200 * share, 550_000, 200 * share},
+		{"bids from the highest", "bids", 100 * share, 425_000, 100 * share},
+		{"thin book", "asks", 500 * share, 550_000, 200 * share},
+		{"zero size", "asks", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, filled, err := ob.VWAP(tt.side, tt.size)
+			if err != nil {
+				t.Fatalf("VWAP() error = %v", err)
+			}
+			if p != tt.wantPrice || filled != tt.wantFilled {
+				t.Errorf("VWAP() = %d, %d, want %d, %d", p, filled, tt.wantPrice, tt.wantFilled)
+			}
+		})
+	}
+
+	if _, _, err := ob.VWAP("bid", share); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("VWAP with an invalid side: got %v, want ErrInvalidSide", err)
+	}
+	if p, filled, err := New().VWAP("asks", share); err != nil || p != 0 || filled != 0 {
+		t.Errorf("VWAP of an empty book = %d, %d, %v, want 0, 0, nil", p, filled, err)
+	}
+
+	// Price times size of these levels overflows int64.
+	large := New()
+	_ = large.Set(999_999, 40_000_000*share, "asks", now)
+	_ = large.Set(500_000, 40_000_000*share, "asks", now)
+	if p, filled, err := large.VWAP("asks", 80_000_000*share); err != nil || p != 749_999 || filled != 80_000_000*share {
+		t.Errorf("VWAP of large levels = %d, %d, %v, want 749999, %d, nil", p, filled, err, 80_000_000*share)
+	}
+
+	// Each level's cost is below one unit of the price scale.
+	small := New()
+	_ = small.Set(333_333, 3, "asks", now)
+	_ = small.Set(333_334, 3, "asks", now)
+	if p, filled, err := small.VWAP("asks", 6); err != nil || p != 333_333 || filled != 6 {
+		t.Errorf("VWAP of small levels = %d, %d, %v, want 333333, 6, nil", p, filled, err)
+	}
+}
+
+func TestTotalSize(t *testing.T) {
+	ob := New()
+	now := time.Now()
+
+	if total, err := ob.TotalSize("bids"); err != nil || total != 0 {
+		t.Fatalf("TotalSize() on empty side = %d, %v, want 0, nil", total, err)
+	}
+
+	_ = ob.Set(450_000, 10, "bids", now)
+	_ = ob.Set(400_000, 20, "bids", now)
+	_ = ob.Set(350_000, 30, "bids", now)
+	_ = ob.Set(500_000, 5, "asks", now)
+
+	if total, err := ob.TotalSize("bids"); err != nil || total != 60 {
+		t.Errorf("TotalSize() = %d, %v, want 60, nil", total, err)
+	}
+	if n := ob.Len("bids"); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+
+	_ = ob.Set(400_000, 0, "bids", now)
+	if total, err := ob.TotalSize("bids"); err != nil || total != 40 {
+		t.Errorf("TotalSize() after removing a level = %d, %v, want 40, nil", total, err)
+	}
+	if n := ob.Len("bids"); n != 2 {
+		t.Errorf("Len() after removing a level = %d, want 2", n)
+	}
+
+	if _, err := ob.TotalSize("bid"); !errors.Is(err, ErrInvalidSide) {
+		t.Errorf("TotalSize(\"bid\") error = %v, want %v", err, ErrInvalidSide)
+	}
+}
+
+func TestSeq(t *testing.T) {
+	ob := New()
+	now := time.Now()
+	if got := ob.Seq(); got != 0 {
+		t.Fatalf("Seq() of a new book = %d, want 0", got)
+	}
+
+	_ = ob.Set(500_000, 10, "bids", now)
+	_ = ob.Update(500_000, 5, "bids", now)
+	_ = ob.Set(600_000, 10, "asks", now)
+	_ = ob.Set(600_000, 0, "asks", now)
+	if got := ob.Seq(); got != 4 {
+		t.Errorf("Seq() after 4 mutations = %d, want 4", got)
+	}
+
+	_, _ = ob.GetTopN("bids", 10)
+	_, _ = ob.GetTopNAggregated("bids", 10)
+	_, _ = ob.TotalSize("bids")
+	_, _, _ = ob.VWAP("bids", 10)
+	ob.BestBid()
+	ob.Spread()
+	ob.Len("bids")
+	ob.PruneStale(now.Add(-time.Hour)) // Removes nothing.
+	if got := ob.Seq(); got != 4 {
+		t.Errorf("Seq() after reads = %d, want 4", got)
+	}
+
+	if err := ob.Set(500_000, 10, "bid", now); err == nil {
+		t.Fatal("Set() with an invalid side succeeded")
+	}
+	if got := ob.Seq(); got != 4 {
+		t.Errorf("Seq() after a rejected Set = %d, want 4", got)
+	}
+
+	ob.Reset()
+	if got := ob.Seq(); got != 5 {
+		t.Errorf("Seq() after Reset = %d, want 5", got)
+	}
+}
+
+func TestPruneStale(t *testing.T) {
+	ob := New()
+	now := time.Now()
+
+	_ = ob.Set(500_000, 10, "bids", now.Add(-time.Hour))
+	_ = ob.Set(490_000, 10, "bids", now)
+	_ = ob.Set(510_000, 10, "asks", now.Add(-time.Hour))
+
+	if n := ob.PruneStale(now.Add(-time.Minute)); n != 2 {
+		t.Errorf("PruneStale removed %d levels, want 2", n)
+	}
+	bids, _ := ob.GetTopN("bids", 10)
+	if len(bids) != 1 || bids[0].Price != 490_000 {
+		t.Errorf("bids after prune = %v, want only 490000", bids)
+	}
+	if ob.Len("asks") != 0 {
+		t.Errorf("asks after prune = %d levels, want 0", ob.Len("asks"))
+	}
+}
+
+func TestUpdateHotLevelsStayConsistent(t *testing.T) {
+	ob := New()
+	now := time.Now()
+	size := func(p price.Price) price.Size {
+		t.Helper()
+		levels, _ := ob.GetTopN("bids", 100)
+		for _, l := range levels {
+			if l.Price == p {
+				return l.Size
+			}
+		}
+		return 0
+	}
+
+	_ = ob.Set(500_000, 100, "bids", now)
+	_ = ob.Update(500_000, 5, "bids", now)
+	if got := size(500_000); got != 105 {
+		t.Fatalf("after Set and Update: size = %d, want 105", got)
+	}
+
+	_ = ob.Set(500_000, 50, "bids", now)
+	_ = ob.Update(500_000, 5, "bids", now)
+	if got := size(500_000); got != 55 {
+		t.Fatalf("after overwriting Set: size = %d, want 55", got)
+	}
+
+	_ = ob.Update(500_000, -55, "bids", now)
+	_ = ob.Update(500_000, 10, "bids", now)
+	if got := size(500_000); got != 10 {
+		t.Fatalf("after removal: size = %d, want 10", got)
+	}
+
+	// More levels than the cache holds.
+	for i := range 10 {
+		_ = ob.Update(price.Price(400_000+i), 1, "bids", now)
+	}
+	for i := range 10 {
+		_ = ob.Update(price.Price(400_000+i), 1, "bids", now)
+	}
+	for i := range 10 {
+		if got := size(price.Price(400_000 + i)); got != 2 {
+			t.Fatalf("level %d: size = %d, want 2", 400_000+i, got)
+		}
+	}
+
+	ob.Reset()
+	_ = ob.Update(500_000, 1, "bids", now)
+	if got := size(500_000); got != 1 {
+		t.Fatalf("after Reset: size = %d, want 1", got)
+	}
+
+	_ = ob.Set(500_000, 7, "bids", now.Add(-time.Hour))
+	ob.PruneStale(now)
+	_ = ob.Update(500_000, 1, "bids", now)
+	if got := size(500_000); got != 1 {
+		t.Fatalf("after PruneStale: size = %d, want 1", got)
+	}
+}
+
+// benchmarkBook returns a book with n levels per side, bids below 500000 and
+// asks above it.
+func benchmarkBook(n int) *Orderbook {
+	ob := New()
+	now := time.Now()
+	for i := range n {
+		_ = ob.Set(price.Price(499_000-i*100), 1_000_000, "bids", now)
+		_ = ob.Set(price.Price(501_000+i*100), 1_000_000, "asks", now)
+	}
+	return ob
+}
+
+// BenchmarkUpdate applies deltas to a book of 1000 levels per side. The hot
+// workload keeps hitting the top four bid levels, as price_change feeds
+// mostly do; the spread workload touches a different level every time.
+func BenchmarkUpdate(b *testing.B) {
+	now := time.Now()
+
+	b.Run("hot", func(b *testing.B) {
+		ob := benchmarkBook(1000)
+		hot := []price.Price{499_000, 498_900, 498_800, 498_700}
+		for i := 0; b.Loop(); i++ {
+			delta := price.Size(1)
+			if i%2 == 1 {
+				delta = -1
+			}
+			_ = ob.Update(hot[i%len(hot)], delta, "bids", now)
+		}
+	})
+
+	b.Run("spread", func(b *testing.B) {
+		ob := benchmarkBook(1000)
+		for i := 0; b.Loop(); i++ {
+			delta := price.Size(1)
+			if i%2 == 1 {
+				delta = -1
+			}
+			_ = ob.Update(price.Price(499_000-(i*37%1000)*100), delta, "bids", now)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+)
+
+// QueueStats is how full the engine's update queues are. Updates are dropped
+// once a queue reaches its capacity, so the fill level warns of drops before
+// they happen.
+type QueueStats struct {
+	// Updates and UpdatesCap are the length and capacity of the queue Send
+	// writes to.
+	Updates    int
+	UpdatesCap int
+	// Workers and WorkersCap add up the queues of all book workers.
+	// MaxWorker is the longest of them, since a single busy book drops its
+	// updates long before the aggregate fills up.
+	Workers    int
+	WorkersCap int
+	MaxWorker  int
+}
+
+// QueueStats samples the length of the engine's update queues. It is safe to
+// call concurrently with updates.
+func (c *Client) QueueStats() QueueStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := QueueStats{Updates: len(c.updates), UpdatesCap: cap(c.updates)}
+	for _, worker := range c.orderbookWorkers {
+		n := len(worker.updates)
+		stats.Workers += n
+		stats.WorkersCap += cap(worker.updates)
+		stats.MaxWorker = max(stats.MaxWorker, n)
+	}
+	return stats
+}
+
+// QueueStatsLoop exports QueueStats every interval until ctx is cancelled.
+// interval must be positive.
+func (c *Client) QueueStatsLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.QueueStats().export()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// export sets the engine's queue gauges.
+func (s QueueStats) export() {
+	metrics.EngineQueueLength.WithLabelValues("updates").Set(float64(s.Updates))
+	metrics.EngineQueueCapacity.WithLabelValues("updates").Set(float64(s.UpdatesCap))
+	metrics.EngineQueueLength.WithLabelValues("workers").Set(float64(s.Workers))
+	metrics.EngineQueueCapacity.WithLabelValues("workers").Set(float64(s.WorkersCap))
+	metrics.EngineMaxWorkerQueueLength.Set(float64(s.MaxWorker))
+}
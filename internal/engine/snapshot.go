@@ -2,29 +2,103 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"time"
 
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
 	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// SnapshotFormat selects how snapshots are stored.
+type SnapshotFormat string
+
+const (
+	// SnapshotFormatRows writes one order_book_snapshots row per price level.
+	SnapshotFormatRows SnapshotFormat = "rows"
+	// SnapshotFormatDocument writes one order_book_documents row per token
+	// holding the whole book as JSONB. Far fewer rows, but levels can't be
+	// queried individually in SQL.
+	SnapshotFormatDocument SnapshotFormat = "document"
+)
+
+// SnapshotTime selects which timestamp populates the time column of snapshot
+// rows.
+type SnapshotTime string
+
+const (
+	// SnapshotTimeEvent uses each level's event time from the source API.
+	// This preserves the source's ordering, but the levels of one snapshot can
+	// carry different times and a level that hasn't changed keeps its old time.
+	SnapshotTimeEvent SnapshotTime = "event"
+	// SnapshotTimeIngest uses the wall clock when the snapshot was captured.
+	// All levels of a snapshot share one time, which reflects when we observed
+	// the book rather than when the source changed it.
+	SnapshotTimeIngest SnapshotTime = "ingest"
+)
+
+// EmptySideLevel is the level of the row that SnapshotConfig.EmptySides
+// writes for a side without levels. Its price and size are 0. Level 0 queries
+// such as the inside quotes don't see it.
+const EmptySideLevel = -1
+
+// SnapshotConfig configures a SnapshotWriter.
+type SnapshotConfig struct {
+	Interval time.Duration
+	Depth    int            // Number of levels captured per side.
+	Format   SnapshotFormat // Defaults to SnapshotFormatRows.
+	Time     SnapshotTime   // Defaults to SnapshotTimeEvent. Only used by SnapshotFormatRows.
+	// VerifyRate is the fraction of writes, between 0 and 1, after which one
+	// token's snapshot is read back and compared to what was written.
+	// 0 disables verification.
+	VerifyRate float64
+	// EmptySides writes an EmptySideLevel row for each side without levels,
+	// so that a book without asks can be told apart from one that wasn't
+	// captured. Only used by SnapshotFormatRows; documents have empty arrays.
+	EmptySides bool
+	// SkipUnchanged only writes the books that changed since they were last
+	// written, see Client.TakeSnapshotsChanged. A book that stays the same
+	// then has no rows for those ticks, so the latest snapshot at or before a
+	// time has to be looked up rather than the one at the tick. A book whose
+	// write failed is only written again once it changes.
+	SkipUnchanged bool
+}
+
 // SnapshotWriter periodically captures orderbook state and writes to the database.
 type SnapshotWriter struct {
-	engine   *Client
-	store    *store.Store
-	interval time.Duration
-	depth    int
-	logger   *slog.Logger
+	engine     *Client
+	store      *store.Store
+	interval   time.Duration
+	depth      int
+	format     SnapshotFormat
+	timeSrc    SnapshotTime
+	verifyRate float64
+	emptySides bool
+	skipSame   bool
+	logger     *slog.Logger
 }
 
 // NewSnapshotWriter creates a new snapshot writer.
-func NewSnapshotWriter(engine *Client, s *store.Store, interval time.Duration, depth int, logger *slog.Logger) *SnapshotWriter {
+func NewSnapshotWriter(engine *Client, s *store.Store, cfg SnapshotConfig, logger *slog.Logger) *SnapshotWriter {
+	if cfg.Format == "" {
+		cfg.Format = SnapshotFormatRows
+	}
+	if cfg.Time == "" {
+		cfg.Time = SnapshotTimeEvent
+	}
+
 	return &SnapshotWriter{
-		engine:   engine,
-		store:    s,
-		interval: interval,
-		depth:    depth,
-		logger:   logger.With("component", "snapshot_writer"),
+		engine:     engine,
+		store:      s,
+		interval:   cfg.Interval,
+		depth:      cfg.Depth,
+		format:     cfg.Format,
+		timeSrc:    cfg.Time,
+		verifyRate: cfg.VerifyRate,
+		emptySides: cfg.EmptySides,
+		skipSame:   cfg.SkipUnchanged,
+		logger:     logger.With("component", "snapshot_writer"),
 	}
 }
 
@@ -33,7 +107,7 @@ func (sw *SnapshotWriter) Start(ctx context.Context) {
 	ticker := time.NewTicker(sw.interval)
 	defer ticker.Stop()
 
-	sw.logger.Info("started snapshot writer", "interval", sw.interval, "depth", sw.depth)
+	sw.logger.Info("started snapshot writer", "interval", sw.interval, "depth", sw.depth, "format", sw.format, "time", sw.timeSrc)
 
 	for {
 		select {
@@ -47,23 +121,112 @@ func (sw *SnapshotWriter) Start(ctx context.Context) {
 }
 
 func (sw *SnapshotWriter) writeSnapshots(ctx context.Context) {
-	snapshots := sw.engine.TakeSnapshots(sw.depth)
+	sw.engine.LevelStats().export()
+
+	var snapshots []Snapshot
+	if sw.skipSame {
+		snapshots = sw.engine.TakeSnapshotsChanged(sw.depth)
+	} else {
+		snapshots = sw.engine.TakeSnapshots(sw.depth)
+	}
 	if len(snapshots) == 0 {
 		return
 	}
 
 	now := time.Now()
+	var written bool
+	switch sw.format {
+	case SnapshotFormatDocument:
+		written = sw.writeDocuments(ctx, snapshots, now)
+	default:
+		written = sw.writeRows(ctx, snapshots, now)
+	}
+	if written {
+		sw.maybeVerify(ctx, snapshots, now)
+	}
+}
+
+// writeRows writes snapshots as one row per level and reports whether any
+// were written.
+func (sw *SnapshotWriter) writeRows(ctx context.Context, snapshots []Snapshot, now time.Time) bool {
+	params := snapshotRows(snapshots, now, sw.timeSrc, sw.emptySides)
+	if len(params) == 0 {
+		return false
+	}
+
+	count, err := sw.store.InsertOrderBookSnapshotBatch(ctx, params)
+	if err != nil {
+		sw.logger.Error("failed to write snapshots", "error", err)
+		return false
+	}
+
+	sw.logger.Debug("wrote snapshots", "tokens", len(snapshots), "rows", count)
+	return true
+}
+
+// writeDocuments writes one book document per snapshot and reports whether
+// any were written.
+func (sw *SnapshotWriter) writeDocuments(ctx context.Context, snapshots []Snapshot, now time.Time) bool {
+	params := make([]store.InsertOrderBookDocumentBatchParams, 0, len(snapshots))
+
+	for _, snap := range snapshots {
+		book, err := json.Marshal(bookDocument(snap))
+		if err != nil {
+			sw.logger.Error("failed to marshal book document", "token", snap.TokenID, "error", err)
+			continue
+		}
+		params = append(params, store.InsertOrderBookDocumentBatchParams{
+			Time:    now, // Capture time, levels carry their own event time
+			TokenID: snap.TokenID,
+			Book:    book,
+		})
+	}
+
+	if len(params) == 0 {
+		return false
+	}
+
+	count, err := sw.store.InsertOrderBookDocumentBatch(ctx, params)
+	if err != nil {
+		sw.logger.Error("failed to write book documents", "error", err)
+		return false
+	}
+
+	sw.logger.Debug("wrote book documents", "rows", count)
+	return true
+}
+
+// snapshotRows converts snapshots to one row per level. now is the capture
+// time, used as the row time for SnapshotTimeIngest and for levels without an
+// event time. With emptySides, an empty side gets an EmptySideLevel row at
+// now.
+func snapshotRows(snapshots []Snapshot, now time.Time, timeSrc SnapshotTime, emptySides bool) []store.InsertOrderBookSnapshotBatchParams {
 	var params []store.InsertOrderBookSnapshotBatchParams
 
+	rowTime := func(lvl orderbook.Level) time.Time {
+		if timeSrc == SnapshotTimeIngest || lvl.UpdatedAt.IsZero() {
+			return now
+		}
+		return lvl.UpdatedAt
+	}
+
+	emptySide := func(tokenID, side string) store.InsertOrderBookSnapshotBatchParams {
+		return store.InsertOrderBookSnapshotBatchParams{
+			Time:    now,
+			TokenID: tokenID,
+			Side:    side,
+			Level:   EmptySideLevel,
+		}
+	}
+
 	for _, snap := range snapshots {
+		start := len(params)
+		if emptySides && len(snap.Bids) == 0 {
+			params = append(params, emptySide(snap.TokenID, "bid"))
+		}
 		for level, bid := range snap.Bids {
-			// Use level's UpdatedAt as event time, fall back to now if not set.
-			eventTime := bid.UpdatedAt
-			if eventTime.IsZero() {
-				eventTime = now
-			}
 			params = append(params, store.InsertOrderBookSnapshotBatchParams{
-				Time:    eventTime, // Event time from source API
+				Time:    rowTime(bid),
 				TokenID: snap.TokenID,
 				Side:    "bid",
 				Level:   int16(level),
@@ -72,13 +235,12 @@ func (sw *SnapshotWriter) writeSnapshots(ctx context.Context) {
 				// ingested_at uses DB default NOW()
 			})
 		}
+		if emptySides && len(snap.Asks) == 0 {
+			params = append(params, emptySide(snap.TokenID, "ask"))
+		}
 		for level, ask := range snap.Asks {
-			eventTime := ask.UpdatedAt
-			if eventTime.IsZero() {
-				eventTime = now
-			}
 			params = append(params, store.InsertOrderBookSnapshotBatchParams{
-				Time:    eventTime, // Event time from source API
+				Time:    rowTime(ask),
 				TokenID: snap.TokenID,
 				Side:    "ask",
 				Level:   int16(level),
@@ -87,17 +249,33 @@ func (sw *SnapshotWriter) writeSnapshots(ctx context.Context) {
 				// ingested_at uses DB default NOW()
 			})
 		}
-	}
 
-	if len(params) == 0 {
-		return
+		rows := params[start:]
+		checksum := pgtype.Int8{Int64: store.SnapshotChecksum(rows), Valid: true}
+		for i := range rows {
+			rows[i].Checksum = checksum
+		}
 	}
 
-	count, err := sw.store.InsertOrderBookSnapshotBatch(ctx, params)
-	if err != nil {
-		sw.logger.Error("failed to write snapshots", "error", err)
-		return
+	return params
+}
+
+// bookDocument converts a snapshot to its JSONB representation.
+func bookDocument(snap Snapshot) store.BookDocument {
+	return store.BookDocument{
+		Bids: documentLevels(snap.Bids),
+		Asks: documentLevels(snap.Asks),
 	}
+}
 
-	sw.logger.Debug("wrote snapshots", "tokens", len(snapshots), "rows", count)
+func documentLevels(levels []orderbook.Level) []store.BookDocumentLevel {
+	docLevels := make([]store.BookDocumentLevel, len(levels))
+	for i, lvl := range levels {
+		docLevels[i] = store.BookDocumentLevel{
+			Price:     int64(lvl.Price),
+			Size:      int64(lvl.Size),
+			UpdatedAt: lvl.UpdatedAt,
+		}
+	}
+	return docLevels
 }
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+func TestBookDocument(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	snap := Snapshot{
+		TokenID: "t1",
+		Bids: []orderbook.Level{
+			{Price: 500_000, Size: 1_000_000, UpdatedAt: at},
+			{Price: 490_000, Size: 2_000_000, UpdatedAt: at},
+		},
+		Asks: []orderbook.Level{
+			{Price: 510_000, Size: 3_000_000, UpdatedAt: at},
+		},
+	}
+
+	doc := bookDocument(snap)
+	if len(doc.Bids) != 2 || len(doc.Asks) != 1 {
+		t.Fatalf("got %d bids, %d asks, want 2, 1", len(doc.Bids), len(doc.Asks))
+	}
+	if doc.Bids[0].Price != 500_000 || doc.Bids[1].Size != 2_000_000 {
+		t.Errorf("bids not preserved in order: %+v", doc.Bids)
+	}
+	if doc.Asks[0].Price != 510_000 || !doc.Asks[0].UpdatedAt.Equal(at) {
+		t.Errorf("asks not preserved: %+v", doc.Asks)
+	}
+}
+
+func TestSnapshotRowsTime(t *testing.T) {
+	event := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := event.Add(time.Minute)
+	snapshots := []Snapshot{{
+		TokenID: "t1",
+		Bids:    []orderbook.Level{{Price: 500_000, Size: 1, UpdatedAt: event}},
+		Asks:    []orderbook.Level{{Price: 510_000, Size: 1}}, // no event time
+	}}
+
+	tests := []struct {
+		name    string
+		timeSrc SnapshotTime
+		wantBid time.Time
+		wantAsk time.Time
+	}{
+		{"event time", SnapshotTimeEvent, event, now},
+		{"ingest time", SnapshotTimeIngest, now, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := snapshotRows(snapshots, now, tt.timeSrc, false)
+			if len(rows) != 2 {
+				t.Fatalf("got %d rows, want 2", len(rows))
+			}
+			if !rows[0].Time.Equal(tt.wantBid) {
+				t.Errorf("bid time = %v, want %v", rows[0].Time, tt.wantBid)
+			}
+			if !rows[1].Time.Equal(tt.wantAsk) {
+				t.Errorf("ask time = %v, want %v", rows[1].Time, tt.wantAsk)
+			}
+		})
+	}
+}
+
+func TestSnapshotRowsChecksum(t *testing.T) {
+	snapshots := []Snapshot{
+		{TokenID: "t1", Bids: []orderbook.Level{{Price: 500_000, Size: 1}}, Asks: []orderbook.Level{{Price: 510_000, Size: 1}}},
+		{TokenID: "t2", Bids: []orderbook.Level{{Price: 400_000, Size: 2}}},
+	}
+	rows := snapshotRows(snapshots, time.Now(), SnapshotTimeIngest, false)
+
+	for _, tokenID := range []string{"t1", "t2"} {
+		var tokenRows []store.InsertOrderBookSnapshotBatchParams
+		for _, row := range rows {
+			if row.TokenID == tokenID {
+				tokenRows = append(tokenRows, row)
+			}
+		}
+		want := store.SnapshotChecksum(tokenRows)
+		for _, row := range tokenRows {
+			if !row.Checksum.Valid || row.Checksum.Int64 != want {
+				t.Errorf("%s %s row checksum = %+v, want %d", tokenID, row.Side, row.Checksum, want)
+			}
+		}
+	}
+}
+
+func TestSnapshotRowsEmptySides(t *testing.T) {
+	now := time.Now()
+	snapshots := []Snapshot{{
+		TokenID: "t1",
+		Bids:    []orderbook.Level{{Price: 500_000, Size: 1, UpdatedAt: now.Add(-time.Minute)}},
+	}}
+
+	if rows := snapshotRows(snapshots, now, SnapshotTimeEvent, false); len(rows) != 1 {
+		t.Fatalf("got %d rows without empty sides, want 1", len(rows))
+	}
+
+	rows := snapshotRows(snapshots, now, SnapshotTimeEvent, true)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want the bid and an empty ask row", len(rows))
+	}
+	empty := rows[1]
+	if empty.Side != "ask" || empty.Level != EmptySideLevel || empty.Price != 0 || empty.Size != 0 || !empty.Time.Equal(now) {
+		t.Errorf("empty ask row = %+v, want level %d with price and size 0 at the capture time", empty, EmptySideLevel)
+	}
+	if !empty.Checksum.Valid || empty.Checksum != rows[0].Checksum {
+		t.Errorf("empty ask row checksum = %+v, want the snapshot's %+v", empty.Checksum, rows[0].Checksum)
+	}
+
+	stored := make([]store.OrderBookSnapshot, 0, len(rows))
+	for _, r := range rows {
+		stored = append(stored, store.OrderBookSnapshot{Time: r.Time, TokenID: r.TokenID, Side: r.Side, Level: r.Level, Price: r.Price, Size: r.Size})
+	}
+	if snap := rowsSnapshot("t1", stored); len(snap.Bids) != 1 || len(snap.Asks) != 0 {
+		t.Errorf("read back %d bids and %d asks, want the empty ask row skipped", len(snap.Bids), len(snap.Asks))
+	}
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// defaultTradeBufferSize is the default of Config.TradeBufferSize.
+const defaultTradeBufferSize = 10_000
+
+// tradeFlushTimeout bounds the last flush of a TradeWriter after its context
+// is cancelled.
+const tradeFlushTimeout = 5 * time.Second
+
+// Sides of a trade, the side of the taker.
+const (
+	TradeBuy  = "buy"
+	TradeSell = "sell"
+)
+
+// Trade is an executed trade of a token. Unlike an Update it doesn't change
+// the book; book changes caused by a trade arrive as updates of their own.
+type Trade struct {
+	Platform string
+	TokenID  string
+	TradeID  string // Empty if the platform doesn't identify trades.
+	Price    price.Price
+	Size     price.Size
+	Side     string    // TradeBuy or TradeSell.
+	Time     time.Time // Timestamp from source API (zero = use current time)
+}
+
+// RecordTrade buffers a trade until a TradeWriter takes it. Returns false if
+// the buffer is full, in which case t is dropped.
+func (c *Client) RecordTrade(t Trade) bool {
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+
+	c.tradesMu.Lock()
+	defer c.tradesMu.Unlock()
+	if len(c.trades) >= c.tradeBuffer {
+		c.droppedTrades.Add(1)
+		metrics.EngineDroppedTrades.Inc()
+		c.dropLogger.Warn("trade buffer full, dropping trade", "platform", t.Platform, "token", t.TokenID)
+		return false
+	}
+	c.trades = append(c.trades, t)
+	return true
+}
+
+// TakeTrades returns the buffered trades in the order they were recorded and
+// empties the buffer.
+func (c *Client) TakeTrades() []Trade {
+	c.tradesMu.Lock()
+	defer c.tradesMu.Unlock()
+	trades := c.trades
+	c.trades = nil
+	return trades
+}
+
+// TradeStore persists trades. *store.Store implements it.
+type TradeStore interface {
+	InsertTradeBatch(ctx context.Context, arg []store.InsertTradeBatchParams) (int64, error)
+}
+
+// TradeWriter periodically writes the trades recorded by the engine to the
+// trades table.
+type TradeWriter struct {
+	engine   *Client
+	store    TradeStore
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewTradeWriter creates a new trade writer.
+func NewTradeWriter(engine *Client, s TradeStore, interval time.Duration, logger *slog.Logger) *TradeWriter {
+	return &TradeWriter{
+		engine:   engine,
+		store:    s,
+		interval: interval,
+		logger:   logger.With("component", "trade_writer"),
+	}
+}
+
+// Start runs the trade writer until the context is cancelled, then writes the
+// trades still buffered.
+func (tw *TradeWriter) Start(ctx context.Context) {
+	ticker := time.NewTicker(tw.interval)
+	defer ticker.Stop()
+
+	tw.logger.Info("started trade writer", "interval", tw.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tradeFlushTimeout)
+			tw.writeTrades(flushCtx)
+			cancel()
+			tw.logger.Info("trade writer stopped", "error", ctx.Err())
+			return
+		case <-ticker.C:
+			tw.writeTrades(ctx)
+		}
+	}
+}
+
+// writeTrades writes the buffered trades in one batch. Trades of a failed
+// batch are lost.
+func (tw *TradeWriter) writeTrades(ctx context.Context) {
+	trades := tw.engine.TakeTrades()
+	if len(trades) == 0 {
+		return
+	}
+
+	count, err := tw.store.InsertTradeBatch(ctx, tradeRows(trades))
+	if err != nil {
+		tw.logger.Error("failed to write trades", "trades", len(trades), "error", err)
+		return
+	}
+
+	tw.logger.Debug("wrote trades", "rows", count)
+}
+
+// tradeRows converts trades to trades rows.
+func tradeRows(trades []Trade) []store.InsertTradeBatchParams {
+	params := make([]store.InsertTradeBatchParams, 0, len(trades))
+	for _, t := range trades {
+		params = append(params, store.InsertTradeBatchParams{
+			Time:    t.Time,
+			TokenID: t.TokenID,
+			TradeID: pgtype.Text{String: t.TradeID, Valid: t.TradeID != ""},
+			Price:   int64(t.Price),
+			Size:    int64(t.Size),
+			Side:    t.Side,
+			// ingested_at uses DB default NOW()
+		})
+	}
+	return params
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+// fakeTradeStore records the trade batches it is given.
+type fakeTradeStore struct {
+	batches [][]store.InsertTradeBatchParams
+	err     error
+}
+
+func (s *fakeTradeStore) InsertTradeBatch(_ context.Context, arg []store.InsertTradeBatchParams) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.batches = append(s.batches, arg)
+	return int64(len(arg)), nil
+}
+
+func TestRecordTradeBuffers(t *testing.T) {
+	c := New(Config{TradeBufferSize: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i, want := range []bool{true, true, false} {
+		if got := c.RecordTrade(Trade{Platform: "p", TokenID: "t", Size: 1_000_000, Price: price.Price(500_000 + 1_000*i)}); got != want {
+			t.Errorf("RecordTrade() #%d = %t, want %t", i, got, want)
+		}
+	}
+	if got := c.Stats().DroppedTrades; got != 1 {
+		t.Errorf("DroppedTrades = %d, want 1", got)
+	}
+
+	trades := c.TakeTrades()
+	if len(trades) != 2 || trades[0].Price != 500_000 || trades[1].Price != 501_000 {
+		t.Fatalf("TakeTrades() = %+v, want the first two trades in order", trades)
+	}
+	if trades[0].Time.IsZero() {
+		t.Error("trade without time wasn't given the current time")
+	}
+	if got := c.TakeTrades(); len(got) != 0 {
+		t.Errorf("TakeTrades() after taking = %+v, want none", got)
+	}
+
+	// Taking the trades makes room for new ones.
+	if !c.RecordTrade(Trade{Platform: "p", TokenID: "t"}) {
+		t.Error("RecordTrade() after TakeTrades dropped the trade")
+	}
+}
+
+func TestTradeWriterInsertsTrades(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := New(Config{}, logger)
+	fake := &fakeTradeStore{}
+	tw := NewTradeWriter(c, fake, time.Hour, logger)
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.RecordTrade(Trade{Platform: "p", TokenID: "a", TradeID: "x1", Price: 550_000, Size: 3_000_000, Side: TradeBuy, Time: t0})
+	c.RecordTrade(Trade{Platform: "p", TokenID: "b", Price: 420_000, Size: 1_500_000, Side: TradeSell, Time: t0.Add(time.Second)})
+
+	tw.writeTrades(context.Background())
+	// Nothing is left to write.
+	tw.writeTrades(context.Background())
+
+	if len(fake.batches) != 1 {
+		t.Fatalf("wrote %d batches, want 1", len(fake.batches))
+	}
+	want := []store.InsertTradeBatchParams{
+		{Time: t0, TokenID: "a", Price: 550_000, Size: 3_000_000, Side: "buy"},
+		{Time: t0.Add(time.Second), TokenID: "b", Price: 420_000, Size: 1_500_000, Side: "sell"},
+	}
+	want[0].TradeID.String, want[0].TradeID.Valid = "x1", true
+	got := fake.batches[0]
+	if len(got) != len(want) {
+		t.Fatalf("rows = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTradeWriterFlushesOnStop(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := New(Config{}, logger)
+	fake := &fakeTradeStore{}
+	tw := NewTradeWriter(c, fake, time.Hour, logger)
+	c.RecordTrade(Trade{Platform: "p", TokenID: "a", Side: TradeBuy})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tw.Start(ctx)
+
+	if len(fake.batches) != 1 || len(fake.batches[0]) != 1 {
+		t.Errorf("batches = %+v, want the buffered trade written on stop", fake.batches)
+	}
+}
+
+func TestTradeWriterDropsFailedBatch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := New(Config{}, logger)
+	tw := NewTradeWriter(c, &fakeTradeStore{err: errors.New("db down")}, time.Hour, logger)
+	c.RecordTrade(Trade{Platform: "p", TokenID: "a", Side: TradeBuy})
+
+	tw.writeTrades(context.Background())
+
+	if got := c.TakeTrades(); len(got) != 0 {
+		t.Errorf("trades of a failed batch were kept: %+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+// maybeVerify reads one of the just-written snapshots back from the store
+// with probability sw.verifyRate and compares it to what was written.
+func (sw *SnapshotWriter) maybeVerify(ctx context.Context, written []Snapshot, writtenAt time.Time) {
+	if sw.verifyRate <= 0 || len(written) == 0 || rand.Float64() >= sw.verifyRate {
+		return
+	}
+
+	snap := written[rand.N(len(written))]
+	// Empty books write no rows without empty side rows, so there is nothing
+	// to read back.
+	if sw.format == SnapshotFormatRows && !sw.emptySides && len(snap.Bids) == 0 && len(snap.Asks) == 0 {
+		return
+	}
+
+	stored, err := sw.readBack(ctx, snap.TokenID, writtenAt)
+	if err != nil {
+		sw.logger.Warn("couldn't read back snapshot", "token", snap.TokenID, "error", err)
+		return
+	}
+	sw.verify(snap, stored)
+}
+
+// readBack loads the most recently stored snapshot of a token.
+func (sw *SnapshotWriter) readBack(ctx context.Context, tokenID string, writtenAt time.Time) (Snapshot, error) {
+	if sw.format == SnapshotFormatDocument {
+		doc, _, err := sw.store.GetBookDocument(ctx, tokenID, writtenAt)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		return documentSnapshot(tokenID, doc), nil
+	}
+
+	rows, err := sw.store.GetLastIngestedOrderBookSnapshot(ctx, tokenID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return rowsSnapshot(tokenID, rows), nil
+}
+
+// verify compares the levels of a stored snapshot to the written one and
+// reports whether they match. Level times aren't compared since they depend
+// on the SnapshotTime setting.
+func (sw *SnapshotWriter) verify(written, stored Snapshot) bool {
+	metrics.EngineSnapshotVerifications.Inc()
+
+	problems := append(
+		diffLevels("bids", written.Bids, stored.Bids),
+		diffLevels("asks", written.Asks, stored.Asks)...,
+	)
+	if len(problems) == 0 {
+		return true
+	}
+
+	metrics.EngineSnapshotDiscrepancies.Inc()
+	sw.logger.Error("stored snapshot doesn't match the engine", "token", written.TokenID, "discrepancies", problems)
+	return false
+}
+
+// diffLevels describes every level that differs between want and got.
+func diffLevels(side string, want, got []orderbook.Level) []string {
+	var problems []string
+	if len(want) != len(got) {
+		problems = append(problems, fmt.Sprintf("%s: %d levels stored, want %d", side, len(got), len(want)))
+	}
+	for i := range min(len(want), len(got)) {
+		if want[i].Price != got[i].Price || want[i].Size != got[i].Size {
+			problems = append(problems, fmt.Sprintf("%s level %d: stored %d@%d, want %d@%d",
+				side, i, got[i].Size, got[i].Price, want[i].Size, want[i].Price))
+		}
+	}
+	return problems
+}
+
+// rowsSnapshot rebuilds a snapshot from order_book_snapshots rows ordered by
+// side and level. Empty side rows are skipped.
+func rowsSnapshot(tokenID string, rows []store.OrderBookSnapshot) Snapshot {
+	snap := Snapshot{TokenID: tokenID}
+	for _, row := range rows {
+		if row.Level == EmptySideLevel {
+			continue
+		}
+		lvl := orderbook.Level{
+			Price:     price.Price(row.Price),
+			Size:      price.Size(row.Size),
+			UpdatedAt: row.Time,
+		}
+		switch row.Side {
+		case "bid":
+			snap.Bids = append(snap.Bids, lvl)
+		case "ask":
+			snap.Asks = append(snap.Asks, lvl)
+		}
+	}
+	return snap
+}
+
+// documentSnapshot rebuilds a snapshot from its JSONB representation.
+func documentSnapshot(tokenID string, doc store.BookDocument) Snapshot {
+	toLevels := func(levels []store.BookDocumentLevel) []orderbook.Level {
+		out := make([]orderbook.Level, 0, len(levels))
+		for _, l := range levels {
+			out = append(out, orderbook.Level{
+				Price:     price.Price(l.Price),
+				Size:      price.Size(l.Size),
+				UpdatedAt: l.UpdatedAt,
+			})
+		}
+		return out
+	}
+	return Snapshot{
+		TokenID: tokenID,
+		Bids:    toLevels(doc.Bids),
+		Asks:    toLevels(doc.Asks),
+	}
+}
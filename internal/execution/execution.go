@@ -0,0 +1,136 @@
+// Package execution simulates filling orders against order book snapshots.
+package execution
+
+import (
+	"errors"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// ErrInsufficientLiquidity is returned when the books can't fill the whole
+// size.
+var ErrInsufficientLiquidity = errors.New("insufficient liquidity")
+
+// bpsScale is one in basis points.
+const bpsScale = 10_000
+
+// Walk simulates buying size shares from asks, best first. It returns the
+// size filled, which is less than size if the book runs out, and its cost
+// scaled by price.PriceScale.
+func Walk(asks []orderbook.Level, size price.Size) (filled price.Size, cost int64) {
+	filled, notional := walk(asks, size)
+	return filled, notional.Cost()
+}
+
+// VWAP returns the volume-weighted average price of buying size shares from
+// asks, or false if the book can't fill it.
+func VWAP(asks []orderbook.Level, size price.Size) (price.Price, bool) {
+	filled, notional := walk(asks, size)
+	if size <= 0 || filled < size {
+		return 0, false
+	}
+	return notional.Average(filled), true
+}
+
+// walk is Walk, returning the exact notional of the fill.
+func walk(asks []orderbook.Level, size price.Size) (price.Size, *orderbook.Notional) {
+	var filled price.Size
+	notional := &orderbook.Notional{}
+	for _, lvl := range asks {
+		if filled >= size {
+			break
+		}
+		take := min(lvl.Size, size-filled)
+		filled += take
+		notional.Add(lvl.Price, take)
+	}
+	return filled, notional
+}
+
+// Venue is a platform's book of the token to buy.
+type Venue struct {
+	Platform string
+	Book     engine.Snapshot
+	Fees     FeeModel // Taker fees apply.
+}
+
+// Fill is what is bought on one venue.
+type Fill struct {
+	Platform string
+	Size     price.Size
+	Cost     int64 // Including Fee, scaled by price.PriceScale.
+	Fee      int64 // Scaled by price.PriceScale.
+}
+
+// Split is the cheapest way to buy a size across venues.
+type Split struct {
+	Fills []Fill // One per venue, in the order given.
+	Size  price.Size
+	Cost  int64 // Including fees, scaled by price.PriceScale.
+}
+
+// BestExecution splits buying size shares across the asks of venues so that
+// the total cost including fees is the lowest. Since the fee of a level only
+// depends on its price and grows with the size taken, taking the cheapest
+// level after fees across all books until size is filled is optimal. If the
+// books together can't fill size, the split of everything available is
+// returned with ErrInsufficientLiquidity.
+func BestExecution(size price.Size, venues ...Venue) (Split, error) {
+	split := Split{Fills: make([]Fill, len(venues))}
+	next := make([]int, len(venues)) // Index of the next ask of each venue.
+	notionals := make([]orderbook.Notional, len(venues))
+	for i, v := range venues {
+		split.Fills[i].Platform = v.Platform
+	}
+
+	for split.Size < size {
+		best := -1
+		var bestPrice int64
+		for i, v := range venues {
+			if next[i] >= len(v.Book.Asks) {
+				continue
+			}
+			if p := feePrice(v.Book.Asks[next[i]].Price, v.Fees); best < 0 || p < bestPrice {
+				best, bestPrice = i, p
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		lvl := venues[best].Book.Asks[next[best]]
+		next[best]++
+		take := min(lvl.Size, size-split.Size)
+		fill := &split.Fills[best]
+		fill.Size += take
+		notionals[best].Add(lvl.Price, take)
+		fill.Fee += int64(venues[best].Fees.Cost(lvl.Price, take, "buy", false))
+		split.Size += take
+	}
+
+	for i := range split.Fills {
+		fill := &split.Fills[i]
+		fill.Cost = notionals[i].Cost() + fill.Fee
+		split.Cost += fill.Cost
+	}
+
+	if split.Size < size {
+		return split, ErrInsufficientLiquidity
+	}
+	return split, nil
+}
+
+// levelCost is the cost of size shares at p, scaled by price.PriceScale.
+func levelCost(p price.Price, size price.Size) int64 {
+	var n orderbook.Notional
+	n.Add(p, size)
+	return n.Cost()
+}
+
+// feePrice is p with the taker fee of a share added, scaled by bpsScale so
+// that prices with different fees compare exactly.
+func feePrice(p price.Price, fees FeeModel) int64 {
+	return int64(p)*bpsScale + fees.perShare(p, false)
+}
@@ -0,0 +1,124 @@
+package execution
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// shares scales a number of shares to a price.Size.
+func shares(n int64) price.Size {
+	return price.Size(n * price.PriceScale)
+}
+
+func asks(levels ...orderbook.Level) engine.Snapshot {
+	return engine.Snapshot{Asks: levels}
+}
+
+func TestWalkAndVWAP(t *testing.T) {
+	book := []orderbook.Level{
+		{Price: 500_000, Size: shares(100)},
+		{Price: 600_000, Size: shares(100)},
+	}
+
+	filled, cost := Walk(book, shares(150))
+	if filled != shares(150) || cost != 80*price.PriceScale {
+		t.Errorf("Walk(150) = %d, %d, want %d, %d", filled, cost, shares(150), 80*price.PriceScale)
+	}
+	if got, ok := VWAP(book, shares(200)); !ok || got != 550_000 {
+		t.Errorf("VWAP(200) = %d, %v, want 550000, true", got, ok)
+	}
+	if _, ok := VWAP(book, shares(201)); ok {
+		t.Error("VWAP beyond the book's depth succeeded")
+	}
+}
+
+func TestWalkLargeAndUnscaledLevels(t *testing.T) {
+	// Price times size of these levels overflows int64.
+	large := []orderbook.Level{
+		{Price: 999_999, Size: shares(40_000_000)},
+		{Price: 500_000, Size: shares(40_000_000)},
+	}
+	if _, cost := Walk(large, shares(80_000_000)); cost != 59_999_960*price.PriceScale {
+		t.Errorf("Walk cost = %d, want %d", cost, 59_999_960*price.PriceScale)
+	}
+	if got, ok := VWAP(large, shares(80_000_000)); !ok || got != 749_999 {
+		t.Errorf("VWAP of large levels = %d, %v, want 749999, true", got, ok)
+	}
+	split, err := BestExecution(shares(80_000_000), Venue{Platform: "polymarket", Book: asks(large...)})
+	if err != nil || split.Cost != 59_999_960*price.PriceScale {
+		t.Errorf("BestExecution cost = %d, %v, want %d, nil", split.Cost, err, 59_999_960*price.PriceScale)
+	}
+
+	// Each level's cost is below one unit of the price scale.
+	small := []orderbook.Level{{Price: 333_333, Size: 3}, {Price: 333_334, Size: 3}}
+	if got, ok := VWAP(small, 6); !ok || got != 333_333 {
+		t.Errorf("VWAP of small levels = %d, %v, want 333333, true", got, ok)
+	}
+}
+
+func TestBestExecutionSplitBeatsSingleVenue(t *testing.T) {
+	poly := Venue{Platform: "polymarket", Book: asks(
+		orderbook.Level{Price: 500_000, Size: shares(100)},
+		orderbook.Level{Price: 600_000, Size: shares(1000)},
+	)}
+	kalshi := Venue{Platform: "kalshi", Fees: FeeModel{TakerBPS: 100}, Book: asks(
+		orderbook.Level{Price: 520_000, Size: shares(100)},
+		orderbook.Level{Price: 700_000, Size: shares(1000)},
+	)}
+	size := shares(200)
+
+	split, err := BestExecution(size, poly, kalshi)
+	if err != nil {
+		t.Fatalf("BestExecution: %v", err)
+	}
+	if split.Fills[0].Size != shares(100) || split.Fills[1].Size != shares(100) {
+		t.Errorf("split = %d on polymarket, %d on kalshi, want 100 on each", split.Fills[0].Size, split.Fills[1].Size)
+	}
+	// 100 at 0.50, plus 100 at 0.52 with a 1% fee.
+	if want := int64(102_520_000); split.Cost != want {
+		t.Errorf("cost = %d, want %d", split.Cost, want)
+	}
+	if split.Fills[1].Fee != 520_000 {
+		t.Errorf("kalshi fee = %d, want 520000", split.Fills[1].Fee)
+	}
+
+	for _, v := range []Venue{poly, kalshi} {
+		single, err := BestExecution(size, v)
+		if err != nil {
+			t.Fatalf("BestExecution on %s only: %v", v.Platform, err)
+		}
+		if single.Cost <= split.Cost {
+			t.Errorf("%s only costs %d, split costs %d, want the split cheaper", v.Platform, single.Cost, split.Cost)
+		}
+	}
+}
+
+func TestBestExecutionFeeChangesVenue(t *testing.T) {
+	poly := Venue{Platform: "polymarket", Book: asks(orderbook.Level{Price: 505_000, Size: shares(100)})}
+	kalshi := Venue{Platform: "kalshi", Fees: FeeModel{TakerBPS: 200}, Book: asks(orderbook.Level{Price: 500_000, Size: shares(100)})}
+
+	split, err := BestExecution(shares(50), poly, kalshi)
+	if err != nil {
+		t.Fatalf("BestExecution: %v", err)
+	}
+	if split.Fills[0].Size != shares(50) || split.Fills[1].Size != 0 {
+		t.Errorf("split = %d on polymarket, %d on kalshi, want all on polymarket", split.Fills[0].Size, split.Fills[1].Size)
+	}
+}
+
+func TestBestExecutionInsufficientLiquidity(t *testing.T) {
+	poly := Venue{Platform: "polymarket", Book: asks(orderbook.Level{Price: 500_000, Size: shares(10)})}
+	kalshi := Venue{Platform: "kalshi", Book: asks(orderbook.Level{Price: 510_000, Size: shares(20)})}
+
+	split, err := BestExecution(shares(100), poly, kalshi)
+	if !errors.Is(err, ErrInsufficientLiquidity) {
+		t.Fatalf("BestExecution error = %v, want %v", err, ErrInsufficientLiquidity)
+	}
+	if split.Size != shares(30) {
+		t.Errorf("filled %d, want everything available (%d)", split.Size, shares(30))
+	}
+}
@@ -0,0 +1,74 @@
+package execution
+
+import (
+	"fmt"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// FeeBasis is what a FeeModel's rates are applied to per share.
+type FeeBasis string
+
+const (
+	// FeeBasisNotional charges the rate on the price.
+	FeeBasisNotional FeeBasis = "notional"
+	// FeeBasisMinPrice charges the rate on the lower of the price and one
+	// minus the price, like Polymarket.
+	FeeBasisMinPrice FeeBasis = "min_price"
+	// FeeBasisVariance charges the rate on the price times one minus the
+	// price, like Kalshi.
+	FeeBasisVariance FeeBasis = "variance"
+)
+
+// ParseFeeBasis returns the basis named s. An empty s is FeeBasisNotional.
+func ParseFeeBasis(s string) (FeeBasis, error) {
+	switch b := FeeBasis(s); b {
+	case "":
+		return FeeBasisNotional, nil
+	case FeeBasisNotional, FeeBasisMinPrice, FeeBasisVariance:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown fee basis %q, want %s, %s or %s", s, FeeBasisNotional, FeeBasisMinPrice, FeeBasisVariance)
+	}
+}
+
+// FeeModel is a platform's trading fees. The zero value charges nothing.
+type FeeModel struct {
+	MakerBPS    int64    // Basis points of the basis, for resting orders.
+	TakerBPS    int64    // Basis points of the basis, for crossing orders.
+	Basis       FeeBasis // Defaults to FeeBasisNotional.
+	PerContract price.Price
+}
+
+// Cost returns the fee of trading size shares at p, scaled by
+// price.PriceScale. Both platforms charge buys and sells alike, side ("buy"
+// or "sell") is there for models that don't.
+func (f FeeModel) Cost(p price.Price, size price.Size, side string, isMaker bool) price.Price {
+	fee := levelCost(f.basis(p), size) * f.bps(isMaker) / bpsScale
+	return price.Price(fee + levelCost(f.PerContract, size))
+}
+
+// perShare is the fee of one share at p, scaled by price.PriceScale and
+// bpsScale so that prices with different fees compare exactly.
+func (f FeeModel) perShare(p price.Price, isMaker bool) int64 {
+	return int64(f.basis(p))*f.bps(isMaker) + int64(f.PerContract)*bpsScale
+}
+
+func (f FeeModel) bps(isMaker bool) int64 {
+	if isMaker {
+		return f.MakerBPS
+	}
+	return f.TakerBPS
+}
+
+// basis returns the amount the rate applies to for a share at p.
+func (f FeeModel) basis(p price.Price) price.Price {
+	switch f.Basis {
+	case FeeBasisMinPrice:
+		return min(p, price.Price(price.PriceScale)-p)
+	case FeeBasisVariance:
+		return price.Price(int64(p) * (price.PriceScale - int64(p)) / price.PriceScale)
+	default:
+		return p
+	}
+}
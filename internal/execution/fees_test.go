@@ -0,0 +1,108 @@
+package execution
+
+import (
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+func TestFeeModelCost(t *testing.T) {
+	tests := []struct {
+		name    string
+		fees    FeeModel
+		price   price.Price
+		size    price.Size
+		isMaker bool
+		want    price.Price
+	}{
+		{
+			name:  "zero fees",
+			price: 500_000,
+			size:  shares(100),
+			want:  0,
+		},
+		{
+			// 100 shares at 0.80 with 2% of min(0.80, 0.20): 100 * 0.20 * 0.02.
+			name:  "polymarket taker",
+			fees:  FeeModel{TakerBPS: 200, Basis: FeeBasisMinPrice},
+			price: 800_000,
+			size:  shares(100),
+			want:  400_000,
+		},
+		{
+			name:    "polymarket maker",
+			fees:    FeeModel{TakerBPS: 200, Basis: FeeBasisMinPrice},
+			price:   800_000,
+			size:    shares(100),
+			isMaker: true,
+			want:    0,
+		},
+		{
+			// 100 contracts at 0.50 with 7% of 0.50 * 0.50: 100 * 0.25 * 0.07.
+			name:  "kalshi taker",
+			fees:  FeeModel{MakerBPS: 175, TakerBPS: 700, Basis: FeeBasisVariance},
+			price: 500_000,
+			size:  shares(100),
+			want:  1_750_000,
+		},
+		{
+			name:    "kalshi maker",
+			fees:    FeeModel{MakerBPS: 175, TakerBPS: 700, Basis: FeeBasisVariance},
+			price:   500_000,
+			size:    shares(100),
+			isMaker: true,
+			want:    437_500,
+		},
+		{
+			name:  "notional with per contract",
+			fees:  FeeModel{TakerBPS: 100, PerContract: 10_000},
+			price: 400_000,
+			size:  shares(50),
+			want:  200_000 + 500_000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, side := range []string{"buy", "sell"} {
+				if got := tt.fees.Cost(tt.price, tt.size, side, tt.isMaker); got != tt.want {
+					t.Errorf("Cost(%s) = %d, want %d", side, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeeBasis(t *testing.T) {
+	if b, err := ParseFeeBasis(""); err != nil || b != FeeBasisNotional {
+		t.Errorf("ParseFeeBasis(\"\") = %q, %v, want %q", b, err, FeeBasisNotional)
+	}
+	if b, err := ParseFeeBasis("variance"); err != nil || b != FeeBasisVariance {
+		t.Errorf("ParseFeeBasis(variance) = %q, %v, want %q", b, err, FeeBasisVariance)
+	}
+	if _, err := ParseFeeBasis("flat"); err == nil {
+		t.Error("ParseFeeBasis(flat) succeeded")
+	}
+}
+
+func TestBestExecutionFeeBasis(t *testing.T) {
+	// At 0.90 a fee on the notional is much larger than one on min(p, 1-p).
+	notional := Venue{Platform: "a", Fees: FeeModel{TakerBPS: 100}, Book: asks(
+		orderbook.Level{Price: 900_000, Size: shares(100)},
+	)}
+	minPrice := Venue{Platform: "b", Fees: FeeModel{TakerBPS: 100, Basis: FeeBasisMinPrice}, Book: asks(
+		orderbook.Level{Price: 905_000, Size: shares(100)},
+	)}
+
+	split, err := BestExecution(shares(10), notional, minPrice)
+	if err != nil {
+		t.Fatalf("BestExecution: %v", err)
+	}
+	if split.Fills[1].Size != shares(10) {
+		t.Errorf("split = %d on a, %d on b, want all on b", split.Fills[0].Size, split.Fills[1].Size)
+	}
+	// 10 at 0.905 plus 1% of 10 * 0.095.
+	if want := int64(9_050_000 + 9_500); split.Cost != want {
+		t.Errorf("cost = %d, want %d", split.Cost, want)
+	}
+}
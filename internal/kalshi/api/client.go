@@ -2,9 +2,12 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/daszybak/prediction_markets/pkg/httpclient"
@@ -18,7 +21,7 @@ type Client struct {
 
 func New(baseURL string, apiKey string) *Client {
 	return &Client{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(httpclient.Timeouts{}),
 		baseURL:    baseURL,
 		APIKey:     apiKey,
 	}
@@ -26,9 +29,46 @@ func New(baseURL string, apiKey string) *Client {
 
 type Market struct {
 	Ticker               string    `json:"ticker"`
+	Title                string    `json:"title"`
 	RulesPrimary         string    `json:"rules_primary"`
 	RulesSecondary       string    `json:"rules_secondary"`
 	LatestExpirationTime time.Time `json:"latest_expiration_time"`
+
+	// Status is the market's lifecycle state, e.g. "active", "closed",
+	// "determined" or "settled".
+	Status string `json:"status"`
+	// Result is "yes" or "no" once the market is determined, empty before.
+	Result string `json:"result"`
+	// SettlementValue is what a Yes contract paid out in cents, set once
+	// the market settled.
+	SettlementValue *int64     `json:"settlement_value"`
+	SettlementTime  *time.Time `json:"settlement_ts"`
+}
+
+// Canonical outcome labels of Kalshi's binary markets, matching
+// store.DefaultOutcomeLabels.
+const (
+	OutcomeYes = "YES"
+	OutcomeNo  = "NO"
+)
+
+// WinningOutcome maps Result to the outcome that won, OutcomeYes or
+// OutcomeNo. It returns false while the market has no result or if the
+// result isn't a side, e.g. a voided market.
+func (m *Market) WinningOutcome() (string, bool) {
+	switch strings.ToLower(m.Result) {
+	case "yes":
+		return OutcomeYes, true
+	case "no":
+		return OutcomeNo, true
+	default:
+		return "", false
+	}
+}
+
+// marketResponse is the response of the single market endpoint.
+type marketResponse struct {
+	Market *Market `json:"market"`
 }
 
 type MarketPage struct {
@@ -36,28 +76,61 @@ type MarketPage struct {
 	Cursor  string    `json:"cursor"`
 }
 
-func (c *Client) GetMarkets(cursor string) (*MarketPage, error) {
-	endpoint := "/markets"
+// MarketStatus filters markets by their lifecycle state.
+type MarketStatus string
+
+const (
+	MarketStatusAll     MarketStatus = ""
+	MarketStatusOpen    MarketStatus = "open"
+	MarketStatusClosed  MarketStatus = "closed"
+	MarketStatusSettled MarketStatus = "settled"
+)
+
+// GetMarkets returns the page of markets at cursor. MarketStatusAll doesn't
+// filter by status.
+func (c *Client) GetMarkets(ctx context.Context, cursor string, status MarketStatus) (*MarketPage, error) {
+	query := url.Values{}
 	if cursor != "" {
-		endpoint += "?cursor=" + cursor
+		query.Set("cursor", cursor)
+	}
+	if status != MarketStatusAll {
+		query.Set("status", string(status))
+	}
+	endpoint := "/markets"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
 	}
-	markets, err := httpclient.GetResource[*MarketPage](c.httpClient, c.baseURL, endpoint, []int{200})
+	markets, err := httpclient.GetResource[*MarketPage](ctx, c.httpClient, c.baseURL, endpoint, []int{200})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get markets by from cursor: %w", err)
 	}
 	return markets, nil
 }
 
-func (c *Client) GetAllMarkets() ([]*Market, error) {
+// GetMarket returns the market with the given ticker, e.g. to poll a single
+// market for its resolution.
+func (c *Client) GetMarket(ctx context.Context, ticker string) (*Market, error) {
+	resp, err := httpclient.GetResource[*marketResponse](ctx, c.httpClient, c.baseURL, "/markets/"+url.PathEscape(ticker), []int{200})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get market %s: %w", ticker, err)
+	}
+	if resp == nil || resp.Market == nil {
+		return nil, fmt.Errorf("couldn't get market %s: response has no market", ticker)
+	}
+	return resp.Market, nil
+}
+
+// GetAllMarkets pages through all markets with the given status.
+func (c *Client) GetAllMarkets(ctx context.Context, status MarketStatus) ([]*Market, error) {
 	markets := []*Market{}
-	firstPage, err := c.GetMarkets("")
+	firstPage, err := c.GetMarkets(ctx, "", status)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get first page of markets: %w", err)
 	}
 	markets = append(markets, firstPage.Markets...)
 	nextCursor := firstPage.Cursor
 	for {
-		page, err := c.GetMarkets(nextCursor)
+		page, err := c.GetMarkets(ctx, nextCursor, status)
 		if err != nil {
 			cursor := nextCursor
 			if decoded, decodeErr := base64.StdEncoding.DecodeString(nextCursor); decodeErr == nil {
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/pkg/httpclient"
+)
+
+func TestGetAllMarketsSendsStatus(t *testing.T) {
+	var statuses []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		statuses = append(statuses, query.Get("status"))
+		if query.Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"markets": [{"ticker": "A"}], "cursor": "YWJj"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"markets": [{"ticker": "B"}], "cursor": ""}`))
+	}))
+	defer srv.Close()
+
+	markets, err := New(srv.URL, "").GetAllMarkets(context.Background(), MarketStatusOpen)
+	if err != nil {
+		t.Fatalf("GetAllMarkets: %v", err)
+	}
+	if len(markets) != 2 {
+		t.Errorf("got %d markets, want 2", len(markets))
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d requests, want 2", len(statuses))
+	}
+	for i, s := range statuses {
+		if s != "open" {
+			t.Errorf("request %d: status = %q, want %q", i, s, "open")
+		}
+	}
+}
+
+func TestGetMarketsWithoutStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("status") {
+			t.Errorf("status sent for MarketStatusAll: %q", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"markets": [], "cursor": ""}`))
+	}))
+	defer srv.Close()
+
+	if _, err := New(srv.URL, "").GetMarkets(context.Background(), "", MarketStatusAll); err != nil {
+		t.Fatalf("GetMarkets: %v", err)
+	}
+}
+
+func TestGetMarket(t *testing.T) {
+	payloads := map[string]string{
+		"/markets/OPEN-24": `{"market": {
+			"ticker": "OPEN-24",
+			"title": "Open market",
+			"status": "active",
+			"result": "",
+			"latest_expiration_time": "2026-12-31T00:00:00Z"
+		}}`,
+		"/markets/SETTLED-24": `{"market": {
+			"ticker": "SETTLED-24",
+			"title": "Settled market",
+			"status": "settled",
+			"result": "no",
+			"settlement_value": 0,
+			"settlement_ts": "2026-01-02T15:04:05Z",
+			"latest_expiration_time": "2026-01-01T00:00:00Z"
+		}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := payloads[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+	c := New(srv.URL, "")
+	ctx := context.Background()
+
+	open, err := c.GetMarket(ctx, "OPEN-24")
+	if err != nil {
+		t.Fatalf("GetMarket(open): %v", err)
+	}
+	if open.Ticker != "OPEN-24" || open.Status != "active" || open.SettlementValue != nil || open.SettlementTime != nil {
+		t.Errorf("open market = %+v", open)
+	}
+	if outcome, ok := open.WinningOutcome(); ok {
+		t.Errorf("open market WinningOutcome() = %q, want none", outcome)
+	}
+
+	settled, err := c.GetMarket(ctx, "SETTLED-24")
+	if err != nil {
+		t.Fatalf("GetMarket(settled): %v", err)
+	}
+	if settled.Status != "settled" || settled.SettlementValue == nil || *settled.SettlementValue != 0 {
+		t.Errorf("settled market = %+v", settled)
+	}
+	if want := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC); settled.SettlementTime == nil || !settled.SettlementTime.Equal(want) {
+		t.Errorf("SettlementTime = %v, want %v", settled.SettlementTime, want)
+	}
+	if outcome, ok := settled.WinningOutcome(); !ok || outcome != OutcomeNo {
+		t.Errorf("settled market WinningOutcome() = %q, %v, want %q, true", outcome, ok, OutcomeNo)
+	}
+
+	if _, err := c.GetMarket(ctx, "MISSING"); !httpclient.IsNotFound(err) {
+		t.Errorf("GetMarket(missing) error = %v, want not found", err)
+	}
+}
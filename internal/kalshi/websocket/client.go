@@ -1,2 +1,247 @@
 // Package websocket to get events of market and user data from Kalshi.
 package websocket
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Endpoint is the path of Kalshi's websocket API, appended to the ws_url.
+const Endpoint = "/trade-api/ws/v2"
+
+const (
+	HandshakeTimeout    = 30 * time.Second
+	DefaultCloseTimeout = 5 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// Headers of the signed handshake.
+const (
+	HeaderAccessKey       = "KALSHI-ACCESS-KEY"
+	HeaderAccessSignature = "KALSHI-ACCESS-SIGNATURE"
+	HeaderAccessTimestamp = "KALSHI-ACCESS-TIMESTAMP"
+)
+
+// ChannelOrderbookDelta delivers a snapshot of each subscribed market's book
+// followed by its changes.
+const ChannelOrderbookDelta = "orderbook_delta"
+
+var (
+	// ErrParse is returned by ReadMessage when a frame was read but couldn't be
+	// parsed. The connection itself is still usable.
+	ErrParse = errors.New("couldn't parse message")
+	// ErrCloseTimeout is returned by Close when the server didn't acknowledge
+	// the close frame before the deadline.
+	ErrCloseTimeout = errors.New("close handshake timed out")
+)
+
+// Auth is the API key the handshake is signed with.
+type Auth struct {
+	KeyID      string
+	PrivateKey *rsa.PrivateKey
+}
+
+// Header returns the headers authenticating a request of method to path at
+// now. The signature is RSA-PSS with SHA-256 over the Unix milliseconds of
+// now, the method and the path.
+func (a Auth) Header(now time.Time, method, path string) (http.Header, error) {
+	if a.PrivateKey == nil {
+		return nil, errors.New("no private key")
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	digest := sha256.Sum256([]byte(timestamp + method + path))
+	signature, err := rsa.SignPSS(rand.Reader, a.PrivateKey, crypto.SHA256, digest[:], &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign request: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set(HeaderAccessKey, a.KeyID)
+	header.Set(HeaderAccessSignature, base64.StdEncoding.EncodeToString(signature))
+	header.Set(HeaderAccessTimestamp, timestamp)
+	return header, nil
+}
+
+type Client struct {
+	conn *websocket.Conn
+	// writeMu is held while writing to conn so writes don't interleave.
+	writeMu sync.Mutex
+	// closing is closed by Close and ForceClose. It makes readPump discard
+	// frames.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// frames carries the frames read by readPump to ReadMessage. pumpDone
+	// is closed when readPump stopped, after it set readErr.
+	frames   chan []byte
+	pumpDone chan struct{}
+	readErr  error
+
+	// lastID is the ID of the last command sent. Replies carry the ID of
+	// their command.
+	lastID atomic.Int64
+}
+
+// New connects to url+Endpoint with a handshake signed by auth.
+func New(ctx context.Context, url string, auth Auth) (*Client, error) {
+	header, err := auth.Header(time.Now(), http.MethodGet, Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	dialer := websocket.Dialer{
+		HandshakeTimeout: HandshakeTimeout,
+	}
+
+	conn, _, err := dialer.DialContext(ctx, url+Endpoint, header)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to %s: %w", url+Endpoint, err)
+	}
+
+	c := &Client{
+		conn:     conn,
+		closing:  make(chan struct{}),
+		frames:   make(chan []byte),
+		pumpDone: make(chan struct{}),
+	}
+	go c.readPump()
+	return c, nil
+}
+
+// Close sends a close frame and waits for the server to acknowledge it until
+// the ctx deadline (or DefaultCloseTimeout), then closes the connection.
+// Frames arriving meanwhile are discarded.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.closing) })
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultCloseTimeout)
+	}
+
+	c.writeMu.Lock()
+	err := c.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		deadline,
+	)
+	c.writeMu.Unlock()
+	if err != nil {
+		return c.conn.Close()
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-c.pumpDone:
+		return c.conn.Close()
+	case <-timer.C:
+		_ = c.conn.Close()
+		return ErrCloseTimeout
+	}
+}
+
+// ForceClose closes the underlying network connection without a close
+// handshake.
+func (c *Client) ForceClose() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	return c.conn.Close()
+}
+
+// Command is a numbered request to the server, e.g. a subscription.
+type Command struct {
+	ID     int64         `json:"id"`
+	Cmd    string        `json:"cmd"`
+	Params CommandParams `json:"params"`
+}
+
+type CommandParams struct {
+	Channels      []string `json:"channels,omitempty"`
+	MarketTickers []string `json:"market_tickers,omitempty"`
+	SIDs          []int64  `json:"sids,omitempty"` // Subscriptions, for unsubscribe.
+}
+
+// SubscribeOrderbook subscribes to the order books of the given markets. It
+// returns the ID of the command, which the Subscribed or Error reply carries.
+func (c *Client) SubscribeOrderbook(ctx context.Context, tickers []string) (int64, error) {
+	return c.send(ctx, "subscribe", CommandParams{
+		Channels:      []string{ChannelOrderbookDelta},
+		MarketTickers: tickers,
+	})
+}
+
+// Unsubscribe ends the subscriptions with the given SIDs, as returned in
+// their Subscribed replies.
+func (c *Client) Unsubscribe(ctx context.Context, sids []int64) (int64, error) {
+	return c.send(ctx, "unsubscribe", CommandParams{SIDs: sids})
+}
+
+// send numbers a command and sends it before the deadline of ctx, or
+// DefaultWriteTimeout if it has none. It is safe for concurrent use.
+func (c *Client) send(ctx context.Context, cmd string, params CommandParams) (int64, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultWriteTimeout)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(deadline)
+
+	id := c.lastID.Add(1)
+	if err := c.conn.WriteJSON(Command{ID: id, Cmd: cmd, Params: params}); err != nil {
+		return 0, fmt.Errorf("couldn't send %s command: %w", cmd, err)
+	}
+	return id, nil
+}
+
+// readPump reads frames and hands them to ReadMessage until reading fails,
+// e.g. because the connection was closed. It is the connection's only reader,
+// so a ReadMessage cancelled by its context leaves the connection usable.
+func (c *Client) readPump() {
+	defer close(c.pumpDone)
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			return
+		}
+
+		select {
+		case c.frames <- msg:
+		case <-c.closing:
+			// Keep reading until the server's close frame.
+		}
+	}
+}
+
+// ReadMessage returns the next message. ReadMessage must not be called
+// concurrently.
+func (c *Client) ReadMessage(ctx context.Context) (*Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("reading message: %w", ctx.Err())
+	case <-c.pumpDone:
+		return nil, fmt.Errorf("couldn't read message: %w", c.readErr)
+	case frame := <-c.frames:
+		msg, err := parseMessage(frame)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrParse, err)
+		}
+		return msg, nil
+	}
+}
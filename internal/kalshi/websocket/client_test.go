@@ -0,0 +1,267 @@
+package websocket
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func testAuth(t *testing.T) Auth {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return Auth{KeyID: "key-id", PrivateKey: key}
+}
+
+// verifyHeader checks that header authenticates method and path for auth.
+func verifyHeader(t *testing.T, auth Auth, header http.Header, method, path string) {
+	t.Helper()
+	if got := header.Get(HeaderAccessKey); got != auth.KeyID {
+		t.Errorf("%s = %q, want %q", HeaderAccessKey, got, auth.KeyID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(HeaderAccessSignature))
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(header.Get(HeaderAccessTimestamp) + method + path))
+	err = rsa.VerifyPSS(&auth.PrivateKey.PublicKey, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	})
+	if err != nil {
+		t.Errorf("signature doesn't verify: %v", err)
+	}
+}
+
+func TestAuthHeader(t *testing.T) {
+	auth := testAuth(t)
+	now := time.UnixMilli(1_700_000_000_123)
+
+	header, err := auth.Header(now, http.MethodGet, Endpoint)
+	if err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+	if got := header.Get(HeaderAccessTimestamp); got != "1700000000123" {
+		t.Errorf("%s = %q, want Unix milliseconds 1700000000123", HeaderAccessTimestamp, got)
+	}
+	verifyHeader(t, auth, header, http.MethodGet, Endpoint)
+
+	if _, err := (Auth{KeyID: "key-id"}).Header(now, http.MethodGet, Endpoint); err == nil {
+		t.Error("Header without a private key succeeded, want an error")
+	}
+}
+
+func newTestServer(t *testing.T, handle func(*http.Request, *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(r, conn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialTestServer(t *testing.T, srv *httptest.Server, auth Auth) *Client {
+	t.Helper()
+	c, err := New(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), auth)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return c
+}
+
+func TestNewSignsHandshake(t *testing.T) {
+	auth := testAuth(t)
+	requests := make(chan *http.Request, 1)
+	srv := newTestServer(t, func(r *http.Request, conn *websocket.Conn) {
+		requests <- r
+		conn.ReadMessage()
+	})
+	c := dialTestServer(t, srv, auth)
+	defer c.Close(context.Background())
+
+	r := <-requests
+	if r.URL.Path != Endpoint {
+		t.Errorf("path = %q, want %q", r.URL.Path, Endpoint)
+	}
+	verifyHeader(t, auth, r.Header, http.MethodGet, Endpoint)
+}
+
+func TestSubscribeOrderbook(t *testing.T) {
+	commands := make(chan string, 2)
+	srv := newTestServer(t, func(_ *http.Request, conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			commands <- string(data)
+		}
+	})
+	c := dialTestServer(t, srv, testAuth(t))
+	defer c.Close(context.Background())
+
+	for i, tickers := range [][]string{{"FED-23DEC-T3.00", "INX-24"}, {"BTC-25"}} {
+		id, err := c.SubscribeOrderbook(context.Background(), tickers)
+		if err != nil {
+			t.Fatalf("SubscribeOrderbook: %v", err)
+		}
+		if want := int64(i + 1); id != want {
+			t.Errorf("command ID = %d, want %d", id, want)
+		}
+
+		var got Command
+		if err := json.Unmarshal([]byte(<-commands), &got); err != nil {
+			t.Fatalf("decode command: %v", err)
+		}
+		if got.ID != id || got.Cmd != "subscribe" {
+			t.Errorf("command = id %d %q, want id %d subscribe", got.ID, got.Cmd, id)
+		}
+		if !slices.Equal(got.Params.Channels, []string{ChannelOrderbookDelta}) {
+			t.Errorf("channels = %v, want [%s]", got.Params.Channels, ChannelOrderbookDelta)
+		}
+		if !slices.Equal(got.Params.MarketTickers, tickers) {
+			t.Errorf("market tickers = %v, want %v", got.Params.MarketTickers, tickers)
+		}
+	}
+}
+
+func TestSubscribeConcurrently(t *testing.T) {
+	const n = 20
+	commands := make(chan string, n)
+	srv := newTestServer(t, func(_ *http.Request, conn *websocket.Conn) {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			commands <- string(data)
+		}
+	})
+	c := dialTestServer(t, srv, testAuth(t))
+	defer c.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			if _, err := c.SubscribeOrderbook(context.Background(), []string{"INX-24"}); err != nil {
+				t.Errorf("SubscribeOrderbook: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	ids := make(map[int64]bool)
+	for range n {
+		var got Command
+		if err := json.Unmarshal([]byte(<-commands), &got); err != nil {
+			t.Fatalf("decode command: %v", err)
+		}
+		ids[got.ID] = true
+	}
+	if len(ids) != n {
+		t.Errorf("got %d distinct command IDs, want %d", len(ids), n)
+	}
+}
+
+func TestCommandEncoding(t *testing.T) {
+	data, err := json.Marshal(Command{
+		ID:  3,
+		Cmd: "subscribe",
+		Params: CommandParams{
+			Channels:      []string{ChannelOrderbookDelta},
+			MarketTickers: []string{"INX-24"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"id":3,"cmd":"subscribe","params":{"channels":["orderbook_delta"],"market_tickers":["INX-24"]}}`
+	if string(data) != want {
+		t.Errorf("command = %s, want %s", data, want)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	frames := []string{
+		`{"id":1,"type":"subscribed","msg":{"channel":"orderbook_delta","sid":7}}`,
+		`{"type":"orderbook_snapshot","sid":7,"seq":1,"msg":{"market_ticker":"INX-24","yes":[[8,300],[22,333]],"no":[[54,20]]}}`,
+		`{"type":"orderbook_delta","sid":7,"seq":2,"msg":{"market_ticker":"INX-24","price":96,"delta":-54,"side":"yes","ts":"2022-11-22T20:44:01Z"}}`,
+		`{"id":2,"type":"error","msg":{"code":6,"msg":"Already subscribed"}}`,
+		`{"type":"orderbook_delta","sid":7,"seq":3,"msg":{"price":"x"}}`,
+	}
+	srv := newTestServer(t, func(_ *http.Request, conn *websocket.Conn) {
+		for _, frame := range frames {
+			conn.WriteMessage(websocket.TextMessage, []byte(frame))
+		}
+		conn.ReadMessage()
+	})
+	c := dialTestServer(t, srv, testAuth(t))
+	defer c.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	read := func() *Message {
+		t.Helper()
+		msg, err := c.ReadMessage(ctx)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg.ID != 1 || msg.Subscribed == nil || msg.Subscribed.SID != 7 {
+		t.Errorf("subscribed = %+v, want sid 7 for command 1", msg)
+	}
+
+	snap := read()
+	if snap.OrderbookSnapshot == nil || snap.SID != 7 || snap.Seq != 1 {
+		t.Fatalf("snapshot = %+v, want sid 7 seq 1", snap)
+	}
+	if got, want := snap.OrderbookSnapshot.Yes, []Level{{80_000, 300}, {220_000, 333}}; !slices.Equal(got, want) {
+		t.Errorf("yes levels = %v, want %v", got, want)
+	}
+	if got, want := snap.OrderbookSnapshot.No, []Level{{540_000, 20}}; !slices.Equal(got, want) {
+		t.Errorf("no levels = %v, want %v", got, want)
+	}
+
+	delta := read().OrderbookDelta
+	want := OrderbookDelta{
+		MarketTicker: "INX-24",
+		Price:        960_000,
+		Delta:        -54,
+		Side:         "yes",
+		TS:           time.Date(2022, 11, 22, 20, 44, 1, 0, time.UTC),
+	}
+	if delta == nil || *delta != want {
+		t.Errorf("delta = %+v, want %+v", delta, want)
+	}
+
+	if msg := read(); msg.ID != 2 || msg.Error == nil || msg.Error.Code != 6 {
+		t.Errorf("error = %+v, want code 6 for command 2", msg)
+	}
+
+	if _, err := c.ReadMessage(ctx); !errors.Is(err, ErrParse) {
+		t.Errorf("ReadMessage of a malformed delta = %v, want ErrParse", err)
+	}
+}
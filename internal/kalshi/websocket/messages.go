@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// Types of the messages the server sends.
+const (
+	TypeSubscribed        = "subscribed"
+	TypeUnsubscribed      = "unsubscribed"
+	TypeError             = "error"
+	TypeOrderbookSnapshot = "orderbook_snapshot"
+	TypeOrderbookDelta    = "orderbook_delta"
+)
+
+// centScale converts Kalshi's cent prices to price.PriceScale.
+const centScale = price.PriceScale / 100
+
+// Message is a message of the server. Depending on Type, one of the typed
+// fields is set; messages of other types only have the envelope.
+type Message struct {
+	Type string `json:"type"`
+	// ID is the command a reply answers, 0 for subscription data.
+	ID int64 `json:"id"`
+	// SID is the subscription data belongs to, and Seq its number within
+	// the subscription. A gap in Seq means a delta was missed and the book
+	// must be resubscribed.
+	SID int64           `json:"sid"`
+	Seq int64           `json:"seq"`
+	Msg json.RawMessage `json:"msg"`
+
+	Subscribed        *Subscribed        `json:"-"`
+	Error             *CommandError      `json:"-"`
+	OrderbookSnapshot *OrderbookSnapshot `json:"-"`
+	OrderbookDelta    *OrderbookDelta    `json:"-"`
+}
+
+// Subscribed confirms a subscription to a channel.
+type Subscribed struct {
+	Channel string `json:"channel"`
+	SID     int64  `json:"sid"`
+}
+
+// CommandError is the reply to a command that failed.
+type CommandError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("kalshi error %d: %s", e.Code, e.Msg)
+}
+
+// OrderbookSnapshot is the full book of a market. Kalshi books have only
+// bids: Yes holds the bids for yes contracts and No those for no contracts.
+type OrderbookSnapshot struct {
+	MarketTicker string  `json:"market_ticker"`
+	Yes          []Level `json:"yes"`
+	No           []Level `json:"no"`
+}
+
+// OrderbookDelta changes the size of one level of a market's book.
+type OrderbookDelta struct {
+	MarketTicker string `json:"market_ticker"`
+	// Price is sent in cents.
+	Price price.Price `json:"-"`
+	// Delta is the change in contracts, negative when orders were removed.
+	Delta int64     `json:"delta"`
+	Side  string    `json:"side"` // yes or no
+	TS    time.Time `json:"ts"`
+}
+
+func (d *OrderbookDelta) UnmarshalJSON(data []byte) error {
+	type plain OrderbookDelta
+	var raw struct {
+		plain
+		Price int64 `json:"price"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*d = OrderbookDelta(raw.plain)
+	d.Price = price.Price(raw.Price * centScale)
+	return nil
+}
+
+// Level is a price level of an OrderbookSnapshot, sent as [cents, contracts].
+type Level struct {
+	Price price.Price
+	Size  int64 // Contracts.
+}
+
+func (l *Level) UnmarshalJSON(data []byte) error {
+	var pair [2]int64
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return fmt.Errorf("level: %w", err)
+	}
+	l.Price = price.Price(pair[0] * centScale)
+	l.Size = pair[1]
+	return nil
+}
+
+// parseMessage decodes the envelope of a frame and its typed payload.
+func parseMessage(data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	var target any
+	switch msg.Type {
+	case TypeSubscribed:
+		msg.Subscribed = &Subscribed{}
+		target = msg.Subscribed
+	case TypeError:
+		msg.Error = &CommandError{}
+		target = msg.Error
+	case TypeOrderbookSnapshot:
+		msg.OrderbookSnapshot = &OrderbookSnapshot{}
+		target = msg.OrderbookSnapshot
+	case TypeOrderbookDelta:
+		msg.OrderbookDelta = &OrderbookDelta{}
+		target = msg.OrderbookDelta
+	default:
+		return &msg, nil
+	}
+	if err := json.Unmarshal(msg.Msg, target); err != nil {
+		return nil, fmt.Errorf("%s message: %w", msg.Type, err)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,94 @@
+// Package matcher finds candidate pairs of equivalent markets across platforms.
+package matcher
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Market is a market's question as seen by the matcher.
+type Market struct {
+	ID       string
+	Platform string
+	Question string
+}
+
+// Candidate is a pair of markets that might be equivalent.
+type Candidate struct {
+	A     Market
+	B     Market
+	Score float64 // 0 (nothing in common) to 1 (same words).
+}
+
+// stopWords carry no meaning for matching questions.
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "at": {}, "be": {}, "by": {}, "for": {},
+	"in": {}, "is": {}, "of": {}, "on": {}, "or": {}, "the": {}, "to": {},
+	"will": {},
+}
+
+// Match scores every market in a against every market in b and returns the
+// pairs scoring at least minScore, best first. Ties are ordered by IDs so the
+// result is stable.
+func Match(a, b []Market, minScore float64) []Candidate {
+	wordsB := make([]map[string]struct{}, len(b))
+	for i, m := range b {
+		wordsB[i] = words(m.Question)
+	}
+
+	var candidates []Candidate
+	for _, ma := range a {
+		wordsA := words(ma.Question)
+		for i, mb := range b {
+			score := jaccard(wordsA, wordsB[i])
+			if score < minScore || score == 0 {
+				continue
+			}
+			candidates = append(candidates, Candidate{A: ma, B: mb, Score: score})
+		}
+	}
+
+	slices.SortFunc(candidates, func(x, y Candidate) int {
+		return cmp.Or(
+			cmp.Compare(y.Score, x.Score),
+			strings.Compare(x.A.ID, y.A.ID),
+			strings.Compare(x.B.ID, y.B.ID),
+		)
+	})
+	return candidates
+}
+
+// Similarity returns the Jaccard similarity of the words in two questions.
+func Similarity(x, y string) float64 {
+	return jaccard(words(x), words(y))
+}
+
+// words returns the lowercased words of s without punctuation and stop words.
+func words(s string) map[string]struct{} {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		if _, ok := stopWords[f]; ok {
+			continue
+		}
+		set[f] = struct{}{}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,33 @@
+package matcher
+
+import "testing"
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		x, y string
+		want float64
+	}{
+		{x: "Will the Fed cut rates?", y: "Fed cut rates", want: 1},
+		{x: "Fed cut rates", y: "Fed hike rates", want: 0.5},
+		{x: "Fed cut rates", y: "Rain tomorrow", want: 0},
+		{x: "", y: "Rain tomorrow", want: 0},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.x, tt.y); got != tt.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFiltersAndOrders(t *testing.T) {
+	a := []Market{{ID: "a1", Question: "Fed cut rates"}, {ID: "a2", Question: "Rain tomorrow"}}
+	b := []Market{{ID: "b1", Question: "Fed hike rates"}, {ID: "b2", Question: "Fed cut rates"}}
+
+	got := Match(a, b, 0.4)
+	if len(got) != 2 {
+		t.Fatalf("got %d candidates, want 2: %+v", len(got), got)
+	}
+	if got[0].B.ID != "b2" || got[1].B.ID != "b1" {
+		t.Errorf("got order %s, %s, want b2, b1", got[0].B.ID, got[1].B.ID)
+	}
+}
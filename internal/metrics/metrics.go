@@ -0,0 +1,161 @@
+// Package metrics defines the Prometheus metrics exported by the services.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "prediction_markets"
+
+// EngineDuplicateUpdates counts updates the engine dropped because it had
+// already applied them.
+var EngineDuplicateUpdates = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "duplicate_updates_total",
+	Help:      "Updates dropped because they were already applied.",
+})
+
+// EngineDroppedUpdates counts updates the engine dropped because its buffer
+// or the buffer of the book's worker was full.
+var EngineDroppedUpdates = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "dropped_updates_total",
+	Help:      "Updates dropped because an engine buffer was full.",
+})
+
+// EngineDroppedTrades counts trades the engine dropped because its trade
+// buffer was full.
+var EngineDroppedTrades = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "dropped_trades_total",
+	Help:      "Trades dropped because the engine's trade buffer was full.",
+})
+
+// EngineCrossedBooks counts order books that stayed crossed for longer than
+// the engine's grace period.
+var EngineCrossedBooks = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "crossed_books_total",
+	Help:      "Order books that stayed crossed for longer than the grace period.",
+})
+
+// EngineBooks is the number of order books the engine holds.
+var EngineBooks = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "books",
+	Help:      "Order books held by the engine.",
+})
+
+// EngineLevels is the number of price levels across all order books, by side.
+var EngineLevels = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "levels",
+	Help:      "Price levels across all order books.",
+}, []string{"side"})
+
+// EngineMaxLevelsPerBook is the most levels, both sides, in a single book.
+var EngineMaxLevelsPerBook = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "max_levels_per_book",
+	Help:      "Most price levels, both sides, held in a single order book.",
+})
+
+// EngineQueueLength is the number of updates waiting in the engine's queues,
+// by queue: "updates" for the queue Send writes to, "workers" for the sum of
+// the per-book queues.
+var EngineQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "queue_length",
+	Help:      "Updates waiting in the engine's queues.",
+}, []string{"queue"})
+
+// EngineQueueCapacity is the capacity of the engine's queues, labelled like
+// EngineQueueLength.
+var EngineQueueCapacity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "queue_capacity",
+	Help:      "Capacity of the engine's queues.",
+}, []string{"queue"})
+
+// EngineMaxWorkerQueueLength is the number of updates waiting in the fullest
+// per-book queue.
+var EngineMaxWorkerQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "max_worker_queue_length",
+	Help:      "Updates waiting in the fullest per-book queue.",
+})
+
+// EngineSnapshotVerifications counts snapshots read back from the store and
+// compared to what was written.
+var EngineSnapshotVerifications = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "snapshot_verifications_total",
+	Help:      "Snapshots read back from the store and compared to what was written.",
+})
+
+// EngineSnapshotDiscrepancies counts verified snapshots whose stored levels
+// didn't match the written ones.
+var EngineSnapshotDiscrepancies = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "engine",
+	Name:      "snapshot_discrepancies_total",
+	Help:      "Verified snapshots whose stored levels didn't match the written ones.",
+})
+
+// WebsocketFrames counts websocket frames received by platform and event
+// type. Frames of an unknown event type are labeled "unknown", frames that
+// aren't valid JSON "invalid".
+var WebsocketFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "websocket",
+	Name:      "frames_total",
+	Help:      "Websocket frames received, by platform and event type.",
+}, []string{"platform", "event_type"})
+
+// WebsocketMessages counts websocket messages handled by platform and event
+// type.
+var WebsocketMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "websocket",
+	Name:      "messages_total",
+	Help:      "Websocket messages handled, by platform and event type.",
+}, []string{"platform", "event_type"})
+
+// HTTPRequestDuration observes the latency of outgoing API requests by host,
+// method, normalized endpoint and status code ("error" if no response).
+var HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of outgoing API requests.",
+	Buckets:   []float64{.025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+}, []string{"host", "method", "endpoint", "code"})
+
+// HTTPResponses counts outgoing API requests by host, normalized endpoint and
+// status class (2xx, 4xx, ..., or "error" if no response).
+var HTTPResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "responses_total",
+	Help:      "Outgoing API requests by status class.",
+}, []string{"host", "endpoint", "class"})
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
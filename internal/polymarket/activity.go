@@ -0,0 +1,122 @@
+package polymarket
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// tokenActivity records when each token last had a message and when it was
+// first subscribed to, to find idle tokens.
+type tokenActivity struct {
+	mu           sync.Mutex
+	lastSeen     map[string]time.Time
+	subscribedAt map[string]time.Time
+}
+
+func newTokenActivity() *tokenActivity {
+	return &tokenActivity{
+		lastSeen:     make(map[string]time.Time),
+		subscribedAt: make(map[string]time.Time),
+	}
+}
+
+// observe records a message for the token at t.
+func (a *tokenActivity) observe(tokenID string, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastSeen[tokenID] = t
+}
+
+// subscribed records t as the subscription time of the tokens that don't
+// have one yet. Resubscribing doesn't make a token less idle.
+func (a *tokenActivity) subscribed(tokenIDs []string, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range tokenIDs {
+		if _, ok := a.subscribedAt[id]; !ok {
+			a.subscribedAt[id] = t
+		}
+	}
+}
+
+// idle returns the tokens out of tokenIDs without a message, or without a
+// subscription if they never had one, since cutoff.
+func (a *tokenActivity) idle(tokenIDs []string, cutoff time.Time) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var idle []string
+	for _, id := range tokenIDs {
+		last, ok := a.lastSeen[id]
+		if !ok {
+			last, ok = a.subscribedAt[id]
+		}
+		if ok && last.Before(cutoff) {
+			idle = append(idle, id)
+		}
+	}
+	return idle
+}
+
+// subscriptionTimes returns the subscription times of the tokens out of
+// tokenIDs that have one.
+func (a *tokenActivity) subscriptionTimes(tokenIDs []string) map[string]time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	times := make(map[string]time.Time, len(tokenIDs))
+	for _, id := range tokenIDs {
+		if t, ok := a.subscribedAt[id]; ok {
+			times[id] = t
+		}
+	}
+	return times
+}
+
+// forget drops everything recorded about the tokens.
+func (a *tokenActivity) forget(tokenIDs []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range tokenIDs {
+		delete(a.lastSeen, id)
+		delete(a.subscribedAt, id)
+	}
+}
+
+// snapshot returns a copy of the last-seen times.
+func (a *tokenActivity) snapshot() map[string]time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return maps.Clone(a.lastSeen)
+}
+
+// TokenLastSeen returns when each token last had a websocket message. Tokens
+// that never had one are missing.
+func (p *Polymarket) TokenLastSeen() map[string]time.Time {
+	return p.activity.snapshot()
+}
+
+// SubscriptionInfo is a token the collector is subscribed to.
+type SubscriptionInfo struct {
+	TokenID      string
+	SubscribedAt time.Time // When the token was added to the subscriptions.
+}
+
+// Subscriptions returns the tokens the collector believes it is subscribed
+// to, sorted by token ID. A token the platform sends no data for despite
+// being listed here points at a lost subscription.
+func (p *Polymarket) Subscriptions() []SubscriptionInfo {
+	p.mu.Lock()
+	tokenIDs := p.subscribedTokens.AsSlice()
+	p.mu.Unlock()
+	slices.Sort(tokenIDs)
+
+	times := p.activity.subscriptionTimes(tokenIDs)
+	subs := make([]SubscriptionInfo, 0, len(tokenIDs))
+	for _, id := range tokenIDs {
+		subs = append(subs, SubscriptionInfo{TokenID: id, SubscribedAt: times[id]})
+	}
+	return subs
+}
@@ -2,9 +2,12 @@
 package clob
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/daszybak/prediction_markets/internal/price"
@@ -16,9 +19,9 @@ type Client struct {
 	baseURL    string
 }
 
-func New(baseURL string) *Client {
+func New(baseURL string, timeouts httpclient.Timeouts) *Client {
 	return &Client{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(timeouts),
 		baseURL:    baseURL,
 	}
 }
@@ -36,6 +39,12 @@ type Market struct {
 	Question    string        `json:"question"`
 	Tokens      []MarketToken `json:"tokens"`
 	EndDateISO  string        `json:"end_date_iso"`
+	// MinimumTickSize is the price grid of the market's tokens until a
+	// tick_size_change event says otherwise.
+	MinimumTickSize  price.Tick `json:"minimum_tick_size"`
+	MinimumOrderSize price.Size `json:"minimum_order_size"`
+	MakerBaseFee     int        `json:"maker_base_fee"` // Basis points.
+	TakerBaseFee     int        `json:"taker_base_fee"` // Basis points.
 }
 
 type MarketPage struct {
@@ -45,29 +54,49 @@ type MarketPage struct {
 	NextCursor *string   `json:"next_cursor,omitempty"`
 }
 
-func (c *Client) GetMarketByConditionID(conditionID string) (*Market, error) {
-	market, err := httpclient.GetResource[*Market](c.httpClient, c.baseURL, "/markets/"+conditionID, []int{200})
+// ErrNotFound is returned when the CLOB API has no market for a condition ID.
+var ErrNotFound = errors.New("market not found")
+
+// GetMarketByConditionID returns the market with the given condition ID, or
+// an error wrapping ErrNotFound if the API doesn't know it.
+func (c *Client) GetMarketByConditionID(ctx context.Context, conditionID string) (*Market, error) {
+	market, err := httpclient.GetResource[*Market](ctx, c.httpClient, c.baseURL, "/markets/"+conditionID, []int{200})
+	if httpclient.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: condition ID %s", ErrNotFound, conditionID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get market by condition ID %s: %w", conditionID, err)
 	}
 	return market, nil
 }
 
-func (c *Client) GetMarkets(nextCursor *string) (*MarketPage, error) {
-	endpoint := "/markets"
+// GetMarkets returns the page of markets at nextCursor. A non-zero
+// updatedSince asks for markets changed after it only. The filter isn't part
+// of the documented API, so callers must cope with getting every market.
+func (c *Client) GetMarkets(ctx context.Context, nextCursor *string, updatedSince time.Time) (*MarketPage, error) {
+	query := url.Values{}
 	if nextCursor != nil {
-		endpoint += "?next_cursor=" + *nextCursor
+		query.Set("next_cursor", *nextCursor)
+	}
+	if !updatedSince.IsZero() {
+		query.Set("updated_since", updatedSince.UTC().Format(time.RFC3339))
+	}
+	endpoint := "/markets"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
 	}
-	markets, err := httpclient.GetResource[*MarketPage](c.httpClient, c.baseURL, endpoint, []int{200})
+	markets, err := httpclient.GetResource[*MarketPage](ctx, c.httpClient, c.baseURL, endpoint, []int{200})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get markets by from next cursor: %w", err)
 	}
 	return markets, nil
 }
 
-func (c *Client) GetAllMarkets() ([]*Market, error) {
+// GetAllMarkets pages through all markets, or those changed after a non-zero
+// updatedSince (see GetMarkets).
+func (c *Client) GetAllMarkets(ctx context.Context, updatedSince time.Time) ([]*Market, error) {
 	markets := []*Market{}
-	firstPage, err := c.GetMarkets(nil)
+	firstPage, err := c.GetMarkets(ctx, nil, updatedSince)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get first page of markets: %w", err)
 	}
@@ -77,7 +106,7 @@ func (c *Client) GetAllMarkets() ([]*Market, error) {
 		return markets, nil
 	}
 	for {
-		page, err := c.GetMarkets(nextCursor)
+		page, err := c.GetMarkets(ctx, nextCursor, updatedSince)
 		if err != nil {
 			cursor := *nextCursor
 			if decoded, decodeErr := base64.StdEncoding.DecodeString(*nextCursor); decodeErr == nil {
@@ -101,3 +130,38 @@ func (c *Client) GetAllMarkets() ([]*Market, error) {
 	}
 	return markets, nil
 }
+
+// Sides accepted by the price endpoint.
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+type midpointResponse struct {
+	Mid price.Price `json:"mid"`
+}
+
+type priceResponse struct {
+	Price price.Price `json:"price"`
+}
+
+// GetMidpoint returns the midpoint between the best bid and best ask of a token.
+func (c *Client) GetMidpoint(ctx context.Context, tokenID string) (price.Price, error) {
+	query := url.Values{"token_id": {tokenID}}
+	resp, err := httpclient.GetResource[midpointResponse](ctx, c.httpClient, c.baseURL, "/midpoint?"+query.Encode(), []int{200})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get midpoint for token %s: %w", tokenID, err)
+	}
+	return resp.Mid, nil
+}
+
+// GetPrice returns the best price a token can be bought (SideBuy) or sold
+// (SideSell) at.
+func (c *Client) GetPrice(ctx context.Context, tokenID, side string) (price.Price, error) {
+	query := url.Values{"token_id": {tokenID}, "side": {side}}
+	resp, err := httpclient.GetResource[priceResponse](ctx, c.httpClient, c.baseURL, "/price?"+query.Encode(), []int{200})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get %s price for token %s: %w", side, tokenID, err)
+	}
+	return resp.Price, nil
+}
@@ -0,0 +1,154 @@
+package clob
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/pkg/httpclient"
+)
+
+func TestGetMidpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/midpoint" || r.URL.Query().Get("token_id") != "123" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"mid": "0.555"}`))
+	}))
+	defer srv.Close()
+
+	got, err := New(srv.URL, httpclient.Timeouts{}).GetMidpoint(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("GetMidpoint: %v", err)
+	}
+	if got != 555_000 {
+		t.Errorf("got %d, want 555000", got)
+	}
+}
+
+func TestGetPrice(t *testing.T) {
+	prices := map[string]string{SideBuy: "0.56", SideSell: "0.55"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := prices[r.URL.Query().Get("side")]
+		if r.URL.Path != "/price" || r.URL.Query().Get("token_id") != "123" || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"price": "` + p + `"}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, httpclient.Timeouts{})
+	for side, want := range map[string]price.Price{SideBuy: 560_000, SideSell: 550_000} {
+		got, err := c.GetPrice(context.Background(), "123", side)
+		if err != nil {
+			t.Fatalf("GetPrice(%s): %v", side, err)
+		}
+		if got != want {
+			t.Errorf("GetPrice(%s) = %d, want %d", side, got, want)
+		}
+	}
+
+	if _, err := c.GetPrice(context.Background(), "unknown", SideBuy); err == nil {
+		t.Error("expected an error for a 404 response")
+	}
+}
+
+func TestGetAllMarketsSendsUpdatedSince(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query().Get("updated_since"))
+		_, _ = w.Write([]byte(`{"limit": 1, "count": 1, "data": [{"condition_id": "0x1"}]}`))
+	}))
+	defer srv.Close()
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := New(srv.URL, httpclient.Timeouts{}).GetAllMarkets(context.Background(), since); err != nil {
+		t.Fatalf("GetAllMarkets: %v", err)
+	}
+	if _, err := New(srv.URL, httpclient.Timeouts{}).GetAllMarkets(context.Background(), time.Time{}); err != nil {
+		t.Fatalf("GetAllMarkets: %v", err)
+	}
+
+	want := []string{"2026-01-02T03:04:05Z", ""}
+	if !slices.Equal(got, want) {
+		t.Errorf("updated_since = %q, want %q", got, want)
+	}
+}
+
+func TestGetMarketByConditionIDDecodesTradingParams(t *testing.T) {
+	const payload = `{
+		"enable_order_book": true,
+		"active": true,
+		"closed": false,
+		"accepting_orders": true,
+		"minimum_order_size": 5,
+		"minimum_tick_size": 0.001,
+		"condition_id": "0xabc",
+		"question_id": "0xdef",
+		"question": "Will it rain tomorrow?",
+		"description": "Resolves YES if it rains.",
+		"market_slug": "will-it-rain-tomorrow",
+		"end_date_iso": "2026-12-31T00:00:00Z",
+		"seconds_delay": 0,
+		"maker_base_fee": 0,
+		"taker_base_fee": 200,
+		"neg_risk": false,
+		"tokens": [
+			{"token_id": "111", "outcome": "Yes", "price": 0.535, "winner": false},
+			{"token_id": "222", "outcome": "No", "price": 0.465, "winner": false}
+		],
+		"tags": ["Weather"]
+	}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	m, err := New(srv.URL, httpclient.Timeouts{}).GetMarketByConditionID(context.Background(), "0xabc")
+	if err != nil {
+		t.Fatalf("GetMarketByConditionID: %v", err)
+	}
+	if m.MinimumTickSize != 1_000 {
+		t.Errorf("MinimumTickSize = %d, want 1000", m.MinimumTickSize)
+	}
+	if m.MinimumOrderSize != 5_000_000 {
+		t.Errorf("MinimumOrderSize = %d, want 5000000", m.MinimumOrderSize)
+	}
+	if m.MakerBaseFee != 0 || m.TakerBaseFee != 200 {
+		t.Errorf("fees = %d/%d bps, want 0/200", m.MakerBaseFee, m.TakerBaseFee)
+	}
+	if len(m.Tokens) != 2 || m.Tokens[0].Price != 535_000 {
+		t.Errorf("tokens = %+v, want 2 with the first at 535000", m.Tokens)
+	}
+}
+
+// A token price of "0.5" must parse as half of price.PriceScale. Dropping the
+// decimal point instead would give 5_000_000.
+func TestMarketTokenPriceScale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"limit": 1, "count": 1, "data": [{"condition_id": "0x1", "tokens": [
+			{"token_id": "111", "outcome": "Yes", "price": "0.5"},
+			{"token_id": "222", "outcome": "No", "price": 0.5}
+		]}]}`))
+	}))
+	defer srv.Close()
+
+	markets, err := New(srv.URL, httpclient.Timeouts{}).GetAllMarkets(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("GetAllMarkets: %v", err)
+	}
+	if len(markets) != 1 || len(markets[0].Tokens) != 2 {
+		t.Fatalf("got %+v, want one market with two tokens", markets)
+	}
+	for _, token := range markets[0].Tokens {
+		if token.Price != 500_000 {
+			t.Errorf("token %s price = %d, want 500000", token.TokenID, token.Price)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package polymarket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/clob"
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+)
+
+// DetailsSource is the API market details were built from.
+type DetailsSource string
+
+const (
+	DetailsSourceCLOB  DetailsSource = "clob"
+	DetailsSourceGamma DetailsSource = "gamma"
+)
+
+// MarketDetails describes a market and its tokens.
+type MarketDetails struct {
+	ConditionID string
+	Question    string
+	Description string
+	EndDateISO  string
+	Tokens      []TokenDetails
+	Source      DetailsSource
+}
+
+// TokenDetails is one outcome token of a market.
+type TokenDetails struct {
+	TokenID string
+	Outcome string
+}
+
+// GetMarketDetails returns the details of a market from the CLOB API. Right
+// after a market is created Gamma may list it before the CLOB API does, so
+// when the CLOB API doesn't know the market the details are built from Gamma
+// alone and Source is DetailsSourceGamma.
+func (p *Polymarket) GetMarketDetails(ctx context.Context, conditionID string) (*MarketDetails, error) {
+	market, err := p.clob.GetMarketByConditionID(ctx, conditionID)
+	if err == nil {
+		return clobDetails(market), nil
+	}
+	if !errors.Is(err, clob.ErrNotFound) {
+		return nil, err
+	}
+
+	gammaMarket, gammaErr := p.gamma.GetMarketByConditionID(ctx, conditionID)
+	if gammaErr != nil {
+		return nil, fmt.Errorf("%w, gamma fallback: %w", err, gammaErr)
+	}
+	return gammaDetails(gammaMarket)
+}
+
+func clobDetails(m *clob.Market) *MarketDetails {
+	details := &MarketDetails{
+		ConditionID: m.ConditionID,
+		Question:    m.Question,
+		Description: m.Description,
+		EndDateISO:  m.EndDateISO,
+		Tokens:      make([]TokenDetails, 0, len(m.Tokens)),
+		Source:      DetailsSourceCLOB,
+	}
+	for _, t := range m.Tokens {
+		details.Tokens = append(details.Tokens, TokenDetails{TokenID: t.TokenID, Outcome: t.Outcome})
+	}
+	return details
+}
+
+// gammaDetails builds market details from a Gamma market, pairing its
+// outcomes with its CLOB token IDs.
+func gammaDetails(m *gamma.Market) (*MarketDetails, error) {
+	outcomes, err := m.OutcomeNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(outcomes) != len(m.ClobTokenIDs) {
+		return nil, fmt.Errorf("market %s has %d outcomes but %d tokens", m.ConditionID, len(outcomes), len(m.ClobTokenIDs))
+	}
+
+	details := &MarketDetails{
+		ConditionID: m.ConditionID,
+		Question:    m.Question,
+		Tokens:      make([]TokenDetails, 0, len(outcomes)),
+		Source:      DetailsSourceGamma,
+	}
+	for i, outcome := range outcomes {
+		details.Tokens = append(details.Tokens, TokenDetails{TokenID: m.ClobTokenIDs[i], Outcome: outcome})
+	}
+	return details, nil
+}
@@ -0,0 +1,69 @@
+package polymarket
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+)
+
+func TestGetMarketDetailsFallsBackToGamma(t *testing.T) {
+	clobSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer clobSrv.Close()
+	gammaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("condition_ids") != "0xabc" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"conditionId": "0xabc", "question": "Will it rain?",
+			"outcomes": "[\"Yes\", \"No\"]", "clobTokenIds": "[\"111\", \"222\"]"}]`))
+	}))
+	defer gammaSrv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{ClobURL: clobSrv.URL, GammaURL: gammaSrv.URL}, nil, engine.New(engine.Config{}, logger), logger)
+
+	details, err := p.GetMarketDetails(context.Background(), "0xabc")
+	if err != nil {
+		t.Fatalf("GetMarketDetails: %v", err)
+	}
+	if details.Source != DetailsSourceGamma {
+		t.Errorf("source = %q, want %q", details.Source, DetailsSourceGamma)
+	}
+	if details.Question != "Will it rain?" {
+		t.Errorf("question = %q", details.Question)
+	}
+	want := []TokenDetails{{TokenID: "111", Outcome: "Yes"}, {TokenID: "222", Outcome: "No"}}
+	if !slices.Equal(details.Tokens, want) {
+		t.Errorf("tokens = %+v, want %+v", details.Tokens, want)
+	}
+
+	if _, err := p.GetMarketDetails(context.Background(), "0xunknown"); err == nil {
+		t.Error("GetMarketDetails of a market neither API knows succeeded")
+	}
+}
+
+func TestGetMarketDetailsPrefersCLOB(t *testing.T) {
+	clobSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"condition_id": "0xabc", "question": "Will it rain?",
+			"tokens": [{"outcome": "Yes", "token_id": "111"}, {"outcome": "No", "token_id": "222"}]}`))
+	}))
+	defer clobSrv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{ClobURL: clobSrv.URL, GammaURL: "http://gamma.invalid"}, nil, engine.New(engine.Config{}, logger), logger)
+
+	details, err := p.GetMarketDetails(context.Background(), "0xabc")
+	if err != nil {
+		t.Fatalf("GetMarketDetails: %v", err)
+	}
+	if details.Source != DetailsSourceCLOB || len(details.Tokens) != 2 {
+		t.Errorf("details = %+v, want the CLOB market", details)
+	}
+}
@@ -0,0 +1,71 @@
+package polymarket
+
+import (
+	"context"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// enrichMarkets fills in the market details only Gamma has for the given
+// markets and, along with them, for those an earlier sync couldn't enrich.
+// Gamma failing doesn't fail the sync: the CLOB markets are already stored,
+// so they are flagged as needing enrichment and tracked as usual.
+func (p *Polymarket) enrichMarkets(ctx context.Context, conditionIDs []string) {
+	if len(conditionIDs) == 0 {
+		return
+	}
+
+	pending, err := p.store.GetMarketsNeedingEnrichment(ctx, platformName)
+	if err != nil {
+		p.log.Warn("couldn't get markets needing enrichment", "error", err)
+	}
+	ids := make([]string, 0, len(conditionIDs)+len(pending))
+	ids = append(ids, conditionIDs...)
+	for _, m := range pending {
+		ids = append(ids, m.ID)
+	}
+
+	markets, err := p.gamma.GetAllMarkets(ctx)
+	if err != nil {
+		p.log.Warn("gamma unavailable, skipping market enrichment", "markets", len(conditionIDs), "error", err)
+		if err := p.store.MarkMarketsNeedEnrichment(ctx, conditionIDs); err != nil {
+			p.log.Warn("couldn't flag markets for enrichment", "error", err)
+		}
+		return
+	}
+
+	enriched := 0
+	for _, arg := range enrichmentParams(ids, markets) {
+		if err := p.store.SetMarketEnrichment(ctx, arg); err != nil {
+			p.log.Warn("couldn't enrich market", "market_id", arg.ID, "error", err)
+			continue
+		}
+		enriched++
+	}
+	p.log.Debug("enriched markets", "count", enriched, "pending", len(pending))
+}
+
+// enrichmentParams returns the Gamma details of each market in conditionIDs
+// that Gamma lists.
+func enrichmentParams(conditionIDs []string, markets []*gamma.Market) []store.SetMarketEnrichmentParams {
+	byConditionID := make(map[string]*gamma.Market, len(markets))
+	for _, m := range markets {
+		byConditionID[m.ConditionID] = m
+	}
+
+	params := make([]store.SetMarketEnrichmentParams, 0, len(conditionIDs))
+	for _, id := range conditionIDs {
+		m, ok := byConditionID[id]
+		if !ok {
+			continue
+		}
+		params = append(params, store.SetMarketEnrichmentParams{
+			ID:       id,
+			Question: pgtype.Text{String: m.Question, Valid: m.Question != ""},
+			Slug:     pgtype.Text{String: m.Slug, Valid: m.Slug != ""},
+		})
+	}
+	return params
+}
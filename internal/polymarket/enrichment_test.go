@@ -0,0 +1,74 @@
+package polymarket
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestEnrichmentParams(t *testing.T) {
+	markets := []*gamma.Market{
+		{ConditionID: "0xa", Question: "Will A happen?", Slug: "will-a-happen"},
+		{ConditionID: "0xb", Question: "Will B happen?"},
+		{ConditionID: "0xc", Question: "Not synced"},
+	}
+
+	got := enrichmentParams([]string{"0xa", "0xb", "0xmissing"}, markets)
+	if len(got) != 2 {
+		t.Fatalf("got %d params, want 2: %+v", len(got), got)
+	}
+	if got[0].ID != "0xa" || got[0].Question.String != "Will A happen?" || got[0].Slug.String != "will-a-happen" {
+		t.Errorf("params for 0xa = %+v", got[0])
+	}
+	if got[1].ID != "0xb" || got[1].Slug.Valid {
+		t.Errorf("params for 0xb = %+v, want no slug", got[1])
+	}
+}
+
+func TestSyncTracksMarketsWhenGammaDown(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	conditionID := "gamma-down"
+	tokenID := "gamma-down-token"
+
+	clobSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"limit": 1, "count": 1, "data": [{"condition_id": %q, "description": "test market",
+			"tokens": [{"outcome": "Yes", "token_id": %q}]}]}`, conditionID, tokenID)
+	}))
+	defer clobSrv.Close()
+	gammaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer gammaSrv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{ClobURL: clobSrv.URL, GammaURL: gammaSrv.URL}, s, engine.New(engine.Config{}, logger), logger)
+
+	if err := p.syncMarkets(ctx); err != nil {
+		t.Fatalf("sync with gamma down: %v", err)
+	}
+
+	tokens, err := s.GetTokensByMarket(ctx, conditionID)
+	if err != nil {
+		t.Fatalf("get tokens: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != tokenID {
+		t.Errorf("tokens = %+v, want the CLOB token to be tracked", tokens)
+	}
+
+	market, err := s.GetMarket(ctx, conditionID)
+	if err != nil {
+		t.Fatalf("get market: %v", err)
+	}
+	if !market.NeedsEnrichment {
+		t.Error("market synced while gamma was down must need enrichment")
+	}
+}
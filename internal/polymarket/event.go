@@ -0,0 +1,115 @@
+package polymarket
+
+import (
+	"strings"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// BookReader gives read access to the current order book of a token.
+// It is implemented by *engine.Client.
+type BookReader interface {
+	Snapshot(platform, tokenID string, depth int) (engine.Snapshot, bool)
+}
+
+// ImpliedFrom selects which price of the YES book is used as the implied
+// probability of a market.
+type ImpliedFrom int
+
+const (
+	// ImpliedFromAsk uses the best ask, i.e. the cost of buying YES.
+	ImpliedFromAsk ImpliedFrom = iota
+	// ImpliedFromMid uses the midpoint between best bid and best ask.
+	ImpliedFromMid
+)
+
+// MarketProbability is the implied probability of one market of an event.
+type MarketProbability struct {
+	MarketID    string
+	TokenID     string
+	Probability price.Price
+	OK          bool // false if the YES book has no usable quote
+}
+
+// EventProbabilities sums the implied probabilities of the markets of a
+// multi-outcome event, e.g. one market per candidate of an election. Their
+// sum should be close to 1.
+type EventProbabilities struct {
+	Markets []MarketProbability
+	Sum     price.Price
+	// Complete is false if any market had no usable quote, in which case Sum
+	// only covers the quoted markets and Mispriced is never set.
+	Complete bool
+	// Mispriced is set when Sum deviates from 1 by more than the threshold,
+	// which is an arbitrage signal within the platform.
+	Mispriced bool
+}
+
+// ImpliedEventProbabilities computes the implied probability of every market
+// of an event from the YES books in books and flags the event when their sum
+// deviates from 1 by more than threshold.
+func ImpliedEventProbabilities(event *gamma.Event, books BookReader, from ImpliedFrom, threshold price.Price) EventProbabilities {
+	result := EventProbabilities{
+		Markets:  make([]MarketProbability, 0, len(event.Markets)),
+		Complete: true,
+	}
+
+	for _, m := range event.Markets {
+		mp := MarketProbability{
+			MarketID: m.ConditionID,
+			TokenID:  yesTokenID(m),
+		}
+		if snap, ok := books.Snapshot(platformName, mp.TokenID, 1); ok {
+			mp.Probability, mp.OK = impliedProbability(snap, from)
+		}
+
+		if mp.OK {
+			result.Sum += mp.Probability
+		} else {
+			result.Complete = false
+		}
+		result.Markets = append(result.Markets, mp)
+	}
+
+	deviation := result.Sum - price.Price(price.PriceScale)
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	result.Mispriced = result.Complete && deviation > threshold
+
+	return result
+}
+
+func impliedProbability(snap engine.Snapshot, from ImpliedFrom) (price.Price, bool) {
+	if len(snap.Asks) == 0 {
+		return 0, false
+	}
+	bestAsk := snap.Asks[0].Price
+	if from == ImpliedFromAsk {
+		return bestAsk, true
+	}
+
+	if len(snap.Bids) == 0 {
+		return 0, false
+	}
+	return (snap.Bids[0].Price + bestAsk) / 2, true
+}
+
+// yesTokenID returns the CLOB token of the "Yes" outcome, falling back to the
+// first token when the outcomes can't be matched.
+func yesTokenID(m *gamma.Market) string {
+	if len(m.ClobTokenIDs) == 0 {
+		return ""
+	}
+
+	if outcomes, err := m.OutcomeNames(); err == nil {
+		for i, outcome := range outcomes {
+			if strings.EqualFold(strings.TrimSpace(outcome), "yes") && i < len(m.ClobTokenIDs) {
+				return m.ClobTokenIDs[i]
+			}
+		}
+	}
+	return m.ClobTokenIDs[0]
+}
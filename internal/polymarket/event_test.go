@@ -0,0 +1,72 @@
+package polymarket
+
+import (
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/engine/orderbook"
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+type fakeBooks map[string]engine.Snapshot
+
+func (f fakeBooks) Snapshot(_, tokenID string, _ int) (engine.Snapshot, bool) {
+	snap, ok := f[tokenID]
+	return snap, ok
+}
+
+func book(bid, ask price.Price) engine.Snapshot {
+	return engine.Snapshot{
+		Bids: []orderbook.Level{{Price: bid, Size: 1}},
+		Asks: []orderbook.Level{{Price: ask, Size: 1}},
+	}
+}
+
+func TestImpliedEventProbabilities(t *testing.T) {
+	event := &gamma.Event{Markets: []*gamma.Market{
+		{ConditionID: "alice", Outcomes: `["Yes", "No"]`, ClobTokenIDs: gamma.TokenIDs{"alice-yes", "alice-no"}},
+		{ConditionID: "bob", Outcomes: `["No", "Yes"]`, ClobTokenIDs: gamma.TokenIDs{"bob-no", "bob-yes"}},
+		{ConditionID: "carol", Outcomes: `["Yes", "No"]`, ClobTokenIDs: gamma.TokenIDs{"carol-yes", "carol-no"}},
+	}}
+	books := fakeBooks{
+		"alice-yes": book(480_000, 500_000),
+		"bob-yes":   book(280_000, 300_000),
+		"carol-yes": book(100_000, 120_000),
+		// NO books must be ignored.
+		"bob-no": book(900_000, 950_000),
+	}
+
+	got := ImpliedEventProbabilities(event, books, ImpliedFromAsk, 50_000)
+	if !got.Complete {
+		t.Fatal("expected a complete result")
+	}
+	if got.Sum != 920_000 {
+		t.Errorf("ask sum = %d, want 920000", got.Sum)
+	}
+	if !got.Mispriced {
+		t.Error("a sum of 0.92 deviates by more than 0.05 and must be flagged")
+	}
+	if got.Markets[1].TokenID != "bob-yes" || got.Markets[1].Probability != 300_000 {
+		t.Errorf("bob = %+v, want the YES token priced at 0.3", got.Markets[1])
+	}
+
+	got = ImpliedEventProbabilities(event, books, ImpliedFromMid, 100_000)
+	if got.Sum != 890_000 {
+		t.Errorf("mid sum = %d, want 890000", got.Sum)
+	}
+	if !got.Mispriced {
+		t.Error("a sum of 0.89 deviates by more than 0.1 and must be flagged")
+	}
+
+	got = ImpliedEventProbabilities(event, books, ImpliedFromAsk, 100_000)
+	if got.Mispriced {
+		t.Error("a sum of 0.92 is within 0.1 and must not be flagged")
+	}
+
+	delete(books, "carol-yes")
+	got = ImpliedEventProbabilities(event, books, ImpliedFromAsk, 0)
+	if got.Complete || got.Mispriced || got.Markets[2].OK {
+		t.Errorf("missing book must make the result incomplete and unflagged: %+v", got)
+	}
+}
@@ -2,9 +2,13 @@
 package gamma
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
 
 	"github.com/daszybak/prediction_markets/pkg/httpclient"
 )
@@ -14,9 +18,9 @@ type Client struct {
 	baseURL    string
 }
 
-func New(baseURL string) *Client {
+func New(baseURL string, timeouts httpclient.Timeouts) *Client {
 	return &Client{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(timeouts),
 		baseURL:    baseURL,
 	}
 }
@@ -34,11 +38,22 @@ func (t *TokenIDs) UnmarshalJSON(data []byte) error {
 
 type Market struct {
 	ID           string   `json:"id"`
-	ConditionID  string   `json:"condition_id"`
+	ConditionID  string   `json:"conditionId"`
 	Question     string   `json:"question"`
 	Slug         string   `json:"slug"`
 	Outcomes     string   `json:"outcomes"`
 	ClobTokenIDs TokenIDs `json:"clobTokenIds"`
+	Volume24hr   float64  `json:"volume24hr"` // Only compared to tier thresholds, float64 is precise enough.
+}
+
+// OutcomeNames decodes Outcomes, a JSON array in a string like ClobTokenIDs.
+// The outcomes are in the order of ClobTokenIDs.
+func (m *Market) OutcomeNames() ([]string, error) {
+	var outcomes []string
+	if err := json.Unmarshal([]byte(m.Outcomes), &outcomes); err != nil {
+		return nil, fmt.Errorf("couldn't decode outcomes of market %s: %w", m.ConditionID, err)
+	}
+	return outcomes, nil
 }
 
 type Event struct {
@@ -46,10 +61,51 @@ type Event struct {
 	Markets []*Market `json:"markets"`
 }
 
-func (c *Client) GetMarkets() ([]*Market, error) {
-	return httpclient.GetResource[[]*Market](c.httpClient, c.baseURL, "/markets", []int{200})
+func (c *Client) GetMarkets(ctx context.Context) ([]*Market, error) {
+	return httpclient.GetResource[[]*Market](ctx, c.httpClient, c.baseURL, "/markets", []int{200})
+}
+
+// marketsPageSize is the page size used by GetAllMarkets.
+const marketsPageSize = 500
+
+// GetAllMarkets pages through all open markets.
+func (c *Client) GetAllMarkets(ctx context.Context) ([]*Market, error) {
+	var markets []*Market
+	for offset := 0; ; offset += marketsPageSize {
+		query := url.Values{
+			"closed": {"false"},
+			"limit":  {strconv.Itoa(marketsPageSize)},
+			"offset": {strconv.Itoa(offset)},
+		}
+		page, err := httpclient.GetResource[[]*Market](ctx, c.httpClient, c.baseURL, "/markets?"+query.Encode(), []int{200})
+		if err != nil {
+			return markets, fmt.Errorf("couldn't get markets at offset %d: %w", offset, err)
+		}
+		markets = append(markets, page...)
+		if len(page) < marketsPageSize {
+			return markets, nil
+		}
+	}
+}
+
+// ErrNotFound is returned when Gamma doesn't list a market.
+var ErrNotFound = errors.New("market not found")
+
+// GetMarketByConditionID returns the market with the given condition ID.
+func (c *Client) GetMarketByConditionID(ctx context.Context, conditionID string) (*Market, error) {
+	query := url.Values{"condition_ids": {conditionID}}
+	markets, err := httpclient.GetResource[[]*Market](ctx, c.httpClient, c.baseURL, "/markets?"+query.Encode(), []int{200})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get market by condition ID %s: %w", conditionID, err)
+	}
+	for _, m := range markets {
+		if m.ConditionID == conditionID {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: condition ID %s", ErrNotFound, conditionID)
 }
 
-func (c *Client) GetEventBySlug(slug string) (*Event, error) {
-	return httpclient.GetResource[*Event](c.httpClient, c.baseURL, "/events/slug/"+slug, []int{200})
+func (c *Client) GetEventBySlug(ctx context.Context, slug string) (*Event, error) {
+	return httpclient.GetResource[*Event](ctx, c.httpClient, c.baseURL, "/events/slug/"+slug, []int{200})
 }
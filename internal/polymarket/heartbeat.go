@@ -0,0 +1,56 @@
+package polymarket
+
+import (
+	"context"
+	"time"
+)
+
+// maxSilenceCheckInterval bounds how often the connection is checked for
+// silence.
+const maxSilenceCheckInterval = 5 * time.Second
+
+// silenceLoop checks the connection for silence until ctx is cancelled.
+func (p *Polymarket) silenceLoop(ctx context.Context) {
+	ticker := time.NewTicker(min(maxSilenceCheckInterval, p.config.Websocket.SilenceTimeout/2))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			p.checkSilence(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkSilence marks the connection unhealthy when no frame arrived for
+// longer than SilenceTimeout and closes it, which makes the read loop
+// reconnect. The server's heartbeats stopping means the feed is dead even if
+// the TCP connection is still up.
+func (p *Polymarket) checkSilence(now time.Time) {
+	ws := p.conn()
+	silence := now.Sub(ws.LastFrame())
+	if silence <= p.config.Websocket.SilenceTimeout {
+		p.healthy.Store(true)
+		return
+	}
+
+	if p.healthy.Swap(false) {
+		p.log.Warn("no frames received, reconnecting websocket", "silence", silence)
+	}
+	_ = ws.ForceClose()
+}
+
+// Healthy reports whether the websocket received a frame within
+// SilenceTimeout at the last check. It is always true if SilenceTimeout is 0.
+func (p *Polymarket) Healthy() bool {
+	return p.healthy.Load()
+}
+
+// NoTokensSubscribed reports whether the last sync left no tokens to
+// subscribe to, e.g. because the token filter excluded all of them. The
+// connection can be healthy while nothing is subscribed.
+func (p *Polymarket) NoTokensSubscribed() bool {
+	return p.noTokens.Load()
+}
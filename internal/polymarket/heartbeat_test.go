@@ -0,0 +1,66 @@
+package polymarket
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+)
+
+func TestCheckSilenceFlipsHealth(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Stay silent until the client goes away.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{
+		Websocket: Websocket{
+			URL:            "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint: "/ws/market",
+			SilenceTimeout: time.Minute,
+		},
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+
+	p.checkSilence(time.Now())
+	if !p.Healthy() {
+		t.Fatal("unhealthy right after connecting")
+	}
+
+	p.checkSilence(ws.LastFrame().Add(2 * time.Minute))
+	if p.Healthy() {
+		t.Error("healthy after two minutes of silence")
+	}
+	// The silent connection is closed so the read loop reconnects.
+	readCtx, readCancel := context.WithTimeout(ctx, time.Second)
+	defer readCancel()
+	if _, err := ws.ReadMessage(readCtx); err == nil || readCtx.Err() != nil {
+		t.Errorf("ReadMessage on the silent connection = %v, want a read error", err)
+	}
+}
@@ -0,0 +1,48 @@
+package polymarket
+
+import (
+	"context"
+	"time"
+)
+
+// maxIdleSweepInterval bounds how often idle tokens are looked for.
+const maxIdleSweepInterval = time.Minute
+
+// idleLoop unsubscribes from idle tokens until ctx is cancelled.
+func (p *Polymarket) idleLoop(ctx context.Context) {
+	ticker := time.NewTicker(min(maxIdleSweepInterval, p.config.IdleUnsubscribeAfter))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			p.sweepIdle(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sweepIdle unsubscribes from the tokens that had no message for longer than
+// IdleUnsubscribeAfter and removes their books from the engine. The next
+// market sync subscribes to them again.
+func (p *Polymarket) sweepIdle(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := p.activity.idle(p.subscribedTokens.AsSlice(), now.Add(-p.config.IdleUnsubscribeAfter))
+	if len(idle) == 0 {
+		return
+	}
+
+	if err := p.ws.UnsubscribeMarket(ctx, idle); err != nil {
+		p.log.Warn("couldn't unsubscribe from idle tokens", "count", len(idle), "error", err)
+		return
+	}
+	for _, id := range idle {
+		p.subscribedTokens.Delete(id)
+		p.engine.RemoveToken(platformName, id)
+	}
+	p.activity.forget(idle)
+	p.log.Info("unsubscribed from idle tokens", "count", len(idle), "idle_after", p.config.IdleUnsubscribeAfter)
+}
@@ -0,0 +1,162 @@
+package polymarket
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/pkg/hashset"
+)
+
+func TestSweepIdleRemovesIdleTokens(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	unsubscribed := make(chan websocket.SubscriptionUpdate, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var update websocket.SubscriptionUpdate
+		if err := conn.ReadJSON(&update); err == nil {
+			unsubscribed <- update
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	e := engine.New(engine.Config{}, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Start(ctx)
+
+	p := New(Config{
+		Websocket:            Websocket{URL: "ws" + strings.TrimPrefix(srv.URL, "http"), MarketEndpoint: "/ws/market"},
+		IdleUnsubscribeAfter: time.Hour,
+	}, nil, e, logger)
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+
+	for _, id := range []string{"idle", "active"} {
+		e.Send(engine.Update{Platform: platformName, TokenID: id, Price: 500_000, Size: 1, Side: "bids"})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, idleOK := e.Snapshot(platformName, "idle", 1)
+		_, activeOK := e.Snapshot(platformName, "active", 1)
+		if idleOK && activeOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("workers never created")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	now := time.Now()
+	p.subscribedTokens = hashset.SetFromSlice([]string{"idle", "active"})
+	p.activity.subscribed([]string{"idle", "active"}, now.Add(-3*time.Hour))
+	p.activity.observe("idle", now.Add(-2*time.Hour))
+	p.activity.observe("active", now.Add(-time.Minute))
+
+	p.sweepIdle(ctx, now)
+
+	select {
+	case update := <-unsubscribed:
+		if update.Operation != "unsubscribe" || !slices.Equal(update.AssetsIDs, []string{"idle"}) {
+			t.Errorf("sent %+v, want an unsubscribe from [idle]", update)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no unsubscribe sent")
+	}
+	if _, ok := e.Snapshot(platformName, "idle", 1); ok {
+		t.Error("idle token's worker must be removed")
+	}
+	if _, ok := e.Snapshot(platformName, "active", 1); !ok {
+		t.Error("active token's worker must be kept")
+	}
+	if p.subscribedTokens.Has("idle") || !p.subscribedTokens.Has("active") {
+		t.Errorf("subscribed tokens = %v, want only active", p.subscribedTokens.AsSlice())
+	}
+}
+
+func TestTokenActivityIdleUsesSubscriptionTime(t *testing.T) {
+	a := newTokenActivity()
+	now := time.Now()
+
+	a.subscribed([]string{"quiet"}, now.Add(-2*time.Hour))
+	// Resubscribing doesn't reset the idle time.
+	a.subscribed([]string{"quiet", "new"}, now)
+
+	got := a.idle([]string{"quiet", "new", "unknown"}, now.Add(-time.Hour))
+	if !slices.Equal(got, []string{"quiet"}) {
+		t.Errorf("idle = %v, want [quiet]", got)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := New(Config{
+		Websocket:            Websocket{URL: "ws" + strings.TrimPrefix(srv.URL, "http"), MarketEndpoint: "/ws/market"},
+		IdleUnsubscribeAfter: time.Hour,
+	}, nil, engine.New(engine.Config{}, logger), logger)
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+
+	if got := p.Subscriptions(); len(got) != 0 {
+		t.Errorf("Subscriptions() before subscribing = %v, want none", got)
+	}
+
+	first := time.Now().Add(-3 * time.Hour)
+	p.setSubscribed(ctx, []string{"b", "a"}, first)
+	second := first.Add(time.Minute)
+	// A sync keeps b, drops a and adds c.
+	p.setSubscribed(ctx, []string{"b", "c"}, second)
+
+	want := []SubscriptionInfo{{TokenID: "b", SubscribedAt: first}, {TokenID: "c", SubscribedAt: second}}
+	if got := p.Subscriptions(); !slices.Equal(got, want) {
+		t.Errorf("Subscriptions() = %v, want %v", got, want)
+	}
+
+	// c has a recent message, b is unsubscribed for being idle.
+	p.activity.observe("c", time.Now())
+	p.sweepIdle(ctx, time.Now())
+
+	want = []SubscriptionInfo{{TokenID: "c", SubscribedAt: second}}
+	if got := p.Subscriptions(); !slices.Equal(got, want) {
+		t.Errorf("Subscriptions() after unsubscribing idle tokens = %v, want %v", got, want)
+	}
+}
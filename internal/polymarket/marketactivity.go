@@ -0,0 +1,115 @@
+package polymarket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/pkg/backoff"
+	"github.com/daszybak/prediction_markets/pkg/ctxutil"
+)
+
+// ActivityCounts are the activity channel events seen for a market since
+// the collector started.
+type ActivityCounts struct {
+	Trades   int64
+	Comments int64
+}
+
+// marketActivity aggregates activity channel events per market.
+type marketActivity struct {
+	mu     sync.Mutex
+	counts map[string]ActivityCounts
+}
+
+func newMarketActivity() *marketActivity {
+	return &marketActivity{counts: make(map[string]ActivityCounts)}
+}
+
+// observe counts the event for its market. Events without a market can't be
+// attributed and are ignored.
+func (ma *marketActivity) observe(a *websocket.Activity) {
+	if a.Payload.ConditionID == "" {
+		return
+	}
+
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	counts := ma.counts[a.Payload.ConditionID]
+	switch a.Topic {
+	case websocket.TopicActivity:
+		counts.Trades++
+	case websocket.TopicComments:
+		counts.Comments++
+	}
+	ma.counts[a.Payload.ConditionID] = counts
+}
+
+// MarketActivity returns the activity counts per market condition ID. It is
+// empty unless the activity handler is configured.
+func (p *Polymarket) MarketActivity() map[string]ActivityCounts {
+	p.marketActivity.mu.Lock()
+	defer p.marketActivity.mu.Unlock()
+	return maps.Clone(p.marketActivity.counts)
+}
+
+// handleActivity is the activity message handler.
+func (p *Polymarket) handleActivity(msg *websocket.Message) error {
+	if msg.Activity != nil {
+		p.marketActivity.observe(msg.Activity)
+	}
+	return nil
+}
+
+// activityLoop reads the activity channel of all markets on its own
+// connection and routes its messages like those of the market channel. A
+// dropped connection is redialed with the same backoff as the market channel.
+// It returns when ctx is cancelled.
+func (p *Polymarket) activityLoop(ctx context.Context) {
+	b := &backoff.Backoff{
+		Initial: p.config.Websocket.ReconnectBaseDelay,
+		Max:     p.config.Websocket.ReconnectMaxDelay,
+		Jitter:  reconnectJitter,
+	}
+	for {
+		err := p.readActivity(ctx, b)
+		if ctx.Err() == nil {
+			p.log.Warn("activity channel dropped, reconnecting", "error", err)
+			_ = ctxutil.Sleep(ctx, b.Next())
+		}
+		if ctx.Err() != nil {
+			p.log.Info("activity channel stopped", "reason", ctx.Err())
+			return
+		}
+	}
+}
+
+// readActivity subscribes to the activity channel on a new connection and
+// routes its messages until reading fails. b is reset once subscribed.
+func (p *Polymarket) readActivity(ctx context.Context, b *backoff.Backoff) error {
+	ws, err := websocket.New(ctx, p.config.Websocket.URL, p.config.Websocket.ActivityEndpoint)
+	if err != nil {
+		return fmt.Errorf("activity connect: %w", err)
+	}
+	defer func() { _ = ws.ForceClose() }()
+
+	if err := ws.SubscribeActivity(ctx, nil); err != nil {
+		return fmt.Errorf("subscribe activity: %w", err)
+	}
+	b.Reset()
+
+	for {
+		msg, err := ws.ReadMessage(ctx)
+		if errors.Is(err, websocket.ErrParse) {
+			p.log.Warn("skipping activity message", "error", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		p.handleMessage(msg)
+	}
+}
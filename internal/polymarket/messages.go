@@ -0,0 +1,146 @@
+package polymarket
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// BookMessage is Polymarket's book event, the full book of a token sent on
+// subscribe and after every trade, as decoded by the websocket client.
+type BookMessage = websocket.Book
+
+// PriceChangeMessage is Polymarket's price_change event, sent when orders
+// are placed or cancelled, as decoded by the websocket client. Its changes
+// can be of several tokens of the market.
+type PriceChangeMessage = websocket.PriceChange
+
+// bookUpdates turns a book message into the updates of an initial dump,
+// setting every level of the token's book.
+func bookUpdates(b *BookMessage) ([]engine.Update, error) {
+	eventTime, err := optionalMillis(b.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	sides := []struct {
+		name   string
+		levels []websocket.OrderSummary
+	}{
+		{"bids", b.Bids},
+		{"asks", b.Asks},
+	}
+	updates := make([]engine.Update, 0, len(b.Bids)+len(b.Asks))
+	for _, side := range sides {
+		for _, l := range side.levels {
+			updates = append(updates, engine.Update{
+				Platform:  platformName,
+				TokenID:   b.AssetID,
+				Price:     l.Price,
+				Size:      l.Size,
+				Side:      side.name,
+				EventTime: eventTime,
+				Dump:      true,
+			})
+		}
+	}
+	return updates, nil
+}
+
+// priceChangeUpdates turns a price_change message into engine updates, one
+// per change, each for the token the change is of. Sizes are the new size of
+// the level rather than a difference, so the updates set the level and a
+// size of 0 removes it.
+func priceChangeUpdates(m *PriceChangeMessage) ([]engine.Update, error) {
+	eventTime, err := optionalMillis(m.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make([]engine.Update, 0, len(m.Changes))
+	for _, c := range m.Changes {
+		side, err := bookSide(c.Side)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, engine.Update{
+			Platform:  platformName,
+			TokenID:   c.AssetID,
+			Price:     c.Price,
+			Size:      c.Size,
+			Side:      side,
+			EventTime: eventTime,
+			ID:        c.Hash,
+		})
+	}
+	return updates, nil
+}
+
+// lastTrade turns a last_trade_price message into an engine trade.
+func lastTrade(m *websocket.LastTradePrice) (engine.Trade, error) {
+	p, err := price.Parse(m.Price)
+	if err != nil {
+		return engine.Trade{}, fmt.Errorf("trade price: %w", err)
+	}
+	size, err := price.Parse(m.Size)
+	if err != nil {
+		return engine.Trade{}, fmt.Errorf("trade size: %w", err)
+	}
+	eventTime, err := parseMillis(m.Timestamp)
+	if err != nil {
+		return engine.Trade{}, err
+	}
+
+	var side string
+	switch m.Side {
+	case "BUY":
+		side = engine.TradeBuy
+	case "SELL":
+		side = engine.TradeSell
+	default:
+		return engine.Trade{}, fmt.Errorf("unknown side %q", m.Side)
+	}
+
+	return engine.Trade{
+		Platform: platformName,
+		TokenID:  m.AssetID,
+		Price:    p,
+		Size:     price.Size(size),
+		Side:     side,
+		Time:     eventTime,
+	}, nil
+}
+
+// parseMillis parses a Unix millisecond timestamp as sent by Polymarket.
+func parseMillis(s string) (time.Time, error) {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("couldn't parse timestamp %q: %w", s, err)
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// optionalMillis is parseMillis, but returns the zero time for frames
+// without a timestamp, which the engine replaces with the current time.
+func optionalMillis(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return parseMillis(s)
+}
+
+// bookSide maps the side of an order to the side of the book it rests on.
+func bookSide(side string) (string, error) {
+	switch side {
+	case "BUY":
+		return "bids", nil
+	case "SELL":
+		return "asks", nil
+	default:
+		return "", fmt.Errorf("unknown side %q", side)
+	}
+}
@@ -0,0 +1,253 @@
+package polymarket
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// bookFrame is a book event as received from the market channel.
+const bookFrame = `{
+	"event_type": "book",
+	"asset_id": "65818619657568813474341868652308942079804919287380422192892211131408793125422",
+	"market": "0xbd31dc8a20211944f6b70f31557f1001557b59905b7738480ca09bd4532f84af",
+	"bids": [{"price": ".48", "size": "30"}, {"price": ".49", "size": "20"}, {"price": ".50", "size": "15.25"}],
+	"asks": [{"price": ".52", "size": "25"}, {"price": ".53", "size": "60"}],
+	"timestamp": "1757908892351",
+	"hash": "0x5c6b1ae0b4a0a1c4e2b2b76f8e0d1f3a9c1c0b7e"
+}`
+
+func TestDecodeBookMessage(t *testing.T) {
+	msg, err := (&websocket.Client{}).ParseMessage([]byte(bookFrame))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	m := msg.Book
+	if msg.EventType != websocket.BookEvent || m == nil {
+		t.Fatalf("parsed %+v, want a book event", msg)
+	}
+	if m.Market != "0xbd31dc8a20211944f6b70f31557f1001557b59905b7738480ca09bd4532f84af" || m.Hash != "0x5c6b1ae0b4a0a1c4e2b2b76f8e0d1f3a9c1c0b7e" {
+		t.Errorf("market, hash = %q, %q", m.Market, m.Hash)
+	}
+
+	updates, err := bookUpdates(m)
+	if err != nil {
+		t.Fatalf("bookUpdates: %v", err)
+	}
+	eventTime := time.UnixMilli(1757908892351)
+	level := func(p price.Price, size price.Size, side string) engine.Update {
+		return engine.Update{Platform: platformName, TokenID: m.AssetID, Price: p, Size: size, Side: side, EventTime: eventTime, Dump: true}
+	}
+	want := []engine.Update{
+		level(480_000, 30_000_000, "bids"),
+		level(490_000, 20_000_000, "bids"),
+		level(500_000, 15_250_000, "bids"),
+		level(520_000, 25_000_000, "asks"),
+		level(530_000, 60_000_000, "asks"),
+	}
+	if !slices.Equal(updates, want) {
+		t.Errorf("bookUpdates() = %+v, want %+v", updates, want)
+	}
+}
+
+// priceChangeFrame is a price_change event as received from the market
+// channel. Its changes are of both tokens of the market.
+const priceChangeFrame = `{
+	"event_type": "price_change",
+	"market": "0x5f65177b394277fd294cd75650044e32ba009a95022d88a0c1d565897d72f8f1",
+	"price_changes": [
+		{"asset_id": "71321045679252212594626385532706912750332728571942532289631379312455583992563", "price": "0.52", "side": "SELL", "size": "0", "hash": "56621a121a47ed9333273e21c83b660cff37ae50"},
+		{"asset_id": "52114319501245915516055106046884209969926127482827954674443846427813813222426", "price": "0.49", "side": "BUY", "size": "12.5", "hash": "1895759e4df7a796bf4f1c5a5950b748306923e2"}
+	],
+	"timestamp": "1757908892351"
+}`
+
+const (
+	yesToken = "71321045679252212594626385532706912750332728571942532289631379312455583992563"
+	noToken  = "52114319501245915516055106046884209969926127482827954674443846427813813222426"
+)
+
+func TestDecodePriceChangeMessage(t *testing.T) {
+	msg, err := (&websocket.Client{}).ParseMessage([]byte(priceChangeFrame))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.EventType != websocket.PriceChangeEvent || msg.PriceChange == nil {
+		t.Fatalf("parsed %+v, want a price_change event", msg)
+	}
+
+	updates, err := priceChangeUpdates(msg.PriceChange)
+	if err != nil {
+		t.Fatalf("priceChangeUpdates: %v", err)
+	}
+	eventTime := time.UnixMilli(1757908892351)
+	want := []engine.Update{
+		{Platform: platformName, TokenID: yesToken, Price: 520_000, Size: 0, Side: "asks", EventTime: eventTime, ID: "56621a121a47ed9333273e21c83b660cff37ae50"},
+		{Platform: platformName, TokenID: noToken, Price: 490_000, Size: 12_500_000, Side: "bids", EventTime: eventTime, ID: "1895759e4df7a796bf4f1c5a5950b748306923e2"},
+	}
+	if !slices.Equal(updates, want) {
+		t.Errorf("priceChangeUpdates() = %+v, want %+v", updates, want)
+	}
+}
+
+func TestPriceChangeUnknownSide(t *testing.T) {
+	m := PriceChangeMessage{
+		Timestamp: "1757908892351",
+		Changes:   []websocket.PriceLevelChange{{AssetID: "a", Price: 500_000, Side: "HOLD", Size: 1}},
+	}
+	if _, err := priceChangeUpdates(&m); err == nil {
+		t.Error("priceChangeUpdates() with an unknown side succeeded, want an error")
+	}
+}
+
+func TestPriceChangeZeroSizeRemovesLevel(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	books := engine.NewInline(logger)
+	p := New(Config{}, nil, books, logger)
+
+	books.Send(engine.Update{Platform: platformName, TokenID: yesToken, Price: 520_000, Size: 25_000_000, Side: "asks", Dump: true})
+	books.Send(engine.Update{Platform: platformName, TokenID: yesToken, Price: 530_000, Size: 60_000_000, Side: "asks", Dump: true})
+
+	msg, err := (&websocket.Client{}).ParseMessage([]byte(priceChangeFrame))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if err := p.processMessage(msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+
+	snap, ok := books.Snapshot(platformName, yesToken, 10)
+	if !ok || len(snap.Asks) != 1 || snap.Asks[0].Price != 530_000 {
+		t.Errorf("asks = %v, want only the level at 530000", snap.Asks)
+	}
+}
+
+func TestLastTradePriceRecordsTrade(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	books := engine.New(engine.Config{}, logger)
+	p := New(Config{}, nil, books, logger)
+
+	msg := &websocket.Message{
+		EventType: websocket.LastTradePriceEvent,
+		LastTradePrice: &websocket.LastTradePrice{
+			AssetID:   "a",
+			Market:    "m",
+			Price:     "0.456",
+			Size:      "219.217767",
+			Side:      "BUY",
+			Timestamp: "1750428146322",
+		},
+	}
+	if err := p.processMessage(msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+
+	trades := books.TakeTrades()
+	want := engine.Trade{
+		Platform: platformName,
+		TokenID:  "a",
+		Price:    456_000,
+		Size:     219_217_767,
+		Side:     engine.TradeBuy,
+		Time:     time.UnixMilli(1750428146322),
+	}
+	if len(trades) != 1 || trades[0] != want {
+		t.Errorf("trades = %+v, want [%+v]", trades, want)
+	}
+}
+
+func TestLastTradePriceUnknownSide(t *testing.T) {
+	_, err := lastTrade(&websocket.LastTradePrice{AssetID: "a", Price: "0.5", Size: "1", Side: "HOLD", Timestamp: "1750428146322"})
+	if err == nil {
+		t.Error("lastTrade accepted an unknown side")
+	}
+}
+
+func TestBookAndPriceChangeUpdateEngine(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	books := engine.NewInline(logger)
+	p := New(Config{}, nil, books, logger)
+
+	book := &websocket.Message{EventType: websocket.BookEvent, Book: &websocket.Book{
+		AssetID:   "a",
+		Timestamp: "1757908892351",
+		Bids:      []websocket.OrderSummary{{Price: 480_000, Size: 30_000_000}, {Price: 490_000, Size: 20_000_000}},
+		Asks:      []websocket.OrderSummary{{Price: 520_000, Size: 25_000_000}},
+	}}
+	change := &websocket.Message{EventType: websocket.PriceChangeEvent, PriceChange: &websocket.PriceChange{
+		Timestamp: "1757908892352",
+		Changes: []websocket.PriceLevelChange{
+			{AssetID: "a", Price: 490_000, Size: 0, Side: "BUY"},
+			{AssetID: "a", Price: 510_000, Size: 12_500_000, Side: "SELL"},
+		},
+	}}
+	for _, msg := range []*websocket.Message{book, change} {
+		if err := p.processMessage(msg); err != nil {
+			t.Fatalf("processMessage(%s): %v", msg.EventType, err)
+		}
+	}
+
+	snap, ok := books.Snapshot(platformName, "a", 10)
+	if !ok {
+		t.Fatal("no book for token a")
+	}
+	if len(snap.Bids) != 1 || snap.Bids[0].Price != 480_000 || snap.Bids[0].Size != 30_000_000 {
+		t.Errorf("bids = %v, want only 30 at 0.48", snap.Bids)
+	}
+	if len(snap.Asks) != 2 || snap.Asks[0].Price != 510_000 || snap.Asks[0].Size != 12_500_000 {
+		t.Errorf("asks = %v, want 12.5 at 0.51 first", snap.Asks)
+	}
+
+	// A new book replaces the old one.
+	book.Book.Bids = nil
+	if err := p.processMessage(book); err != nil {
+		t.Fatalf("processMessage(book): %v", err)
+	}
+	if snap, _ := books.Snapshot(platformName, "a", 10); len(snap.Bids) != 0 || len(snap.Asks) != 1 {
+		t.Errorf("book after new dump = %+v, want only the ask at 0.52", snap)
+	}
+}
+
+func TestReplayedPriceChangeDropped(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	books := engine.NewInline(logger)
+	p := New(Config{}, nil, books, logger)
+	books.Send(engine.Update{Platform: platformName, TokenID: noToken, Price: 480_000, Size: 1_000_000, Side: "bids", Dump: true})
+
+	parse := func(frame string) *websocket.Message {
+		t.Helper()
+		msg, err := (&websocket.Client{}).ParseMessage([]byte(frame))
+		if err != nil {
+			t.Fatalf("ParseMessage: %v", err)
+		}
+		return msg
+	}
+	newer := `{"event_type": "price_change", "market": "m", "timestamp": "1757908892999", "price_changes": [
+		{"asset_id": "` + noToken + `", "price": "0.49", "side": "BUY", "size": "20", "hash": "0xnewer"}]}`
+
+	before := testutil.ToFloat64(metrics.EngineDuplicateUpdates)
+	// The older frame is redelivered after a reconnect, behind a newer one.
+	for _, frame := range []string{priceChangeFrame, newer, priceChangeFrame} {
+		if err := p.processMessage(parse(frame)); err != nil {
+			t.Fatalf("processMessage: %v", err)
+		}
+	}
+
+	snap, _ := books.Snapshot(platformName, noToken, 10)
+	if len(snap.Bids) == 0 || snap.Bids[0].Price != 490_000 || snap.Bids[0].Size != 20_000_000 {
+		t.Errorf("bids = %v, want 20 at 0.49 from the newer frame", snap.Bids)
+	}
+	// Both changes of the replayed frame are dropped.
+	if got := testutil.ToFloat64(metrics.EngineDuplicateUpdates) - before; got != 2 {
+		t.Errorf("duplicates counted = %v, want 2", got)
+	}
+}
@@ -3,152 +3,562 @@ package polymarket
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/daszybak/prediction_markets/internal/engine"
 	"github.com/daszybak/prediction_markets/internal/polymarket/clob"
 	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
 	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/internal/price"
 	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/pkg/backoff"
 	"github.com/daszybak/prediction_markets/pkg/hashset"
+	"github.com/daszybak/prediction_markets/pkg/httpclient"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const platformName = "polymarket"
 
+// errSuspiciousSync is returned by syncMarkets when the API returned fewer
+// markets than expected after previously returning enough.
+var errSuspiciousSync = errors.New("suspiciously few markets returned")
+
+// ErrNoTokens is returned by Start with Config.FailOnNoTokens when a sync
+// leaves no tokens to subscribe to, e.g. because of the token filter.
+var ErrNoTokens = errors.New("no tokens subscribed")
+
+const (
+	defaultMinExpectedMarkets = 1
+	defaultResyncTimeout      = 30 * time.Second
+	defaultReconnectBaseDelay = time.Second
+	defaultReconnectMaxDelay  = 30 * time.Second
+	defaultReducedDepth       = 5
+
+	// reconnectJitter is the fraction of each reconnect delay that is randomized.
+	reconnectJitter = 0.5
+)
+
 type Config struct {
-	ClobURL            string
-	GammaURL           string
+	ClobURL  string
+	GammaURL string
+	// HTTPTimeouts bound connecting to the CLOB and Gamma APIs and waiting for
+	// their responses, but not reading the responses.
+	HTTPTimeouts       httpclient.Timeouts
 	Websocket          Websocket
 	MarketSyncInterval time.Duration
+	// AdaptiveSync, if enabled, adjusts the interval between market syncs
+	// to how many markets change, starting from MarketSyncInterval.
+	AdaptiveSync AdaptiveSyncConfig
+	// MinExpectedMarkets is the number of markets below which a sync is treated
+	// as a soft failure if the previous sync returned at least as many.
+	MinExpectedMarkets int
+	// ResyncTimeout is how long to wait after a reconnect for every token to
+	// receive its initial book snapshot.
+	ResyncTimeout time.Duration
+	// Tiers limits how much of the long tail is subscribed to.
+	Tiers TierConfig
+	// IncrementalSync asks the CLOB API for markets updated since the last
+	// successful sync only. If the API ignores the filter, every sync is full.
+	IncrementalSync bool
+	// Tokens overrides which tokens are subscribed to.
+	Tokens TokenFilter
+	// Handlers names the message handlers, see HandlerNames. Defaults to
+	// DefaultHandlers.
+	Handlers []string
+	// IdleUnsubscribeAfter, if positive, unsubscribes from tokens without a
+	// message for that long and frees their books until the next sync.
+	IdleUnsubscribeAfter time.Duration
+	// MaxMarkets, if positive, caps how many markets a sync stores, taking
+	// them in the order the CLOB API lists them. Meant for ramping up; with
+	// IncrementalSync each sync adds up to MaxMarkets changed markets.
+	// Subscriptions are capped too, to the tokens of the MaxMarkets most
+	// recently synced markets; the tokens of older ones are unsubscribed.
+	MaxMarkets int
+	// LogMessages enables the debug log of every received message. It is
+	// off by default since formatting it is costly at high message rates,
+	// even when debug logging is on for everything else.
+	LogMessages bool
+	// FailOnNoTokens makes Start return ErrNoTokens when a sync leaves no
+	// tokens to subscribe to. Otherwise it is logged, reported by
+	// NoTokensSubscribed and market syncs continue.
+	FailOnNoTokens bool
 }
 
 type Websocket struct {
 	URL            string
 	MarketEndpoint string
+	// A dropped connection is redialed immediately. After a failed attempt the
+	// delay starts at ReconnectBaseDelay and doubles up to ReconnectMaxDelay,
+	// with up to 50% of it randomly taken off.
+	ReconnectBaseDelay time.Duration
+	ReconnectMaxDelay  time.Duration
+	// SilenceTimeout, if positive, is how long the connection may go without
+	// receiving a frame before it's considered dead and redialed.
+	SilenceTimeout time.Duration
+	// ActivityEndpoint is the endpoint of the activity channel, read on a
+	// connection of its own if HandlerActivity is configured.
+	ActivityEndpoint string
+	// SubscribeConfirmTimeout, if positive, is how long a market sync waits
+	// for a frame after subscribing. The sync fails if none arrives or the
+	// server rejects the subscription.
+	SubscribeConfirmTimeout time.Duration
 }
 
 type Polymarket struct {
-	config           Config
-	store            *store.Store
-	log              *slog.Logger
+	config Config
+	store  *store.Store
+	engine *engine.Client
+	log    *slog.Logger
+
+	// mu guards ws, which is replaced on reconnect, and subscribedTokens.
+	// It is also held while writing to ws so writes don't interleave.
+	mu               sync.Mutex
+	ws               *websocket.Client
 	subscribedTokens hashset.Set[string]
+	resync           atomic.Pointer[resyncTracker]
+	lastMarketCount  int       // Only accessed by the sync loop.
+	lastSyncAt       time.Time // Only accessed by the sync loop.
+	// churn counts new and changed markets; lastChurn is the count of the
+	// last successful sync. Only accessed by the sync loop.
+	churn     *marketChurn
+	lastChurn int
+
+	router   *MessageRouter
+	activity *tokenActivity
+	ticks    *tickSizes
+	healthy  atomic.Bool // See Healthy.
+	noTokens atomic.Bool // See NoTokensSubscribed.
+
+	// marketActivity counts activity channel events, see MarketActivity.
+	marketActivity *marketActivity
 
 	clob  *clob.Client
 	gamma *gamma.Client
-	ws    *websocket.Client
 }
 
 // New creates a Polymarket client. Call Start() to connect.
-func New(cfg Config, s *store.Store, log *slog.Logger) *Polymarket {
-	return &Polymarket{
-		config: cfg,
-		store:  s,
-		log:    log.With("component", platformName),
-		clob:   clob.New(cfg.ClobURL),
-		gamma:  gamma.New(cfg.GammaURL),
+func New(cfg Config, s *store.Store, e *engine.Client, log *slog.Logger) *Polymarket {
+	if cfg.MinExpectedMarkets <= 0 {
+		cfg.MinExpectedMarkets = defaultMinExpectedMarkets
+	}
+	if cfg.ResyncTimeout <= 0 {
+		cfg.ResyncTimeout = defaultResyncTimeout
+	}
+	if cfg.Websocket.ReconnectBaseDelay <= 0 {
+		cfg.Websocket.ReconnectBaseDelay = defaultReconnectBaseDelay
+	}
+	if cfg.Websocket.ReconnectMaxDelay <= 0 {
+		cfg.Websocket.ReconnectMaxDelay = defaultReconnectMaxDelay
 	}
+	if cfg.Tiers.ReducedDepth <= 0 {
+		cfg.Tiers.ReducedDepth = defaultReducedDepth
+	}
+	if len(cfg.Handlers) == 0 {
+		cfg.Handlers = DefaultHandlers
+	}
+
+	p := &Polymarket{
+		config:           cfg,
+		store:            s,
+		engine:           e,
+		log:              log.With("component", platformName),
+		subscribedTokens: hashset.NewSet[string](),
+		activity:         newTokenActivity(),
+		marketActivity:   newMarketActivity(),
+		ticks:            newTickSizes(),
+		churn:            newMarketChurn(),
+		clob:             clob.New(cfg.ClobURL, cfg.HTTPTimeouts),
+		gamma:            gamma.New(cfg.GammaURL, cfg.HTTPTimeouts),
+	}
+
+	router, err := NewMessageRouter(cfg.Handlers, p.messageHandlers())
+	if err != nil {
+		p.log.Error("invalid message handlers, using the defaults", "handlers", cfg.Handlers, "error", err)
+		router, _ = NewMessageRouter(DefaultHandlers, p.messageHandlers())
+	}
+	p.router = router
+	p.healthy.Store(true)
+	return p
 }
 
 // Start connects the websocket and begins reading messages.
-// This method blocks until ctx is cancelled.
+// This method blocks until ctx is cancelled, or with Config.FailOnNoTokens
+// until a sync leaves no tokens to subscribe to.
 func (p *Polymarket) Start(ctx context.Context) error {
-	p.log.Info("starting")
+	p.log.Info("starting", "handlers", p.router.Handlers())
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-	// Connect websocket
-	ws, err := websocket.New(ctx, p.config.Websocket.URL, p.config.Websocket.MarketEndpoint)
+	ws, err := p.dial(ctx)
 	if err != nil {
 		return fmt.Errorf("websocket connect: %w", err)
 	}
+	p.mu.Lock()
 	p.ws = ws
+	p.mu.Unlock()
 
-	go p.syncLoop(ctx)
+	go p.syncLoop(ctx, cancel)
+	if p.config.IdleUnsubscribeAfter > 0 {
+		go p.idleLoop(ctx)
+	}
+	if p.config.Websocket.SilenceTimeout > 0 {
+		go p.silenceLoop(ctx)
+	}
+	if slices.Contains(p.router.Handlers(), HandlerActivity) {
+		go p.activityLoop(ctx)
+	}
+
+	err = p.readLoop(ctx)
+	if cause := context.Cause(ctx); errors.Is(cause, ErrNoTokens) {
+		return cause
+	}
+	return err
+}
+
+func (p *Polymarket) dial(ctx context.Context) (*websocket.Client, error) {
+	return websocket.New(ctx, p.config.Websocket.URL, p.config.Websocket.MarketEndpoint)
+}
+
+func (p *Polymarket) conn() *websocket.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ws
+}
 
-	// Read messages until context is cancelled
+// readLoop reads messages until ctx is cancelled, reconnecting whenever the
+// connection drops.
+func (p *Polymarket) readLoop(ctx context.Context) error {
 	for {
 		select {
 		case <-ctx.Done():
 			p.log.Info("stopping", "reason", ctx.Err())
 			return ctx.Err()
 		default:
-			msg, err := p.ws.ReadMessage(ctx)
+			msg, err := p.conn().ReadMessage(ctx)
+			if errors.Is(err, websocket.ErrParse) {
+				p.log.Warn("skipping message", "error", err)
+				continue
+			}
+			if errors.Is(err, websocket.ErrSubscriptionRejected) {
+				p.log.Error("server rejected a message", "error", err)
+				continue
+			}
 			if err != nil {
+				if ctx.Err() != nil {
+					p.log.Info("stopping", "reason", ctx.Err())
+					return ctx.Err()
+				}
 				p.log.Error("read message failed", "error", err)
-				return err
+				if err := p.reconnect(ctx); err != nil {
+					return err
+				}
+				continue
 			}
-			p.log.Debug("message received", "size", len(msg.EventType))
-			p.processMessage(msg)
+			p.handleMessage(msg)
 		}
 	}
 }
 
+// handleMessage records the token's activity and routes msg to the handlers.
+func (p *Polymarket) handleMessage(msg *websocket.Message) {
+	if p.config.LogMessages {
+		p.log.Debug("message received", "event_type", msg.EventType)
+	}
+	for _, tokenID := range msg.AssetIDs() {
+		p.activity.observe(tokenID, time.Now())
+	}
+	if err := p.router.Route(msg); err != nil {
+		p.log.Warn("couldn't process message", "event_type", msg.EventType, "error", err)
+	}
+}
+
 func (p *Polymarket) processMessage(msg *websocket.Message) error {
 	switch msg.EventType {
 	case websocket.BookEvent:
 		if msg.Book == nil {
 			return fmt.Errorf("event type is %s but object book doesn't exist", websocket.BookEvent)
 		}
+		if tracker := p.resync.Load(); tracker != nil {
+			tracker.observe(msg.Book.AssetID)
+		}
+		updates, err := bookUpdates(msg.Book)
+		if err != nil {
+			return fmt.Errorf("book of token %s: %w", msg.Book.AssetID, err)
+		}
+		// The book replaces the one the engine has.
+		p.engine.ResetToken(platformName, msg.Book.AssetID)
+		for _, u := range updates {
+			p.engine.Send(u)
+		}
+		p.engine.FinishResync(platformName, msg.Book.AssetID)
+	case websocket.PriceChangeEvent:
+		if msg.PriceChange == nil {
+			return fmt.Errorf("event type is %s but object price_change doesn't exist", websocket.PriceChangeEvent)
+		}
+		updates, err := priceChangeUpdates(msg.PriceChange)
+		if err != nil {
+			return fmt.Errorf("price change of market %s: %w", msg.PriceChange.Market, err)
+		}
+		for _, u := range updates {
+			p.engine.Send(u)
+		}
+	case websocket.TickSizeChangeEvent:
+		change := msg.TickSizeChange
+		if change == nil {
+			return fmt.Errorf("event type is %s but object tick_size_change doesn't exist", websocket.TickSizeChangeEvent)
+		}
+		tick, err := price.Parse(change.NewTickSize)
+		if err != nil {
+			return fmt.Errorf("tick size of token %s: %w", change.AssetID, err)
+		}
+		if tick <= 0 {
+			return fmt.Errorf("invalid tick size %q for token %s", change.NewTickSize, change.AssetID)
+		}
+		p.ticks.set(change.AssetID, price.Tick(tick))
+		p.log.Info("tick size changed", "token", change.AssetID, "old", change.OldTickSize, "new", change.NewTickSize)
+	case websocket.LastTradePriceEvent:
+		if msg.LastTradePrice == nil {
+			return fmt.Errorf("event type is %s but object last_trade_price doesn't exist", websocket.LastTradePriceEvent)
+		}
+		trade, err := lastTrade(msg.LastTradePrice)
+		if err != nil {
+			return fmt.Errorf("trade of token %s: %w", msg.LastTradePrice.AssetID, err)
+		}
+		p.engine.RecordTrade(trade)
 	}
 	return nil
 }
 
-// Stop closes the websocket connection.
-func (p *Polymarket) Stop(ctx context.Context) error {
-	if p.ws != nil {
-		return p.ws.Close(ctx)
+// reconnect dials until a new connection is established or ctx is cancelled,
+// then resubscribes to all previously subscribed tokens. Their books are
+// marked as resyncing meanwhile, so that snapshots skip them until their
+// fresh book arrives.
+func (p *Polymarket) reconnect(ctx context.Context) error {
+	p.mu.Lock()
+	tokenIDs := p.subscribedTokens.AsSlice()
+	p.mu.Unlock()
+	for _, tokenID := range tokenIDs {
+		p.engine.ResyncToken(platformName, tokenID)
 	}
+
+	b := &backoff.Backoff{
+		Initial: p.config.Websocket.ReconnectBaseDelay,
+		Max:     p.config.Websocket.ReconnectMaxDelay,
+		Jitter:  reconnectJitter,
+	}
+
+	attempt := 0
+	var ws *websocket.Client
+	err := backoff.Retry(ctx, func(ctx context.Context) error {
+		attempt++
+		p.log.Info("reconnecting websocket", "attempt", attempt)
+
+		var err error
+		ws, err = p.dial(ctx)
+		if err != nil {
+			p.log.Warn("reconnect failed", "attempt", attempt, "error", err)
+		}
+		return err
+	}, b)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	old := p.ws
+	p.ws = ws
+	p.mu.Unlock()
+
+	closeCtx, cancel := context.WithTimeout(ctx, websocket.DefaultCloseTimeout)
+	_ = old.Close(closeCtx)
+	cancel()
+
+	p.log.Info("reconnected websocket", "attempt", attempt)
+	p.resubscribe(ctx)
 	return nil
 }
 
-func (p *Polymarket) syncLoop(ctx context.Context) {
-	// Sync markets before starting websocket
-	if err := p.syncMarkets(ctx); err != nil {
-		p.log.Error("initial market sync", "error", err)
+// resubscribe resets the books of all subscribed tokens and subscribes again
+// with an initial dump. Whether every token actually received a fresh book is
+// verified in the background.
+func (p *Polymarket) resubscribe(ctx context.Context) {
+	p.mu.Lock()
+	tokenIDs := p.subscribedTokens.AsSlice()
+	p.mu.Unlock()
+
+	if len(tokenIDs) == 0 {
+		return
+	}
+
+	for _, tokenID := range tokenIDs {
+		p.engine.ResetToken(platformName, tokenID)
+	}
+
+	tracker := newResyncTracker(tokenIDs)
+	p.resync.Store(tracker)
+
+	if err := p.subscribe(ctx, tokenIDs, true); err != nil {
+		p.log.Error("resubscribe after reconnect", "error", err)
+		return
+	}
+
+	go p.verifyResync(ctx, tracker, len(tokenIDs))
+}
+
+func (p *Polymarket) verifyResync(ctx context.Context, tracker *resyncTracker, count int) {
+	missing := tracker.wait(ctx, p.config.ResyncTimeout)
+	p.resync.CompareAndSwap(tracker, nil)
+
+	if len(missing) > 0 {
+		p.log.Warn("tokens not resynced after reconnect", "count", len(missing), "tokens", missing)
+		return
+	}
+	p.log.Info("resynced tokens after reconnect", "count", count)
+}
+
+// Stop closes the websocket connection with CloseGoingAway. If the graceful close doesn't finish
+// before ctx is done, the connection is closed forcibly so Stop always
+// returns by the ctx deadline.
+func (p *Polymarket) Stop(ctx context.Context) error {
+	ws := p.conn()
+	if ws == nil {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ws.CloseWithReason(ctx, websocket.CloseGoingAway, "collector stopping")
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("close websocket: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		_ = ws.ForceClose()
+		return fmt.Errorf("close websocket: graceful close didn't finish, closed forcibly: %w", ctx.Err())
 	}
-	tokenIDs, err := p.store.GetTokenIDsForPlatform(ctx, platformName)
+}
+
+// syncLoop syncs markets every MarketSyncInterval, or as Config.AdaptiveSync
+// decides, until ctx is cancelled.
+// With Config.FailOnNoTokens, it stops Start through fail when a sync leaves
+// no tokens to subscribe to.
+func (p *Polymarket) syncLoop(ctx context.Context, fail context.CancelCauseFunc) {
+	// Resubscribe to what was active before a restart right away, so books
+	// don't wait for the market sync. The next sync picks up new markets.
+	restored, err := p.restoreSubscriptions(ctx)
 	if err != nil {
-		p.log.Error("intial market sync", "error", err)
+		p.log.Warn("couldn't restore subscriptions", "error", err)
 	}
 
-	if err := p.subscribeToMarkets(ctx, tokenIDs); err != nil {
+	// Without restored subscriptions, the initial sync subscribes to the
+	// tokens already in the database even if fetching markets fails.
+	if err := p.syncMarkets(ctx); err != nil {
 		p.log.Error("initial market sync", "error", err)
 	}
+	if !restored {
+		if err := p.subscribeFromStore(ctx); errors.Is(err, ErrNoTokens) {
+			fail(err)
+			return
+		} else if err != nil {
+			p.log.Error("initial market sync", "error", err)
+		}
+	}
 
-	ticker := time.NewTicker(p.config.MarketSyncInterval)
+	interval := p.config.AdaptiveSync.clamp(p.config.MarketSyncInterval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := p.syncMarkets(ctx); err != nil {
+			err := p.sync(ctx)
+			if errors.Is(err, ErrNoTokens) {
+				fail(err)
+				return
+			} else if errors.Is(err, errSuspiciousSync) {
+				p.log.Warn("keeping existing subscriptions", "error", err)
+			} else if err != nil {
 				p.log.Error("syncing market", "error", err)
-				continue
-			}
-
-			tokenIDs, err := p.store.GetTokenIDsForPlatform(ctx, platformName)
-			if err != nil {
-				p.log.Error("syncing market", "error", err)
-				continue
-			}
-
-			if err := p.subscribeToMarkets(ctx, tokenIDs); err != nil {
-				p.log.Error("syncing market", "error", err)
-				continue
+			} else if next := p.config.AdaptiveSync.next(interval, p.lastChurn); next != interval {
+				p.log.Info("changing market sync interval", "churn", p.lastChurn, "from", interval, "to", next)
+				interval = next
+				ticker.Reset(interval)
 			}
 		case <-ctx.Done():
 			p.log.Info("market sync stopped", "reason", ctx.Err())
+			return
 		}
 	}
 }
 
+// sync fetches markets and subscribes to the platform's tokens. If fetching
+// markets fails, the existing subscriptions are kept.
+func (p *Polymarket) sync(ctx context.Context) error {
+	if err := p.syncMarkets(ctx); err != nil {
+		return err
+	}
+	return p.subscribeFromStore(ctx)
+}
+
+func (p *Polymarket) subscribeFromStore(ctx context.Context) error {
+	var tokenIDs []string
+	var err error
+	if limit := p.config.MaxMarkets; limit > 0 {
+		// The store still has the markets of earlier syncs.
+		tokenIDs, err = p.store.GetTokenIDsForRecentMarkets(ctx, store.GetTokenIDsForRecentMarketsParams{
+			Platform:   platformName,
+			MaxMarkets: int32(limit),
+		})
+	} else {
+		tokenIDs, err = p.store.GetTokenIDsForPlatform(ctx, platformName)
+	}
+	if err != nil {
+		return fmt.Errorf("get token IDs: %w", err)
+	}
+	return p.subscribeToMarkets(ctx, p.selectTokens(ctx, tokenIDs))
+}
+
 // syncMarkets fetches markets from the API and upserts them into the database.
 func (p *Polymarket) syncMarkets(ctx context.Context) error {
-	markets, err := p.clob.GetAllMarkets()
+	// Incremental syncs only fetch markets changed since the last successful
+	// sync. The first sync is always full.
+	var since time.Time
+	if p.config.IncrementalSync {
+		since = p.lastSyncAt
+	}
+	startedAt := time.Now()
+
+	markets, err := p.clob.GetAllMarkets(ctx, since)
 	if err != nil {
 		return fmt.Errorf("get all markets: %w", err)
 	}
 
+	// A sudden drop below the expected minimum is more likely an API hiccup
+	// than markets disappearing, so don't act on it. Incremental syncs are
+	// expected to return few markets.
+	if since.IsZero() {
+		if p.lastMarketCount >= p.config.MinExpectedMarkets && len(markets) < p.config.MinExpectedMarkets {
+			return fmt.Errorf("%w: got %d markets, previously %d", errSuspiciousSync, len(markets), p.lastMarketCount)
+		}
+		p.lastMarketCount = len(markets)
+	}
+	if limit := p.config.MaxMarkets; limit > 0 && len(markets) > limit {
+		p.log.Info("capping synced markets", "count", len(markets), "max_markets", limit)
+		markets = markets[:limit]
+	}
+
+	conditionIDs := make([]string, 0, len(markets))
 	for _, m := range markets {
 		// Parse end date.
 		var endDate pgtype.Timestamptz
@@ -163,10 +573,14 @@ func (p *Polymarket) syncMarkets(ctx context.Context) error {
 
 		// Upsert market.
 		if err := p.store.UpsertMarket(ctx, store.UpsertMarketParams{
-			ID:          m.ConditionID,
-			Platform:    platformName,
-			Description: m.Description,
-			EndDate:     endDate,
+			ID:               m.ConditionID,
+			Platform:         platformName,
+			Description:      m.Description,
+			EndDate:          endDate,
+			MinimumTickSize:  pgtype.Int8{Int64: int64(m.MinimumTickSize), Valid: m.MinimumTickSize > 0},
+			MinimumOrderSize: pgtype.Int8{Int64: int64(m.MinimumOrderSize), Valid: m.MinimumOrderSize > 0},
+			MakerBaseFeeBps:  pgtype.Int4{Int32: int32(m.MakerBaseFee), Valid: true},
+			TakerBaseFeeBps:  pgtype.Int4{Int32: int32(m.TakerBaseFee), Valid: true},
 		}); err != nil {
 			return fmt.Errorf("upsert market %s: %w", m.ConditionID, err)
 		}
@@ -180,25 +594,172 @@ func (p *Polymarket) syncMarkets(ctx context.Context) error {
 			}); err != nil {
 				return fmt.Errorf("upsert token %s: %w", t.TokenID, err)
 			}
+			p.ticks.set(t.TokenID, m.MinimumTickSize)
 		}
+		conditionIDs = append(conditionIDs, m.ConditionID)
 	}
 
+	p.enrichMarkets(ctx, conditionIDs)
+
 	// TODO Pair markets.
 
-	p.log.Info("synced markets", "count", len(markets))
+	p.lastChurn = p.churn.observe(markets)
+	p.lastSyncAt = startedAt
+	p.log.Info("synced markets", "count", len(markets), "incremental", !since.IsZero())
 	return nil
 }
 
+// subscribeToMarkets subscribes to tokenIDs. Without tokens the existing
+// subscriptions are kept and the state is reported by NoTokensSubscribed;
+// with Config.FailOnNoTokens it returns ErrNoTokens instead.
 func (p *Polymarket) subscribeToMarkets(ctx context.Context, tokenIDs []string) error {
 	if len(tokenIDs) == 0 {
+		p.noTokens.Store(true)
+		if p.config.FailOnNoTokens {
+			return fmt.Errorf("subscribe: %w", ErrNoTokens)
+		}
 		p.log.Warn("no tokens to subscribe to")
 		return nil
 	}
 
-	if err := p.ws.SubscribeMarket(ctx, tokenIDs, true, nil); err != nil {
+	sentAt := time.Now()
+	if err := p.subscribeTokens(ctx, tokenIDs); err != nil {
 		return fmt.Errorf("subscribe: %w", err)
 	}
+	if err := p.confirmSubscription(ctx, sentAt); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	p.setSubscribed(ctx, tokenIDs, time.Now())
+	p.noTokens.Store(false)
+
+	if err := p.store.SaveSubscriptions(ctx, platformName, tokenIDs); err != nil {
+		p.log.Warn("couldn't save subscriptions", "error", err)
+	}
 
 	p.log.Info("subscribed to tokens", "count", len(tokenIDs))
 	return nil
 }
+
+// restoreSubscriptions subscribes to the tokens saved by the previous run and
+// reports whether there were any. The engine starts empty, so they are still
+// subscribed with an initial dump.
+func (p *Polymarket) restoreSubscriptions(ctx context.Context) (bool, error) {
+	state, err := p.store.GetSubscriptionState(ctx, platformName)
+	if err != nil {
+		return false, fmt.Errorf("get subscription state: %w", err)
+	}
+	if len(state) == 0 {
+		return false, nil
+	}
+
+	tokenIDs := make([]string, 0, len(state))
+	for _, st := range state {
+		tokenIDs = append(tokenIDs, st.TokenID)
+	}
+	// The token filter may have changed since the subscriptions were saved.
+	tokenIDs = p.config.Tokens.apply(tokenIDs)
+	if len(tokenIDs) == 0 {
+		return false, nil
+	}
+	sentAt := time.Now()
+	if err := p.subscribe(ctx, tokenIDs, true); err != nil {
+		return false, fmt.Errorf("subscribe: %w", err)
+	}
+	if err := p.confirmSubscription(ctx, sentAt); err != nil {
+		return false, fmt.Errorf("subscribe: %w", err)
+	}
+
+	p.setSubscribed(ctx, tokenIDs, time.Now())
+
+	p.log.Info("restored subscriptions", "count", len(tokenIDs))
+	return true, nil
+}
+
+// setSubscribed replaces the subscribed tokens with tokenIDs, subscribed at
+// now. Tokens that were already subscribed keep their subscription time.
+// Tokens no longer in tokenIDs, e.g. of markets past Config.MaxMarkets, are
+// unsubscribed and their books removed. If unsubscribing fails they stay
+// subscribed, and the next call retries.
+func (p *Polymarket) setSubscribed(ctx context.Context, tokenIDs []string, now time.Time) {
+	subscribed := hashset.SetFromSlice(tokenIDs)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dropped := p.subscribedTokens.Remove(subscribed).AsSlice()
+	if len(dropped) > 0 {
+		if err := p.ws.UnsubscribeMarket(ctx, dropped); err != nil {
+			p.log.Warn("couldn't unsubscribe from dropped tokens", "count", len(dropped), "error", err)
+			for _, id := range dropped {
+				subscribed.Set(id)
+			}
+			dropped = nil
+		}
+	}
+	p.subscribedTokens = subscribed
+	for _, id := range dropped {
+		p.engine.RemoveToken(platformName, id)
+	}
+
+	p.activity.forget(dropped)
+	p.activity.subscribed(tokenIDs, now)
+	if len(dropped) > 0 {
+		p.log.Info("unsubscribed from dropped tokens", "count", len(dropped))
+	}
+}
+
+func (p *Polymarket) subscribe(ctx context.Context, tokenIDs []string, initialDump bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ws.SubscribeMarket(ctx, tokenIDs, initialDump, nil)
+}
+
+// subscribeTokens subscribes to tokenIDs, asking for an initial dump only for
+// the tokens that need a book: those not subscribed yet and those the engine
+// has no book for, e.g. because it evicted an idle one. Tokens that are
+// subscribed and have a book are sent without a dump, so a sync doesn't make
+// the server resend every book.
+func (p *Polymarket) subscribeTokens(ctx context.Context, tokenIDs []string) error {
+	tracked := hashset.NewSet[string]()
+	for _, key := range p.engine.TrackedTokens() {
+		if key.Platform == platformName {
+			tracked.Set(key.TokenID)
+		}
+	}
+
+	var fresh, resent []string
+	p.mu.Lock()
+	for _, tokenID := range tokenIDs {
+		if p.subscribedTokens.Has(tokenID) && tracked.Has(tokenID) {
+			resent = append(resent, tokenID)
+		} else {
+			fresh = append(fresh, tokenID)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(fresh) > 0 {
+		if err := p.subscribe(ctx, fresh, true); err != nil {
+			return err
+		}
+	}
+	if len(resent) > 0 {
+		if err := p.subscribe(ctx, resent, false); err != nil {
+			return err
+		}
+	}
+	p.log.Debug("subscribing to tokens", "new", len(fresh), "resent", len(resent))
+	return nil
+}
+
+// confirmSubscription waits for the server to respond to a subscription sent
+// at since, see websocket.Client.AwaitSubscription. It returns nil right away
+// without Websocket.SubscribeConfirmTimeout. It relies on readLoop reading
+// the frames, so it must not be called from it.
+func (p *Polymarket) confirmSubscription(ctx context.Context, since time.Time) error {
+	timeout := p.config.Websocket.SubscribeConfirmTimeout
+	if timeout <= 0 {
+		return nil
+	}
+	return p.conn().AwaitSubscription(ctx, since, timeout)
+}
@@ -0,0 +1,785 @@
+package polymarket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+	"github.com/daszybak/prediction_markets/pkg/hashset"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent log writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReconnectResyncReportsMissingTokens(t *testing.T) {
+	var (
+		upgrader    gorilla.Upgrader
+		connections atomic.Int32
+		resubs      = make(chan websocket.Subscription, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var sub websocket.Subscription
+		if err := conn.ReadJSON(&sub); err != nil {
+			return
+		}
+		if connections.Add(1) == 1 {
+			// Drop the first connection to force a reconnect.
+			return
+		}
+		resubs <- sub
+
+		// Only token "a" resyncs.
+		_ = conn.WriteMessage(gorilla.TextMessage, []byte(`{"event_type":"book","asset_id":"a","market":"m","buys":[],"sells":[]}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	p := New(Config{
+		Websocket: Websocket{
+			URL:                "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint:     "/ws/market",
+			ReconnectBaseDelay: 10 * time.Millisecond,
+			ReconnectMaxDelay:  20 * time.Millisecond,
+		},
+		ResyncTimeout: 200 * time.Millisecond,
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	if err := p.subscribe(ctx, []string{"a", "b"}, true); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	p.subscribedTokens = hashset.SetFromSlice([]string{"a", "b"})
+	go p.readLoop(ctx)
+
+	select {
+	case sub := <-resubs:
+		if sub.InitialDump == nil || !*sub.InitialDump {
+			t.Errorf("resubscription must request an initial dump")
+		}
+		slices.Sort(sub.AssetsIDs)
+		if !slices.Equal(sub.AssetsIDs, []string{"a", "b"}) {
+			t.Errorf("resubscribed to %v, want [a b]", sub.AssetsIDs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client didn't reconnect")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), "tokens not resynced") {
+		if time.Now().After(deadline) {
+			t.Fatalf("missing resync warning, logs:\n%s", logs.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(logs.String(), "tokens=[b]") {
+		t.Errorf("expected only token b to be reported, logs:\n%s", logs.String())
+	}
+}
+
+func TestReconnectRetriesFailedDials(t *testing.T) {
+	var (
+		upgrader gorilla.Upgrader
+		requests atomic.Int32
+		resubs   = make(chan websocket.Subscription, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if n == 2 || n == 3 {
+			// The first two redials fail.
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var sub websocket.Subscription
+		if err := conn.ReadJSON(&sub); err != nil {
+			return
+		}
+		if n == 1 {
+			// Drop the first connection to force a reconnect.
+			return
+		}
+		resubs <- sub
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := New(Config{
+		Websocket: Websocket{
+			URL:                "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint:     "/ws/market",
+			ReconnectBaseDelay: 10 * time.Millisecond,
+			ReconnectMaxDelay:  20 * time.Millisecond,
+		},
+		ResyncTimeout: 100 * time.Millisecond,
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	if err := p.subscribe(ctx, []string{"a", "b", "c"}, true); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	p.subscribedTokens = hashset.SetFromSlice([]string{"a", "b", "c"})
+	go p.readLoop(ctx)
+
+	select {
+	case sub := <-resubs:
+		var want []string
+		for _, s := range p.Subscriptions() {
+			want = append(want, s.TokenID)
+		}
+		slices.Sort(sub.AssetsIDs)
+		if !slices.Equal(sub.AssetsIDs, want) {
+			t.Errorf("resubscribed to %v, want the subscriptions %v", sub.AssetsIDs, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client didn't reconnect")
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("server got %d connection attempts, want 4", got)
+	}
+}
+
+func TestReconnectSkipsStaleBooksInSnapshots(t *testing.T) {
+	var (
+		upgrader    gorilla.Upgrader
+		connections atomic.Int32
+		drop        = make(chan struct{})
+		release     = make(chan struct{})
+		resubs      = make(chan struct{}, 1)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if err := conn.ReadJSON(&websocket.Subscription{}); err != nil {
+			return
+		}
+		if connections.Add(1) == 1 {
+			_ = conn.WriteMessage(gorilla.TextMessage, []byte(`{"event_type":"book","asset_id":"a","bids":[{"price":"0.5","size":"1"}],"asks":[]}`))
+			// Drop the first connection to force a reconnect.
+			<-drop
+			return
+		}
+		resubs <- struct{}{}
+		<-release
+		_ = conn.WriteMessage(gorilla.TextMessage, []byte(`{"event_type":"book","asset_id":"a","bids":[{"price":"0.6","size":"1"}],"asks":[]}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	eng := engine.NewInline(logger)
+	p := New(Config{
+		Websocket: Websocket{
+			URL:                "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint:     "/ws/market",
+			ReconnectBaseDelay: 10 * time.Millisecond,
+			ReconnectMaxDelay:  20 * time.Millisecond,
+		},
+		ResyncTimeout: time.Second,
+	}, nil, eng, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	if err := p.subscribe(ctx, []string{"a"}, true); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	p.subscribedTokens = hashset.SetFromSlice([]string{"a"})
+	go p.readLoop(ctx)
+
+	// waitForBid waits until the snapshots hold token a with the given best
+	// bid.
+	waitForBid := func(bid int64) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			snaps := eng.TakeSnapshots(1)
+			if len(snaps) == 1 && len(snaps[0].Bids) == 1 && int64(snaps[0].Bids[0].Price) == bid {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("snapshots = %+v, want token a with best bid %d", snaps, bid)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitForBid(500_000)
+
+	close(drop)
+	select {
+	case <-resubs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client didn't reconnect")
+	}
+	// Reconnected, but the fresh book hasn't arrived yet.
+	if snaps := eng.TakeSnapshots(1); len(snaps) != 0 {
+		t.Errorf("TakeSnapshots() during the reconnect = %+v, want the resyncing book skipped", snaps)
+	}
+	if snap, ok := eng.Snapshot(platformName, "a", 1); !ok || !snap.Resyncing {
+		t.Errorf("Snapshot() during the reconnect = %+v, %v, want it flagged as resyncing", snap, ok)
+	}
+
+	close(release)
+	waitForBid(600_000)
+}
+
+func TestStopReturnsByDeadlineWhenCloseIsSlow(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Never read, so the close frame is never acknowledged.
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{
+		Websocket: Websocket{
+			URL:            "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint: "/ws/market",
+		},
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	ws, err := p.dial(context.Background())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = p.Stop(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop took %v, want it bounded by the 100ms deadline", elapsed)
+	}
+	if err == nil {
+		t.Error("expected an error when the graceful close times out")
+	}
+}
+
+func TestSyncKeepsSubscriptionsWhenMarketsVanish(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"limit": 0, "count": 0, "data": []}`))
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{ClobURL: srv.URL, MinExpectedMarkets: 10}, nil, engine.New(engine.Config{}, logger), logger)
+	p.lastMarketCount = 500
+	p.subscribedTokens.Set("a")
+
+	err := p.sync(context.Background())
+	if !errors.Is(err, errSuspiciousSync) {
+		t.Fatalf("got %v, want errSuspiciousSync", err)
+	}
+	if !p.subscribedTokens.Has("a") {
+		t.Error("existing subscriptions must be kept")
+	}
+	if p.lastMarketCount != 500 {
+		t.Errorf("lastMarketCount = %d, a suspicious sync must not overwrite it", p.lastMarketCount)
+	}
+}
+
+func TestIncrementalSyncAdvancesLastSync(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.URL.Query().Get("updated_since"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"limit": 0, "count": 0, "data": []}`))
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{ClobURL: srv.URL, IncrementalSync: true}, nil, engine.New(engine.Config{}, logger), logger)
+
+	before := time.Now()
+	if err := p.syncMarkets(context.Background()); err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	first := p.lastSyncAt
+	if first.Before(before) {
+		t.Fatalf("lastSyncAt = %v, want it advanced past %v", first, before)
+	}
+
+	if err := p.syncMarkets(context.Background()); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if !p.lastSyncAt.After(first) {
+		t.Errorf("lastSyncAt = %v, want it after %v", p.lastSyncAt, first)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("got %d requests, want 2", len(sent))
+	}
+	if sent[0] != "" {
+		t.Errorf("first sync sent updated_since=%q, want a full sync", sent[0])
+	}
+	if want := first.UTC().Format(time.RFC3339); sent[1] != want {
+		t.Errorf("second sync sent updated_since=%q, want %q", sent[1], want)
+	}
+}
+
+func TestReadLoopRecordsTokenLastSeen(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(gorilla.TextMessage, []byte(`{"event_type":"price_change","asset_id":"a","price":"0.5","size":"1","side":"BUY"}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{
+		Websocket: Websocket{URL: "ws" + strings.TrimPrefix(srv.URL, "http"), MarketEndpoint: "/ws/market"},
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	before := time.Now()
+	go p.readLoop(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if seen, ok := p.TokenLastSeen()["a"]; ok {
+			if seen.Before(before) {
+				t.Errorf("last seen %v, want after %v", seen, before)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("token a never recorded as seen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSyncCapsMarkets(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	// Market 0 is from an earlier sync, the API lists markets 1 to 3.
+	conditionIDs := make([]string, 4)
+	for i := range conditionIDs {
+		conditionIDs[i] = fmt.Sprintf("max-markets-%d", i)
+	}
+	if err := s.UpsertMarket(ctx, store.UpsertMarketParams{ID: conditionIDs[0], Platform: platformName, Description: "earlier"}); err != nil {
+		t.Fatalf("seed market: %v", err)
+	}
+	if err := s.UpsertToken(ctx, store.UpsertTokenParams{ID: conditionIDs[0] + "-yes", MarketID: conditionIDs[0], Outcome: "Yes"}); err != nil {
+		t.Fatalf("seed token: %v", err)
+	}
+
+	clobSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		markets := make([]string, 0, 3)
+		for _, id := range conditionIDs[1:] {
+			markets = append(markets, fmt.Sprintf(`{"condition_id": %q, "description": "listed", "tokens": [
+				{"token_id": "%[1]s-yes", "outcome": "Yes"}, {"token_id": "%[1]s-no", "outcome": "No"}]}`, id))
+		}
+		fmt.Fprintf(w, `{"limit": 3, "count": 3, "data": [%s]}`, strings.Join(markets, ","))
+	}))
+	defer clobSrv.Close()
+	gammaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer gammaSrv.Close()
+	var upgrader gorilla.Upgrader
+	unsubscribed := make(chan []string, 1)
+	wsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var update websocket.SubscriptionUpdate
+			if err := conn.ReadJSON(&update); err != nil {
+				return
+			}
+			if update.Operation == "unsubscribe" {
+				unsubscribed <- update.AssetsIDs
+			}
+		}
+	}))
+	defer wsSrv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	e := engine.NewInline(logger)
+	p := New(Config{
+		ClobURL:    clobSrv.URL,
+		GammaURL:   gammaSrv.URL,
+		MaxMarkets: 2,
+		Websocket:  Websocket{URL: "ws" + strings.TrimPrefix(wsSrv.URL, "http"), MarketEndpoint: "/ws/market"},
+	}, s, e, logger)
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	defer ws.ForceClose()
+
+	// The earlier market's token is subscribed and has a book.
+	earlier := conditionIDs[0] + "-yes"
+	p.subscribedTokens = hashset.SetFromSlice([]string{earlier})
+	e.Send(engine.Update{Platform: platformName, TokenID: earlier, Price: 500_000, Size: 1, Side: "bids"})
+
+	if err := p.sync(ctx); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	for i, id := range conditionIDs[1:] {
+		_, err := s.GetMarket(ctx, id)
+		if stored := err == nil; stored != (i < 2) {
+			t.Errorf("listed market %d stored = %v (error %v), want only the first 2 stored", i, stored, err)
+		}
+	}
+	if got := len(p.subscribedTokens); got != 4 {
+		t.Errorf("subscribed to %d tokens %v, want the 4 tokens of the 2 synced markets", got, p.subscribedTokens.AsSlice())
+	}
+	for _, id := range conditionIDs[1:3] {
+		if !p.subscribedTokens.Has(id+"-yes") || !p.subscribedTokens.Has(id+"-no") {
+			t.Errorf("tokens of synced market %s not subscribed", id)
+		}
+	}
+
+	select {
+	case ids := <-unsubscribed:
+		if !slices.Equal(ids, []string{earlier}) {
+			t.Errorf("unsubscribed from %v, want %v", ids, []string{earlier})
+		}
+	case <-time.After(time.Second):
+		t.Fatal("earlier market's token not unsubscribed")
+	}
+	if slices.Contains(e.TrackedTokens(), engine.BookKey{Platform: platformName, TokenID: earlier}) {
+		t.Error("earlier market's book still tracked")
+	}
+}
+
+func TestHandleMessageLogsOnlyWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		var buf syncBuffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		p := New(Config{LogMessages: enabled}, nil, engine.New(engine.Config{}, logger), logger)
+
+		p.handleMessage(&websocket.Message{EventType: websocket.LastTradePriceEvent})
+
+		if logged := strings.Contains(buf.String(), "message received"); logged != enabled {
+			t.Errorf("LogMessages %t: message logged = %t, output %q", enabled, logged, buf.String())
+		}
+	}
+}
+
+func TestNoTokensKeepsSubscriptions(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	p := New(Config{}, nil, engine.New(engine.Config{}, logger), logger)
+	p.subscribedTokens.Set("a")
+
+	if err := p.subscribeToMarkets(context.Background(), nil); err != nil {
+		t.Fatalf("subscribeToMarkets() error = %v, want nil without FailOnNoTokens", err)
+	}
+	if !p.NoTokensSubscribed() {
+		t.Error("NoTokensSubscribed() = false after subscribing to no tokens")
+	}
+	if !p.subscribedTokens.Has("a") {
+		t.Error("existing subscriptions must be kept")
+	}
+	if !strings.Contains(buf.String(), "no tokens to subscribe to") {
+		t.Errorf("no warning logged, output %q", buf.String())
+	}
+}
+
+func TestNoTokensFailsFast(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{FailOnNoTokens: true}, nil, engine.New(engine.Config{}, logger), logger)
+
+	err := p.subscribeToMarkets(context.Background(), nil)
+	if !errors.Is(err, ErrNoTokens) {
+		t.Fatalf("subscribeToMarkets() error = %v, want ErrNoTokens", err)
+	}
+	if !p.NoTokensSubscribed() {
+		t.Error("NoTokensSubscribed() = false after subscribing to no tokens")
+	}
+}
+
+func TestSubscribeFailsOnRejection(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sub websocket.Subscription
+		if err := conn.ReadJSON(&sub); err != nil {
+			return
+		}
+		_ = conn.WriteMessage(gorilla.TextMessage, []byte("INVALID OPERATION"))
+		<-release
+	}))
+	defer srv.Close()
+
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	p := New(Config{
+		Websocket: Websocket{
+			URL:                     "ws" + strings.TrimPrefix(srv.URL, "http"),
+			MarketEndpoint:          "/ws/market",
+			SubscribeConfirmTimeout: time.Second,
+		},
+	}, nil, engine.New(engine.Config{}, logger), logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	defer ws.ForceClose()
+	go p.readLoop(ctx)
+
+	err = p.subscribeToMarkets(ctx, []string{"a"})
+	if !errors.Is(err, websocket.ErrSubscriptionRejected) {
+		t.Fatalf("subscribeToMarkets() error = %v, want %v", err, websocket.ErrSubscriptionRejected)
+	}
+	if p.subscribedTokens.Has("a") {
+		t.Error("rejected tokens must not be marked subscribed")
+	}
+	if !strings.Contains(buf.String(), "server rejected a message") {
+		t.Errorf("rejection not logged, output %q", buf.String())
+	}
+}
+
+func TestSubscribeTokensDumpsOnlyNewTokens(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	subs := make(chan websocket.Subscription, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var sub websocket.Subscription
+			if err := conn.ReadJSON(&sub); err != nil {
+				return
+			}
+			subs <- sub
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	e := engine.NewInline(logger)
+	p := New(Config{
+		Websocket: Websocket{URL: "ws" + strings.TrimPrefix(srv.URL, "http"), MarketEndpoint: "/ws/market"},
+	}, nil, e, logger)
+	ctx := context.Background()
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	defer ws.ForceClose()
+
+	// "old" is subscribed and has a book, "evicted" is subscribed without
+	// one and "new" isn't subscribed yet.
+	p.subscribedTokens = hashset.SetFromSlice([]string{"old", "evicted"})
+	e.Send(engine.Update{Platform: platformName, TokenID: "old", Price: 500_000, Size: 1, Side: "bids"})
+
+	if err := p.subscribeTokens(ctx, []string{"old", "evicted", "new"}); err != nil {
+		t.Fatalf("subscribeTokens() error = %v", err)
+	}
+
+	got := map[bool][]string{}
+	for range 2 {
+		select {
+		case sub := <-subs:
+			if sub.InitialDump == nil {
+				t.Fatalf("subscription %v without initial_dump", sub.AssetsIDs)
+			}
+			got[*sub.InitialDump] = sub.AssetsIDs
+		case <-time.After(time.Second):
+			t.Fatal("subscription not received")
+		}
+	}
+	if want := []string{"evicted", "new"}; !slices.Equal(got[true], want) {
+		t.Errorf("tokens with initial_dump = %v, want %v", got[true], want)
+	}
+	if want := []string{"old"}; !slices.Equal(got[false], want) {
+		t.Errorf("tokens without initial_dump = %v, want %v", got[false], want)
+	}
+}
+
+func TestSubscribeTokensDumpsEvictedBooks(t *testing.T) {
+	var upgrader gorilla.Upgrader
+	subs := make(chan websocket.Subscription, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var sub websocket.Subscription
+			if err := conn.ReadJSON(&sub); err != nil {
+				return
+			}
+			subs <- sub
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	e := engine.NewInline(logger)
+	p := New(Config{
+		Websocket: Websocket{URL: "ws" + strings.TrimPrefix(srv.URL, "http"), MarketEndpoint: "/ws/market"},
+	}, nil, e, logger)
+	ctx := context.Background()
+	ws, err := p.dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	p.ws = ws
+	defer ws.ForceClose()
+
+	p.subscribedTokens = hashset.SetFromSlice([]string{"a"})
+	book := &websocket.Message{EventType: websocket.BookEvent, Book: &websocket.Book{
+		AssetID: "a",
+		Bids:    []websocket.OrderSummary{{Price: 500_000, Size: 10_000_000}},
+	}}
+	if err := p.processMessage(book); err != nil {
+		t.Fatalf("process book: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if got := e.EvictStale(time.Millisecond); got != 1 {
+		t.Fatalf("EvictStale() = %d, want 1", got)
+	}
+
+	// The token is still subscribed and keeps sending changes, which alone
+	// would only rebuild the levels they touch.
+	change := &websocket.Message{EventType: websocket.PriceChangeEvent, PriceChange: &websocket.PriceChange{
+		Changes: []websocket.PriceLevelChange{{AssetID: "a", Price: 400_000, Size: 5_000_000, Side: "BUY"}},
+	}}
+	if err := p.processMessage(change); err != nil {
+		t.Fatalf("process price change: %v", err)
+	}
+
+	if err := p.subscribeTokens(ctx, []string{"a"}); err != nil {
+		t.Fatalf("subscribeTokens() error = %v", err)
+	}
+	select {
+	case sub := <-subs:
+		if sub.InitialDump == nil || !*sub.InitialDump || !slices.Equal(sub.AssetsIDs, []string{"a"}) {
+			t.Errorf("subscription = %v with initial_dump %v, want [a] with a dump", sub.AssetsIDs, sub.InitialDump)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription not received")
+	}
+}
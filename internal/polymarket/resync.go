@@ -0,0 +1,59 @@
+package polymarket
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/daszybak/prediction_markets/pkg/hashset"
+)
+
+// resyncTracker tracks which tokens still owe an initial book snapshot after
+// a reconnect.
+type resyncTracker struct {
+	mu      sync.Mutex
+	pending hashset.Set[string]
+	done    chan struct{}
+}
+
+func newResyncTracker(tokenIDs []string) *resyncTracker {
+	t := &resyncTracker{
+		pending: hashset.SetFromSlice(tokenIDs),
+		done:    make(chan struct{}),
+	}
+	if len(t.pending) == 0 {
+		close(t.done)
+	}
+	return t
+}
+
+// observe marks the token as resynced.
+func (t *resyncTracker) observe(tokenID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.pending.Has(tokenID) {
+		return
+	}
+	t.pending.Delete(tokenID)
+	if len(t.pending) == 0 {
+		close(t.done)
+	}
+}
+
+// wait blocks until every token was observed, the timeout elapses or ctx is
+// cancelled, and returns the tokens that weren't observed.
+func (t *resyncTracker) wait(ctx context.Context, timeout time.Duration) []string {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-t.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending.AsSlice()
+}
@@ -0,0 +1,106 @@
+package polymarket
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+)
+
+// MessageHandler processes a decoded websocket message.
+type MessageHandler func(msg *websocket.Message) error
+
+// Names of the message handlers that can be listed in Config.Handlers.
+const (
+	// HandlerEngine applies book events to the engine, records trades and
+	// tracks resyncs.
+	HandlerEngine = "engine"
+	// HandlerMetrics counts messages by event type.
+	HandlerMetrics = "metrics"
+	// HandlerRaw logs every message at debug level.
+	HandlerRaw = "raw"
+	// HandlerActivity counts trade and comment events per market. Listing it
+	// also opens the activity channel, see Websocket.ActivityEndpoint.
+	HandlerActivity = "activity"
+)
+
+// HandlerNames lists the valid message handler names.
+var HandlerNames = []string{HandlerEngine, HandlerMetrics, HandlerRaw, HandlerActivity}
+
+// DefaultHandlers are used when Config.Handlers is empty.
+var DefaultHandlers = []string{HandlerEngine, HandlerMetrics}
+
+// ValidateHandlers reports unknown and duplicate handler names.
+func ValidateHandlers(names []string) error {
+	var errs []error
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !slices.Contains(HandlerNames, name) {
+			errs = append(errs, fmt.Errorf("unknown handler %q, want one of %s", name, strings.Join(HandlerNames, ", ")))
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("duplicate handler %q", name))
+		}
+		seen[name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// MessageRouter passes each message to its handlers in order.
+type MessageRouter struct {
+	names    []string
+	handlers []MessageHandler
+}
+
+// NewMessageRouter returns a router for the named handlers out of available.
+func NewMessageRouter(names []string, available map[string]MessageHandler) (*MessageRouter, error) {
+	if err := ValidateHandlers(names); err != nil {
+		return nil, err
+	}
+
+	r := &MessageRouter{}
+	for _, name := range names {
+		h, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("handler %q isn't available", name)
+		}
+		r.names = append(r.names, name)
+		r.handlers = append(r.handlers, h)
+	}
+	return r, nil
+}
+
+// Route passes msg to every handler, even if an earlier one fails.
+func (r *MessageRouter) Route(msg *websocket.Message) error {
+	var errs []error
+	for i, h := range r.handlers {
+		if err := h(msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handlers returns the names of the router's handlers in order.
+func (r *MessageRouter) Handlers() []string {
+	return slices.Clone(r.names)
+}
+
+// messageHandlers returns the handlers the platform provides, by name.
+func (p *Polymarket) messageHandlers() map[string]MessageHandler {
+	return map[string]MessageHandler{
+		HandlerEngine: p.processMessage,
+		HandlerMetrics: func(msg *websocket.Message) error {
+			metrics.WebsocketMessages.WithLabelValues(platformName, msg.EventType).Inc()
+			return nil
+		},
+		HandlerRaw: func(msg *websocket.Message) error {
+			p.log.Debug("message", "event_type", msg.EventType, "message", msg)
+			return nil
+		},
+		HandlerActivity: p.handleActivity,
+	}
+}
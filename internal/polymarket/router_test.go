@@ -0,0 +1,103 @@
+package polymarket
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+)
+
+func TestRouterOnlyCallsConfiguredHandlers(t *testing.T) {
+	var got []string
+	record := func(name string) MessageHandler {
+		return func(*websocket.Message) error {
+			got = append(got, name)
+			return nil
+		}
+	}
+	available := map[string]MessageHandler{
+		HandlerEngine:  record(HandlerEngine),
+		HandlerMetrics: record(HandlerMetrics),
+		HandlerRaw:     record(HandlerRaw),
+	}
+
+	r, err := NewMessageRouter([]string{HandlerRaw, HandlerEngine}, available)
+	if err != nil {
+		t.Fatalf("NewMessageRouter: %v", err)
+	}
+	if err := r.Route(&websocket.Message{EventType: websocket.BookEvent}); err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+
+	if want := []string{HandlerRaw, HandlerEngine}; !slices.Equal(got, want) {
+		t.Errorf("handlers called %v, want %v", got, want)
+	}
+}
+
+func TestRouterCallsEveryHandlerOnError(t *testing.T) {
+	errEngine := errors.New("engine failed")
+	metricsCalled := false
+	r, err := NewMessageRouter([]string{HandlerEngine, HandlerMetrics}, map[string]MessageHandler{
+		HandlerEngine: func(*websocket.Message) error { return errEngine },
+		HandlerMetrics: func(*websocket.Message) error {
+			metricsCalled = true
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewMessageRouter: %v", err)
+	}
+
+	if err := r.Route(&websocket.Message{}); !errors.Is(err, errEngine) {
+		t.Errorf("Route error = %v, want %v", err, errEngine)
+	}
+	if !metricsCalled {
+		t.Error("a failing handler must not stop later ones")
+	}
+}
+
+func TestValidateHandlers(t *testing.T) {
+	if err := ValidateHandlers(HandlerNames); err != nil {
+		t.Errorf("ValidateHandlers(%v) = %v", HandlerNames, err)
+	}
+	if err := ValidateHandlers([]string{"trades"}); err == nil {
+		t.Error("unknown handler must be rejected")
+	}
+	if err := ValidateHandlers([]string{HandlerEngine, HandlerEngine}); err == nil {
+		t.Error("duplicate handler must be rejected")
+	}
+}
+
+func TestActivityHandlerCountsPerMarket(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{Handlers: []string{HandlerActivity}}, nil, engine.New(engine.Config{}, logger), logger)
+
+	activity := func(topic, market string) *websocket.Message {
+		return &websocket.Message{
+			EventType: websocket.ActivityEvent,
+			Activity:  &websocket.Activity{Topic: topic, Payload: websocket.ActivityPayload{ConditionID: market}},
+		}
+	}
+	for _, msg := range []*websocket.Message{
+		activity(websocket.TopicActivity, "a"),
+		activity(websocket.TopicActivity, "a"),
+		activity(websocket.TopicComments, "a"),
+		activity(websocket.TopicActivity, "b"),
+		activity(websocket.TopicActivity, ""),
+		{EventType: websocket.BookEvent, Book: &websocket.Book{AssetID: "t"}},
+	} {
+		p.handleMessage(msg)
+	}
+
+	want := map[string]ActivityCounts{
+		"a": {Trades: 2, Comments: 1},
+		"b": {Trades: 1},
+	}
+	if got := p.MarketActivity(); !maps.Equal(got, want) {
+		t.Errorf("MarketActivity() = %v, want %v", got, want)
+	}
+}
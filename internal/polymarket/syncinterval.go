@@ -0,0 +1,99 @@
+package polymarket
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/clob"
+)
+
+// AdaptiveSyncConfig lets the market sync interval follow how many markets
+// change. After a sync that found at least ChurnThreshold new or changed
+// markets the interval is halved, down to MinInterval; after a quieter sync
+// it is doubled, up to MaxInterval. Failed syncs leave it unchanged.
+//
+// The zero value disables it and markets are synced every
+// Config.MarketSyncInterval.
+type AdaptiveSyncConfig struct {
+	MinInterval    time.Duration
+	MaxInterval    time.Duration
+	ChurnThreshold int
+}
+
+func (c AdaptiveSyncConfig) enabled() bool {
+	return c.MinInterval > 0 && c.MaxInterval > 0
+}
+
+// next returns the interval to wait after a sync that found churn new or
+// changed markets, given the current interval.
+func (c AdaptiveSyncConfig) next(current time.Duration, churn int) time.Duration {
+	if !c.enabled() {
+		return current
+	}
+	if churn >= c.ChurnThreshold {
+		current /= 2
+	} else {
+		current *= 2
+	}
+	return c.clamp(current)
+}
+
+// clamp bounds an interval to [MinInterval, MaxInterval].
+func (c AdaptiveSyncConfig) clamp(d time.Duration) time.Duration {
+	if !c.enabled() {
+		return d
+	}
+	return min(max(d, c.MinInterval), c.MaxInterval)
+}
+
+// marketChurn remembers a fingerprint of every synced market to count the
+// markets a sync added or changed. It is only used by the sync loop.
+type marketChurn struct {
+	fingerprints map[string]uint64
+}
+
+func newMarketChurn() *marketChurn {
+	return &marketChurn{fingerprints: make(map[string]uint64)}
+}
+
+// observe records markets and returns how many of them are new or differ
+// from when they were last observed. The first observation only sets the
+// baseline and returns 0.
+func (mc *marketChurn) observe(markets []*clob.Market) int {
+	baseline := len(mc.fingerprints) == 0
+	churn := 0
+	for _, m := range markets {
+		fp := fingerprint(m)
+		if old, ok := mc.fingerprints[m.ConditionID]; !ok || old != fp {
+			churn++
+		}
+		mc.fingerprints[m.ConditionID] = fp
+	}
+	if baseline {
+		return 0
+	}
+	return churn
+}
+
+// fingerprint hashes the fields of a market that the sync stores.
+func fingerprint(m *clob.Market) uint64 {
+	h := fnv.New64a()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	write(m.Description)
+	write(m.Question)
+	write(m.EndDateISO)
+	write(strconv.FormatInt(int64(m.MinimumTickSize), 10))
+	write(strconv.FormatInt(int64(m.MinimumOrderSize), 10))
+	write(strconv.Itoa(m.MakerBaseFee))
+	write(strconv.Itoa(m.TakerBaseFee))
+	for _, t := range m.Tokens {
+		write(t.TokenID)
+		write(t.Outcome)
+		write(strconv.FormatBool(t.Winner))
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,92 @@
+package polymarket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/clob"
+)
+
+// syncedMarkets returns n markets, of which the first changed have a new
+// description in version.
+func syncedMarkets(n, changed, version int) []*clob.Market {
+	markets := make([]*clob.Market, n)
+	for i := range markets {
+		description := "initial"
+		if i < changed {
+			description = fmt.Sprintf("version %d", version)
+		}
+		markets[i] = &clob.Market{
+			ConditionID: fmt.Sprintf("0x%d", i),
+			Description: description,
+			Tokens:      []clob.MarketToken{{TokenID: fmt.Sprintf("t%d", i), Outcome: "Yes", Price: 500_000}},
+		}
+	}
+	return markets
+}
+
+func TestAdaptiveSyncFollowsChurn(t *testing.T) {
+	cfg := AdaptiveSyncConfig{MinInterval: time.Minute, MaxInterval: 20 * time.Minute, ChurnThreshold: 10}
+	churn := newMarketChurn()
+	interval := cfg.clamp(5 * time.Minute)
+
+	if got := churn.observe(syncedMarkets(100, 0, 0)); got != 0 {
+		t.Fatalf("baseline churn = %d, want 0", got)
+	}
+
+	// High churn: 50 changed markets per sync until the minimum.
+	var intervals []time.Duration
+	for v := 1; v <= 4; v++ {
+		c := churn.observe(syncedMarkets(100, 50, v))
+		if c != 50 {
+			t.Fatalf("churn of sync %d = %d, want 50", v, c)
+		}
+		interval = cfg.next(interval, c)
+		intervals = append(intervals, interval)
+	}
+	wantHigh := []time.Duration{150 * time.Second, 75 * time.Second, time.Minute, time.Minute}
+	for i := range wantHigh {
+		if intervals[i] != wantHigh[i] {
+			t.Errorf("high churn intervals = %v, want %v", intervals, wantHigh)
+			break
+		}
+	}
+
+	// Low churn: nothing but prices change, the interval grows to the max.
+	stable := syncedMarkets(100, 50, 4)
+	intervals = nil
+	for range 6 {
+		for _, m := range stable {
+			m.Tokens[0].Price += 1_000
+		}
+		c := churn.observe(stable)
+		if c != 0 {
+			t.Fatalf("churn of stable sync = %d, want 0", c)
+		}
+		interval = cfg.next(interval, c)
+		intervals = append(intervals, interval)
+	}
+	wantLow := []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 20 * time.Minute, 20 * time.Minute}
+	for i := range wantLow {
+		if intervals[i] != wantLow[i] {
+			t.Errorf("low churn intervals = %v, want %v", intervals, wantLow)
+			break
+		}
+	}
+
+	// New markets count as churn too.
+	if got := churn.observe(append(stable, syncedMarkets(120, 0, 0)[100:]...)); got != 20 {
+		t.Errorf("churn with 20 new markets = %d, want 20", got)
+	}
+}
+
+func TestAdaptiveSyncDisabled(t *testing.T) {
+	var cfg AdaptiveSyncConfig
+	if got := cfg.next(5*time.Minute, 1000); got != 5*time.Minute {
+		t.Errorf("disabled next() = %v, want the interval unchanged", got)
+	}
+	if got := cfg.clamp(time.Second); got != time.Second {
+		t.Errorf("disabled clamp() = %v, want the interval unchanged", got)
+	}
+}
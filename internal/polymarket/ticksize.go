@@ -0,0 +1,49 @@
+package polymarket
+
+import (
+	"sync"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// tickSizes records the active tick size of each token, from the CLOB
+// market metadata and tick_size_change events.
+type tickSizes struct {
+	mu    sync.RWMutex
+	ticks map[string]price.Tick
+}
+
+func newTickSizes() *tickSizes {
+	return &tickSizes{ticks: make(map[string]price.Tick)}
+}
+
+// set records tick as the token's tick size. Ticks <= 0 are ignored.
+func (ts *tickSizes) set(tokenID string, tick price.Tick) {
+	if tick <= 0 {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.ticks[tokenID] = tick
+}
+
+// get returns the token's tick size, or false if it isn't known.
+func (ts *tickSizes) get(tokenID string) (price.Tick, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	tick, ok := ts.ticks[tokenID]
+	return tick, ok
+}
+
+// round snaps p to the token's price grid so that an off-grid price doesn't
+// add a phantom level. Prices of tokens without a known tick size are
+// returned unchanged.
+func (ts *tickSizes) round(tokenID string, p price.Price) price.Price {
+	tick, _ := ts.get(tokenID)
+	return tick.RoundToTick(p)
+}
+
+// TickSize returns the token's active tick size, or false if it isn't known.
+func (p *Polymarket) TickSize(tokenID string) (price.Tick, bool) {
+	return p.ticks.get(tokenID)
+}
@@ -0,0 +1,54 @@
+package polymarket
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+	"github.com/daszybak/prediction_markets/internal/polymarket/websocket"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+func TestTickSizeChange(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := New(Config{}, nil, engine.New(engine.Config{}, logger), logger)
+
+	if got := p.ticks.round("t1", 123_456); got != 123_456 {
+		t.Errorf("round without a tick size = %d, want the price unchanged", got)
+	}
+
+	p.ticks.set("t1", 10_000)
+	if got := p.ticks.round("t1", 123_456); got != 120_000 {
+		t.Errorf("round at 0.01 = %d, want 120000", got)
+	}
+
+	err := p.processMessage(&websocket.Message{
+		EventType: websocket.TickSizeChangeEvent,
+		TickSizeChange: &websocket.TickSizeChange{
+			AssetID:     "t1",
+			OldTickSize: "0.01",
+			NewTickSize: "0.001",
+		},
+	})
+	if err != nil {
+		t.Fatalf("processMessage() error = %v", err)
+	}
+	if tick, ok := p.TickSize("t1"); !ok || tick != price.Tick(1_000) {
+		t.Errorf("TickSize() = %d, %t, want 1000, true", tick, ok)
+	}
+	if got := p.ticks.round("t1", 123_456); got != 123_000 {
+		t.Errorf("round at 0.001 = %d, want 123000", got)
+	}
+
+	err = p.processMessage(&websocket.Message{
+		EventType:      websocket.TickSizeChangeEvent,
+		TickSizeChange: &websocket.TickSizeChange{AssetID: "t1", NewTickSize: "0"},
+	})
+	if err == nil {
+		t.Error("processMessage() with a zero tick size didn't fail")
+	}
+	if tick, _ := p.TickSize("t1"); tick != 1_000 {
+		t.Errorf("TickSize() after an invalid change = %d, want 1000", tick)
+	}
+}
@@ -0,0 +1,121 @@
+package polymarket
+
+import (
+	"context"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+)
+
+// tier is the level of treatment a token gets when subscribing.
+type tier int
+
+const (
+	// tierFull tokens are subscribed and snapshotted at the full engine depth.
+	tierFull tier = iota
+	// tierReduced tokens are subscribed but snapshotted at TierConfig.ReducedDepth.
+	tierReduced
+	// tierSkip tokens aren't subscribed.
+	tierSkip
+)
+
+func (t tier) String() string {
+	switch t {
+	case tierFull:
+		return "full"
+	case tierReduced:
+		return "reduced"
+	case tierSkip:
+		return "skip"
+	default:
+		return "unknown"
+	}
+}
+
+// TierConfig ranks markets by their 24h Gamma volume. Markets with at least
+// FullMinVolume get full treatment, markets with at least ReducedMinVolume
+// are snapshotted at ReducedDepth, and the rest are skipped.
+//
+// The zero value disables tiering and every token gets full treatment.
+type TierConfig struct {
+	FullMinVolume    float64
+	ReducedMinVolume float64
+	ReducedDepth     int
+}
+
+func (c TierConfig) enabled() bool {
+	return c.FullMinVolume > 0 || c.ReducedMinVolume > 0
+}
+
+// assign returns the tier for a market with the given volume.
+func (c TierConfig) assign(volume float64) tier {
+	switch {
+	case !c.enabled(), volume >= c.FullMinVolume:
+		return tierFull
+	case volume >= c.ReducedMinVolume:
+		return tierReduced
+	default:
+		return tierSkip
+	}
+}
+
+// assignTokens returns the tier for each token. Tokens without volume data
+// get full treatment, so missing metadata never drops a subscription.
+func (c TierConfig) assignTokens(tokenIDs []string, volumes map[string]float64) map[string]tier {
+	tiers := make(map[string]tier, len(tokenIDs))
+	for _, id := range tokenIDs {
+		volume, ok := volumes[id]
+		if !ok {
+			tiers[id] = tierFull
+			continue
+		}
+		tiers[id] = c.assign(volume)
+	}
+	return tiers
+}
+
+// tokenVolumes maps each token to its market's 24h volume.
+func tokenVolumes(markets []*gamma.Market) map[string]float64 {
+	volumes := make(map[string]float64)
+	for _, m := range markets {
+		for _, id := range m.ClobTokenIDs {
+			volumes[id] = m.Volume24hr
+		}
+	}
+	return volumes
+}
+
+// applyTiers returns the tokens to subscribe to and sets the engine's
+// snapshot depth limit for each of them. If volumes can't be fetched every
+// token is subscribed.
+func (p *Polymarket) applyTiers(ctx context.Context, tokenIDs []string) []string {
+	if !p.config.Tiers.enabled() {
+		return tokenIDs
+	}
+
+	markets, err := p.gamma.GetAllMarkets(ctx)
+	if err != nil {
+		p.log.Warn("couldn't get market volumes, subscribing to all tokens", "error", err)
+		return tokenIDs
+	}
+
+	tiers := p.config.Tiers.assignTokens(tokenIDs, tokenVolumes(markets))
+	counts := make(map[tier]int)
+	subscribe := make([]string, 0, len(tokenIDs))
+	for _, id := range tokenIDs {
+		t := tiers[id]
+		counts[t]++
+		switch t {
+		case tierSkip:
+			continue
+		case tierReduced:
+			p.engine.SetDepthLimit(platformName, id, p.config.Tiers.ReducedDepth)
+		default:
+			p.engine.SetDepthLimit(platformName, id, 0)
+		}
+		subscribe = append(subscribe, id)
+	}
+
+	p.log.Info("assigned subscription tiers",
+		"full", counts[tierFull], "reduced", counts[tierReduced], "skipped", counts[tierSkip])
+	return subscribe
+}
@@ -0,0 +1,62 @@
+package polymarket
+
+import (
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/polymarket/gamma"
+)
+
+func TestTierConfigAssign(t *testing.T) {
+	cfg := TierConfig{FullMinVolume: 10_000, ReducedMinVolume: 500}
+
+	tests := []struct {
+		volume float64
+		want   tier
+	}{
+		{volume: 250_000, want: tierFull},
+		{volume: 10_000, want: tierFull},
+		{volume: 9_999.99, want: tierReduced},
+		{volume: 500, want: tierReduced},
+		{volume: 499, want: tierSkip},
+		{volume: 0, want: tierSkip},
+	}
+	for _, tt := range tests {
+		if got := cfg.assign(tt.volume); got != tt.want {
+			t.Errorf("assign(%v) = %v, want %v", tt.volume, got, tt.want)
+		}
+	}
+}
+
+func TestTierConfigDisabled(t *testing.T) {
+	var cfg TierConfig
+	if got := cfg.assign(0); got != tierFull {
+		t.Errorf("assign(0) with tiering disabled = %v, want %v", got, tierFull)
+	}
+}
+
+func TestTierConfigAssignTokens(t *testing.T) {
+	cfg := TierConfig{FullMinVolume: 1000, ReducedMinVolume: 100}
+	markets := []*gamma.Market{
+		{ClobTokenIDs: gamma.TokenIDs{"hot-yes", "hot-no"}, Volume24hr: 5000},
+		{ClobTokenIDs: gamma.TokenIDs{"warm-yes", "warm-no"}, Volume24hr: 150},
+		{ClobTokenIDs: gamma.TokenIDs{"cold-yes", "cold-no"}, Volume24hr: 3},
+	}
+
+	got := cfg.assignTokens([]string{"hot-yes", "hot-no", "warm-yes", "cold-no", "unknown"}, tokenVolumes(markets))
+
+	want := map[string]tier{
+		"hot-yes":  tierFull,
+		"hot-no":   tierFull,
+		"warm-yes": tierReduced,
+		"cold-no":  tierSkip,
+		"unknown":  tierFull,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tiers, want %d", len(got), len(want))
+	}
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("tier for %s = %v, want %v", id, got[id], w)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package polymarket
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/daszybak/prediction_markets/pkg/hashset"
+)
+
+// TokenFilter overrides the set of tokens subscribed to, e.g. to reproduce
+// an issue with specific markets.
+type TokenFilter struct {
+	// Allow, if not empty, is subscribed to instead of the platform's tokens
+	// from the database. Tiering is skipped.
+	Allow []string
+	// Block is never subscribed to.
+	Block []string
+}
+
+// LoadTokenList reads token IDs from a file, one per line. Blank lines and
+// lines starting with # are ignored.
+func LoadTokenList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open token list: %w", err)
+	}
+	defer f.Close()
+
+	var tokenIDs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokenIDs = append(tokenIDs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read token list %s: %w", path, err)
+	}
+	return tokenIDs, nil
+}
+
+// apply returns the allowlist, if set, or tokenIDs, without blocked tokens.
+func (f TokenFilter) apply(tokenIDs []string) []string {
+	if len(f.Allow) > 0 {
+		tokenIDs = f.Allow
+	}
+	if len(f.Block) == 0 {
+		return tokenIDs
+	}
+
+	blocked := hashset.SetFromSlice(f.Block)
+	selected := make([]string, 0, len(tokenIDs))
+	for _, id := range tokenIDs {
+		if !blocked.Has(id) {
+			selected = append(selected, id)
+		}
+	}
+	return selected
+}
+
+// selectTokens returns the tokens to subscribe to out of the platform's
+// tokens. Tiering only applies without an allowlist.
+func (p *Polymarket) selectTokens(ctx context.Context, tokenIDs []string) []string {
+	if len(p.config.Tokens.Allow) == 0 {
+		tokenIDs = p.applyTiers(ctx, tokenIDs)
+	}
+	return p.config.Tokens.apply(tokenIDs)
+}
@@ -0,0 +1,59 @@
+package polymarket
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/engine"
+)
+
+func writeTokenList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write token list: %v", err)
+	}
+	return path
+}
+
+func TestAllowlistReplacesSyncedTokens(t *testing.T) {
+	allow, err := LoadTokenList(writeTokenList(t, "# debugging the fed market\nfed-yes\n\n  fed-no  \n"))
+	if err != nil {
+		t.Fatalf("load allowlist: %v", err)
+	}
+	if want := []string{"fed-yes", "fed-no"}; !slices.Equal(allow, want) {
+		t.Fatalf("loaded %v, want %v", allow, want)
+	}
+
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	// Tiering would need Gamma, so with an allowlist it must not run.
+	p := New(Config{
+		Tiers:  TierConfig{FullMinVolume: 1000, ReducedMinVolume: 100},
+		Tokens: TokenFilter{Allow: allow, Block: []string{"fed-no"}},
+	}, nil, engine.New(engine.Config{}, logger), logger)
+
+	got := p.selectTokens(context.Background(), []string{"a", "b", "fed-yes"})
+	if want := []string{"fed-yes"}; !slices.Equal(got, want) {
+		t.Errorf("selected %v, want %v", got, want)
+	}
+}
+
+func TestBlocklistFiltersSyncedTokens(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&syncBuffer{}, nil))
+	p := New(Config{Tokens: TokenFilter{Block: []string{"b"}}}, nil, engine.New(engine.Config{}, logger), logger)
+
+	got := p.selectTokens(context.Background(), []string{"a", "b", "c"})
+	if want := []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("selected %v, want %v", got, want)
+	}
+}
+
+func TestLoadTokenListMissingFile(t *testing.T) {
+	if _, err := LoadTokenList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
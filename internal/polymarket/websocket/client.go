@@ -2,16 +2,27 @@
 package websocket
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/price"
 )
 
+// platformName labels the metrics of this package.
+const platformName = "polymarket"
+
 const (
 	HandshakeTimeout    = 30 * time.Second
 	DefaultCloseTimeout = 5 * time.Second
@@ -19,22 +30,87 @@ const (
 	PingInterval        = 50 * time.Second
 )
 
+var (
+	// ErrParse is returned by ReadMessage when a frame was read but couldn't be
+	// parsed. The connection itself is still usable.
+	ErrParse = errors.New("couldn't parse message")
+	// ErrCloseTimeout is returned by Close when the server didn't acknowledge
+	// the close frame before the deadline.
+	ErrCloseTimeout = errors.New("close handshake timed out")
+	// ErrSubscriptionRejected is returned by ReadMessage when the server
+	// rejected a subscription message, and by AwaitSubscription. The
+	// connection itself is still usable.
+	ErrSubscriptionRejected = errors.New("subscription rejected")
+	// ErrNoSubscriptionData is returned by AwaitSubscription when no frame
+	// arrived in time.
+	ErrNoSubscriptionData = errors.New("no data after subscribing")
+	// ErrNoAuth is returned by SubscribeUser without credentials, which the
+	// user channel requires.
+	ErrNoAuth = errors.New("user channel requires credentials")
+)
+
+// rejectionFrame is the text frame the server answers messages it doesn't
+// accept with, e.g. a subscription with unknown fields. It doesn't
+// acknowledge accepted subscriptions.
+const rejectionFrame = "INVALID OPERATION"
+
 type Client struct {
-	conn     *websocket.Conn
-	stopPing chan struct{}
+	conn *websocket.Conn
+	// closing is closed by Close and ForceClose. It stops pingLoop and makes
+	// readPump discard frames.
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// frames carries the frames read by readPump to ReadMessage. pumpDone
+	// is closed when readPump stopped, after it set readErr.
+	frames   chan []byte
+	pumpDone chan struct{}
+	readErr  error
+	// lastFrame is the Unix time in nanoseconds at which the last frame,
+	// including pongs, was received, or the connection was established.
+	lastFrame atomic.Int64
+
+	// lastData and lastRejection are the Unix times in nanoseconds at which
+	// the last data frame and the last rejectionFrame were read. received
+	// is closed and replaced after each, see AwaitSubscription.
+	lastData      atomic.Int64
+	lastRejection atomic.Int64
+	receivedMu    sync.Mutex
+	received      chan struct{}
+
+	// pending holds the events of the last frame that ReadMessage hasn't
+	// returned yet. Only used by ReadMessage.
+	pending []parsedEvent
 }
 
+// parsedEvent is one event of a frame, or the error parsing it.
+type parsedEvent struct {
+	msg *Message
+	err error
+}
+
+// Auth holds the CLOB API credentials sent with a subscription.
 type Auth struct {
 	APIKey     string `json:"apiKey"`
 	Secret     string `json:"secret"`
 	Passphrase string `json:"passphrase"`
 }
 
-type MarketSubscription struct {
+// Channels a Subscription can be for.
+const (
+	ChannelMarket   = "market"
+	ChannelUser     = "user"
+	ChannelActivity = "activity"
+)
+
+// Subscription is the first message sent on a connection, it selects the
+// channel and what to receive on it.
+type Subscription struct {
 	Auth        *Auth    `json:"auth"`
-	AssetsIDs   []string `json:"assets_ids"`
+	AssetsIDs   []string `json:"assets_ids,omitempty"` // Tokens, for the market channel.
+	Markets     []string `json:"markets,omitempty"`    // Condition IDs, for the user and activity channels.
 	Type        string   `json:"type"`
-	InitialDump *bool    `json:"initial_dump"`
+	InitialDump *bool    `json:"initial_dump,omitempty"`
 }
 
 func New(ctx context.Context, url string, endpoint string) (*Client, error) {
@@ -50,20 +126,40 @@ func New(ctx context.Context, url string, endpoint string) (*Client, error) {
 
 	c := &Client{
 		conn:     conn,
-		stopPing: make(chan struct{}),
+		closing:  make(chan struct{}),
+		frames:   make(chan []byte),
+		pumpDone: make(chan struct{}),
+		received: make(chan struct{}),
 	}
+	c.touch()
+	conn.SetPongHandler(func(string) error {
+		c.touch()
+		return nil
+	})
 	go c.pingLoop()
+	go c.readPump()
 
 	return c, nil
 }
 
+// touch records that the connection is alive now.
+func (c *Client) touch() {
+	c.lastFrame.Store(time.Now().UnixNano())
+}
+
+// LastFrame returns when a frame was last received, or when the connection was
+// established if none was yet.
+func (c *Client) LastFrame() time.Time {
+	return time.Unix(0, c.lastFrame.Load())
+}
+
 func (c *Client) pingLoop() {
 	ticker := time.NewTicker(PingInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-c.stopPing:
+		case <-c.closing:
 			return
 		case <-ticker.C:
 			deadline := time.Now().Add(DefaultWriteTimeout)
@@ -75,8 +171,25 @@ func (c *Client) pingLoop() {
 	}
 }
 
+// Close codes for CloseWithReason, see RFC 6455 section 7.4.1.
+const (
+	CloseNormal        = websocket.CloseNormalClosure
+	CloseGoingAway     = websocket.CloseGoingAway
+	CloseProtocolError = websocket.CloseProtocolError
+)
+
+// Close sends a close frame with CloseNormal and waits for the server to
+// acknowledge it until the ctx deadline (or DefaultCloseTimeout), then closes
+// the connection. Frames arriving meanwhile are discarded.
 func (c *Client) Close(ctx context.Context) error {
-	close(c.stopPing)
+	return c.CloseWithReason(ctx, CloseNormal, "")
+}
+
+// CloseWithReason is Close with the given close code and reason in the close
+// frame, telling the server why the connection is closed. The reason must
+// fit the frame, at most 123 bytes.
+func (c *Client) CloseWithReason(ctx context.Context, code int, reason string) error {
+	c.closeOnce.Do(func() { close(c.closing) })
 
 	deadline, ok := ctx.Deadline()
 	if !ok {
@@ -85,67 +198,238 @@ func (c *Client) Close(ctx context.Context) error {
 
 	err := c.conn.WriteControl(
 		websocket.CloseMessage,
-		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		websocket.FormatCloseMessage(code, reason),
 		deadline,
 	)
 	if err != nil {
 		log.Printf("failed to send close message: %v", err)
+		return c.conn.Close()
 	}
 
+	if err := c.awaitCloseAck(deadline); err != nil {
+		_ = c.conn.Close()
+		return err
+	}
 	return c.conn.Close()
 }
 
-func (c *Client) SubscribeMarket(ctx context.Context, tokenIDs []string, initialDump bool, _ *Auth) error {
+// ForceClose closes the underlying network connection without a close
+// handshake.
+func (c *Client) ForceClose() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	return c.conn.Close()
+}
+
+// awaitCloseAck waits until readPump stopped, which it does once the
+// server's close frame arrived or the connection is gone, or the deadline
+// passes.
+func (c *Client) awaitCloseAck(deadline time.Time) error {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-c.pumpDone:
+		return nil
+	case <-timer.C:
+		return ErrCloseTimeout
+	}
+}
+
+// SubscribeMarket subscribes to the order book and price events of the given
+// tokens. auth is optional, the market channel is public.
+func (c *Client) SubscribeMarket(ctx context.Context, tokenIDs []string, initialDump bool, auth *Auth) error {
+	return c.subscribe(ctx, Subscription{
+		Auth:        auth,
+		AssetsIDs:   tokenIDs,
+		Type:        ChannelMarket,
+		InitialDump: &initialDump,
+	})
+}
+
+// SubscribeUser subscribes to the order and trade events of the authenticated
+// user in the given markets. It returns ErrNoAuth if auth is nil.
+func (c *Client) SubscribeUser(ctx context.Context, markets []string, auth *Auth) error {
+	if auth == nil {
+		return ErrNoAuth
+	}
+	return c.subscribe(ctx, Subscription{
+		Auth:    auth,
+		Markets: markets,
+		Type:    ChannelUser,
+	})
+}
+
+// SubscribeActivity subscribes to the trade and comment events of the given
+// markets, or of all markets if there are none.
+func (c *Client) SubscribeActivity(ctx context.Context, markets []string) error {
+	return c.subscribe(ctx, Subscription{
+		Markets: markets,
+		Type:    ChannelActivity,
+	})
+}
+
+func (c *Client) subscribe(ctx context.Context, sub Subscription) error {
+	return c.writeJSON(ctx, sub)
+}
+
+// SubscriptionUpdate changes the assets of an open market subscription.
+type SubscriptionUpdate struct {
+	AssetsIDs []string `json:"assets_ids"`
+	Operation string   `json:"operation"` // subscribe or unsubscribe
+}
+
+// UnsubscribeMarket stops the updates for tokenIDs on this connection.
+func (c *Client) UnsubscribeMarket(ctx context.Context, tokenIDs []string) error {
+	return c.writeJSON(ctx, SubscriptionUpdate{
+		AssetsIDs: tokenIDs,
+		Operation: "unsubscribe",
+	})
+}
+
+// writeJSON sends v before the deadline of ctx, or DefaultWriteTimeout if it
+// has none.
+func (c *Client) writeJSON(ctx context.Context, v any) error {
 	deadline, ok := ctx.Deadline()
 	if !ok {
 		deadline = time.Now().Add(DefaultWriteTimeout)
 	}
 	c.conn.SetWriteDeadline(deadline)
 
-	sub := MarketSubscription{
-		AssetsIDs:   tokenIDs,
-		Type:        "market",
-		InitialDump: &initialDump,
+	return c.conn.WriteJSON(v)
+}
+
+// notifyReceived records a data frame, or a rejection, read at now and wakes
+// AwaitSubscription.
+func (c *Client) notifyReceived(now time.Time, rejection bool) {
+	if rejection {
+		c.lastRejection.Store(now.UnixNano())
+	} else {
+		c.lastData.Store(now.UnixNano())
 	}
-	return c.conn.WriteJSON(sub)
+
+	c.receivedMu.Lock()
+	close(c.received)
+	c.received = make(chan struct{})
+	c.receivedMu.Unlock()
 }
 
-type result struct {
-	RawMessage []byte
-	Error      error
+// AwaitSubscription waits up to timeout for the server to respond to a
+// subscription sent at since. Polymarket doesn't acknowledge subscriptions,
+// so any data frame read after since counts as accepted, and
+// ErrSubscriptionRejected is returned if a rejection was read first.
+// Frames of earlier subscriptions count too, so without an initial dump this
+// only shows that the connection is still delivering. Frames must be read
+// by another goroutine meanwhile, e.g. a loop calling ReadMessage.
+func (c *Client) AwaitSubscription(ctx context.Context, since time.Time, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.receivedMu.Lock()
+		received := c.received
+		c.receivedMu.Unlock()
+
+		// A rejection wins, it may be followed by data of other
+		// subscriptions.
+		if c.lastRejection.Load() >= since.UnixNano() {
+			return ErrSubscriptionRejected
+		}
+		if c.lastData.Load() >= since.UnixNano() {
+			return nil
+		}
+
+		select {
+		case <-received:
+		case <-timer.C:
+			return fmt.Errorf("%w within %v", ErrNoSubscriptionData, timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
+// ReadMessage returns the next event. The server sends some events, e.g. the
+// books of an initial dump, as a JSON array in one frame; their events are
+// returned one by one in order, and an event that couldn't be parsed doesn't
+// affect the others. ReadMessage must not be called concurrently.
 func (c *Client) ReadMessage(ctx context.Context) (*Message, error) {
-	resultCh := make(chan result, 1)
+	// An empty array holds no events, so keep reading.
+	for len(c.pending) == 0 {
+		frame, err := c.readFrame(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.pending = c.parseFrame(frame)
+	}
 
-	go func() {
+	next := c.pending[0]
+	c.pending[0] = parsedEvent{}
+	c.pending = c.pending[1:]
+	return next.msg, next.err
+}
+
+// readPump reads frames and hands them to ReadMessage until reading fails,
+// e.g. because the connection was closed. It is the connection's only reader,
+// so a ReadMessage cancelled by its context leaves the connection usable and
+// no goroutine behind.
+func (c *Client) readPump() {
+	defer close(c.pumpDone)
+
+	for {
 		_, msg, err := c.conn.ReadMessage()
-		resultCh <- result{
-			RawMessage: msg,
-			Error:      err,
+		if err != nil {
+			c.readErr = err
+			return
 		}
-	}()
+		c.touch()
 
+		select {
+		case c.frames <- msg:
+		case <-c.closing:
+			// Keep reading until the server's close frame.
+		}
+	}
+}
+
+// readFrame returns the next data frame read by readPump.
+func (c *Client) readFrame(ctx context.Context) ([]byte, error) {
 	select {
 	case <-ctx.Done():
-		if err := c.conn.SetReadDeadline(time.Now()); err != nil {
-			log.Printf("failed to set read deadline: %v", err)
-		}
 		return nil, fmt.Errorf("reading message: %w", ctx.Err())
-	case result := <-resultCh:
-		if result.Error != nil {
-			return nil, fmt.Errorf("couldn't read message: %w", result.Error)
+	case <-c.pumpDone:
+		return nil, fmt.Errorf("couldn't read message: %w", c.readErr)
+	case frame := <-c.frames:
+		if string(bytes.TrimSpace(frame)) == rejectionFrame {
+			c.notifyReceived(time.Now(), true)
+			return nil, fmt.Errorf("%w: server replied %q", ErrSubscriptionRejected, rejectionFrame)
 		}
-		msg, err := c.ParseMessage(result.RawMessage)
+		c.notifyReceived(time.Now(), false)
+		return frame, nil
+	}
+}
+
+// parseFrame parses a frame holding a single event or a JSON array of events.
+func (c *Client) parseFrame(frame []byte) []parsedEvent {
+	var events []json.RawMessage
+	if trimmed := bytes.TrimSpace(frame); len(trimmed) == 0 || trimmed[0] != '[' || json.Unmarshal(trimmed, &events) != nil {
+		events = []json.RawMessage{frame}
+	}
+
+	parsed := make([]parsedEvent, 0, len(events))
+	for _, event := range events {
+		msg, err := c.ParseMessage(event)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't parse message: %w", err)
+			err = fmt.Errorf("%w: %w", ErrParse, err)
 		}
-		return msg, nil
+		parsed = append(parsed, parsedEvent{msg: msg, err: err})
 	}
+	return parsed
 }
 
 type Message struct {
-	EventType      string `json:"event_type"`
+	EventType string `json:"event_type"`
+	// Topic is set instead of EventType by the activity channel.
+	Topic          string `json:"topic"`
 	Book           *Book
 	PriceChange    *PriceChange
 	BestBidAsk     *BestBidAsk
@@ -153,30 +437,85 @@ type Message struct {
 	LastTradePrice *LastTradePrice
 	NewMarket      *NewMarket
 	MarketResolved *MarketResolved
+	Activity       *Activity
 }
 
+// Book is the full book of a token, sent on subscribe and after every trade.
+// Older frames name the sides buys and sells, which are decoded into Bids and
+// Asks.
 type Book struct {
 	AssetID   string         `json:"asset_id"`
 	Market    string         `json:"market"`
-	Timestamp string         `json:"timestamp"`
+	Timestamp string         `json:"timestamp"` // Unix milliseconds.
 	Hash      string         `json:"hash"`
-	Buys      []OrderSummary `json:"buys"`
-	Sells     []OrderSummary `json:"sells"`
+	Bids      []OrderSummary `json:"bids"`
+	Asks      []OrderSummary `json:"asks"`
 }
 
+func (b *Book) UnmarshalJSON(data []byte) error {
+	type plain Book
+	var raw struct {
+		plain
+		Buys  []OrderSummary `json:"buys"`
+		Sells []OrderSummary `json:"sells"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*b = Book(raw.plain)
+	if b.Bids == nil {
+		b.Bids = raw.Buys
+	}
+	if b.Asks == nil {
+		b.Asks = raw.Sells
+	}
+	return nil
+}
+
+// OrderSummary is a price level of a Book.
 type OrderSummary struct {
-	Price string `json:"price"`
-	Size  string `json:"size"`
+	Price price.Price `json:"price"`
+	Size  price.Size  `json:"size"`
 }
 
+// PriceChange is a price_change event, sent when orders are placed or
+// cancelled. Changes lists the changed levels, possibly of several tokens of
+// the market, each with its own asset ID. Older frames carry a single change
+// inline, which is decoded into Changes.
 type PriceChange struct {
-	AssetID string `json:"asset_id"`
-	Price   string `json:"price"`
-	Size    string `json:"size"`
-	Side    string `json:"side"`
-	Hash    string `json:"hash"`
-	BestBid string `json:"best_ask"`
-	BestAsk string `json:"best_bid"`
+	Market    string             `json:"market"`
+	Timestamp string             `json:"timestamp"` // Unix milliseconds.
+	Changes   []PriceLevelChange `json:"price_changes"`
+}
+
+func (p *PriceChange) UnmarshalJSON(data []byte) error {
+	type plain PriceChange
+	var raw struct {
+		plain
+		PriceLevelChange
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*p = PriceChange(raw.plain)
+	if len(p.Changes) == 0 && raw.Side != "" {
+		p.Changes = []PriceLevelChange{raw.PriceLevelChange}
+	}
+	return nil
+}
+
+// PriceLevelChange is one changed level. Size is the new size of the level,
+// 0 when it was emptied.
+type PriceLevelChange struct {
+	AssetID string      `json:"asset_id"`
+	Price   price.Price `json:"price"`
+	Size    price.Size  `json:"size"`
+	Side    string      `json:"side"` // BUY or SELL
+	Hash    string      `json:"hash"`
+	BestBid string      `json:"best_bid"`
+	BestAsk string      `json:"best_ask"`
 }
 
 type TickSizeChange struct {
@@ -235,90 +574,151 @@ type MarketResolved struct {
 	EventMessage      EventMessage `json:"event_message"`
 }
 
+// Activity is a trade or comment event of the activity channel.
+type Activity struct {
+	Topic     string          `json:"topic"` // TopicActivity or TopicComments.
+	Type      string          `json:"type"`  // e.g. trades or comment_created.
+	Timestamp int64           `json:"timestamp"`
+	Payload   ActivityPayload `json:"payload"`
+}
+
+// ActivityPayload holds the fields of trade and comment events used for
+// aggregates. Price and size are only set for trades.
+type ActivityPayload struct {
+	ConditionID string      `json:"conditionId"`
+	Asset       string      `json:"asset"`
+	Side        string      `json:"side"`
+	Price       json.Number `json:"price"`
+	Size        json.Number `json:"size"`
+}
+
+// Topics of activity channel frames.
+const (
+	TopicActivity = "activity"
+	TopicComments = "comments"
+)
+
 const (
 	BookEvent           = "book"
 	PriceChangeEvent    = "price_change"
 	TickSizeChangeEvent = "tick_size_change"
+	LastTradePriceEvent = "last_trade_price"
 	BestBidAskEvent     = "best_bid_ask"
 	NewMarketEvent      = "new_market"
 	MarketResolvedEvent = "market_resolved"
+	// ActivityEvent is the event type given to activity channel frames,
+	// which have a topic instead.
+	ActivityEvent = "activity"
 )
 
+// Labels of frames that aren't a known event for metrics.WebsocketFrames.
+const (
+	unknownEvent = "unknown"
+	invalidEvent = "invalid"
+)
+
+// ParseMessage parses a frame into a Message and counts it by event type in
+// metrics.WebsocketFrames.
 func (c *Client) ParseMessage(msg []byte) (*Message, error) {
+	parsed, err := parseMessage(msg)
+	eventType := invalidEvent
+	if parsed != nil {
+		eventType = parsed.EventType
+	} else if errors.Is(err, errUnknownEvent) {
+		eventType = unknownEvent
+	}
+	metrics.WebsocketFrames.WithLabelValues(platformName, eventType).Inc()
+	return parsed, err
+}
+
+// errUnknownEvent is returned by parseMessage for well-formed frames of an
+// event type it doesn't know.
+var errUnknownEvent = errors.New("couldn't find event type")
+
+func parseMessage(msg []byte) (*Message, error) {
 	base := &Message{}
-	err := json.Unmarshal(msg, base)
-	if err != nil {
+	if err := json.Unmarshal(msg, base); err != nil {
 		return nil, fmt.Errorf("couldn't parse base message: %w", err)
 	}
+	if base.EventType == "" && (base.Topic == TopicActivity || base.Topic == TopicComments) {
+		base.EventType = ActivityEvent
+	}
 
+	parsed := &Message{EventType: base.EventType}
+	var target any
 	switch base.EventType {
 	case BookEvent:
-		book := &Book{}
-		err = json.Unmarshal(msg, book)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse book event: %w", err)
-		}
-
-		return &Message{
-			EventType: BookEvent,
-			Book:      book,
-		}, nil
+		parsed.Book = &Book{}
+		target = parsed.Book
 	case PriceChangeEvent:
-		pC := &PriceChange{}
-		err = json.Unmarshal(msg, pC)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse price change event: %w", err)
-		}
-
-		return &Message{
-			EventType:   PriceChangeEvent,
-			PriceChange: pC,
-		}, nil
+		parsed.PriceChange = &PriceChange{}
+		target = parsed.PriceChange
 	case TickSizeChangeEvent:
-		tSC := &TickSizeChange{}
-		err = json.Unmarshal(msg, tSC)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse tick size change event: %w", err)
-		}
-
-		return &Message{
-			EventType:      PriceChangeEvent,
-			TickSizeChange: tSC,
-		}, nil
+		parsed.TickSizeChange = &TickSizeChange{}
+		target = parsed.TickSizeChange
+	case LastTradePriceEvent:
+		parsed.LastTradePrice = &LastTradePrice{}
+		target = parsed.LastTradePrice
 	case BestBidAskEvent:
-		bbA := &BestBidAsk{}
-		err = json.Unmarshal(msg, bbA)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse best bid ask event: %w", err)
-		}
-
-		return &Message{
-			EventType:  PriceChangeEvent,
-			BestBidAsk: bbA,
-		}, nil
+		parsed.BestBidAsk = &BestBidAsk{}
+		target = parsed.BestBidAsk
 	case NewMarketEvent:
-		nM := &NewMarket{}
-		err = json.Unmarshal(msg, nM)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse new market event: %w", err)
+		parsed.NewMarket = &NewMarket{}
+		target = parsed.NewMarket
+	case MarketResolvedEvent:
+		parsed.MarketResolved = &MarketResolved{}
+		target = parsed.MarketResolved
+	case ActivityEvent:
+		parsed.Activity = &Activity{}
+		target = parsed.Activity
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownEvent, base.EventType)
+	}
+
+	if err := json.Unmarshal(msg, target); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s event: %w", base.EventType, err)
+	}
+	return parsed, nil
+}
+
+// AssetID returns the token the message is about, or "" for market-wide
+// events.
+func (m *Message) AssetID() string {
+	switch {
+	case m.Book != nil:
+		return m.Book.AssetID
+	case m.PriceChange != nil:
+		// Changes of one frame can be of several tokens, use the first.
+		if len(m.PriceChange.Changes) > 0 {
+			return m.PriceChange.Changes[0].AssetID
 		}
+		return ""
+	case m.TickSizeChange != nil:
+		return m.TickSizeChange.AssetID
+	case m.LastTradePrice != nil:
+		return m.LastTradePrice.AssetID
+	case m.BestBidAsk != nil:
+		return m.BestBidAsk.AssetID
+	default:
+		return ""
+	}
+}
 
-		return &Message{
-			EventType: PriceChangeEvent,
-			NewMarket: nM,
-		}, nil
-	case MarketResolvedEvent:
-		mR := &MarketResolved{}
-		err = json.Unmarshal(msg, mR)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't parse market resolved event: %w", err)
+// AssetIDs returns every token the message is about, which can be several
+// for price_change events.
+func (m *Message) AssetIDs() []string {
+	if m.PriceChange == nil {
+		if id := m.AssetID(); id != "" {
+			return []string{id}
 		}
+		return nil
+	}
 
-		return &Message{
-			EventType:      PriceChangeEvent,
-			MarketResolved: mR,
-		}, nil
-	default:
-		return nil, fmt.Errorf("couldn't find event type")
+	var ids []string
+	for _, level := range m.PriceChange.Changes {
+		if level.AssetID != "" && !slices.Contains(ids, level.AssetID) {
+			ids = append(ids, level.AssetID)
+		}
 	}
+	return ids
 }
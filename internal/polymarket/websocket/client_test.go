@@ -0,0 +1,298 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestServer(t *testing.T, handle func(*websocket.Conn)) *httptest.Server {
+	t.Helper()
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialTestServer(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	c, err := New(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), "/ws/market")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return c
+}
+
+func TestCloseWaitsForAck(t *testing.T) {
+	srv := newTestServer(t, func(conn *websocket.Conn) {
+		// Reading makes gorilla echo the close frame.
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	c := dialTestServer(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestCloseTimesOutWithoutAck(t *testing.T) {
+	release := make(chan struct{})
+	srv := newTestServer(t, func(*websocket.Conn) { <-release })
+	defer close(release)
+	c := dialTestServer(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Close(ctx); !errors.Is(err, ErrCloseTimeout) {
+		t.Errorf("Close: got %v, want ErrCloseTimeout", err)
+	}
+}
+
+func TestSubscribePayload(t *testing.T) {
+	auth := &Auth{APIKey: "key", Secret: "secret", Passphrase: "pass"}
+	tests := []struct {
+		name      string
+		subscribe func(*Client) error
+		want      Subscription
+	}{
+		{"market", func(c *Client) error {
+			return c.SubscribeMarket(context.Background(), []string{"token"}, true, nil)
+		}, Subscription{Type: ChannelMarket, AssetsIDs: []string{"token"}}},
+		{"market with auth", func(c *Client) error {
+			return c.SubscribeMarket(context.Background(), []string{"token"}, true, auth)
+		}, Subscription{Type: ChannelMarket, AssetsIDs: []string{"token"}, Auth: auth}},
+		{"user", func(c *Client) error {
+			return c.SubscribeUser(context.Background(), []string{"0xmarket"}, auth)
+		}, Subscription{Type: ChannelUser, Markets: []string{"0xmarket"}, Auth: auth}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := make(chan Subscription, 1)
+			srv := newTestServer(t, func(conn *websocket.Conn) {
+				var sub Subscription
+				if err := conn.ReadJSON(&sub); err == nil {
+					subs <- sub
+				}
+			})
+			c := dialTestServer(t, srv)
+			defer c.Close(context.Background())
+
+			if err := tt.subscribe(c); err != nil {
+				t.Fatalf("subscribe: %v", err)
+			}
+			select {
+			case sub := <-subs:
+				if sub.Type != tt.want.Type {
+					t.Errorf("type = %q, want %q", sub.Type, tt.want.Type)
+				}
+				if !slices.Equal(sub.AssetsIDs, tt.want.AssetsIDs) || !slices.Equal(sub.Markets, tt.want.Markets) {
+					t.Errorf("assets %v, markets %v; want %v, %v", sub.AssetsIDs, sub.Markets, tt.want.AssetsIDs, tt.want.Markets)
+				}
+				switch {
+				case tt.want.Auth == nil && sub.Auth != nil:
+					t.Errorf("auth = %+v, want none", *sub.Auth)
+				case tt.want.Auth != nil && (sub.Auth == nil || *sub.Auth != *tt.want.Auth):
+					t.Errorf("auth = %+v, want %+v", sub.Auth, *tt.want.Auth)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no subscription received")
+			}
+		})
+	}
+}
+
+func TestSubscribeUserRequiresAuth(t *testing.T) {
+	srv := newTestServer(t, func(conn *websocket.Conn) { conn.ReadMessage() })
+	c := dialTestServer(t, srv)
+	defer c.Close(context.Background())
+
+	if err := c.SubscribeUser(context.Background(), []string{"0xmarket"}, nil); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("SubscribeUser without auth = %v, want ErrNoAuth", err)
+	}
+}
+
+// readAll reads messages from c until it is closed, like the caller's read
+// loop would.
+func readAll(c *Client) {
+	for {
+		if _, err := c.ReadMessage(context.Background()); err != nil &&
+			!errors.Is(err, ErrParse) && !errors.Is(err, ErrSubscriptionRejected) {
+			return
+		}
+	}
+}
+
+func TestAwaitSubscription(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string // Sent after the subscription, nothing if empty.
+		want  error
+	}{
+		{"rejected", "INVALID OPERATION", ErrSubscriptionRejected},
+		{"data", `{"event_type": "book", "asset_id": "token"}`, nil},
+		{"silent", "", ErrNoSubscriptionData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			srv := newTestServer(t, func(conn *websocket.Conn) {
+				var sub Subscription
+				if err := conn.ReadJSON(&sub); err != nil {
+					return
+				}
+				if tt.reply != "" {
+					_ = conn.WriteMessage(websocket.TextMessage, []byte(tt.reply))
+				}
+				<-release
+			})
+			defer close(release)
+			c := dialTestServer(t, srv)
+			defer c.ForceClose()
+			go readAll(c)
+
+			ctx := context.Background()
+			sentAt := time.Now()
+			if err := c.SubscribeMarket(ctx, []string{"token"}, true, nil); err != nil {
+				t.Fatalf("SubscribeMarket: %v", err)
+			}
+			err := c.AwaitSubscription(ctx, sentAt, 200*time.Millisecond)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("AwaitSubscription() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMessageSplitsArrays(t *testing.T) {
+	srv := newTestServer(t, func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`[{"event_type":"book","asset_id":"a"},{"event_type":"book","asset_id":"b"}]`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`[]`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event_type":"book","asset_id":"c"}`))
+		// Keep the connection open until the client is done.
+		conn.ReadMessage()
+	})
+	c := dialTestServer(t, srv)
+	defer c.Close(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []string{"a", "b", "c"} {
+		msg, err := c.ReadMessage(ctx)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if got := msg.AssetID(); got != want {
+			t.Errorf("AssetID() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestCloseWithReasonSendsCodeAndReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		close  func(*Client, context.Context) error
+		code   int
+		reason string
+	}{
+		{"default", (*Client).Close, CloseNormal, ""},
+		{"going away", func(c *Client, ctx context.Context) error {
+			return c.CloseWithReason(ctx, CloseGoingAway, "collector stopping")
+		}, CloseGoingAway, "collector stopping"},
+		{"protocol error", func(c *Client, ctx context.Context) error {
+			return c.CloseWithReason(ctx, CloseProtocolError, "unexpected frame")
+		}, CloseProtocolError, "unexpected frame"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closes := make(chan *websocket.CloseError, 1)
+			srv := newTestServer(t, func(conn *websocket.Conn) {
+				for {
+					_, _, err := conn.ReadMessage()
+					var closeErr *websocket.CloseError
+					if errors.As(err, &closeErr) {
+						closes <- closeErr
+					}
+					if err != nil {
+						return
+					}
+				}
+			})
+			c := dialTestServer(t, srv)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := tt.close(c, ctx); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			select {
+			case got := <-closes:
+				if got.Code != tt.code || got.Text != tt.reason {
+					t.Errorf("close frame = %d %q, want %d %q", got.Code, got.Text, tt.code, tt.reason)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no close frame received")
+			}
+		})
+	}
+}
+
+func TestReadMessageCancelLeaksNoGoroutines(t *testing.T) {
+	send := make(chan struct{})
+	srv := newTestServer(t, func(conn *websocket.Conn) {
+		<-send
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event_type":"book","asset_id":"a"}`))
+		conn.ReadMessage()
+	})
+	c := dialTestServer(t, srv)
+	defer c.Close(context.Background())
+
+	before := runtime.NumGoroutine()
+	for range 1000 {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := c.ReadMessage(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("ReadMessage with a cancelled context = %v, want context.Canceled", err)
+		}
+	}
+	for range 10 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		c.ReadMessage(ctx)
+		cancel()
+	}
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Errorf("goroutines grew from %d to %d over cancelled reads", before, after)
+	}
+
+	// Cancelled reads leave the connection usable.
+	close(send)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := c.ReadMessage(ctx)
+	if err != nil {
+		t.Fatalf("ReadMessage after cancelled reads: %v", err)
+	}
+	if msg.AssetID() != "a" {
+		t.Errorf("AssetID() = %q, want a", msg.AssetID())
+	}
+}
@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+)
+
+func TestParseMessageCountsFramesByEventType(t *testing.T) {
+	frames := []string{
+		`{"event_type":"book","asset_id":"a","market":"m","buys":[],"sells":[]}`,
+		`{"event_type":"book","asset_id":"b","market":"m","buys":[],"sells":[]}`,
+		`{"event_type":"price_change","asset_id":"a","price":"0.5","size":"10","side":"BUY"}`,
+		`{"event_type":"last_trade_price","asset_id":"a","price":"0.5","size":"3","side":"SELL"}`,
+		`{"event_type":"tick_size_change","asset_id":"a","old_tick_size":"0.01","new_tick_size":"0.001"}`,
+		`{"event_type":"something_new"}`,
+		`not json`,
+	}
+	want := map[string]float64{
+		BookEvent:           2,
+		PriceChangeEvent:    1,
+		LastTradePriceEvent: 1,
+		TickSizeChangeEvent: 1,
+		unknownEvent:        1,
+		invalidEvent:        1,
+	}
+
+	before := make(map[string]float64, len(want))
+	for eventType := range want {
+		before[eventType] = testutil.ToFloat64(metrics.WebsocketFrames.WithLabelValues(platformName, eventType))
+	}
+
+	c := &Client{}
+	for _, f := range frames {
+		msg, err := c.ParseMessage([]byte(f))
+		if err == nil && msg.AssetID() == "" {
+			t.Errorf("no asset ID in parsed %s message", msg.EventType)
+		}
+	}
+
+	for eventType, w := range want {
+		got := testutil.ToFloat64(metrics.WebsocketFrames.WithLabelValues(platformName, eventType)) - before[eventType]
+		if got != w {
+			t.Errorf("%s frames = %v, want %v", eventType, got, w)
+		}
+	}
+}
+
+func TestParseMessageKeepsEventType(t *testing.T) {
+	msg, err := (&Client{}).ParseMessage([]byte(`{"event_type":"tick_size_change","asset_id":"a","new_tick_size":"0.001"}`))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.EventType != TickSizeChangeEvent || msg.TickSizeChange == nil || msg.TickSizeChange.NewTickSize != "0.001" {
+		t.Errorf("parsed %+v, want a tick_size_change event", msg)
+	}
+}
+
+func TestParseActivityMessage(t *testing.T) {
+	frame := `{"topic":"activity","type":"trades","timestamp":1767225600000,"payload":{` +
+		`"asset":"123","conditionId":"0xabc","eventSlug":"election","outcome":"Yes",` +
+		`"price":0.52,"side":"BUY","size":25,"slug":"will-it-happen"}}`
+
+	msg, err := (&Client{}).ParseMessage([]byte(frame))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if msg.EventType != ActivityEvent || msg.Activity == nil {
+		t.Fatalf("parsed %+v, want an activity event", msg)
+	}
+	a := msg.Activity
+	if a.Topic != TopicActivity || a.Type != "trades" || a.Timestamp != 1767225600000 {
+		t.Errorf("activity = %+v, want a trades event of topic activity", a)
+	}
+	if a.Payload.ConditionID != "0xabc" || a.Payload.Asset != "123" || a.Payload.Side != "BUY" ||
+		a.Payload.Price != "0.52" || a.Payload.Size != "25" {
+		t.Errorf("payload = %+v, want the trade's fields", a.Payload)
+	}
+
+	comment, err := (&Client{}).ParseMessage([]byte(`{"topic":"comments","type":"comment_created","payload":{"conditionId":"0xabc","body":"hi"}}`))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if comment.Activity == nil || comment.Activity.Topic != TopicComments {
+		t.Errorf("parsed %+v, want a comments activity event", comment)
+	}
+}
+
+// Captured market channel frames.
+const (
+	sampleBook = `{
+		"event_type": "book",
+		"asset_id": "65818619657568813474341868652308942079804919287380422192892211131408793125422",
+		"market": "0xbd31dc8a20211944f6b70f31557f1001557b59905b7738480ca09bd4532f84af",
+		"bids": [{"price": ".48", "size": "30"}, {"price": ".49", "size": "20"}],
+		"asks": [{"price": ".52", "size": "25"}],
+		"timestamp": "1757908892351",
+		"hash": "0x5c6b1ae0b4a0a1c4e2b2b76f8e0d1f3a9c1c0b7e"
+	}`
+	samplePriceChange = `{
+		"market": "0x5f65177b394277fd294cd75650044e32ba009a95022d88a0c1d565897d72f8f1",
+		"price_changes": [
+			{"asset_id": "71321045679252212594626385532706912750332728571942532289631379312455583992563", "price": "0.5", "size": "200", "side": "BUY", "hash": "56621a121a47ed9333273e21c83b660cff37ae50", "best_bid": "0.5", "best_ask": "1"},
+			{"asset_id": "52114319501245915516055106046884209969926127482827954674443846427813813222426", "price": "0.5", "size": "0", "side": "SELL", "hash": "1895759e4df7a796bf4f1c5a5950b748306923e2", "best_bid": "0", "best_ask": "0.5"}
+		],
+		"timestamp": "1757908892351",
+		"event_type": "price_change"
+	}`
+	sampleLastTradePrice = `{
+		"asset_id": "114122071509644379678018727908709560226618148003371446110114509806601493071694",
+		"event_type": "last_trade_price",
+		"fee_rate_bps": "0",
+		"market": "0x6a67b9d828d53862160e470329ffea5246f338ecfffdf2cab45211ec578b0347",
+		"price": "0.456",
+		"side": "BUY",
+		"size": "219.217767",
+		"timestamp": "1750428146322"
+	}`
+)
+
+func TestParseSampleBook(t *testing.T) {
+	msg, err := parseMessage([]byte(sampleBook))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	b := msg.Book
+	if b == nil || b.Timestamp != "1757908892351" || b.Hash != "0x5c6b1ae0b4a0a1c4e2b2b76f8e0d1f3a9c1c0b7e" {
+		t.Fatalf("book = %+v", b)
+	}
+	if len(b.Bids) != 2 || b.Bids[1] != (OrderSummary{Price: 490_000, Size: 20_000_000}) {
+		t.Errorf("bids = %v", b.Bids)
+	}
+	if len(b.Asks) != 1 || b.Asks[0] != (OrderSummary{Price: 520_000, Size: 25_000_000}) {
+		t.Errorf("asks = %v", b.Asks)
+	}
+
+	// Older frames name the sides buys and sells.
+	msg, err = parseMessage([]byte(`{"event_type":"book","asset_id":"a","buys":[{"price":"0.4","size":"1"}],"sells":[]}`))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	if bids := msg.Book.Bids; len(bids) != 1 || bids[0].Price != 400_000 {
+		t.Errorf("buys as bids = %v", bids)
+	}
+	if msg.Book.Asks == nil {
+		t.Error("sells not decoded as asks")
+	}
+}
+
+func TestParseSamplePriceChange(t *testing.T) {
+	msg, err := parseMessage([]byte(samplePriceChange))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	levels := msg.PriceChange.Changes
+	if len(levels) != 2 {
+		t.Fatalf("levels = %+v, want 2", levels)
+	}
+	want := PriceLevelChange{
+		AssetID: "71321045679252212594626385532706912750332728571942532289631379312455583992563",
+		Price:   500_000,
+		Size:    200_000_000,
+		Side:    "BUY",
+		Hash:    "56621a121a47ed9333273e21c83b660cff37ae50",
+		BestBid: "0.5",
+		BestAsk: "1",
+	}
+	if levels[0] != want {
+		t.Errorf("level 0 = %+v, want %+v", levels[0], want)
+	}
+	if levels[1].Size != 0 || levels[1].Side != "SELL" {
+		t.Errorf("level 1 = %+v", levels[1])
+	}
+	if ids := msg.AssetIDs(); len(ids) != 2 || ids[1] != levels[1].AssetID {
+		t.Errorf("AssetIDs() = %v, want both tokens", ids)
+	}
+
+	// Older frames carry a single change inline.
+	msg, err = parseMessage([]byte(`{"event_type":"price_change","asset_id":"a","price":"0.5","size":"10","side":"BUY"}`))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	if levels := msg.PriceChange.Changes; len(levels) != 1 || levels[0].AssetID != "a" || levels[0].Size != 10_000_000 {
+		t.Errorf("inline levels = %+v", levels)
+	}
+}
+
+func TestParseSampleLastTradePrice(t *testing.T) {
+	msg, err := parseMessage([]byte(sampleLastTradePrice))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	trade := msg.LastTradePrice
+	if trade == nil || trade.Price != "0.456" || trade.Size != "219.217767" || trade.Side != "BUY" || trade.Timestamp != "1750428146322" {
+		t.Errorf("last trade price = %+v", trade)
+	}
+}
+
+func TestParseFrameArray(t *testing.T) {
+	frame := "[" + sampleBook + `, {"event_type": "something_new"}, ` + sampleLastTradePrice + "]"
+
+	events := (&Client{}).parseFrame([]byte(frame))
+	if len(events) != 3 {
+		t.Fatalf("parsed %d events, want 3", len(events))
+	}
+	if events[0].err != nil || events[0].msg.EventType != BookEvent {
+		t.Errorf("event 0 = %+v, want a book", events[0])
+	}
+	if !errors.Is(events[1].err, ErrParse) {
+		t.Errorf("event 1 error = %v, want ErrParse", events[1].err)
+	}
+	if events[2].err != nil || events[2].msg.EventType != LastTradePriceEvent {
+		t.Errorf("event 2 = %+v, want a last trade price", events[2])
+	}
+
+	if events := (&Client{}).parseFrame([]byte("[]")); len(events) != 0 {
+		t.Errorf("empty array parsed as %+v", events)
+	}
+}
@@ -4,42 +4,168 @@ package price
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 )
 
 type (
 	Price int64
+	// Size is a number of shares scaled by PriceScale like a price, e.g.
+	// 1_500_000 for 1.5 shares, so fractional sizes are exact.
 	// TODO Rethink where this should be defined.
 	Size int64
+	// Tick is the price increment a market accepts, e.g. 10_000 for 0.01.
+	Tick Price
 )
 
-var _ json.Unmarshaler = (*Price)(nil)
+var (
+	_ json.Unmarshaler = (*Price)(nil)
+	_ json.Unmarshaler = (*Size)(nil)
+	_ json.Unmarshaler = (*Tick)(nil)
+	_ json.Marshaler   = Price(0)
+)
 
 const PriceScale int64 = 1_000_000
 
+// UnmarshalJSON parses a decimal, quoted or not. JSON null leaves p
+// unchanged.
 func (p *Price) UnmarshalJSON(data []byte) error {
-	if len(data) > 2 && data[0] == '"' && data[len(data)-1] == '"' {
-		data = data[1 : len(data)-1]
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := parseScaled(data)
+	if err != nil {
+		return err
+	}
+	*p = Price(v)
+	return nil
+}
+
+// MarshalJSON formats the price as a decimal string with trailing zeros
+// trimmed, e.g. "0.5" for 500000, which UnmarshalJSON reads back unchanged.
+func (p Price) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, formatScaled(int64(p))), nil
+}
+
+// String formats the price as a decimal with trailing zeros trimmed, e.g.
+// "0.5" for 500000, as in MarshalJSON but unquoted.
+func (p Price) String() string {
+	return formatScaled(int64(p))
+}
+
+// Float64 returns the price as a fraction of 1, e.g. 0.5 for 500000. Use it
+// for display and statistics, not for arithmetic on prices.
+func (p Price) Float64() float64 {
+	return float64(p) / float64(PriceScale)
+}
+
+// formatScaled formats a value scaled by PriceScale as a decimal with up to
+// six decimal places and trailing zeros trimmed.
+func formatScaled(v int64) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	whole, frac := v/PriceScale, v%PriceScale
+	if frac == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	fracStr := strconv.FormatInt(frac+PriceScale, 10)[1:] // Zero-padded to 6 digits.
+	return sign + strconv.FormatInt(whole, 10) + "." + strings.TrimRight(fracStr, "0")
+}
+
+// UnmarshalJSON parses a size with the same scale as a price, so that
+// fractional share sizes survive. Like for a price, integers and decimals are
+// accepted quoted or not, and anything else, including sizes too large to
+// scale, is an error.
+func (s *Size) UnmarshalJSON(data []byte) error {
+	return (*Price)(s).UnmarshalJSON(data)
+}
+
+func (t *Tick) UnmarshalJSON(data []byte) error {
+	return (*Price)(t).UnmarshalJSON(data)
+}
+
+// Parse parses a decimal string such as "0.001" into a Price. Digits past
+// the scale are truncated.
+func Parse(s string) (Price, error) {
+	v, err := parseScaled([]byte(s))
+	return Price(v), err
+}
+
+// RoundToTick snaps p to the nearest multiple of t, rounding halves up. A
+// tick <= 0 leaves p unchanged.
+func (t Tick) RoundToTick(p Price) Price {
+	if t <= 0 {
+		return p
+	}
+	// Division truncates toward zero, so floor it for negative prices.
+	shifted := p + Price(t)/2
+	q := shifted / Price(t)
+	if shifted%Price(t) < 0 {
+		q--
+	}
+	return q * Price(t)
+}
+
+// parseScaled parses a decimal, quoted or not, into an integer scaled by
+// PriceScale. Digits past the scale are truncated, values that don't fit in
+// an int64 once scaled are an error.
+func parseScaled(data []byte) (int64, error) {
+	s := data
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
 	}
 	// Else we assume that it is a raw number.
 
+	negative := len(s) > 0 && s[0] == '-'
+	if negative {
+		s = s[1:]
+	}
+
 	var res int64
+	digits := 0
 	i := 0
 
-	for i < len(data) && data[i] != '.' {
-		res = res*10 + int64(data[i]-'0')*PriceScale
+	for i < len(s) && s[i] != '.' {
+		d := s[i] - '0'
+		if d > 9 {
+			return 0, fmt.Errorf("invalid decimal %q", data)
+		}
+		if res > (math.MaxInt64-int64(d)*PriceScale)/10 {
+			return 0, fmt.Errorf("decimal %q out of range", data)
+		}
+		res = res*10 + int64(d)*PriceScale
+		digits++
 		i++
 	}
 
-	if i < len(data) && data[i] == '.' {
+	if i < len(s) && s[i] == '.' {
 		i++
 		mult := PriceScale
-		for i < len(data) {
+		for i < len(s) {
+			d := s[i] - '0'
+			if d > 9 {
+				return 0, fmt.Errorf("invalid decimal %q", data)
+			}
 			mult /= 10
-			res += int64(data[i]-'0') * mult
+			if res > math.MaxInt64-int64(d)*mult {
+				return 0, fmt.Errorf("decimal %q out of range", data)
+			}
+			res += int64(d) * mult
+			digits++
 			i++
 		}
 	}
 
-	*p = Price(res)
-	return nil
+	if digits == 0 {
+		return 0, fmt.Errorf("invalid decimal %q", data)
+	}
+	if negative {
+		res = -res
+	}
+	return res, nil
 }
@@ -2,6 +2,7 @@ package price
 
 import (
 	"encoding/json"
+	"math"
 	"testing"
 )
 
@@ -26,6 +27,16 @@ func TestPriceUnmarshalJSON(t *testing.T) {
 		{"two whole", `"2.0"`, 2_000_000, false},
 		{"small frac", `"0.000001"`, 1, false},
 		{"max precision", `"0.999999"`, 999_999, false},
+		{"negative", `"-0.5"`, -500_000, false},
+		{"negative raw number", `-1.25`, -1_250_000, false},
+		{"letters", `"abc"`, 0, true},
+		{"trailing letter", `"0.5x"`, 0, true},
+		{"empty", `""`, 0, true},
+		{"sign only", `"-"`, 0, true},
+		{"dot only", `"."`, 0, true},
+		{"two dots", `"0.5.1"`, 0, true},
+		{"exponent", `1e-3`, 0, true},
+		{"space", `" 0.5"`, 0, true},
 	}
 
 	for _, tt := range tests {
@@ -67,6 +78,25 @@ func TestPriceUnmarshalJSON_ViaJsonUnmarshal(t *testing.T) {
 	}
 }
 
+func TestPriceUnmarshalJSONNull(t *testing.T) {
+	p := Price(42)
+	if err := json.Unmarshal([]byte(`null`), &p); err != nil {
+		t.Fatalf("unmarshal null: %v", err)
+	}
+	if p != 42 {
+		t.Errorf("got %d after null, want it unchanged", p)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if got, err := Parse("0.001"); err != nil || got != 1_000 {
+		t.Errorf(`Parse("0.001") = %d, %v, want 1000`, got, err)
+	}
+	if _, err := Parse("0,01"); err == nil {
+		t.Error(`Parse("0,01") succeeded, want an error`)
+	}
+}
+
 func TestPriceInStruct(t *testing.T) {
 	type Order struct {
 		Price Price `json:"price"`
@@ -90,3 +120,135 @@ func BenchmarkPriceUnmarshalJSON(b *testing.B) {
 		_ = p.UnmarshalJSON(data)
 	}
 }
+
+func TestSizeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Size
+		wantErr bool
+	}{
+		{`"0"`, 0, false},
+		{`"100"`, 100_000_000, false},
+		{`"100.5"`, 100_500_000, false},
+		{`"12.5"`, 12_500_000, false},
+		{`"1234.560000"`, 1_234_560_000, false},
+		{`100.5`, 100_500_000, false},
+		{`"9000000000.000001"`, 9_000_000_000_000_001, false},
+		{`1234567890123`, 1_234_567_890_123_000_000, false},
+		{`"9223372036854.775807"`, math.MaxInt64, false},
+		{`"9223372036854.775808"`, 0, true},
+		{`"10000000000000"`, 0, true},
+		{`"1,234.56"`, 0, true},
+		{`"abc"`, 0, true},
+		{`""`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		var got Size
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unmarshal %s: error = %v, wantErr = %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("unmarshal %s = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRoundToTick(t *testing.T) {
+	tests := []struct {
+		name string
+		tick Tick
+		in   Price
+		want Price
+	}{
+		{"cent on grid", 10_000, 540_000, 540_000},
+		{"cent rounds down", 10_000, 544_999, 540_000},
+		{"cent half rounds up", 10_000, 545_000, 550_000},
+		{"cent rounds up", 10_000, 546_000, 550_000},
+		{"cent near zero", 10_000, 4_000, 0},
+		{"tenth of a cent on grid", 1_000, 123_000, 123_000},
+		{"tenth of a cent rounds down", 1_000, 123_400, 123_000},
+		{"tenth of a cent rounds up", 1_000, 123_600, 124_000},
+		{"tenth of a cent near one", 1_000, 999_700, 1_000_000},
+		{"negative rounds down", 10, -7, -10},
+		{"negative rounds up", 10, -3, 0},
+		{"negative half rounds up", 10, -5, 0},
+		{"negative half rounds up to a tick", 10, -15, -10},
+		{"negative on grid", 10_000, -540_000, -540_000},
+		{"no tick", 0, 123_456, 123_456},
+	}
+	for _, tt := range tests {
+		if got := tt.tick.RoundToTick(tt.in); got != tt.want {
+			t.Errorf("%s: Tick(%d).RoundToTick(%d) = %d, want %d", tt.name, tt.tick, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPriceMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input Price
+		want  string
+	}{
+		{"zero", 0, `"0"`},
+		{"one", 1_000_000, `"1"`},
+		{"half", 500_000, `"0.5"`},
+		{"quarter", 250_000, `"0.25"`},
+		{"typical price", 123_456, `"0.123456"`},
+		{"one digit", 100_000, `"0.1"`},
+		{"two digits", 120_000, `"0.12"`},
+		{"three digits", 123_000, `"0.123"`},
+		{"whole with frac", 1_500_000, `"1.5"`},
+		{"two whole", 2_000_000, `"2"`},
+		{"small frac", 1, `"0.000001"`},
+		{"max precision", 999_999, `"0.999999"`},
+		{"negative", -250_000, `"-0.25"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatalf("marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+
+			var back Price
+			if err := json.Unmarshal(got, &back); err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+			if back != tt.input {
+				t.Errorf("round trip got %d, want %d", back, tt.input)
+			}
+		})
+	}
+}
+
+func TestPriceStringAndFloat64(t *testing.T) {
+	tests := []struct {
+		input     Price
+		wantStr   string
+		wantFloat float64
+	}{
+		{0, "0", 0},
+		{1_000_000, "1", 1},
+		{999_999, "0.999999", 0.999999},
+		{500_000, "0.5", 0.5},
+		{1, "0.000001", 0.000001},
+		{2_500_000, "2.5", 2.5},
+		{-250_000, "-0.25", -0.25},
+	}
+	for _, tt := range tests {
+		if got := tt.input.String(); got != tt.wantStr {
+			t.Errorf("Price(%d).String() = %q, want %q", int64(tt.input), got, tt.wantStr)
+		}
+		if got := tt.input.Float64(); got != tt.wantFloat {
+			t.Errorf("Price(%d).Float64() = %v, want %v", int64(tt.input), got, tt.wantFloat)
+		}
+	}
+}
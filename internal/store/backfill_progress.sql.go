@@ -0,0 +1,41 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: backfill_progress.sql
+
+package store
+
+import (
+	"context"
+	"time"
+)
+
+const getBackfillProgress = `-- name: GetBackfillProgress :one
+SELECT last_backfilled_ts FROM backfill_progress WHERE token_id = $1
+`
+
+func (q *Queries) GetBackfillProgress(ctx context.Context, tokenID string) (time.Time, error) {
+	row := q.db.QueryRow(ctx, getBackfillProgress, tokenID)
+	var last_backfilled_ts time.Time
+	err := row.Scan(&last_backfilled_ts)
+	return last_backfilled_ts, err
+}
+
+const upsertBackfillProgress = `-- name: UpsertBackfillProgress :exec
+INSERT INTO backfill_progress (token_id, last_backfilled_ts)
+VALUES ($1, $2)
+ON CONFLICT (token_id) DO UPDATE SET
+    last_backfilled_ts = GREATEST(backfill_progress.last_backfilled_ts, EXCLUDED.last_backfilled_ts),
+    updated_at = NOW()
+`
+
+type UpsertBackfillProgressParams struct {
+	TokenID          string    `json:"token_id"`
+	LastBackfilledTs time.Time `json:"last_backfilled_ts"`
+}
+
+// The cursor never moves back, e.g. when a window is backfilled again.
+func (q *Queries) UpsertBackfillProgress(ctx context.Context, arg UpsertBackfillProgressParams) error {
+	_, err := q.db.Exec(ctx, upsertBackfillProgress, arg.TokenID, arg.LastBackfilledTs)
+	return err
+}
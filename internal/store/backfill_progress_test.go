@@ -0,0 +1,42 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestBackfillProgress(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	tokenID := testID(t, "token")
+	seedMarket(t, s, testID(t, "platform"), tokenID)
+
+	if _, err := s.GetBackfillProgress(ctx, tokenID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("GetBackfillProgress() before any progress error = %v, want %v", err, pgx.ErrNoRows)
+	}
+
+	later := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	earlier := later.Add(-time.Hour)
+	for _, ts := range []time.Time{later, earlier} {
+		if err := s.UpsertBackfillProgress(ctx, store.UpsertBackfillProgressParams{TokenID: tokenID, LastBackfilledTs: ts}); err != nil {
+			t.Fatalf("UpsertBackfillProgress(%v): %v", ts, err)
+		}
+	}
+
+	// The cursor doesn't move back.
+	got, err := s.GetBackfillProgress(ctx, tokenID)
+	if err != nil {
+		t.Fatalf("GetBackfillProgress(): %v", err)
+	}
+	if !got.Equal(later) {
+		t.Errorf("progress = %v, want %v", got, later)
+	}
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BookDocument is the JSONB representation of an order book stored in
+// order_book_documents. Prices and sizes use scale 10^6.
+type BookDocument struct {
+	Bids []BookDocumentLevel `json:"bids"`
+	Asks []BookDocumentLevel `json:"asks"`
+}
+
+// BookDocumentLevel is a single price level of a BookDocument.
+type BookDocumentLevel struct {
+	Price     int64     `json:"price"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// InsertBookDocument stores the book of a token captured at t.
+func (s *Store) InsertBookDocument(ctx context.Context, tokenID string, t time.Time, doc BookDocument) error {
+	book, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshal book document: %w", err)
+	}
+
+	_, err = s.InsertOrderBookDocumentBatch(ctx, []InsertOrderBookDocumentBatchParams{{
+		Time:    t,
+		TokenID: tokenID,
+		Book:    book,
+	}})
+	return err
+}
+
+// GetBookDocument returns the latest book of a token captured at or before t,
+// along with its capture time.
+func (s *Store) GetBookDocument(ctx context.Context, tokenID string, t time.Time) (BookDocument, time.Time, error) {
+	row, err := s.GetOrderBookDocumentAt(ctx, GetOrderBookDocumentAtParams{
+		TokenID: tokenID,
+		Time:    t,
+	})
+	if err != nil {
+		return BookDocument{}, time.Time{}, err
+	}
+
+	var doc BookDocument
+	if err := json.Unmarshal(row.Book, &doc); err != nil {
+		return BookDocument{}, time.Time{}, fmt.Errorf("unmarshal book document: %w", err)
+	}
+	return doc, row.Time, nil
+}
@@ -0,0 +1,50 @@
+package store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestBookDocumentRoundTrip(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	tokenID := testID(t, "token")
+	seedMarket(t, s, "polymarket", tokenID)
+
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	want := store.BookDocument{
+		Bids: []store.BookDocumentLevel{
+			{Price: 500_000, Size: 1_000_000, UpdatedAt: at.Add(-time.Second)},
+			{Price: 490_000, Size: 2_500_000, UpdatedAt: at.Add(-2 * time.Second)},
+		},
+		Asks: []store.BookDocumentLevel{
+			{Price: 510_000, Size: 3_000_000, UpdatedAt: at.Add(-time.Second)},
+		},
+	}
+	if err := s.InsertBookDocument(ctx, tokenID, at, want); err != nil {
+		t.Fatalf("InsertBookDocument: %v", err)
+	}
+
+	got, gotTime, err := s.GetBookDocument(ctx, tokenID, at.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetBookDocument: %v", err)
+	}
+	if !gotTime.Equal(at) {
+		t.Errorf("time = %v, want %v", gotTime, at)
+	}
+	for i := range got.Bids {
+		got.Bids[i].UpdatedAt = got.Bids[i].UpdatedAt.UTC()
+	}
+	for i := range got.Asks {
+		got.Asks[i].UpdatedAt = got.Asks[i].UpdatedAt.UTC()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -9,6 +9,40 @@ import (
 	"context"
 )
 
+// iteratorForInsertOrderBookDocumentBatch implements pgx.CopyFromSource.
+type iteratorForInsertOrderBookDocumentBatch struct {
+	rows                 []InsertOrderBookDocumentBatchParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForInsertOrderBookDocumentBatch) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForInsertOrderBookDocumentBatch) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].Time,
+		r.rows[0].TokenID,
+		r.rows[0].Book,
+	}, nil
+}
+
+func (r iteratorForInsertOrderBookDocumentBatch) Err() error {
+	return nil
+}
+
+func (q *Queries) InsertOrderBookDocumentBatch(ctx context.Context, arg []InsertOrderBookDocumentBatchParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"order_book_documents"}, []string{"time", "token_id", "book"}, &iteratorForInsertOrderBookDocumentBatch{rows: arg})
+}
+
 // iteratorForInsertOrderBookMetricsBatch implements pgx.CopyFromSource.
 type iteratorForInsertOrderBookMetricsBatch struct {
 	rows                 []InsertOrderBookMetricsBatchParams
@@ -78,6 +112,7 @@ func (r iteratorForInsertOrderBookSnapshotBatch) Values() ([]interface{}, error)
 		r.rows[0].Level,
 		r.rows[0].Price,
 		r.rows[0].Size,
+		r.rows[0].Checksum,
 	}, nil
 }
 
@@ -86,7 +121,7 @@ func (r iteratorForInsertOrderBookSnapshotBatch) Err() error {
 }
 
 func (q *Queries) InsertOrderBookSnapshotBatch(ctx context.Context, arg []InsertOrderBookSnapshotBatchParams) (int64, error) {
-	return q.db.CopyFrom(ctx, []string{"order_book_snapshots"}, []string{"time", "token_id", "side", "level", "price", "size"}, &iteratorForInsertOrderBookSnapshotBatch{rows: arg})
+	return q.db.CopyFrom(ctx, []string{"order_book_snapshots"}, []string{"time", "token_id", "side", "level", "price", "size", "checksum"}, &iteratorForInsertOrderBookSnapshotBatch{rows: arg})
 }
 
 // iteratorForInsertTradeBatch implements pgx.CopyFromSource.
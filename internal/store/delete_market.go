@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeletedRows counts the rows DeleteMarket removed per table.
+type DeletedRows struct {
+	Markets       int64
+	Tokens        int64
+	Snapshots     int64 // order_book_snapshots
+	BookDocuments int64 // order_book_documents
+}
+
+// DeleteMarket deletes a market of a platform and its tokens in one
+// transaction. Rows referencing them with ON DELETE CASCADE (subscriptions,
+// embeddings, pairs, news links) go with them. The order book history of the
+// tokens is deleted too unless keepSnapshots is set. Trades are always kept.
+// Deleting a market that doesn't exist deletes nothing and isn't an error.
+func (s *Store) DeleteMarket(ctx context.Context, id, platform string, keepSnapshots bool) (DeletedRows, error) {
+	var deleted DeletedRows
+	err := s.WithTx(ctx, func(q *Queries) error {
+		var err error
+		if !keepSnapshots {
+			deleted.Snapshots, err = q.DeleteMarketSnapshots(ctx, DeleteMarketSnapshotsParams{ID: id, Platform: platform})
+			if err != nil {
+				return fmt.Errorf("delete snapshots: %w", err)
+			}
+			deleted.BookDocuments, err = q.DeleteMarketBookDocuments(ctx, DeleteMarketBookDocumentsParams{ID: id, Platform: platform})
+			if err != nil {
+				return fmt.Errorf("delete book documents: %w", err)
+			}
+		}
+		deleted.Tokens, err = q.DeleteMarketTokens(ctx, DeleteMarketTokensParams{ID: id, Platform: platform})
+		if err != nil {
+			return fmt.Errorf("delete tokens: %w", err)
+		}
+		deleted.Markets, err = q.DeleteMarketRow(ctx, DeleteMarketRowParams{ID: id, Platform: platform})
+		if err != nil {
+			return fmt.Errorf("delete market: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return DeletedRows{}, err
+	}
+	return deleted, nil
+}
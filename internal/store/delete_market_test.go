@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestDeleteMarket(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+	now := time.Now()
+
+	seed := func(name string) (marketID string, tokenIDs []string) {
+		t.Helper()
+		tokenIDs = []string{testID(t, name+"-yes"), testID(t, name+"-no")}
+		marketID = seedMarket(t, s, platform, tokenIDs...)
+		for _, tokenID := range tokenIDs {
+			if _, err := s.InsertOrderBookSnapshotBatch(ctx, []store.InsertOrderBookSnapshotBatchParams{
+				{Time: now, TokenID: tokenID, Side: "bid", Level: 0, Price: 500_000, Size: 1},
+				{Time: now, TokenID: tokenID, Side: "ask", Level: 0, Price: 510_000, Size: 1},
+			}); err != nil {
+				t.Fatalf("insert snapshots: %v", err)
+			}
+			if err := s.InsertBookDocument(ctx, tokenID, now, store.BookDocument{}); err != nil {
+				t.Fatalf("insert book document: %v", err)
+			}
+		}
+		return marketID, tokenIDs
+	}
+	countSnapshots := func(tokenIDs []string) int {
+		t.Helper()
+		var n int
+		if err := s.Pool().QueryRow(ctx,
+			"SELECT count(*) FROM order_book_snapshots WHERE token_id = ANY($1)", tokenIDs,
+		).Scan(&n); err != nil {
+			t.Fatalf("count snapshots: %v", err)
+		}
+		return n
+	}
+
+	purged, purgedTokens := seed("purged")
+	kept, keptTokens := seed("kept")
+
+	// The platform must match.
+	deleted, err := s.DeleteMarket(ctx, purged, "other-platform", false)
+	if err != nil || deleted != (store.DeletedRows{}) {
+		t.Fatalf("DeleteMarket with another platform = %+v, %v, want nothing deleted", deleted, err)
+	}
+
+	deleted, err = s.DeleteMarket(ctx, purged, platform, false)
+	if err != nil {
+		t.Fatalf("DeleteMarket: %v", err)
+	}
+	if want := (store.DeletedRows{Markets: 1, Tokens: 2, Snapshots: 4, BookDocuments: 2}); deleted != want {
+		t.Errorf("deleted %+v, want %+v", deleted, want)
+	}
+	if _, err := s.GetMarket(ctx, purged); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetMarket after delete: err = %v, want %v", err, pgx.ErrNoRows)
+	}
+	if n := countSnapshots(purgedTokens); n != 0 {
+		t.Errorf("%d snapshot rows left, want 0", n)
+	}
+
+	deleted, err = s.DeleteMarket(ctx, kept, platform, true)
+	if err != nil {
+		t.Fatalf("DeleteMarket keeping snapshots: %v", err)
+	}
+	if want := (store.DeletedRows{Markets: 1, Tokens: 2}); deleted != want {
+		t.Errorf("deleted %+v, want %+v", deleted, want)
+	}
+	if n := countSnapshots(keptTokens); n != 4 {
+		t.Errorf("%d snapshot rows left, want all 4 kept", n)
+	}
+}
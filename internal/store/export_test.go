@@ -0,0 +1,16 @@
+package store
+
+import "context"
+
+// Queries whose plans snapshot_indexes_test.go checks.
+const (
+	GetLatestOrderBookSnapshotQuery       = getLatestOrderBookSnapshot
+	GetLastIngestedOrderBookSnapshotQuery = getLastIngestedOrderBookSnapshot
+	GetInsideQuoteRowsQuery               = getInsideQuoteRows
+	GetRealizedVolatilityRowQuery         = getRealizedVolatilityRow
+)
+
+// StreamTokenIDs pages through a platform's token IDs with a chosen page size.
+func (s *Store) StreamTokenIDs(ctx context.Context, platform string, pageSize int, fn func(id string) error) error {
+	return s.streamTokenIDs(ctx, platform, pageSize, fn)
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// InsideQuote is the best bid and ask of a token in one snapshot.
+// A side without a level 0 row is 0.
+type InsideQuote struct {
+	Time    time.Time // When the snapshot was written, its ingested_at.
+	BestBid price.Price
+	BestAsk price.Price
+}
+
+// GetInsideQuotes returns the level 0 prices of a token's snapshots written
+// in [from, to), oldest first.
+func (s *Store) GetInsideQuotes(ctx context.Context, tokenID string, from, to time.Time) ([]InsideQuote, error) {
+	rows, err := s.ReadQueries().GetInsideQuoteRows(ctx, GetInsideQuoteRowsParams{
+		TokenID:  tokenID,
+		FromTime: from,
+		ToTime:   to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]InsideQuote, 0, len(rows))
+	for _, row := range rows {
+		quotes = append(quotes, InsideQuote{
+			Time:    row.IngestedAt,
+			BestBid: price.Price(row.BestBid),
+			BestAsk: price.Price(row.BestAsk),
+		})
+	}
+	return quotes, nil
+}
@@ -0,0 +1,56 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestGetInsideQuotes(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	tokenID := testID(t, "token")
+	seedMarket(t, s, "polymarket", tokenID)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2 := from, from.Add(time.Minute)
+	insertSnapshotAt(t, s, t1,
+		store.InsertOrderBookSnapshotBatchParams{Time: t1, TokenID: tokenID, Side: "bid", Level: 0, Price: 500_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t1, TokenID: tokenID, Side: "bid", Level: 1, Price: 490_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t1, TokenID: tokenID, Side: "ask", Level: 0, Price: 510_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t1, TokenID: tokenID, Side: "ask", Level: 1, Price: 520_000, Size: 1},
+	)
+	// With event times, every level carries the time it was last updated.
+	insertSnapshotAt(t, s, t2,
+		store.InsertOrderBookSnapshotBatchParams{Time: t2.Add(-3 * time.Second), TokenID: tokenID, Side: "bid", Level: 0, Price: 505_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t2.Add(-40 * time.Second), TokenID: tokenID, Side: "bid", Level: 1, Price: 500_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t2.Add(-2 * time.Second), TokenID: tokenID, Side: "bid", Level: 2, Price: 495_000, Size: 1},
+		store.InsertOrderBookSnapshotBatchParams{Time: t2.Add(-7 * time.Second), TokenID: tokenID, Side: "ask", Level: 0, Price: 515_000, Size: 1},
+	)
+	// Outside the window.
+	insertSnapshotAt(t, s, from.Add(time.Hour),
+		store.InsertOrderBookSnapshotBatchParams{Time: from.Add(30 * time.Minute), TokenID: tokenID, Side: "bid", Level: 0, Price: 1, Size: 1},
+	)
+
+	got, err := s.GetInsideQuotes(ctx, tokenID, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("get inside quotes: %v", err)
+	}
+
+	want := []store.InsideQuote{
+		{Time: t1, BestBid: 500_000, BestAsk: 510_000},
+		{Time: t2, BestBid: 505_000, BestAsk: 515_000},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d quotes, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].BestBid != want[i].BestBid || got[i].BestAsk != want[i].BestAsk {
+			t.Errorf("quote %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
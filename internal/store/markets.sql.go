@@ -7,21 +7,104 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const deleteMarket = `-- name: DeleteMarket :exec
-DELETE FROM markets WHERE id = $1
+const deleteMarketBookDocuments = `-- name: DeleteMarketBookDocuments :execrows
+DELETE FROM order_book_documents
+WHERE token_id IN (
+    SELECT t.id FROM tokens t
+    JOIN markets m ON t.market_id = m.id
+    WHERE m.id = $1 AND m.platform = $2
+)
 `
 
-func (q *Queries) DeleteMarket(ctx context.Context, id string) error {
-	_, err := q.db.Exec(ctx, deleteMarket, id)
-	return err
+type DeleteMarketBookDocumentsParams struct {
+	ID       string `json:"id"`
+	Platform string `json:"platform"`
+}
+
+func (q *Queries) DeleteMarketBookDocuments(ctx context.Context, arg DeleteMarketBookDocumentsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMarketBookDocuments, arg.ID, arg.Platform)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteMarketRow = `-- name: DeleteMarketRow :execrows
+DELETE FROM markets WHERE id = $1 AND platform = $2
+`
+
+type DeleteMarketRowParams struct {
+	ID       string `json:"id"`
+	Platform string `json:"platform"`
+}
+
+// Use Store.DeleteMarket, which deletes the market's tokens first.
+func (q *Queries) DeleteMarketRow(ctx context.Context, arg DeleteMarketRowParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMarketRow, arg.ID, arg.Platform)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteMarketSnapshots = `-- name: DeleteMarketSnapshots :execrows
+DELETE FROM order_book_snapshots
+WHERE token_id IN (
+    SELECT t.id FROM tokens t
+    JOIN markets m ON t.market_id = m.id
+    WHERE m.id = $1 AND m.platform = $2
+)
+`
+
+type DeleteMarketSnapshotsParams struct {
+	ID       string `json:"id"`
+	Platform string `json:"platform"`
+}
+
+func (q *Queries) DeleteMarketSnapshots(ctx context.Context, arg DeleteMarketSnapshotsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMarketSnapshots, arg.ID, arg.Platform)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteMarketTokens = `-- name: DeleteMarketTokens :execrows
+DELETE FROM tokens t USING markets m
+WHERE t.market_id = m.id AND m.id = $1 AND m.platform = $2
+`
+
+type DeleteMarketTokensParams struct {
+	ID       string `json:"id"`
+	Platform string `json:"platform"`
+}
+
+func (q *Queries) DeleteMarketTokens(ctx context.Context, arg DeleteMarketTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMarketTokens, arg.ID, arg.Platform)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getConditionIDBySlug = `-- name: GetConditionIDBySlug :one
+SELECT id FROM markets WHERE slug = $1::text ORDER BY id LIMIT 1
+`
+
+func (q *Queries) GetConditionIDBySlug(ctx context.Context, slug string) (string, error) {
+	row := q.db.QueryRow(ctx, getConditionIDBySlug, slug)
+	var id string
+	err := row.Scan(&id)
+	return id, err
 }
 
 const getMarket = `-- name: GetMarket :one
-SELECT id, platform, description, end_date, created_at, updated_at FROM markets WHERE id = $1
+SELECT id, platform, description, end_date, created_at, updated_at, question, slug, needs_enrichment, minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps FROM markets WHERE id = $1
 `
 
 func (q *Queries) GetMarket(ctx context.Context, id string) (Market, error) {
@@ -34,12 +117,19 @@ func (q *Queries) GetMarket(ctx context.Context, id string) (Market, error) {
 		&i.EndDate,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Question,
+		&i.Slug,
+		&i.NeedsEnrichment,
+		&i.MinimumTickSize,
+		&i.MinimumOrderSize,
+		&i.MakerBaseFeeBps,
+		&i.TakerBaseFeeBps,
 	)
 	return i, err
 }
 
 const getMarketsByPlatform = `-- name: GetMarketsByPlatform :many
-SELECT id, platform, description, end_date, created_at, updated_at FROM markets WHERE platform = $1 ORDER BY created_at DESC
+SELECT id, platform, description, end_date, created_at, updated_at, question, slug, needs_enrichment, minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps FROM markets WHERE platform = $1 ORDER BY created_at DESC
 `
 
 func (q *Queries) GetMarketsByPlatform(ctx context.Context, platform string) ([]Market, error) {
@@ -58,6 +148,13 @@ func (q *Queries) GetMarketsByPlatform(ctx context.Context, platform string) ([]
 			&i.EndDate,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Question,
+			&i.Slug,
+			&i.NeedsEnrichment,
+			&i.MinimumTickSize,
+			&i.MinimumOrderSize,
+			&i.MakerBaseFeeBps,
+			&i.TakerBaseFeeBps,
 		); err != nil {
 			return nil, err
 		}
@@ -69,8 +166,145 @@ func (q *Queries) GetMarketsByPlatform(ctx context.Context, platform string) ([]
 	return items, nil
 }
 
+const getMarketsNeedingEnrichment = `-- name: GetMarketsNeedingEnrichment :many
+SELECT id, platform, description, end_date, created_at, updated_at, question, slug, needs_enrichment, minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps FROM markets WHERE platform = $1 AND needs_enrichment ORDER BY id
+`
+
+func (q *Queries) GetMarketsNeedingEnrichment(ctx context.Context, platform string) ([]Market, error) {
+	rows, err := q.db.Query(ctx, getMarketsNeedingEnrichment, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Market
+	for rows.Next() {
+		var i Market
+		if err := rows.Scan(
+			&i.ID,
+			&i.Platform,
+			&i.Description,
+			&i.EndDate,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Question,
+			&i.Slug,
+			&i.NeedsEnrichment,
+			&i.MinimumTickSize,
+			&i.MinimumOrderSize,
+			&i.MakerBaseFeeBps,
+			&i.TakerBaseFeeBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMarketsPastEndDateUnresolved = `-- name: GetMarketsPastEndDateUnresolved :many
+SELECT m.id, m.platform, m.description, m.end_date, m.created_at, m.updated_at, m.question, m.slug, m.needs_enrichment, m.minimum_tick_size, m.minimum_order_size, m.maker_base_fee_bps, m.taker_base_fee_bps FROM markets m
+WHERE m.end_date < $1::timestamptz
+  AND NOT EXISTS (
+      SELECT 1 FROM tokens t
+      WHERE t.market_id = m.id AND t.winning IS NOT NULL
+  )
+ORDER BY m.end_date
+`
+
+// Markets whose end date has passed but none of whose tokens has a
+// resolution yet, for polling resolution without a full sync.
+func (q *Queries) GetMarketsPastEndDateUnresolved(ctx context.Context, now time.Time) ([]Market, error) {
+	rows, err := q.db.Query(ctx, getMarketsPastEndDateUnresolved, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Market
+	for rows.Next() {
+		var i Market
+		if err := rows.Scan(
+			&i.ID,
+			&i.Platform,
+			&i.Description,
+			&i.EndDate,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Question,
+			&i.Slug,
+			&i.NeedsEnrichment,
+			&i.MinimumTickSize,
+			&i.MinimumOrderSize,
+			&i.MakerBaseFeeBps,
+			&i.TakerBaseFeeBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMarketsWithoutTokens = `-- name: GetMarketsWithoutTokens :many
+SELECT m.id, m.platform, m.description, m.end_date, m.created_at, m.updated_at, m.question, m.slug, m.needs_enrichment, m.minimum_tick_size, m.minimum_order_size, m.maker_base_fee_bps, m.taker_base_fee_bps FROM markets m
+WHERE m.platform = $1
+  AND NOT EXISTS (SELECT 1 FROM tokens t WHERE t.market_id = m.id)
+ORDER BY m.created_at
+`
+
+// Markets left without tokens by a sync that failed part way, for repair.
+func (q *Queries) GetMarketsWithoutTokens(ctx context.Context, platform string) ([]Market, error) {
+	rows, err := q.db.Query(ctx, getMarketsWithoutTokens, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Market
+	for rows.Next() {
+		var i Market
+		if err := rows.Scan(
+			&i.ID,
+			&i.Platform,
+			&i.Description,
+			&i.EndDate,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Question,
+			&i.Slug,
+			&i.NeedsEnrichment,
+			&i.MinimumTickSize,
+			&i.MinimumOrderSize,
+			&i.MakerBaseFeeBps,
+			&i.TakerBaseFeeBps,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSlugByConditionID = `-- name: GetSlugByConditionID :one
+SELECT slug::text FROM markets WHERE id = $1 AND slug IS NOT NULL
+`
+
+func (q *Queries) GetSlugByConditionID(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRow(ctx, getSlugByConditionID, id)
+	var slug string
+	err := row.Scan(&slug)
+	return slug, err
+}
+
 const listMarkets = `-- name: ListMarkets :many
-SELECT id, platform, description, end_date, created_at, updated_at FROM markets ORDER BY created_at DESC LIMIT $1 OFFSET $2
+SELECT id, platform, description, end_date, created_at, updated_at, question, slug, needs_enrichment, minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps FROM markets ORDER BY created_at DESC LIMIT $1 OFFSET $2
 `
 
 type ListMarketsParams struct {
@@ -94,6 +328,13 @@ func (q *Queries) ListMarkets(ctx context.Context, arg ListMarketsParams) ([]Mar
 			&i.EndDate,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Question,
+			&i.Slug,
+			&i.NeedsEnrichment,
+			&i.MinimumTickSize,
+			&i.MinimumOrderSize,
+			&i.MakerBaseFeeBps,
+			&i.TakerBaseFeeBps,
 		); err != nil {
 			return nil, err
 		}
@@ -105,28 +346,70 @@ func (q *Queries) ListMarkets(ctx context.Context, arg ListMarketsParams) ([]Mar
 	return items, nil
 }
 
+const markMarketsNeedEnrichment = `-- name: MarkMarketsNeedEnrichment :exec
+UPDATE markets SET needs_enrichment = TRUE WHERE id = ANY($1::text[])
+`
+
+func (q *Queries) MarkMarketsNeedEnrichment(ctx context.Context, ids []string) error {
+	_, err := q.db.Exec(ctx, markMarketsNeedEnrichment, ids)
+	return err
+}
+
+const setMarketEnrichment = `-- name: SetMarketEnrichment :exec
+UPDATE markets SET question = $2, slug = $3, needs_enrichment = FALSE, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetMarketEnrichmentParams struct {
+	ID       string      `json:"id"`
+	Question pgtype.Text `json:"question"`
+	Slug     pgtype.Text `json:"slug"`
+}
+
+func (q *Queries) SetMarketEnrichment(ctx context.Context, arg SetMarketEnrichmentParams) error {
+	_, err := q.db.Exec(ctx, setMarketEnrichment, arg.ID, arg.Question, arg.Slug)
+	return err
+}
+
 const upsertMarket = `-- name: UpsertMarket :exec
-INSERT INTO markets (id, platform, description, end_date, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
+INSERT INTO markets (
+    id, platform, description, end_date,
+    minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps,
+    created_at, updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
 ON CONFLICT (id) DO UPDATE SET
     description = EXCLUDED.description,
     end_date = EXCLUDED.end_date,
+    minimum_tick_size = COALESCE(EXCLUDED.minimum_tick_size, markets.minimum_tick_size),
+    minimum_order_size = COALESCE(EXCLUDED.minimum_order_size, markets.minimum_order_size),
+    maker_base_fee_bps = COALESCE(EXCLUDED.maker_base_fee_bps, markets.maker_base_fee_bps),
+    taker_base_fee_bps = COALESCE(EXCLUDED.taker_base_fee_bps, markets.taker_base_fee_bps),
     updated_at = NOW()
 `
 
 type UpsertMarketParams struct {
-	ID          string             `json:"id"`
-	Platform    string             `json:"platform"`
-	Description string             `json:"description"`
-	EndDate     pgtype.Timestamptz `json:"end_date"`
+	ID               string             `json:"id"`
+	Platform         string             `json:"platform"`
+	Description      string             `json:"description"`
+	EndDate          pgtype.Timestamptz `json:"end_date"`
+	MinimumTickSize  pgtype.Int8        `json:"minimum_tick_size"`
+	MinimumOrderSize pgtype.Int8        `json:"minimum_order_size"`
+	MakerBaseFeeBps  pgtype.Int4        `json:"maker_base_fee_bps"`
+	TakerBaseFeeBps  pgtype.Int4        `json:"taker_base_fee_bps"`
 }
 
+// Trading parameters left NULL keep the stored ones.
 func (q *Queries) UpsertMarket(ctx context.Context, arg UpsertMarketParams) error {
 	_, err := q.db.Exec(ctx, upsertMarket,
 		arg.ID,
 		arg.Platform,
 		arg.Description,
 		arg.EndDate,
+		arg.MinimumTickSize,
+		arg.MinimumOrderSize,
+		arg.MakerBaseFeeBps,
+		arg.TakerBaseFeeBps,
 	)
 	return err
 }
@@ -0,0 +1,150 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestGetMarketsPastEndDateUnresolved(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+	now := time.Now()
+
+	setEndDate := func(marketID string, endDate time.Time) {
+		t.Helper()
+		if err := s.UpsertMarket(ctx, store.UpsertMarketParams{
+			ID:          marketID,
+			Platform:    platform,
+			Description: "test market",
+			EndDate:     pgtype.Timestamptz{Time: endDate, Valid: true},
+		}); err != nil {
+			t.Fatalf("upsert market: %v", err)
+		}
+	}
+
+	expired := seedMarket(t, s, platform, testID(t, "expired-yes"), testID(t, "expired-no"))
+	setEndDate(expired, now.Add(-time.Hour))
+
+	resolvedYes := testID(t, "resolved-yes")
+	resolved := seedMarket(t, s, platform, resolvedYes, testID(t, "resolved-no"))
+	setEndDate(resolved, now.Add(-time.Hour))
+	if err := s.SetTokenResolution(ctx, store.SetTokenResolutionParams{
+		ID:      resolvedYes,
+		Winning: pgtype.Bool{Bool: true, Valid: true},
+	}); err != nil {
+		t.Fatalf("set token resolution: %v", err)
+	}
+
+	open := seedMarket(t, s, platform, testID(t, "open-yes"))
+	setEndDate(open, now.Add(time.Hour))
+
+	markets, err := s.GetMarketsPastEndDateUnresolved(ctx, now)
+	if err != nil {
+		t.Fatalf("get markets past end date: %v", err)
+	}
+
+	var got []string
+	for _, m := range markets {
+		if m.Platform == platform {
+			got = append(got, m.ID)
+		}
+	}
+	if len(got) != 1 || got[0] != expired {
+		t.Errorf("got markets %v, want only %s", got, expired)
+	}
+}
+
+func TestGetMarketsWithoutTokens(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+
+	seedMarket(t, s, platform, testID(t, "token"))
+	tokenless := seedMarket(t, s, platform)
+
+	markets, err := s.GetMarketsWithoutTokens(ctx, platform)
+	if err != nil {
+		t.Fatalf("get markets without tokens: %v", err)
+	}
+	if len(markets) != 1 || markets[0].ID != tokenless {
+		t.Errorf("got %+v, want only market %s", markets, tokenless)
+	}
+}
+
+func TestSlugLookups(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	marketID := seedMarket(t, s, testID(t, "platform"), testID(t, "yes"))
+	slug := testID(t, "will-it-rain-tomorrow")
+	if err := s.SetMarketEnrichment(ctx, store.SetMarketEnrichmentParams{
+		ID:   marketID,
+		Slug: pgtype.Text{String: slug, Valid: true},
+	}); err != nil {
+		t.Fatalf("set market enrichment: %v", err)
+	}
+
+	gotSlug, err := s.GetSlugByConditionID(ctx, marketID)
+	if err != nil {
+		t.Fatalf("get slug: %v", err)
+	}
+	if gotSlug != slug {
+		t.Errorf("slug = %q, want %q", gotSlug, slug)
+	}
+
+	gotID, err := s.GetConditionIDBySlug(ctx, slug)
+	if err != nil {
+		t.Fatalf("get condition ID: %v", err)
+	}
+	if gotID != marketID {
+		t.Errorf("condition ID = %q, want %q", gotID, marketID)
+	}
+
+	if _, err := s.GetConditionIDBySlug(ctx, testID(t, "unknown-slug")); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("unknown slug error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
+
+func TestUpsertMarketKeepsTradingParams(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+	marketID := seedMarket(t, s, platform)
+
+	upsert := func(params store.UpsertMarketParams) {
+		t.Helper()
+		params.ID = marketID
+		params.Platform = platform
+		params.Description = "test market"
+		if err := s.UpsertMarket(ctx, params); err != nil {
+			t.Fatalf("upsert market: %v", err)
+		}
+	}
+	upsert(store.UpsertMarketParams{
+		MinimumTickSize:  pgtype.Int8{Int64: 1_000, Valid: true},
+		MinimumOrderSize: pgtype.Int8{Int64: 5_000_000, Valid: true},
+		MakerBaseFeeBps:  pgtype.Int4{Int32: 0, Valid: true},
+		TakerBaseFeeBps:  pgtype.Int4{Int32: 200, Valid: true},
+	})
+	// An upsert without trading params, e.g. from another source, keeps them.
+	upsert(store.UpsertMarketParams{})
+
+	m, err := s.GetMarket(ctx, marketID)
+	if err != nil {
+		t.Fatalf("get market: %v", err)
+	}
+	if m.MinimumTickSize.Int64 != 1_000 || m.MinimumOrderSize.Int64 != 5_000_000 ||
+		!m.MakerBaseFeeBps.Valid || m.MakerBaseFeeBps.Int32 != 0 || m.TakerBaseFeeBps.Int32 != 200 {
+		t.Errorf("trading params = %v %v %v %v, want 1000 5000000 0 200",
+			m.MinimumTickSize, m.MinimumOrderSize, m.MakerBaseFeeBps, m.TakerBaseFeeBps)
+	}
+}
@@ -11,6 +11,13 @@ import (
 	"github.com/pgvector/pgvector-go"
 )
 
+type BackfillProgress struct {
+	TokenID string `json:"token_id"`
+	// End of the last backfilled window, exclusive. The cursor only moves forward
+	LastBackfilledTs time.Time `json:"last_backfilled_ts"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
 type Market struct {
 	ID          string             `json:"id"`
 	Platform    string             `json:"platform"`
@@ -18,6 +25,18 @@ type Market struct {
 	EndDate     pgtype.Timestamptz `json:"end_date"`
 	CreatedAt   time.Time          `json:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at"`
+	Question    pgtype.Text        `json:"question"`
+	Slug        pgtype.Text        `json:"slug"`
+	// Set when a sync couldn't fetch the Gamma details of the market
+	NeedsEnrichment bool `json:"needs_enrichment"`
+	// Price increment, scaled by 10^6
+	MinimumTickSize pgtype.Int8 `json:"minimum_tick_size"`
+	// Smallest order in shares, scaled by 10^6
+	MinimumOrderSize pgtype.Int8 `json:"minimum_order_size"`
+	// Maker fee in basis points
+	MakerBaseFeeBps pgtype.Int4 `json:"maker_base_fee_bps"`
+	// Taker fee in basis points
+	TakerBaseFeeBps pgtype.Int4 `json:"taker_base_fee_bps"`
 }
 
 type MarketEmbedding struct {
@@ -64,7 +83,17 @@ type NewsMarketLink struct {
 	CreatedAt       time.Time     `json:"created_at"`
 }
 
+type OrderBookDocument struct {
+	// When the snapshot was captured
+	Time    time.Time `json:"time"`
+	TokenID string    `json:"token_id"`
+	Book    []byte    `json:"book"`
+	// When data was stored in our DB
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
 type OrderBookMetric struct {
+	// Event time from source API
 	Time       time.Time   `json:"time"`
 	TokenID    string      `json:"token_id"`
 	MidPrice   pgtype.Int8 `json:"mid_price"`
@@ -77,27 +106,48 @@ type OrderBookMetric struct {
 	BidDepth10 pgtype.Int8 `json:"bid_depth_10"`
 	AskDepth10 pgtype.Int8 `json:"ask_depth_10"`
 	Imbalance  pgtype.Int2 `json:"imbalance"`
+	// When data was stored in our DB
+	IngestedAt time.Time `json:"ingested_at"`
 }
 
 type OrderBookSnapshot struct {
+	// Event time from source API
 	Time    time.Time `json:"time"`
 	TokenID string    `json:"token_id"`
 	Side    string    `json:"side"`
-	Level   int16     `json:"level"`
-	Price   int64     `json:"price"`
-	Size    int64     `json:"size"`
+	// Level from the best price, 0 is the best. -1 marks an empty side (price and size 0) when empty side rows are enabled
+	Level int16 `json:"level"`
+	Price int64 `json:"price"`
+	Size  int64 `json:"size"`
+	// When data was stored in our DB
+	IngestedAt time.Time `json:"ingested_at"`
+	// First 8 bytes of the MD5 of the snapshot levels, NULL for rows written before checksums
+	Checksum pgtype.Int8 `json:"checksum"`
+}
+
+type SubscriptionState struct {
+	Platform string `json:"platform"`
+	TokenID  string `json:"token_id"`
+	// When the token was first saved as subscribed
+	SubscribedAt time.Time `json:"subscribed_at"`
 }
 
 type Token struct {
-	ID              string      `json:"id"`
-	MarketID        string      `json:"market_id"`
+	ID       string `json:"id"`
+	MarketID string `json:"market_id"`
+	// Canonical outcome label, e.g. YES or NO
 	Outcome         string      `json:"outcome"`
 	Winning         pgtype.Bool `json:"winning"`
 	SettlementPrice pgtype.Int8 `json:"settlement_price"`
 	CreatedAt       time.Time   `json:"created_at"`
+	// Outcome label as received from the platform
+	OutcomeRaw pgtype.Text `json:"outcome_raw"`
+	// When the resolution was first recorded, NULL until resolved
+	ResolvedAt pgtype.Timestamptz `json:"resolved_at"`
 }
 
 type Trade struct {
+	// Event time from source API
 	Time    time.Time   `json:"time"`
 	TokenID string      `json:"token_id"`
 	TradeID pgtype.Text `json:"trade_id"`
@@ -106,4 +156,6 @@ type Trade struct {
 	Side    string      `json:"side"`
 	Maker   pgtype.Text `json:"maker"`
 	Taker   pgtype.Text `json:"taker"`
+	// When data was stored in our DB
+	IngestedAt time.Time `json:"ingested_at"`
 }
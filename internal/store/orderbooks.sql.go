@@ -12,8 +12,92 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const getInsideQuoteRows = `-- name: GetInsideQuoteRows :many
+SELECT ingested_at,
+    COALESCE(MAX(price) FILTER (WHERE side = 'bid'), 0)::BIGINT AS best_bid,
+    COALESCE(MAX(price) FILTER (WHERE side = 'ask'), 0)::BIGINT AS best_ask
+FROM order_book_snapshots
+WHERE token_id = $1 AND level = 0
+AND ingested_at >= $2 AND ingested_at < $3
+GROUP BY ingested_at
+ORDER BY ingested_at
+`
+
+type GetInsideQuoteRowsParams struct {
+	TokenID  string    `json:"token_id"`
+	FromTime time.Time `json:"from_time"`
+	ToTime   time.Time `json:"to_time"`
+}
+
+type GetInsideQuoteRowsRow struct {
+	IngestedAt time.Time `json:"ingested_at"`
+	BestBid    int64     `json:"best_bid"`
+	BestAsk    int64     `json:"best_ask"`
+}
+
+// Level 0 of each side per snapshot ingested in [from, to). Snapshots are
+// told apart by ingested_at since with event times the levels of one
+// snapshot have different times.
+func (q *Queries) GetInsideQuoteRows(ctx context.Context, arg GetInsideQuoteRowsParams) ([]GetInsideQuoteRowsRow, error) {
+	rows, err := q.db.Query(ctx, getInsideQuoteRows, arg.TokenID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetInsideQuoteRowsRow
+	for rows.Next() {
+		var i GetInsideQuoteRowsRow
+		if err := rows.Scan(&i.IngestedAt, &i.BestBid, &i.BestAsk); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLastIngestedOrderBookSnapshot = `-- name: GetLastIngestedOrderBookSnapshot :many
+SELECT time, token_id, side, level, price, size, ingested_at, checksum FROM order_book_snapshots obs
+WHERE obs.token_id = $1
+AND obs.ingested_at = (SELECT MAX(sub.ingested_at) FROM order_book_snapshots sub WHERE sub.token_id = $1)
+ORDER BY obs.side, obs.level
+`
+
+// Rows of the most recent batch written for a token. All rows of a batch
+// share ingested_at, while their time can differ per level.
+func (q *Queries) GetLastIngestedOrderBookSnapshot(ctx context.Context, tokenID string) ([]OrderBookSnapshot, error) {
+	rows, err := q.db.Query(ctx, getLastIngestedOrderBookSnapshot, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrderBookSnapshot
+	for rows.Next() {
+		var i OrderBookSnapshot
+		if err := rows.Scan(
+			&i.Time,
+			&i.TokenID,
+			&i.Side,
+			&i.Level,
+			&i.Price,
+			&i.Size,
+			&i.IngestedAt,
+			&i.Checksum,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLatestOrderBookMetrics = `-- name: GetLatestOrderBookMetrics :one
-SELECT time, token_id, mid_price, best_bid, best_ask, spread, spread_bps, bid_depth_5, ask_depth_5, bid_depth_10, ask_depth_10, imbalance FROM order_book_metrics
+SELECT time, token_id, mid_price, best_bid, best_ask, spread, spread_bps, bid_depth_5, ask_depth_5, bid_depth_10, ask_depth_10, imbalance, ingested_at FROM order_book_metrics
 WHERE token_id = $1
 ORDER BY time DESC
 LIMIT 1
@@ -35,12 +119,13 @@ func (q *Queries) GetLatestOrderBookMetrics(ctx context.Context, tokenID string)
 		&i.BidDepth10,
 		&i.AskDepth10,
 		&i.Imbalance,
+		&i.IngestedAt,
 	)
 	return i, err
 }
 
 const getLatestOrderBookSnapshot = `-- name: GetLatestOrderBookSnapshot :many
-SELECT time, token_id, side, level, price, size FROM order_book_snapshots obs
+SELECT time, token_id, side, level, price, size, ingested_at, checksum FROM order_book_snapshots obs
 WHERE obs.token_id = $1
 AND obs.time = (SELECT MAX(sub.time) FROM order_book_snapshots sub WHERE sub.token_id = $1)
 ORDER BY obs.side, obs.level
@@ -62,6 +147,8 @@ func (q *Queries) GetLatestOrderBookSnapshot(ctx context.Context, tokenID string
 			&i.Level,
 			&i.Price,
 			&i.Size,
+			&i.IngestedAt,
+			&i.Checksum,
 		); err != nil {
 			return nil, err
 		}
@@ -73,8 +160,32 @@ func (q *Queries) GetLatestOrderBookSnapshot(ctx context.Context, tokenID string
 	return items, nil
 }
 
+const getOrderBookDocumentAt = `-- name: GetOrderBookDocumentAt :one
+SELECT time, token_id, book, ingested_at FROM order_book_documents
+WHERE token_id = $1 AND time <= $2
+ORDER BY time DESC
+LIMIT 1
+`
+
+type GetOrderBookDocumentAtParams struct {
+	TokenID string    `json:"token_id"`
+	Time    time.Time `json:"time"`
+}
+
+func (q *Queries) GetOrderBookDocumentAt(ctx context.Context, arg GetOrderBookDocumentAtParams) (OrderBookDocument, error) {
+	row := q.db.QueryRow(ctx, getOrderBookDocumentAt, arg.TokenID, arg.Time)
+	var i OrderBookDocument
+	err := row.Scan(
+		&i.Time,
+		&i.TokenID,
+		&i.Book,
+		&i.IngestedAt,
+	)
+	return i, err
+}
+
 const getOrderBookMetricsRange = `-- name: GetOrderBookMetricsRange :many
-SELECT time, token_id, mid_price, best_bid, best_ask, spread, spread_bps, bid_depth_5, ask_depth_5, bid_depth_10, ask_depth_10, imbalance FROM order_book_metrics
+SELECT time, token_id, mid_price, best_bid, best_ask, spread, spread_bps, bid_depth_5, ask_depth_5, bid_depth_10, ask_depth_10, imbalance, ingested_at FROM order_book_metrics
 WHERE token_id = $1 AND time >= $2 AND time <= $3
 ORDER BY time DESC
 `
@@ -107,6 +218,7 @@ func (q *Queries) GetOrderBookMetricsRange(ctx context.Context, arg GetOrderBook
 			&i.BidDepth10,
 			&i.AskDepth10,
 			&i.Imbalance,
+			&i.IngestedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -118,6 +230,121 @@ func (q *Queries) GetOrderBookMetricsRange(ctx context.Context, arg GetOrderBook
 	return items, nil
 }
 
+const getRealizedVolatilityRow = `-- name: GetRealizedVolatilityRow :one
+WITH mids AS (
+    -- Mid price of each snapshot with both sides. Snapshots are told apart
+    -- by ingested_at, see GetInsideQuoteRows.
+    SELECT ingested_at AS time,
+        (MAX(price) FILTER (WHERE side = 'bid') + MAX(price) FILTER (WHERE side = 'ask')) / 2.0 AS mid
+    FROM order_book_snapshots
+    WHERE token_id = $2 AND level = 0
+    AND ingested_at >= $3 AND ingested_at < $4
+    GROUP BY ingested_at
+    HAVING MAX(price) FILTER (WHERE side = 'bid') > 0 AND MAX(price) FILTER (WHERE side = 'ask') > 0
+),
+buckets AS (
+    -- Last mid of each bucket, buckets starting at from_time.
+    SELECT DISTINCT ON (bucket) bucket, mid
+    FROM (
+        SELECT date_bin(make_interval(secs => $1::float8), time, $3) AS bucket, time, mid
+        FROM mids
+    ) binned
+    ORDER BY bucket, time DESC
+),
+returns AS (
+    -- Only returns between adjacent buckets count, so gaps don't inflate them.
+    SELECT bucket - LAG(bucket) OVER w AS gap, ln(mid / LAG(mid) OVER w) AS log_return
+    FROM buckets
+    WINDOW w AS (ORDER BY bucket)
+)
+SELECT COALESCE(stddev_samp(log_return), 0)::float8 AS volatility, COUNT(log_return)::int AS returns
+FROM returns
+WHERE gap = make_interval(secs => $1::float8)
+`
+
+type GetRealizedVolatilityRowParams struct {
+	BucketSeconds float64   `json:"bucket_seconds"`
+	TokenID       string    `json:"token_id"`
+	FromTime      time.Time `json:"from_time"`
+	ToTime        time.Time `json:"to_time"`
+}
+
+type GetRealizedVolatilityRowRow struct {
+	Volatility float64 `json:"volatility"`
+	Returns    int32   `json:"returns"`
+}
+
+// Use Store.GetRealizedVolatility.
+func (q *Queries) GetRealizedVolatilityRow(ctx context.Context, arg GetRealizedVolatilityRowParams) (GetRealizedVolatilityRowRow, error) {
+	row := q.db.QueryRow(ctx, getRealizedVolatilityRow,
+		arg.BucketSeconds,
+		arg.TokenID,
+		arg.FromTime,
+		arg.ToTime,
+	)
+	var i GetRealizedVolatilityRowRow
+	err := row.Scan(&i.Volatility, &i.Returns)
+	return i, err
+}
+
+const getSnapshotChecksumMismatches = `-- name: GetSnapshotChecksumMismatches :many
+SELECT token_id, ingested_at,
+    MIN(checksum)::BIGINT AS stored_checksum,
+    ('x' || substr(md5(string_agg(side || ':' || level || ':' || price || ':' || size, ',' ORDER BY side, level)), 1, 16))::bit(64)::BIGINT AS computed_checksum
+FROM order_book_snapshots
+WHERE checksum IS NOT NULL
+AND ingested_at >= $1 AND ingested_at < $2
+GROUP BY token_id, ingested_at
+HAVING COUNT(DISTINCT checksum) > 1
+    OR MIN(checksum) <> ('x' || substr(md5(string_agg(side || ':' || level || ':' || price || ':' || size, ',' ORDER BY side, level)), 1, 16))::bit(64)::BIGINT
+ORDER BY ingested_at, token_id
+`
+
+type GetSnapshotChecksumMismatchesParams struct {
+	FromTime time.Time `json:"from_time"`
+	ToTime   time.Time `json:"to_time"`
+}
+
+type GetSnapshotChecksumMismatchesRow struct {
+	TokenID          string    `json:"token_id"`
+	IngestedAt       time.Time `json:"ingested_at"`
+	StoredChecksum   int64     `json:"stored_checksum"`
+	ComputedChecksum int64     `json:"computed_checksum"`
+}
+
+// Snapshots ingested in [from, to) whose rows don't match the checksum they
+// were written with. Must compute the same checksum as SnapshotChecksum.
+func (q *Queries) GetSnapshotChecksumMismatches(ctx context.Context, arg GetSnapshotChecksumMismatchesParams) ([]GetSnapshotChecksumMismatchesRow, error) {
+	rows, err := q.db.Query(ctx, getSnapshotChecksumMismatches, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSnapshotChecksumMismatchesRow
+	for rows.Next() {
+		var i GetSnapshotChecksumMismatchesRow
+		if err := rows.Scan(
+			&i.TokenID,
+			&i.IngestedAt,
+			&i.StoredChecksum,
+			&i.ComputedChecksum,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type InsertOrderBookDocumentBatchParams struct {
+	Time    time.Time `json:"time"`
+	TokenID string    `json:"token_id"`
+	Book    []byte    `json:"book"`
+}
+
 const insertOrderBookMetrics = `-- name: InsertOrderBookMetrics :exec
 INSERT INTO order_book_metrics (
     time, token_id, mid_price, best_bid, best_ask, spread, spread_bps,
@@ -201,10 +428,11 @@ func (q *Queries) InsertOrderBookSnapshot(ctx context.Context, arg InsertOrderBo
 }
 
 type InsertOrderBookSnapshotBatchParams struct {
-	Time    time.Time `json:"time"`
-	TokenID string    `json:"token_id"`
-	Side    string    `json:"side"`
-	Level   int16     `json:"level"`
-	Price   int64     `json:"price"`
-	Size    int64     `json:"size"`
+	Time     time.Time   `json:"time"`
+	TokenID  string      `json:"token_id"`
+	Side     string      `json:"side"`
+	Level    int16       `json:"level"`
+	Price    int64       `json:"price"`
+	Size     int64       `json:"size"`
+	Checksum pgtype.Int8 `json:"checksum"`
 }
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DefaultOutcomeLabels maps common spellings of binary outcomes to their
+// canonical label. Keys are matched after trimming and upper-casing.
+var DefaultOutcomeLabels = map[string]string{
+	"YES":   "YES",
+	"Y":     "YES",
+	"TRUE":  "YES",
+	"NO":    "NO",
+	"N":     "NO",
+	"FALSE": "NO",
+}
+
+// OutcomeNormalizer canonicalizes outcome labels.
+type OutcomeNormalizer struct {
+	labels map[string]string
+}
+
+// NewOutcomeNormalizer returns a normalizer using DefaultOutcomeLabels plus
+// extra, which takes precedence. Keys of extra are matched like the defaults.
+func NewOutcomeNormalizer(extra map[string]string) OutcomeNormalizer {
+	labels := maps.Clone(DefaultOutcomeLabels)
+	for from, to := range extra {
+		labels[normalizeKey(from)] = to
+	}
+	return OutcomeNormalizer{labels: labels}
+}
+
+// Normalize returns the canonical label for outcome, or outcome unchanged if
+// it isn't mapped.
+func (n OutcomeNormalizer) Normalize(outcome string) string {
+	if canonical, ok := n.labels[normalizeKey(outcome)]; ok {
+		return canonical
+	}
+	return outcome
+}
+
+func normalizeKey(label string) string {
+	return strings.ToUpper(strings.TrimSpace(label))
+}
+
+// SetOutcomeLabels replaces the extra outcome mappings used by UpsertToken.
+func (s *Store) SetOutcomeLabels(extra map[string]string) {
+	s.outcomes = NewOutcomeNormalizer(extra)
+}
+
+// UpsertTokenParams are the parameters of UpsertToken.
+type UpsertTokenParams struct {
+	ID              string
+	MarketID        string
+	Outcome         string // As received from the platform.
+	Winning         pgtype.Bool
+	SettlementPrice pgtype.Int8
+}
+
+// UpsertToken inserts or updates a token. The outcome label is stored
+// canonicalized, and as received in outcome_raw.
+func (s *Store) UpsertToken(ctx context.Context, arg UpsertTokenParams) error {
+	return s.UpsertTokenRow(ctx, UpsertTokenRowParams{
+		ID:              arg.ID,
+		MarketID:        arg.MarketID,
+		Outcome:         s.outcomes.Normalize(arg.Outcome),
+		OutcomeRaw:      pgtype.Text{String: arg.Outcome, Valid: true},
+		Winning:         arg.Winning,
+		SettlementPrice: arg.SettlementPrice,
+	})
+}
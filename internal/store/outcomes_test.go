@@ -0,0 +1,50 @@
+package store
+
+import "testing"
+
+func TestOutcomeNormalizerDefaults(t *testing.T) {
+	n := NewOutcomeNormalizer(nil)
+
+	tests := map[string]string{
+		"Yes":   "YES",
+		"YES":   "YES",
+		"yes ":  "YES",
+		"Y":     "YES",
+		"true":  "YES",
+		"No":    "NO",
+		" n":    "NO",
+		"FALSE": "NO",
+	}
+	for in, want := range tests {
+		if got := n.Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOutcomeNormalizerUnmappedPassesThrough(t *testing.T) {
+	n := NewOutcomeNormalizer(nil)
+
+	for _, in := range []string{"Trump", " Over 2.5 ", "Lakers"} {
+		if got := n.Normalize(in); got != in {
+			t.Errorf("Normalize(%q) = %q, want it unchanged", in, got)
+		}
+	}
+}
+
+func TestOutcomeNormalizerExtraLabels(t *testing.T) {
+	n := NewOutcomeNormalizer(map[string]string{
+		"over ": "OVER",
+		"y":     "Y", // Overrides a default.
+	})
+
+	if got := n.Normalize("Over"); got != "OVER" {
+		t.Errorf("Normalize(%q) = %q, want %q", "Over", got, "OVER")
+	}
+	if got := n.Normalize("Y"); got != "Y" {
+		t.Errorf("Normalize(%q) = %q, want %q", "Y", got, "Y")
+	}
+	if got := n.Normalize("Yes"); got != "YES" {
+		t.Errorf("Normalize(%q) = %q, want %q", "Yes", got, "YES")
+	}
+}
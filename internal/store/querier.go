@@ -6,20 +6,35 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
-	DeleteMarket(ctx context.Context, id string) error
+	DeleteMarketBookDocuments(ctx context.Context, arg DeleteMarketBookDocumentsParams) (int64, error)
 	DeleteMarketEmbedding(ctx context.Context, marketID string) error
 	DeleteMarketPair(ctx context.Context, arg DeleteMarketPairParams) error
+	// Use Store.DeleteMarket, which deletes the market's tokens first.
+	DeleteMarketRow(ctx context.Context, arg DeleteMarketRowParams) (int64, error)
+	DeleteMarketSnapshots(ctx context.Context, arg DeleteMarketSnapshotsParams) (int64, error)
+	DeleteMarketTokens(ctx context.Context, arg DeleteMarketTokensParams) (int64, error)
 	DeleteNewsArticle(ctx context.Context, id int32) error
 	DeleteNewsMarketLink(ctx context.Context, arg DeleteNewsMarketLinkParams) error
+	DeleteSubscriptionsNotIn(ctx context.Context, arg DeleteSubscriptionsNotInParams) error
 	DeleteToken(ctx context.Context, id string) error
 	FindSimilarMarketsByDescription(ctx context.Context, arg FindSimilarMarketsByDescriptionParams) ([]FindSimilarMarketsByDescriptionRow, error)
 	FindSimilarNewsByHeadline(ctx context.Context, arg FindSimilarNewsByHeadlineParams) ([]FindSimilarNewsByHeadlineRow, error)
+	GetBackfillProgress(ctx context.Context, tokenID string) (time.Time, error)
+	GetConditionIDBySlug(ctx context.Context, slug string) (string, error)
 	GetEquivalentMarkets(ctx context.Context, marketIDA string) ([]MarketPair, error)
+	// Level 0 of each side per snapshot ingested in [from, to). Snapshots are
+	// told apart by ingested_at since with event times the levels of one
+	// snapshot have different times.
+	GetInsideQuoteRows(ctx context.Context, arg GetInsideQuoteRowsParams) ([]GetInsideQuoteRowsRow, error)
+	// Rows of the most recent batch written for a token. All rows of a batch
+	// share ingested_at, while their time can differ per level.
+	GetLastIngestedOrderBookSnapshot(ctx context.Context, tokenID string) ([]OrderBookSnapshot, error)
 	GetLatestOrderBookMetrics(ctx context.Context, tokenID string) (OrderBookMetric, error)
 	GetLatestOrderBookSnapshot(ctx context.Context, tokenID string) ([]OrderBookSnapshot, error)
 	GetLinksForMarket(ctx context.Context, arg GetLinksForMarketParams) ([]NewsMarketLink, error)
@@ -28,21 +43,45 @@ type Querier interface {
 	GetMarketEmbedding(ctx context.Context, marketID string) (MarketEmbedding, error)
 	GetMarketPair(ctx context.Context, arg GetMarketPairParams) (MarketPair, error)
 	GetMarketsByPlatform(ctx context.Context, platform string) ([]Market, error)
+	GetMarketsNeedingEnrichment(ctx context.Context, platform string) ([]Market, error)
+	// Markets whose end date has passed but none of whose tokens has a
+	// resolution yet, for polling resolution without a full sync.
+	GetMarketsPastEndDateUnresolved(ctx context.Context, now time.Time) ([]Market, error)
+	// Markets left without tokens by a sync that failed part way, for repair.
+	GetMarketsWithoutTokens(ctx context.Context, platform string) ([]Market, error)
 	GetNewsArticle(ctx context.Context, id int32) (NewsArticle, error)
 	GetNewsArticleByURL(ctx context.Context, url pgtype.Text) (NewsArticle, error)
 	GetNewsMarketLink(ctx context.Context, arg GetNewsMarketLinkParams) (NewsMarketLink, error)
+	GetOrderBookDocumentAt(ctx context.Context, arg GetOrderBookDocumentAtParams) (OrderBookDocument, error)
 	GetOrderBookMetricsRange(ctx context.Context, arg GetOrderBookMetricsRangeParams) ([]OrderBookMetric, error)
+	// Use Store.GetRealizedVolatility.
+	GetRealizedVolatilityRow(ctx context.Context, arg GetRealizedVolatilityRowParams) (GetRealizedVolatilityRowRow, error)
+	// Use Store.GetResolvedMarketsWithWinners.
+	GetResolvedMarketRows(ctx context.Context, arg GetResolvedMarketRowsParams) ([]GetResolvedMarketRowsRow, error)
+	GetSlugByConditionID(ctx context.Context, id string) (string, error)
+	// Snapshots ingested in [from, to) whose rows don't match the checksum they
+	// were written with. Must compute the same checksum as SnapshotChecksum.
+	GetSnapshotChecksumMismatches(ctx context.Context, arg GetSnapshotChecksumMismatchesParams) ([]GetSnapshotChecksumMismatchesRow, error)
+	GetSubscriptionState(ctx context.Context, platform string) ([]SubscriptionState, error)
 	GetToken(ctx context.Context, id string) (Token, error)
 	GetTokenIDsForPlatform(ctx context.Context, platform string) ([]string, error)
+	// Use Store.StreamTokenIDsForPlatform.
+	GetTokenIDsForPlatformPage(ctx context.Context, arg GetTokenIDsForPlatformPageParams) ([]string, error)
+	// Tokens of the platform's max_markets most recently synced markets.
+	GetTokenIDsForRecentMarkets(ctx context.Context, arg GetTokenIDsForRecentMarketsParams) ([]string, error)
 	GetTokensByMarket(ctx context.Context, marketID string) ([]Token, error)
+	// Use Store.GetTopMarketsByVolume.
+	GetTopMarketVolumeRows(ctx context.Context, arg GetTopMarketVolumeRowsParams) ([]GetTopMarketVolumeRowsRow, error)
 	GetTradeByID(ctx context.Context, tradeID pgtype.Text) (Trade, error)
 	GetTradesByToken(ctx context.Context, arg GetTradesByTokenParams) ([]Trade, error)
 	GetTradesRange(ctx context.Context, arg GetTradesRangeParams) ([]Trade, error)
 	InsertNewsArticle(ctx context.Context, arg InsertNewsArticleParams) (int32, error)
+	InsertOrderBookDocumentBatch(ctx context.Context, arg []InsertOrderBookDocumentBatchParams) (int64, error)
 	InsertOrderBookMetrics(ctx context.Context, arg InsertOrderBookMetricsParams) error
 	InsertOrderBookMetricsBatch(ctx context.Context, arg []InsertOrderBookMetricsBatchParams) (int64, error)
 	InsertOrderBookSnapshot(ctx context.Context, arg InsertOrderBookSnapshotParams) error
 	InsertOrderBookSnapshotBatch(ctx context.Context, arg []InsertOrderBookSnapshotBatchParams) (int64, error)
+	InsertSubscriptions(ctx context.Context, arg InsertSubscriptionsParams) error
 	InsertTrade(ctx context.Context, arg InsertTradeParams) error
 	InsertTradeBatch(ctx context.Context, arg []InsertTradeBatchParams) (int64, error)
 	ListMarkets(ctx context.Context, arg ListMarketsParams) ([]Market, error)
@@ -50,13 +89,21 @@ type Querier interface {
 	ListUnanalyzedLinks(ctx context.Context, limit int32) ([]NewsMarketLink, error)
 	ListUnprocessedNewsArticles(ctx context.Context, limit int32) ([]NewsArticle, error)
 	ListUnverifiedPairs(ctx context.Context, limit int32) ([]MarketPair, error)
+	MarkMarketsNeedEnrichment(ctx context.Context, ids []string) error
 	MarkNewsArticleProcessed(ctx context.Context, id int32) error
+	SetMarketEnrichment(ctx context.Context, arg SetMarketEnrichmentParams) error
 	SetTokenResolution(ctx context.Context, arg SetTokenResolutionParams) error
+	SumMarketTradeSize(ctx context.Context, arg SumMarketTradeSizeParams) (int64, error)
+	SumTokenTradeSize(ctx context.Context, arg SumTokenTradeSizeParams) (int64, error)
+	// The cursor never moves back, e.g. when a window is backfilled again.
+	UpsertBackfillProgress(ctx context.Context, arg UpsertBackfillProgressParams) error
+	// Trading parameters left NULL keep the stored ones.
 	UpsertMarket(ctx context.Context, arg UpsertMarketParams) error
 	UpsertMarketEmbedding(ctx context.Context, arg UpsertMarketEmbeddingParams) error
 	UpsertMarketPair(ctx context.Context, arg UpsertMarketPairParams) error
 	UpsertNewsMarketLink(ctx context.Context, arg UpsertNewsMarketLinkParams) error
-	UpsertToken(ctx context.Context, arg UpsertTokenParams) error
+	// Use Store.UpsertToken, which canonicalizes the outcome label.
+	UpsertTokenRow(ctx context.Context, arg UpsertTokenRowParams) error
 }
 
 var _ Querier = (*Queries)(nil)
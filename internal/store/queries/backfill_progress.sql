@@ -0,0 +1,10 @@
+-- name: GetBackfillProgress :one
+SELECT last_backfilled_ts FROM backfill_progress WHERE token_id = $1;
+
+-- name: UpsertBackfillProgress :exec
+-- The cursor never moves back, e.g. when a window is backfilled again.
+INSERT INTO backfill_progress (token_id, last_backfilled_ts)
+VALUES ($1, $2)
+ON CONFLICT (token_id) DO UPDATE SET
+    last_backfilled_ts = GREATEST(backfill_progress.last_backfilled_ts, EXCLUDED.last_backfilled_ts),
+    updated_at = NOW();
@@ -8,12 +8,76 @@ SELECT * FROM markets WHERE platform = $1 ORDER BY created_at DESC;
 SELECT * FROM markets ORDER BY created_at DESC LIMIT $1 OFFSET $2;
 
 -- name: UpsertMarket :exec
-INSERT INTO markets (id, platform, description, end_date, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
+-- Trading parameters left NULL keep the stored ones.
+INSERT INTO markets (
+    id, platform, description, end_date,
+    minimum_tick_size, minimum_order_size, maker_base_fee_bps, taker_base_fee_bps,
+    created_at, updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
 ON CONFLICT (id) DO UPDATE SET
     description = EXCLUDED.description,
     end_date = EXCLUDED.end_date,
+    minimum_tick_size = COALESCE(EXCLUDED.minimum_tick_size, markets.minimum_tick_size),
+    minimum_order_size = COALESCE(EXCLUDED.minimum_order_size, markets.minimum_order_size),
+    maker_base_fee_bps = COALESCE(EXCLUDED.maker_base_fee_bps, markets.maker_base_fee_bps),
+    taker_base_fee_bps = COALESCE(EXCLUDED.taker_base_fee_bps, markets.taker_base_fee_bps),
     updated_at = NOW();
 
--- name: DeleteMarket :exec
-DELETE FROM markets WHERE id = $1;
+-- name: DeleteMarketRow :execrows
+-- Use Store.DeleteMarket, which deletes the market's tokens first.
+DELETE FROM markets WHERE id = sqlc.arg(id) AND platform = sqlc.arg(platform);
+
+-- name: DeleteMarketTokens :execrows
+DELETE FROM tokens t USING markets m
+WHERE t.market_id = m.id AND m.id = sqlc.arg(id) AND m.platform = sqlc.arg(platform);
+
+-- name: DeleteMarketSnapshots :execrows
+DELETE FROM order_book_snapshots
+WHERE token_id IN (
+    SELECT t.id FROM tokens t
+    JOIN markets m ON t.market_id = m.id
+    WHERE m.id = sqlc.arg(id) AND m.platform = sqlc.arg(platform)
+);
+
+-- name: DeleteMarketBookDocuments :execrows
+DELETE FROM order_book_documents
+WHERE token_id IN (
+    SELECT t.id FROM tokens t
+    JOIN markets m ON t.market_id = m.id
+    WHERE m.id = sqlc.arg(id) AND m.platform = sqlc.arg(platform)
+);
+
+-- name: GetMarketsPastEndDateUnresolved :many
+-- Markets whose end date has passed but none of whose tokens has a
+-- resolution yet, for polling resolution without a full sync.
+SELECT m.* FROM markets m
+WHERE m.end_date < sqlc.arg(now)::timestamptz
+  AND NOT EXISTS (
+      SELECT 1 FROM tokens t
+      WHERE t.market_id = m.id AND t.winning IS NOT NULL
+  )
+ORDER BY m.end_date;
+
+-- name: GetMarketsWithoutTokens :many
+-- Markets left without tokens by a sync that failed part way, for repair.
+SELECT m.* FROM markets m
+WHERE m.platform = $1
+  AND NOT EXISTS (SELECT 1 FROM tokens t WHERE t.market_id = m.id)
+ORDER BY m.created_at;
+
+-- name: SetMarketEnrichment :exec
+UPDATE markets SET question = $2, slug = $3, needs_enrichment = FALSE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: MarkMarketsNeedEnrichment :exec
+UPDATE markets SET needs_enrichment = TRUE WHERE id = ANY(sqlc.arg(ids)::text[]);
+
+-- name: GetMarketsNeedingEnrichment :many
+SELECT * FROM markets WHERE platform = $1 AND needs_enrichment ORDER BY id;
+
+-- name: GetSlugByConditionID :one
+SELECT slug::text FROM markets WHERE id = $1 AND slug IS NOT NULL;
+
+-- name: GetConditionIDBySlug :one
+SELECT id FROM markets WHERE slug = sqlc.arg(slug)::text ORDER BY id LIMIT 1;
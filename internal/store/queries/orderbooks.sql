@@ -3,8 +3,8 @@ INSERT INTO order_book_snapshots (time, token_id, side, level, price, size)
 VALUES ($1, $2, $3, $4, $5, $6);
 
 -- name: InsertOrderBookSnapshotBatch :copyfrom
-INSERT INTO order_book_snapshots (time, token_id, side, level, price, size)
-VALUES ($1, $2, $3, $4, $5, $6);
+INSERT INTO order_book_snapshots (time, token_id, side, level, price, size, checksum)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
 
 -- name: GetLatestOrderBookSnapshot :many
 SELECT * FROM order_book_snapshots obs
@@ -12,6 +12,41 @@ WHERE obs.token_id = $1
 AND obs.time = (SELECT MAX(sub.time) FROM order_book_snapshots sub WHERE sub.token_id = $1)
 ORDER BY obs.side, obs.level;
 
+-- name: GetLastIngestedOrderBookSnapshot :many
+-- Rows of the most recent batch written for a token. All rows of a batch
+-- share ingested_at, while their time can differ per level.
+SELECT * FROM order_book_snapshots obs
+WHERE obs.token_id = $1
+AND obs.ingested_at = (SELECT MAX(sub.ingested_at) FROM order_book_snapshots sub WHERE sub.token_id = $1)
+ORDER BY obs.side, obs.level;
+
+-- name: GetSnapshotChecksumMismatches :many
+-- Snapshots ingested in [from, to) whose rows don't match the checksum they
+-- were written with. Must compute the same checksum as SnapshotChecksum.
+SELECT token_id, ingested_at,
+    MIN(checksum)::BIGINT AS stored_checksum,
+    ('x' || substr(md5(string_agg(side || ':' || level || ':' || price || ':' || size, ',' ORDER BY side, level)), 1, 16))::bit(64)::BIGINT AS computed_checksum
+FROM order_book_snapshots
+WHERE checksum IS NOT NULL
+AND ingested_at >= sqlc.arg(from_time) AND ingested_at < sqlc.arg(to_time)
+GROUP BY token_id, ingested_at
+HAVING COUNT(DISTINCT checksum) > 1
+    OR MIN(checksum) <> ('x' || substr(md5(string_agg(side || ':' || level || ':' || price || ':' || size, ',' ORDER BY side, level)), 1, 16))::bit(64)::BIGINT
+ORDER BY ingested_at, token_id;
+
+-- name: GetInsideQuoteRows :many
+-- Level 0 of each side per snapshot ingested in [from, to). Snapshots are
+-- told apart by ingested_at since with event times the levels of one
+-- snapshot have different times.
+SELECT ingested_at,
+    COALESCE(MAX(price) FILTER (WHERE side = 'bid'), 0)::BIGINT AS best_bid,
+    COALESCE(MAX(price) FILTER (WHERE side = 'ask'), 0)::BIGINT AS best_ask
+FROM order_book_snapshots
+WHERE token_id = sqlc.arg(token_id) AND level = 0
+AND ingested_at >= sqlc.arg(from_time) AND ingested_at < sqlc.arg(to_time)
+GROUP BY ingested_at
+ORDER BY ingested_at;
+
 -- name: InsertOrderBookMetrics :exec
 INSERT INTO order_book_metrics (
     time, token_id, mid_price, best_bid, best_ask, spread, spread_bps,
@@ -36,3 +71,45 @@ LIMIT 1;
 SELECT * FROM order_book_metrics
 WHERE token_id = $1 AND time >= $2 AND time <= $3
 ORDER BY time DESC;
+
+-- name: InsertOrderBookDocumentBatch :copyfrom
+INSERT INTO order_book_documents (time, token_id, book)
+VALUES ($1, $2, $3);
+
+-- name: GetOrderBookDocumentAt :one
+SELECT * FROM order_book_documents
+WHERE token_id = $1 AND time <= $2
+ORDER BY time DESC
+LIMIT 1;
+
+-- name: GetRealizedVolatilityRow :one
+-- Use Store.GetRealizedVolatility.
+WITH mids AS (
+    -- Mid price of each snapshot with both sides. Snapshots are told apart
+    -- by ingested_at, see GetInsideQuoteRows.
+    SELECT ingested_at AS time,
+        (MAX(price) FILTER (WHERE side = 'bid') + MAX(price) FILTER (WHERE side = 'ask')) / 2.0 AS mid
+    FROM order_book_snapshots
+    WHERE token_id = sqlc.arg(token_id) AND level = 0
+    AND ingested_at >= sqlc.arg(from_time) AND ingested_at < sqlc.arg(to_time)
+    GROUP BY ingested_at
+    HAVING MAX(price) FILTER (WHERE side = 'bid') > 0 AND MAX(price) FILTER (WHERE side = 'ask') > 0
+),
+buckets AS (
+    -- Last mid of each bucket, buckets starting at from_time.
+    SELECT DISTINCT ON (bucket) bucket, mid
+    FROM (
+        SELECT date_bin(make_interval(secs => sqlc.arg(bucket_seconds)::float8), time, sqlc.arg(from_time)) AS bucket, time, mid
+        FROM mids
+    ) binned
+    ORDER BY bucket, time DESC
+),
+returns AS (
+    -- Only returns between adjacent buckets count, so gaps don't inflate them.
+    SELECT bucket - LAG(bucket) OVER w AS gap, ln(mid / LAG(mid) OVER w) AS log_return
+    FROM buckets
+    WINDOW w AS (ORDER BY bucket)
+)
+SELECT COALESCE(stddev_samp(log_return), 0)::float8 AS volatility, COUNT(log_return)::int AS returns
+FROM returns
+WHERE gap = make_interval(secs => sqlc.arg(bucket_seconds)::float8);
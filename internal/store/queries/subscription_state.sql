@@ -0,0 +1,11 @@
+-- name: GetSubscriptionState :many
+SELECT * FROM subscription_state WHERE platform = $1 ORDER BY token_id;
+
+-- name: InsertSubscriptions :exec
+INSERT INTO subscription_state (platform, token_id)
+SELECT sqlc.arg(platform), unnest(sqlc.arg(token_ids)::TEXT[])
+ON CONFLICT (platform, token_id) DO NOTHING;
+
+-- name: DeleteSubscriptionsNotIn :exec
+DELETE FROM subscription_state
+WHERE platform = sqlc.arg(platform) AND NOT (token_id = ANY(sqlc.arg(token_ids)::TEXT[]));
@@ -4,16 +4,22 @@ SELECT * FROM tokens WHERE id = $1;
 -- name: GetTokensByMarket :many
 SELECT * FROM tokens WHERE market_id = $1 ORDER BY outcome;
 
--- name: UpsertToken :exec
-INSERT INTO tokens (id, market_id, outcome, winning, settlement_price, created_at)
-VALUES ($1, $2, $3, $4, $5, NOW())
+-- name: UpsertTokenRow :exec
+-- Use Store.UpsertToken, which canonicalizes the outcome label.
+INSERT INTO tokens (id, market_id, outcome, outcome_raw, winning, settlement_price, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
 ON CONFLICT (id) DO UPDATE SET
     outcome = EXCLUDED.outcome,
+    outcome_raw = EXCLUDED.outcome_raw,
     winning = EXCLUDED.winning,
     settlement_price = EXCLUDED.settlement_price;
 
 -- name: SetTokenResolution :exec
-UPDATE tokens SET winning = $2, settlement_price = $3 WHERE id = $1;
+UPDATE tokens SET
+    winning = $2,
+    settlement_price = $3,
+    resolved_at = CASE WHEN $2::boolean IS NULL THEN NULL ELSE COALESCE(resolved_at, NOW()) END
+WHERE id = $1;
 
 -- name: DeleteToken :exec
 DELETE FROM tokens WHERE id = $1;
@@ -22,3 +28,31 @@ DELETE FROM tokens WHERE id = $1;
 SELECT t.id FROM tokens t
 JOIN markets m ON t.market_id = m.id
 WHERE m.platform = $1;
+
+-- name: GetTokenIDsForRecentMarkets :many
+-- Tokens of the platform's max_markets most recently synced markets.
+SELECT t.id FROM tokens t
+JOIN (
+    SELECT id FROM markets
+    WHERE platform = sqlc.arg(platform)::text
+    ORDER BY updated_at DESC, id
+    LIMIT sqlc.arg(max_markets)::int
+) m ON t.market_id = m.id;
+
+-- name: GetTokenIDsForPlatformPage :many
+-- Use Store.StreamTokenIDsForPlatform.
+SELECT t.id FROM tokens t
+JOIN markets m ON t.market_id = m.id
+WHERE m.platform = sqlc.arg(platform)::text AND t.id > sqlc.arg(after_id)::text
+ORDER BY t.id
+LIMIT sqlc.arg(page_size)::int;
+
+-- name: GetResolvedMarketRows :many
+-- Use Store.GetResolvedMarketsWithWinners.
+SELECT m.id AS market_id, m.platform, t.id AS winning_token_id, t.outcome AS winning_outcome,
+    t.resolved_at::timestamptz AS resolved_at
+FROM tokens t
+JOIN markets m ON m.id = t.market_id
+WHERE t.winning
+  AND t.resolved_at >= sqlc.arg(from_time)::timestamptz AND t.resolved_at < sqlc.arg(to_time)::timestamptz
+ORDER BY t.resolved_at, m.id;
@@ -19,3 +19,24 @@ LIMIT $2;
 SELECT * FROM trades
 WHERE token_id = $1 AND time >= $2 AND time <= $3
 ORDER BY time DESC;
+
+-- name: SumTokenTradeSize :one
+SELECT COALESCE(SUM(size), 0)::BIGINT AS volume FROM trades
+WHERE token_id = $1 AND time >= $2 AND time < $3;
+
+-- name: SumMarketTradeSize :one
+SELECT COALESCE(SUM(tr.size), 0)::BIGINT AS volume FROM trades tr
+JOIN tokens t ON tr.token_id = t.id
+WHERE t.market_id = $1 AND tr.time >= $2 AND tr.time < $3;
+
+-- name: GetTopMarketVolumeRows :many
+-- Use Store.GetTopMarketsByVolume.
+SELECT m.id AS market_id, m.platform, COALESCE(m.question, m.description)::text AS question,
+    SUM(tr.size)::BIGINT AS volume
+FROM trades tr
+JOIN tokens t ON tr.token_id = t.id
+JOIN markets m ON t.market_id = m.id
+WHERE tr.time >= sqlc.arg(since)::timestamptz
+GROUP BY m.id
+ORDER BY volume DESC, m.id
+LIMIT sqlc.arg(max_markets)::int;
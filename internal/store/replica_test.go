@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lazyPool returns a pool that never connects until a query runs.
+func lazyPool(t *testing.T, database string) *pgxpool.Pool {
+	t.Helper()
+	pool, err := pgxpool.New(context.Background(), "postgres://test@127.0.0.1:1/"+database+"?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	return pool
+}
+
+func TestReadQueriesUsesReplica(t *testing.T) {
+	primary := lazyPool(t, "primary")
+	defer primary.Close()
+	s := NewStore(primary)
+
+	if s.ReadQueries().db != primary {
+		t.Fatal("without a replica, reads must use the primary pool")
+	}
+
+	replica := lazyPool(t, "replica")
+	s.SetReadReplica(replica)
+	if s.ReadQueries().db != replica {
+		t.Fatal("reads must use the replica pool")
+	}
+	if s.Queries.db != primary {
+		t.Fatal("writes must keep using the primary pool")
+	}
+
+	// A closed replica fails reads with its own error, not the primary's
+	// connection error, showing where they were routed.
+	replica.Close()
+	_, err := s.GetInsideQuotes(context.Background(), "token", time.Time{}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "closed pool") {
+		t.Errorf("GetInsideQuotes error = %v, want it to come from the closed replica", err)
+	}
+}
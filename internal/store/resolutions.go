@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// ResolvedMarket is a market whose winning token is known.
+type ResolvedMarket struct {
+	MarketID       string
+	Platform       string
+	WinningTokenID string
+	WinningOutcome string
+	ResolvedAt     time.Time
+}
+
+// SettlementPrice returns what a share of a token of the market pays out:
+// 1.0 for the winning token and 0 for the others.
+func (r ResolvedMarket) SettlementPrice(tokenID string) price.Price {
+	if tokenID == r.WinningTokenID {
+		return price.Price(price.PriceScale)
+	}
+	return 0
+}
+
+// SettlementValue values a position of size shares in a token of the market
+// at settlement, scaled by price.PriceScale. A short position (negative
+// size) has a negative value.
+func (r ResolvedMarket) SettlementValue(tokenID string, size price.Size) int64 {
+	return int64(size) * int64(r.SettlementPrice(tokenID)) / price.PriceScale
+}
+
+// GetResolvedMarketsWithWinners returns the markets resolved in [from, to),
+// oldest first.
+func (s *Store) GetResolvedMarketsWithWinners(ctx context.Context, from, to time.Time) ([]ResolvedMarket, error) {
+	rows, err := s.ReadQueries().GetResolvedMarketRows(ctx, GetResolvedMarketRowsParams{
+		FromTime: from,
+		ToTime:   to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]ResolvedMarket, 0, len(rows))
+	for _, row := range rows {
+		markets = append(markets, ResolvedMarket(row))
+	}
+	return markets, nil
+}
@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestSettlementValue(t *testing.T) {
+	m := store.ResolvedMarket{MarketID: "m", WinningTokenID: "yes"}
+
+	tests := []struct {
+		tokenID string
+		size    price.Size
+		want    int64
+	}{
+		{tokenID: "yes", size: 25_000_000, want: 25_000_000},
+		{tokenID: "no", size: 25_000_000, want: 0},
+		{tokenID: "yes", size: -3_500_000, want: -3_500_000},
+		{tokenID: "yes", size: 0, want: 0},
+	}
+	for _, tt := range tests {
+		if got := m.SettlementValue(tt.tokenID, tt.size); got != tt.want {
+			t.Errorf("SettlementValue(%s, %d) = %d, want %d", tt.tokenID, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestGetResolvedMarketsWithWinners(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+
+	resolve := func(tokenID string, winning bool) {
+		t.Helper()
+		settlement := int64(0)
+		if winning {
+			settlement = price.PriceScale
+		}
+		if err := s.SetTokenResolution(ctx, store.SetTokenResolutionParams{
+			ID:              tokenID,
+			Winning:         pgtype.Bool{Bool: winning, Valid: true},
+			SettlementPrice: pgtype.Int8{Int64: settlement, Valid: true},
+		}); err != nil {
+			t.Fatalf("set token resolution: %v", err)
+		}
+	}
+
+	from := time.Now().Add(-time.Minute)
+
+	yes, no := testID(t, "yes"), testID(t, "no")
+	resolved := seedMarket(t, s, platform, yes, no)
+	resolve(yes, true)
+	resolve(no, false)
+
+	seedMarket(t, s, platform, testID(t, "open-yes"), testID(t, "open-no"))
+
+	markets, err := s.GetResolvedMarketsWithWinners(ctx, from, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("get resolved markets: %v", err)
+	}
+
+	var got []store.ResolvedMarket
+	for _, m := range markets {
+		if m.Platform == platform {
+			got = append(got, m)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d resolved markets, want 1: %+v", len(got), got)
+	}
+	m := got[0]
+	if m.MarketID != resolved || m.WinningTokenID != yes {
+		t.Errorf("resolved market = %+v, want market %s won by %s", m, resolved, yes)
+	}
+	if m.ResolvedAt.Before(from) {
+		t.Errorf("resolved at %v, want after %v", m.ResolvedAt, from)
+	}
+	if v := m.SettlementValue(no, 10_000_000); v != 0 {
+		t.Errorf("losing position value = %d, want 0", v)
+	}
+}
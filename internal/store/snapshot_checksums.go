@@ -0,0 +1,36 @@
+package store
+
+import (
+	"cmp"
+	"crypto/md5"
+	"encoding/binary"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SnapshotChecksum returns the checksum of the rows of one token's snapshot:
+// the first 8 bytes of the MD5 of "side:level:price:size" of every row,
+// ordered by side and level and joined by commas. GetSnapshotChecksumMismatches
+// recomputes it in SQL, so the two must stay in sync.
+func SnapshotChecksum(rows []InsertOrderBookSnapshotBatchParams) int64 {
+	sorted := slices.SortedFunc(slices.Values(rows), func(a, b InsertOrderBookSnapshotBatchParams) int {
+		return cmp.Or(strings.Compare(a.Side, b.Side), cmp.Compare(a.Level, b.Level))
+	})
+
+	var b strings.Builder
+	for i, row := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(row.Side)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(int(row.Level)))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(row.Price, 10))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(row.Size, 10))
+	}
+	sum := md5.Sum([]byte(b.String()))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
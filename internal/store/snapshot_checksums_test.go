@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func checksumRows(tokenID string, t time.Time) []store.InsertOrderBookSnapshotBatchParams {
+	return []store.InsertOrderBookSnapshotBatchParams{
+		{Time: t, TokenID: tokenID, Side: "bid", Level: 0, Price: 500_000, Size: 10},
+		{Time: t, TokenID: tokenID, Side: "bid", Level: 1, Price: 490_000, Size: 20},
+		{Time: t, TokenID: tokenID, Side: "ask", Level: 0, Price: 510_000, Size: 30},
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+	rows := checksumRows("token", time.Now())
+	want := store.SnapshotChecksum(rows)
+
+	reversed := slices.Clone(rows)
+	slices.Reverse(reversed)
+	if got := store.SnapshotChecksum(reversed); got != want {
+		t.Errorf("checksum depends on row order: %d, want %d", got, want)
+	}
+
+	rows[1].Size++
+	if store.SnapshotChecksum(rows) == want {
+		t.Error("checksum didn't change with a level's size")
+	}
+}
+
+func TestGetSnapshotChecksumMismatches(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	intact, corrupted := testID(t, "intact"), testID(t, "corrupted")
+	seedMarket(t, s, "polymarket", intact, corrupted)
+
+	from := time.Now().Add(-time.Minute)
+	var rows []store.InsertOrderBookSnapshotBatchParams
+	for _, tokenID := range []string{intact, corrupted} {
+		tokenRows := checksumRows(tokenID, time.Now())
+		checksum := pgtype.Int8{Int64: store.SnapshotChecksum(tokenRows), Valid: true}
+		for i := range tokenRows {
+			tokenRows[i].Checksum = checksum
+		}
+		rows = append(rows, tokenRows...)
+	}
+	if _, err := s.InsertOrderBookSnapshotBatch(ctx, rows); err != nil {
+		t.Fatalf("insert snapshots: %v", err)
+	}
+
+	params := store.GetSnapshotChecksumMismatchesParams{FromTime: from, ToTime: time.Now().Add(time.Minute)}
+	mismatches, err := s.GetSnapshotChecksumMismatches(ctx, params)
+	if err != nil {
+		t.Fatalf("get mismatches: %v", err)
+	}
+	for _, m := range mismatches {
+		if m.TokenID == intact || m.TokenID == corrupted {
+			t.Errorf("freshly written snapshot of %s reported as mismatched", m.TokenID)
+		}
+	}
+
+	if _, err := s.Pool().Exec(ctx, "UPDATE order_book_snapshots SET size = size + 1 WHERE token_id = $1 AND side = 'ask'", corrupted); err != nil {
+		t.Fatalf("corrupt row: %v", err)
+	}
+	mismatches, err = s.GetSnapshotChecksumMismatches(ctx, params)
+	if err != nil {
+		t.Fatalf("get mismatches: %v", err)
+	}
+	var got []string
+	for _, m := range mismatches {
+		if m.TokenID == intact || m.TokenID == corrupted {
+			got = append(got, m.TokenID)
+		}
+	}
+	if len(got) != 1 || got[0] != corrupted {
+		t.Errorf("mismatched snapshots = %v, want only %s", got, corrupted)
+	}
+}
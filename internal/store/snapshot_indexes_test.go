@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+// explain returns the plan of query. Sequential scans are disabled so the
+// plan shows whether an index is usable even on the tiny test tables, where a
+// scan would be cheaper.
+func explain(t *testing.T, s *store.Store, query string, args ...any) string {
+	t.Helper()
+	ctx := context.Background()
+
+	tx, err := s.Pool().Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+		t.Fatalf("disable seqscan: %v", err)
+	}
+	rows, err := tx.Query(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatalf("scan plan: %v", err)
+		}
+		plan.WriteString(line + "\n")
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("read plan: %v", err)
+	}
+	return plan.String()
+}
+
+func TestSnapshotQueriesUseIndexes(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	tokenID := testID(t, "token")
+	seedMarket(t, s, "polymarket", tokenID)
+
+	now := time.Now()
+	if _, err := s.InsertOrderBookSnapshotBatch(ctx, []store.InsertOrderBookSnapshotBatchParams{
+		{Time: now, TokenID: tokenID, Side: "bid", Level: 0, Price: 500_000, Size: 1},
+		{Time: now, TokenID: tokenID, Side: "ask", Level: 0, Price: 510_000, Size: 1},
+	}); err != nil {
+		t.Fatalf("insert snapshots: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		index string
+	}{
+		{"latest", store.GetLatestOrderBookSnapshotQuery, []any{tokenID}, "idx_obs_token_time"},
+		{"last ingested", store.GetLastIngestedOrderBookSnapshotQuery, []any{tokenID}, "idx_obs_token_ingested"},
+		{"inside quotes", store.GetInsideQuoteRowsQuery, []any{tokenID, now.Add(-time.Hour), now.Add(time.Hour)}, "idx_obs_token_level_ingested"},
+		{"volatility", store.GetRealizedVolatilityRowQuery, []any{60.0, tokenID, now.Add(-time.Hour), now.Add(time.Hour)}, "idx_obs_token_level_ingested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plan := explain(t, s, tt.query, tt.args...); !strings.Contains(plan, tt.index) {
+				t.Errorf("plan doesn't use %s:\n%s", tt.index, plan)
+			}
+		})
+	}
+}
@@ -10,14 +10,20 @@ import (
 // Store wraps the generated Queries and provides transaction support.
 type Store struct {
 	*Queries
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	outcomes OutcomeNormalizer
+
+	// read and readPool are set by SetReadReplica.
+	read     *Queries
+	readPool *pgxpool.Pool
 }
 
 // NewStore creates a new Store with the given connection pool.
 func NewStore(pool *pgxpool.Pool) *Store {
 	return &Store{
-		Queries: New(pool),
-		pool:    pool,
+		Queries:  New(pool),
+		pool:     pool,
+		outcomes: NewOutcomeNormalizer(nil),
 	}
 }
 
@@ -26,9 +32,28 @@ func (s *Store) Pool() *pgxpool.Pool {
 	return s.pool
 }
 
-// Close closes the underlying connection pool.
+// SetReadReplica routes the Store's analytical reads (snapshot history,
+// volume) to a replica pool. Writes keep using the primary pool.
+func (s *Store) SetReadReplica(pool *pgxpool.Pool) {
+	s.read = New(pool)
+	s.readPool = pool
+}
+
+// ReadQueries returns Queries bound to the read replica, or to the primary
+// pool if no replica is set. Reads through it may lag behind writes.
+func (s *Store) ReadQueries() *Queries {
+	if s.read != nil {
+		return s.read
+	}
+	return s.Queries
+}
+
+// Close closes the underlying connection pools.
 func (s *Store) Close() {
 	s.pool.Close()
+	if s.readPool != nil {
+		s.readPool.Close()
+	}
 }
 
 // WithTx executes fn within a transaction.
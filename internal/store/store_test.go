@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+// testID returns an ID unique to this test run.
+func testID(t *testing.T, name string) string {
+	return fmt.Sprintf("test-%s-%s-%d", t.Name(), name, time.Now().UnixNano())
+}
+
+// seedMarket inserts a market with the given tokens.
+func seedMarket(t *testing.T, s *store.Store, platform string, tokenIDs ...string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	marketID := testID(t, "market")
+	if err := s.UpsertMarket(ctx, store.UpsertMarketParams{
+		ID:          marketID,
+		Platform:    platform,
+		Description: "test market",
+	}); err != nil {
+		t.Fatalf("upsert market: %v", err)
+	}
+	for i, tokenID := range tokenIDs {
+		if err := s.UpsertToken(ctx, store.UpsertTokenParams{
+			ID:       tokenID,
+			MarketID: marketID,
+			Outcome:  fmt.Sprintf("outcome-%d", i),
+		}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+
+	return marketID
+}
+
+// insertSnapshotAt writes the rows of one snapshot as ingested at
+// ingestedAt, which the batch insert leaves to the database.
+func insertSnapshotAt(t *testing.T, s *store.Store, ingestedAt time.Time, rows ...store.InsertOrderBookSnapshotBatchParams) {
+	t.Helper()
+	columns := []string{"time", "token_id", "side", "level", "price", "size", "ingested_at"}
+	_, err := s.Pool().CopyFrom(context.Background(), pgx.Identifier{"order_book_snapshots"}, columns,
+		pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+			r := rows[i]
+			return []any{r.Time, r.TokenID, r.Side, r.Level, r.Price, r.Size, ingestedAt}, nil
+		}))
+	if err != nil {
+		t.Fatalf("insert snapshot: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package storetest
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+)
+
+func TestNewTestStore(t *testing.T) {
+	s := NewTestStore(t)
+	ctx := context.Background()
+
+	if err := s.UpsertMarket(ctx, store.UpsertMarketParams{
+		ID:          "market-1",
+		Platform:    "polymarket",
+		Description: "test market",
+	}); err != nil {
+		t.Fatalf("upsert market: %v", err)
+	}
+	for _, id := range []string{"token-yes", "token-no"} {
+		if err := s.UpsertToken(ctx, store.UpsertTokenParams{ID: id, MarketID: "market-1", Outcome: id}); err != nil {
+			t.Fatalf("upsert token: %v", err)
+		}
+	}
+
+	// The database is private to the test, so these are the only tokens.
+	var got []string
+	if err := s.StreamTokenIDsForPlatform(ctx, "polymarket", func(id string) error {
+		got = append(got, id)
+		return nil
+	}); err != nil {
+		t.Fatalf("stream token IDs: %v", err)
+	}
+	slices.Sort(got)
+	if want := []string{"token-no", "token-yes"}; !slices.Equal(got, want) {
+		t.Errorf("token IDs = %v, want %v", got, want)
+	}
+}
+
+func TestNewTestStoreIsolated(t *testing.T) {
+	a, b := NewTestStore(t), NewTestStore(t)
+	ctx := context.Background()
+
+	if err := a.UpsertMarket(ctx, store.UpsertMarketParams{
+		ID:          "market-1",
+		Platform:    "polymarket",
+		Description: "test market",
+	}); err != nil {
+		t.Fatalf("upsert market: %v", err)
+	}
+
+	var n int
+	if err := b.Pool().QueryRow(ctx, "SELECT count(*) FROM markets").Scan(&n); err != nil {
+		t.Fatalf("count markets: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("second store has %d markets, want 0", n)
+	}
+}
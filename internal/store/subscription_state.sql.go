@@ -0,0 +1,65 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: subscription_state.sql
+
+package store
+
+import (
+	"context"
+)
+
+const deleteSubscriptionsNotIn = `-- name: DeleteSubscriptionsNotIn :exec
+DELETE FROM subscription_state
+WHERE platform = $1 AND NOT (token_id = ANY($2::TEXT[]))
+`
+
+type DeleteSubscriptionsNotInParams struct {
+	Platform string   `json:"platform"`
+	TokenIds []string `json:"token_ids"`
+}
+
+func (q *Queries) DeleteSubscriptionsNotIn(ctx context.Context, arg DeleteSubscriptionsNotInParams) error {
+	_, err := q.db.Exec(ctx, deleteSubscriptionsNotIn, arg.Platform, arg.TokenIds)
+	return err
+}
+
+const getSubscriptionState = `-- name: GetSubscriptionState :many
+SELECT platform, token_id, subscribed_at FROM subscription_state WHERE platform = $1 ORDER BY token_id
+`
+
+func (q *Queries) GetSubscriptionState(ctx context.Context, platform string) ([]SubscriptionState, error) {
+	rows, err := q.db.Query(ctx, getSubscriptionState, platform)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SubscriptionState
+	for rows.Next() {
+		var i SubscriptionState
+		if err := rows.Scan(&i.Platform, &i.TokenID, &i.SubscribedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertSubscriptions = `-- name: InsertSubscriptions :exec
+INSERT INTO subscription_state (platform, token_id)
+SELECT $1, unnest($2::TEXT[])
+ON CONFLICT (platform, token_id) DO NOTHING
+`
+
+type InsertSubscriptionsParams struct {
+	Platform string   `json:"platform"`
+	TokenIds []string `json:"token_ids"`
+}
+
+func (q *Queries) InsertSubscriptions(ctx context.Context, arg InsertSubscriptionsParams) error {
+	_, err := q.db.Exec(ctx, insertSubscriptions, arg.Platform, arg.TokenIds)
+	return err
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// SaveSubscriptions replaces the platform's saved subscription set with
+// tokenIDs. Tokens that stay subscribed keep their subscription time.
+func (s *Store) SaveSubscriptions(ctx context.Context, platform string, tokenIDs []string) error {
+	return s.WithTx(ctx, func(q *Queries) error {
+		if err := q.DeleteSubscriptionsNotIn(ctx, DeleteSubscriptionsNotInParams{
+			Platform: platform,
+			TokenIds: tokenIDs,
+		}); err != nil {
+			return fmt.Errorf("delete stale subscriptions: %w", err)
+		}
+		if err := q.InsertSubscriptions(ctx, InsertSubscriptionsParams{
+			Platform: platform,
+			TokenIds: tokenIDs,
+		}); err != nil {
+			return fmt.Errorf("insert subscriptions: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestSaveAndRestoreSubscriptions(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	platform := testID(t, "platform")
+	a, b, c := testID(t, "a"), testID(t, "b"), testID(t, "c")
+	seedMarket(t, s, platform, a, b, c)
+
+	if err := s.SaveSubscriptions(ctx, platform, []string{a, b}); err != nil {
+		t.Fatalf("save subscriptions: %v", err)
+	}
+	first, err := s.GetSubscriptionState(ctx, platform)
+	if err != nil {
+		t.Fatalf("get subscription state: %v", err)
+	}
+
+	// b stays subscribed, a is dropped and c is added.
+	if err := s.SaveSubscriptions(ctx, platform, []string{b, c}); err != nil {
+		t.Fatalf("save subscriptions: %v", err)
+	}
+
+	state, err := s.GetSubscriptionState(ctx, platform)
+	if err != nil {
+		t.Fatalf("get subscription state: %v", err)
+	}
+	got := make([]string, 0, len(state))
+	for _, st := range state {
+		got = append(got, st.TokenID)
+	}
+	want := []string{b, c}
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("restored %v, want %v", got, want)
+	}
+
+	subscribedAt := first[slices.IndexFunc(first, func(st store.SubscriptionState) bool { return st.TokenID == b })].SubscribedAt
+	for _, st := range state {
+		if st.TokenID == b && !st.SubscribedAt.Equal(subscribedAt) {
+			t.Errorf("subscribed at = %v, want %v kept", st.SubscribedAt, subscribedAt)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import "context"
+
+// tokenIDPageSize is how many token IDs StreamTokenIDsForPlatform reads per
+// query.
+const tokenIDPageSize = 1000
+
+// StreamTokenIDsForPlatform calls fn with every token ID of a platform in ID
+// order. IDs are read a page at a time, so the whole list is never held in
+// memory. It stops at the first error fn returns and returns it.
+func (s *Store) StreamTokenIDsForPlatform(ctx context.Context, platform string, fn func(id string) error) error {
+	return s.streamTokenIDs(ctx, platform, tokenIDPageSize, fn)
+}
+
+func (s *Store) streamTokenIDs(ctx context.Context, platform string, pageSize int, fn func(id string) error) error {
+	afterID := ""
+	for {
+		ids, err := s.GetTokenIDsForPlatformPage(ctx, GetTokenIDsForPlatformPageParams{
+			Platform: platform,
+			AfterID:  afterID,
+			PageSize: int32(pageSize),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := fn(id); err != nil {
+				return err
+			}
+		}
+		if len(ids) < pageSize {
+			return nil
+		}
+		afterID = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,56 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestStreamTokenIDsForPlatform(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+	platform := testID(t, "platform")
+
+	var want []string
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		id := testID(t, name)
+		seedMarket(t, s, platform, id)
+		want = append(want, id)
+	}
+	seedMarket(t, s, testID(t, "other-platform"), testID(t, "other"))
+	slices.Sort(want)
+
+	// A page size that doesn't divide the token count covers a partial last page.
+	var got []string
+	if err := s.StreamTokenIDs(ctx, platform, 2, func(id string) error {
+		got = append(got, id)
+		return nil
+	}); err != nil {
+		t.Fatalf("stream token IDs: %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("visited %v, want %v", got, want)
+	}
+
+	all, err := s.GetTokenIDsForPlatform(ctx, platform)
+	if err != nil {
+		t.Fatalf("get token IDs: %v", err)
+	}
+	slices.Sort(all)
+	if !slices.Equal(all, want) {
+		t.Errorf("GetTokenIDsForPlatform = %v, want %v", all, want)
+	}
+
+	errStop := errors.New("stop")
+	visited := 0
+	err = s.StreamTokenIDsForPlatform(ctx, platform, func(string) error {
+		visited++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || visited != 1 {
+		t.Errorf("stream with failing fn: err = %v after %d IDs, want %v after 1", err, visited, errStop)
+	}
+}
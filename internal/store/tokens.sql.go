@@ -7,6 +7,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -20,8 +21,58 @@ func (q *Queries) DeleteToken(ctx context.Context, id string) error {
 	return err
 }
 
+const getResolvedMarketRows = `-- name: GetResolvedMarketRows :many
+SELECT m.id AS market_id, m.platform, t.id AS winning_token_id, t.outcome AS winning_outcome,
+    t.resolved_at::timestamptz AS resolved_at
+FROM tokens t
+JOIN markets m ON m.id = t.market_id
+WHERE t.winning
+  AND t.resolved_at >= $1::timestamptz AND t.resolved_at < $2::timestamptz
+ORDER BY t.resolved_at, m.id
+`
+
+type GetResolvedMarketRowsParams struct {
+	FromTime time.Time `json:"from_time"`
+	ToTime   time.Time `json:"to_time"`
+}
+
+type GetResolvedMarketRowsRow struct {
+	MarketID       string    `json:"market_id"`
+	Platform       string    `json:"platform"`
+	WinningTokenID string    `json:"winning_token_id"`
+	WinningOutcome string    `json:"winning_outcome"`
+	ResolvedAt     time.Time `json:"resolved_at"`
+}
+
+// Use Store.GetResolvedMarketsWithWinners.
+func (q *Queries) GetResolvedMarketRows(ctx context.Context, arg GetResolvedMarketRowsParams) ([]GetResolvedMarketRowsRow, error) {
+	rows, err := q.db.Query(ctx, getResolvedMarketRows, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetResolvedMarketRowsRow
+	for rows.Next() {
+		var i GetResolvedMarketRowsRow
+		if err := rows.Scan(
+			&i.MarketID,
+			&i.Platform,
+			&i.WinningTokenID,
+			&i.WinningOutcome,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getToken = `-- name: GetToken :one
-SELECT id, market_id, outcome, winning, settlement_price, created_at FROM tokens WHERE id = $1
+SELECT id, market_id, outcome, winning, settlement_price, created_at, outcome_raw, resolved_at FROM tokens WHERE id = $1
 `
 
 func (q *Queries) GetToken(ctx context.Context, id string) (Token, error) {
@@ -34,6 +85,8 @@ func (q *Queries) GetToken(ctx context.Context, id string) (Token, error) {
 		&i.Winning,
 		&i.SettlementPrice,
 		&i.CreatedAt,
+		&i.OutcomeRaw,
+		&i.ResolvedAt,
 	)
 	return i, err
 }
@@ -64,8 +117,79 @@ func (q *Queries) GetTokenIDsForPlatform(ctx context.Context, platform string) (
 	return items, nil
 }
 
+const getTokenIDsForPlatformPage = `-- name: GetTokenIDsForPlatformPage :many
+SELECT t.id FROM tokens t
+JOIN markets m ON t.market_id = m.id
+WHERE m.platform = $1::text AND t.id > $2::text
+ORDER BY t.id
+LIMIT $3::int
+`
+
+type GetTokenIDsForPlatformPageParams struct {
+	Platform string `json:"platform"`
+	AfterID  string `json:"after_id"`
+	PageSize int32  `json:"page_size"`
+}
+
+// Use Store.StreamTokenIDsForPlatform.
+func (q *Queries) GetTokenIDsForPlatformPage(ctx context.Context, arg GetTokenIDsForPlatformPageParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, getTokenIDsForPlatformPage, arg.Platform, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTokenIDsForRecentMarkets = `-- name: GetTokenIDsForRecentMarkets :many
+SELECT t.id FROM tokens t
+JOIN (
+    SELECT id FROM markets
+    WHERE platform = $1::text
+    ORDER BY updated_at DESC, id
+    LIMIT $2::int
+) m ON t.market_id = m.id
+`
+
+type GetTokenIDsForRecentMarketsParams struct {
+	Platform   string `json:"platform"`
+	MaxMarkets int32  `json:"max_markets"`
+}
+
+// Tokens of the platform's max_markets most recently synced markets.
+func (q *Queries) GetTokenIDsForRecentMarkets(ctx context.Context, arg GetTokenIDsForRecentMarketsParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, getTokenIDsForRecentMarkets, arg.Platform, arg.MaxMarkets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTokensByMarket = `-- name: GetTokensByMarket :many
-SELECT id, market_id, outcome, winning, settlement_price, created_at FROM tokens WHERE market_id = $1 ORDER BY outcome
+SELECT id, market_id, outcome, winning, settlement_price, created_at, outcome_raw, resolved_at FROM tokens WHERE market_id = $1 ORDER BY outcome
 `
 
 func (q *Queries) GetTokensByMarket(ctx context.Context, marketID string) ([]Token, error) {
@@ -84,6 +208,8 @@ func (q *Queries) GetTokensByMarket(ctx context.Context, marketID string) ([]Tok
 			&i.Winning,
 			&i.SettlementPrice,
 			&i.CreatedAt,
+			&i.OutcomeRaw,
+			&i.ResolvedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -96,7 +222,11 @@ func (q *Queries) GetTokensByMarket(ctx context.Context, marketID string) ([]Tok
 }
 
 const setTokenResolution = `-- name: SetTokenResolution :exec
-UPDATE tokens SET winning = $2, settlement_price = $3 WHERE id = $1
+UPDATE tokens SET
+    winning = $2,
+    settlement_price = $3,
+    resolved_at = CASE WHEN $2::boolean IS NULL THEN NULL ELSE COALESCE(resolved_at, NOW()) END
+WHERE id = $1
 `
 
 type SetTokenResolutionParams struct {
@@ -110,28 +240,32 @@ func (q *Queries) SetTokenResolution(ctx context.Context, arg SetTokenResolution
 	return err
 }
 
-const upsertToken = `-- name: UpsertToken :exec
-INSERT INTO tokens (id, market_id, outcome, winning, settlement_price, created_at)
-VALUES ($1, $2, $3, $4, $5, NOW())
+const upsertTokenRow = `-- name: UpsertTokenRow :exec
+INSERT INTO tokens (id, market_id, outcome, outcome_raw, winning, settlement_price, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
 ON CONFLICT (id) DO UPDATE SET
     outcome = EXCLUDED.outcome,
+    outcome_raw = EXCLUDED.outcome_raw,
     winning = EXCLUDED.winning,
     settlement_price = EXCLUDED.settlement_price
 `
 
-type UpsertTokenParams struct {
+type UpsertTokenRowParams struct {
 	ID              string      `json:"id"`
 	MarketID        string      `json:"market_id"`
 	Outcome         string      `json:"outcome"`
+	OutcomeRaw      pgtype.Text `json:"outcome_raw"`
 	Winning         pgtype.Bool `json:"winning"`
 	SettlementPrice pgtype.Int8 `json:"settlement_price"`
 }
 
-func (q *Queries) UpsertToken(ctx context.Context, arg UpsertTokenParams) error {
-	_, err := q.db.Exec(ctx, upsertToken,
+// Use Store.UpsertToken, which canonicalizes the outcome label.
+func (q *Queries) UpsertTokenRow(ctx context.Context, arg UpsertTokenRowParams) error {
+	_, err := q.db.Exec(ctx, upsertTokenRow,
 		arg.ID,
 		arg.MarketID,
 		arg.Outcome,
+		arg.OutcomeRaw,
 		arg.Winning,
 		arg.SettlementPrice,
 	)
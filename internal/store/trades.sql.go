@@ -12,8 +12,58 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const getTopMarketVolumeRows = `-- name: GetTopMarketVolumeRows :many
+SELECT m.id AS market_id, m.platform, COALESCE(m.question, m.description)::text AS question,
+    SUM(tr.size)::BIGINT AS volume
+FROM trades tr
+JOIN tokens t ON tr.token_id = t.id
+JOIN markets m ON t.market_id = m.id
+WHERE tr.time >= $1::timestamptz
+GROUP BY m.id
+ORDER BY volume DESC, m.id
+LIMIT $2::int
+`
+
+type GetTopMarketVolumeRowsParams struct {
+	Since      time.Time `json:"since"`
+	MaxMarkets int32     `json:"max_markets"`
+}
+
+type GetTopMarketVolumeRowsRow struct {
+	MarketID string `json:"market_id"`
+	Platform string `json:"platform"`
+	Question string `json:"question"`
+	Volume   int64  `json:"volume"`
+}
+
+// Use Store.GetTopMarketsByVolume.
+func (q *Queries) GetTopMarketVolumeRows(ctx context.Context, arg GetTopMarketVolumeRowsParams) ([]GetTopMarketVolumeRowsRow, error) {
+	rows, err := q.db.Query(ctx, getTopMarketVolumeRows, arg.Since, arg.MaxMarkets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTopMarketVolumeRowsRow
+	for rows.Next() {
+		var i GetTopMarketVolumeRowsRow
+		if err := rows.Scan(
+			&i.MarketID,
+			&i.Platform,
+			&i.Question,
+			&i.Volume,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTradeByID = `-- name: GetTradeByID :one
-SELECT time, token_id, trade_id, price, size, side, maker, taker FROM trades WHERE trade_id = $1
+SELECT time, token_id, trade_id, price, size, side, maker, taker, ingested_at FROM trades WHERE trade_id = $1
 `
 
 func (q *Queries) GetTradeByID(ctx context.Context, tradeID pgtype.Text) (Trade, error) {
@@ -28,12 +78,13 @@ func (q *Queries) GetTradeByID(ctx context.Context, tradeID pgtype.Text) (Trade,
 		&i.Side,
 		&i.Maker,
 		&i.Taker,
+		&i.IngestedAt,
 	)
 	return i, err
 }
 
 const getTradesByToken = `-- name: GetTradesByToken :many
-SELECT time, token_id, trade_id, price, size, side, maker, taker FROM trades
+SELECT time, token_id, trade_id, price, size, side, maker, taker, ingested_at FROM trades
 WHERE token_id = $1
 ORDER BY time DESC
 LIMIT $2
@@ -62,6 +113,7 @@ func (q *Queries) GetTradesByToken(ctx context.Context, arg GetTradesByTokenPara
 			&i.Side,
 			&i.Maker,
 			&i.Taker,
+			&i.IngestedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -74,7 +126,7 @@ func (q *Queries) GetTradesByToken(ctx context.Context, arg GetTradesByTokenPara
 }
 
 const getTradesRange = `-- name: GetTradesRange :many
-SELECT time, token_id, trade_id, price, size, side, maker, taker FROM trades
+SELECT time, token_id, trade_id, price, size, side, maker, taker, ingested_at FROM trades
 WHERE token_id = $1 AND time >= $2 AND time <= $3
 ORDER BY time DESC
 `
@@ -103,6 +155,7 @@ func (q *Queries) GetTradesRange(ctx context.Context, arg GetTradesRangeParams)
 			&i.Side,
 			&i.Maker,
 			&i.Taker,
+			&i.IngestedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -154,3 +207,40 @@ type InsertTradeBatchParams struct {
 	Maker   pgtype.Text `json:"maker"`
 	Taker   pgtype.Text `json:"taker"`
 }
+
+const sumMarketTradeSize = `-- name: SumMarketTradeSize :one
+SELECT COALESCE(SUM(tr.size), 0)::BIGINT AS volume FROM trades tr
+JOIN tokens t ON tr.token_id = t.id
+WHERE t.market_id = $1 AND tr.time >= $2 AND tr.time < $3
+`
+
+type SumMarketTradeSizeParams struct {
+	MarketID string    `json:"market_id"`
+	Time     time.Time `json:"time"`
+	Time_2   time.Time `json:"time_2"`
+}
+
+func (q *Queries) SumMarketTradeSize(ctx context.Context, arg SumMarketTradeSizeParams) (int64, error) {
+	row := q.db.QueryRow(ctx, sumMarketTradeSize, arg.MarketID, arg.Time, arg.Time_2)
+	var volume int64
+	err := row.Scan(&volume)
+	return volume, err
+}
+
+const sumTokenTradeSize = `-- name: SumTokenTradeSize :one
+SELECT COALESCE(SUM(size), 0)::BIGINT AS volume FROM trades
+WHERE token_id = $1 AND time >= $2 AND time < $3
+`
+
+type SumTokenTradeSizeParams struct {
+	TokenID string    `json:"token_id"`
+	Time    time.Time `json:"time"`
+	Time_2  time.Time `json:"time_2"`
+}
+
+func (q *Queries) SumTokenTradeSize(ctx context.Context, arg SumTokenTradeSizeParams) (int64, error) {
+	row := q.db.QueryRow(ctx, sumTokenTradeSize, arg.TokenID, arg.Time, arg.Time_2)
+	var volume int64
+	err := row.Scan(&volume)
+	return volume, err
+}
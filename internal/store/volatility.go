@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// year is the period Annualized scales volatility to. Prediction markets
+// trade around the clock, so it is a calendar year.
+const year = 365 * 24 * time.Hour
+
+// RealizedVolatility is the standard deviation of a token's mid price log
+// returns between consecutive buckets.
+type RealizedVolatility struct {
+	PerBucket float64
+	Bucket    time.Duration
+	// Returns is the number of log returns the deviation is computed from.
+	// With fewer than 2 it is 0 and says nothing.
+	Returns int
+}
+
+// Annualized scales the per-bucket volatility to a year.
+func (v RealizedVolatility) Annualized() float64 {
+	if v.Bucket <= 0 {
+		return 0
+	}
+	return v.PerBucket * math.Sqrt(float64(year)/float64(v.Bucket))
+}
+
+// GetRealizedVolatility computes the realized volatility of a token's mid
+// price from its level 0 snapshots written in [from, to), bucketed by when
+// they were written. The mid of a bucket is the last one in it, and snapshots without both sides are skipped. Returns are
+// only taken between adjacent buckets, so a bucket without snapshots drops
+// the returns into and out of it instead of stretching one across the gap.
+func (s *Store) GetRealizedVolatility(ctx context.Context, tokenID string, from, to time.Time, bucket time.Duration) (RealizedVolatility, error) {
+	if bucket <= 0 {
+		return RealizedVolatility{}, errors.New("bucket must be positive")
+	}
+
+	row, err := s.ReadQueries().GetRealizedVolatilityRow(ctx, GetRealizedVolatilityRowParams{
+		BucketSeconds: bucket.Seconds(),
+		TokenID:       tokenID,
+		FromTime:      from,
+		ToTime:        to,
+	})
+	if err != nil {
+		return RealizedVolatility{}, err
+	}
+	return RealizedVolatility{
+		PerBucket: row.Volatility,
+		Bucket:    bucket,
+		Returns:   int(row.Returns),
+	}, nil
+}
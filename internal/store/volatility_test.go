@@ -0,0 +1,69 @@
+package store_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestGetRealizedVolatility(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	tokenID := testID(t, "token")
+	seedMarket(t, s, "polymarket", tokenID)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// quote writes a snapshot at the given time whose sides, like with event
+	// times, were last updated at different times before it.
+	quote := func(at time.Time, mid int64) {
+		insertSnapshotAt(t, s, at,
+			store.InsertOrderBookSnapshotBatchParams{Time: at.Add(-5 * time.Second), TokenID: tokenID, Side: "bid", Level: 0, Price: mid - 10_000, Size: 1},
+			store.InsertOrderBookSnapshotBatchParams{Time: at.Add(-2 * time.Second), TokenID: tokenID, Side: "ask", Level: 0, Price: mid + 10_000, Size: 1},
+		)
+	}
+	// Mids alternate between 0.50 and 0.55 in buckets 0 to 4, so the log
+	// returns alternate between +ln(1.1) and -ln(1.1).
+	for i, mid := range []int64{500_000, 550_000, 500_000, 550_000, 500_000} {
+		bucketStart := from.Add(time.Duration(i) * time.Minute)
+		// Only the last mid of a bucket counts.
+		quote(bucketStart.Add(10*time.Second), 900_000)
+		quote(bucketStart.Add(30*time.Second), mid)
+	}
+	// A snapshot without asks is skipped.
+	insertSnapshotAt(t, s, from.Add(4*time.Minute+40*time.Second),
+		store.InsertOrderBookSnapshotBatchParams{Time: from.Add(4*time.Minute + 40*time.Second), TokenID: tokenID, Side: "bid", Level: 0, Price: 100_000, Size: 1},
+	)
+	// Bucket 5 is empty, so the jump into bucket 6 doesn't count.
+	quote(from.Add(6*time.Minute), 900_000)
+
+	got, err := s.GetRealizedVolatility(ctx, tokenID, from, from.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatalf("GetRealizedVolatility: %v", err)
+	}
+	// Four returns of ±a with mean 0 have a sample deviation of a·√(4/3).
+	want := math.Log(1.1) * math.Sqrt(4.0/3)
+	if got.Returns != 4 || math.Abs(got.PerBucket-want) > 1e-9 {
+		t.Errorf("volatility = %+v, want %v over 4 returns", got, want)
+	}
+
+	// A single bucket has no returns.
+	got, err = s.GetRealizedVolatility(ctx, tokenID, from, from.Add(time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("GetRealizedVolatility of one bucket: %v", err)
+	}
+	if got.Returns != 0 || got.PerBucket != 0 {
+		t.Errorf("volatility of one bucket = %+v, want none", got)
+	}
+}
+
+func TestRealizedVolatilityAnnualized(t *testing.T) {
+	v := store.RealizedVolatility{PerBucket: 0.01, Bucket: 24 * time.Hour, Returns: 30}
+	if got, want := v.Annualized(), 0.01*math.Sqrt(365); math.Abs(got-want) > 1e-12 {
+		t.Errorf("Annualized() = %v, want %v", got, want)
+	}
+}
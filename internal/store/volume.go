@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+// GetVolume returns the total traded size of a token in [from, to).
+func (s *Store) GetVolume(ctx context.Context, tokenID string, from, to time.Time) (price.Size, error) {
+	volume, err := s.ReadQueries().SumTokenTradeSize(ctx, SumTokenTradeSizeParams{
+		TokenID: tokenID,
+		Time:    from,
+		Time_2:  to,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return price.Size(volume), nil
+}
+
+// GetMarketVolume returns the total traded size across all tokens of a market
+// in [from, to).
+func (s *Store) GetMarketVolume(ctx context.Context, marketID string, from, to time.Time) (price.Size, error) {
+	volume, err := s.ReadQueries().SumMarketTradeSize(ctx, SumMarketTradeSizeParams{
+		MarketID: marketID,
+		Time:     from,
+		Time_2:   to,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return price.Size(volume), nil
+}
+
+// MarketVolume is a market's total traded size over a window.
+type MarketVolume struct {
+	MarketID string
+	Platform string
+	Question string // The description for markets without a question.
+	Volume   price.Size
+}
+
+// GetTopMarketsByVolume returns up to limit markets of any platform with the
+// most traded size over the last window, highest first.
+func (s *Store) GetTopMarketsByVolume(ctx context.Context, window time.Duration, limit int) ([]MarketVolume, error) {
+	rows, err := s.ReadQueries().GetTopMarketVolumeRows(ctx, GetTopMarketVolumeRowsParams{
+		Since:      time.Now().Add(-window),
+		MaxMarkets: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	markets := make([]MarketVolume, 0, len(rows))
+	for _, row := range rows {
+		markets = append(markets, MarketVolume{
+			MarketID: row.MarketID,
+			Platform: row.Platform,
+			Question: row.Question,
+			Volume:   price.Size(row.Volume),
+		})
+	}
+	return markets, nil
+}
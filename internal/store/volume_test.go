@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/store"
+	"github.com/daszybak/prediction_markets/internal/store/storetest"
+)
+
+func TestGetVolume(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	yes, no := testID(t, "yes"), testID(t, "no")
+	marketID := seedMarket(t, s, "polymarket", yes, no)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	trades := []store.InsertTradeBatchParams{
+		{Time: from, TokenID: yes, Price: 500_000, Size: 10_000_000, Side: "buy"},
+		{Time: from.Add(30 * time.Minute), TokenID: yes, Price: 510_000, Size: 5_000_000, Side: "sell"},
+		{Time: from.Add(10 * time.Minute), TokenID: no, Price: 490_000, Size: 2_000_000, Side: "buy"},
+		// Outside the window.
+		{Time: from.Add(-time.Minute), TokenID: yes, Price: 500_000, Size: 99_000_000, Side: "buy"},
+		{Time: to, TokenID: no, Price: 500_000, Size: 99_000_000, Side: "buy"},
+	}
+	if _, err := s.InsertTradeBatch(ctx, trades); err != nil {
+		t.Fatalf("insert trades: %v", err)
+	}
+
+	got, err := s.GetVolume(ctx, yes, from, to)
+	if err != nil {
+		t.Fatalf("GetVolume: %v", err)
+	}
+	if got != 15_000_000 {
+		t.Errorf("token volume = %d, want 15000000", got)
+	}
+
+	got, err = s.GetMarketVolume(ctx, marketID, from, to)
+	if err != nil {
+		t.Fatalf("GetMarketVolume: %v", err)
+	}
+	if got != 17_000_000 {
+		t.Errorf("market volume = %d, want 17000000", got)
+	}
+
+	got, err = s.GetVolume(ctx, "no-such-token", from, to)
+	if err != nil {
+		t.Fatalf("GetVolume: %v", err)
+	}
+	if got != 0 {
+		t.Errorf("unknown token volume = %d, want 0", got)
+	}
+}
+
+func TestGetTopMarketsByVolume(t *testing.T) {
+	s := storetest.NewTestStore(t)
+	ctx := context.Background()
+
+	small, medium, large := testID(t, "small"), testID(t, "medium"), testID(t, "large")
+	smallMarket := seedMarket(t, s, "polymarket", small)
+	mediumMarket := seedMarket(t, s, "kalshi", medium)
+	largeYes, largeNo := large+"-yes", large+"-no"
+	largeMarket := seedMarket(t, s, "polymarket", largeYes, largeNo)
+
+	// Sizes dwarf anything other tests leave behind, so these markets lead.
+	const lot = 1_000_000_000_000_000
+	now := time.Now()
+	trades := []store.InsertTradeBatchParams{
+		{Time: now.Add(-time.Hour), TokenID: small, Price: 500_000, Size: 1 * lot, Side: "buy"},
+		{Time: now.Add(-2 * time.Hour), TokenID: medium, Price: 500_000, Size: 3 * lot, Side: "buy"},
+		// Both tokens of a market count towards it.
+		{Time: now.Add(-time.Hour), TokenID: largeYes, Price: 500_000, Size: 2 * lot, Side: "buy"},
+		{Time: now.Add(-time.Minute), TokenID: largeNo, Price: 500_000, Size: 2 * lot, Side: "sell"},
+		// Outside the window.
+		{Time: now.Add(-48 * time.Hour), TokenID: small, Price: 500_000, Size: 10 * lot, Side: "buy"},
+	}
+	if _, err := s.InsertTradeBatch(ctx, trades); err != nil {
+		t.Fatalf("insert trades: %v", err)
+	}
+
+	got, err := s.GetTopMarketsByVolume(ctx, 24*time.Hour, 3)
+	if err != nil {
+		t.Fatalf("GetTopMarketsByVolume: %v", err)
+	}
+	want := []store.MarketVolume{
+		{MarketID: largeMarket, Platform: "polymarket", Question: "test market", Volume: 4 * lot},
+		{MarketID: mediumMarket, Platform: "kalshi", Question: "test market", Volume: 3 * lot},
+		{MarketID: smallMarket, Platform: "polymarket", Question: "test market", Volume: 1 * lot},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+// Package backoff computes exponential retry delays.
+package backoff
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/daszybak/prediction_markets/pkg/ctxutil"
+)
+
+const defaultMultiplier = 2
+
+// Backoff produces delays that start at Initial and grow by Multiplier after
+// every call to Next, up to Max. Jitter is the fraction of each delay, between
+// 0 and 1, that is randomly taken off so that clients retrying together spread
+// out. Delays are never longer than Max.
+//
+// A Backoff is not safe for concurrent use.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64 // Defaults to 2 if <= 1.
+	Jitter     float64
+
+	current time.Duration
+}
+
+// Next returns the delay to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+	} else {
+		multiplier := b.Multiplier
+		if multiplier <= 1 {
+			multiplier = defaultMultiplier
+		}
+		b.current = time.Duration(float64(b.current) * multiplier)
+	}
+	if b.Max > 0 && (b.current > b.Max || b.current <= 0) {
+		b.current = b.Max
+	}
+
+	delay := b.current
+	if jitter := time.Duration(float64(delay) * min(max(b.Jitter, 0), 1)); jitter > 0 {
+		delay -= rand.N(jitter + 1)
+	}
+	return delay
+}
+
+// Reset starts the delays over from Initial.
+func (b *Backoff) Reset() {
+	b.current = 0
+}
+
+// Retry calls fn until it succeeds or ctx is cancelled, waiting b.Next()
+// between attempts. b is reset before the first attempt.
+func Retry(ctx context.Context, fn func(ctx context.Context) error, b *Backoff) error {
+	b.Reset()
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if sleepErr := ctxutil.Sleep(ctx, b.Next()); sleepErr != nil {
+			return fmt.Errorf("%w: last error: %w", sleepErr, err)
+		}
+	}
+}
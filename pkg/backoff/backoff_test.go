@@ -0,0 +1,108 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextGrowsAndCapsAtMax(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 3}
+
+	want := []time.Duration{
+		time.Second,
+		3 * time.Second,
+		9 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+	for i, w := range want {
+		if got := b.Next(); got != w {
+			t.Errorf("attempt %d: Next() = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestNextDefaultsMultiplier(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: time.Minute}
+	b.Next()
+	if got := b.Next(); got != 2*time.Second {
+		t.Errorf("Next() = %v, want %v", got, 2*time.Second)
+	}
+}
+
+func TestNextJitterBounds(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: 8 * time.Second, Jitter: 0.5}
+
+	for i := range 1000 {
+		if i%5 == 0 {
+			b.Reset()
+		}
+		base := b.current * 2
+		if b.current == 0 {
+			base = b.Initial
+		}
+		base = min(base, b.Max)
+
+		got := b.Next()
+		if got < base/2 || got > base {
+			t.Fatalf("Next() = %v, want within [%v, %v]", got, base/2, base)
+		}
+	}
+}
+
+func TestNextWithJitterNeverExceedsMax(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: 4 * time.Second, Jitter: 1}
+	for range 100 {
+		if got := b.Next(); got > b.Max {
+			t.Fatalf("Next() = %v, exceeds max %v", got, b.Max)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: time.Minute}
+	b.Next()
+	b.Next()
+	b.Reset()
+	if got := b.Next(); got != time.Second {
+		t.Errorf("Next() after Reset = %v, want %v", got, time.Second)
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	b := &Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+	calls := 0
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}, b)
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Backoff{Initial: time.Hour, Max: time.Hour}
+	errFailed := errors.New("failed")
+
+	err := Retry(ctx, func(context.Context) error {
+		cancel()
+		return errFailed
+	}, b)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Retry() error = %v, want %v", err, context.Canceled)
+	}
+	if !errors.Is(err, errFailed) {
+		t.Errorf("Retry() error = %v, want it to wrap the last error", err)
+	}
+}
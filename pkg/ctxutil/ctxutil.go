@@ -0,0 +1,25 @@
+// Package ctxutil has helpers for working with contexts.
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for d or until ctx is done, whichever comes first. It returns
+// ctx.Err() if ctx was done before d elapsed.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package ctxutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleepElapses(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep() error = %v, want nil", err)
+	}
+}
+
+func TestSleepInterruptedByCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := Sleep(ctx, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sleep() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Sleep() returned after %v, want it interrupted early", elapsed)
+	}
+}
+
+func TestSleepAlreadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Sleep(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep() error = %v, want %v", err, context.Canceled)
+	}
+}
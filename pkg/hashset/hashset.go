@@ -18,6 +18,10 @@ func (vs Set[T]) Set(v T) {
 	vs[v] = struct{}{}
 }
 
+func (vs Set[T]) Delete(v T) {
+	delete(vs, v)
+}
+
 func (vs Set[T]) Has(v T) bool {
 	_, ok := vs[v]
 	return ok
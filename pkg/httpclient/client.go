@@ -0,0 +1,47 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Timeouts bound the phases of a request that should be quick. Zero fields
+// use the defaults. Nothing bounds reading the body, so large responses that
+// keep arriving are read to the end; cancel the request's context to give up
+// on it.
+type Timeouts struct {
+	Dial           time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+}
+
+const (
+	defaultDialTimeout           = 10 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 30 * time.Second
+	keepAlive                    = 30 * time.Second
+)
+
+// New returns a client whose connects and waits for response headers are
+// bounded by timeouts.
+func New(timeouts Timeouts) *http.Client {
+	if timeouts.Dial <= 0 {
+		timeouts.Dial = defaultDialTimeout
+	}
+	if timeouts.TLSHandshake <= 0 {
+		timeouts.TLSHandshake = defaultTLSHandshakeTimeout
+	}
+	if timeouts.ResponseHeader <= 0 {
+		timeouts.ResponseHeader = defaultResponseHeaderTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.Dial,
+		KeepAlive: keepAlive,
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	transport.ResponseHeaderTimeout = timeouts.ResponseHeader
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowConnectFailsFast(t *testing.T) {
+	// Accepts connections but never answers the TLS handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := New(Timeouts{TLSHandshake: 100 * time.Millisecond})
+	start := time.Now()
+	_, err = GetResource[[]int](context.Background(), client, "https://"+ln.Addr().String(), "/markets", []int{200})
+	if err == nil {
+		t.Fatal("GetResource succeeded without a TLS handshake")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetResource took %v to fail, want it bounded by the 100ms handshake timeout", elapsed)
+	}
+}
+
+func TestSlowSteadyBodySucceeds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		_, _ = w.Write([]byte("["))
+		for i := range 5 {
+			flusher.Flush()
+			time.Sleep(50 * time.Millisecond)
+			_, _ = w.Write([]byte{'0' + byte(i), ','})
+		}
+		_, _ = w.Write([]byte("5]"))
+	}))
+	defer srv.Close()
+
+	// Every phase timeout is shorter than the whole response takes.
+	client := New(Timeouts{Dial: 100 * time.Millisecond, TLSHandshake: 100 * time.Millisecond, ResponseHeader: 100 * time.Millisecond})
+	got, err := GetResource[[]int](context.Background(), client, srv.URL, "/markets", []int{200})
+	if err != nil {
+		t.Fatalf("GetResource: %v", err)
+	}
+	if len(got) != 6 {
+		t.Errorf("got %v, want 6 numbers", got)
+	}
+}
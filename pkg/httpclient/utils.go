@@ -3,30 +3,35 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
 )
 
-func GetResource[T any](client *http.Client, baseURL, endpoint string, expectedStatusCodes []int) (T, error) {
+func GetResource[T any](ctx context.Context, client *http.Client, baseURL, endpoint string, expectedStatusCodes []int) (T, error) {
 	var zero T
-	body, err := requestJSON(client, http.MethodGet, baseURL+endpoint, expectedStatusCodes, nil)
+	body, err := requestJSON(ctx, client, http.MethodGet, baseURL, endpoint, expectedStatusCodes, nil)
 	if err != nil {
 		return zero, err
 	}
 
 	var result T
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := decodeJSON(body, &result); err != nil {
 		return zero, fmt.Errorf("parse response: %w", err)
 	}
 	return result, nil
 }
 
-func PostResource[T any](client *http.Client, baseURL, endpoint string, data any, expectedStatusCodes []int) (T, error) {
+func PostResource[T any](ctx context.Context, client *http.Client, baseURL, endpoint string, data any, expectedStatusCodes []int) (T, error) {
 	var zero T
 	var reqBody io.Reader
 	if data != nil {
@@ -37,20 +42,50 @@ func PostResource[T any](client *http.Client, baseURL, endpoint string, data any
 		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	body, err := requestJSON(client, http.MethodPost, baseURL+endpoint, expectedStatusCodes, reqBody)
+	body, err := requestJSON(ctx, client, http.MethodPost, baseURL, endpoint, expectedStatusCodes, reqBody)
 	if err != nil {
 		return zero, err
 	}
 
 	var result T
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := decodeJSON(body, &result); err != nil {
 		return zero, fmt.Errorf("parse response: %w", err)
 	}
 	return result, nil
 }
 
-func requestJSON(client *http.Client, method, url string, expectedStatusCodes []int, reqBody io.Reader) ([]byte, error) {
-	req, err := http.NewRequest(method, url, reqBody)
+// decodeJSON decodes a response body into v. Numbers decoded into an
+// interface value become json.Number rather than float64, so large integers
+// keep every digit. Typed fields that need exact values should use
+// price.Price or price.Size, which parse the decimal string themselves.
+func decodeJSON(body []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// StatusError is returned when a response has an unexpected status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Expected   []int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected %s, got %d from %s %s: %s", renderStatusCodes(e.Expected), e.StatusCode, e.Method, e.URL, e.Body)
+}
+
+// IsNotFound reports whether err is a StatusError for a 404 response.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func requestJSON(ctx context.Context, client *http.Client, method, baseURL, endpoint string, expectedStatusCodes []int, reqBody io.Reader) ([]byte, error) {
+	url := baseURL + endpoint
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("creating %s request for %s: %w", method, url, err)
 	}
@@ -59,20 +94,28 @@ func requestJSON(client *http.Client, method, url string, expectedStatusCodes []
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
+		observe(req, endpoint, 0, time.Since(start))
 		return nil, fmt.Errorf("making %s request to %s: %w", method, url, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	observe(req, endpoint, resp.StatusCode, time.Since(start))
 	if err != nil {
 		return nil, fmt.Errorf("reading %s response from %s: %w", method, url, err)
 	}
 
 	if !slices.Contains(expectedStatusCodes, resp.StatusCode) {
-		rendered := renderStatusCodes(expectedStatusCodes)
-		return nil, fmt.Errorf("expected %s, got %d from %s %s: %s", rendered, resp.StatusCode, method, url, strings.TrimSpace(string(body)))
+		return nil, &StatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Expected:   expectedStatusCodes,
+			Body:       strings.TrimSpace(string(body)),
+		}
 	}
 
 	if resp.StatusCode == http.StatusNoContent {
@@ -89,3 +132,39 @@ func renderStatusCodes(codes []int) string {
 	}
 	return strings.Join(codeReprs, "/")
 }
+
+// observe records the latency and status of a request. A status of 0 means
+// no response was received.
+func observe(req *http.Request, endpoint string, status int, elapsed time.Duration) {
+	code, class := "error", "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+		class = strconv.Itoa(status/100) + "xx"
+	}
+	endpoint = normalizeEndpoint(endpoint)
+	metrics.HTTPRequestDuration.WithLabelValues(req.URL.Host, req.Method, endpoint, code).Observe(elapsed.Seconds())
+	metrics.HTTPResponses.WithLabelValues(req.URL.Host, endpoint, class).Inc()
+}
+
+// normalizeEndpoint drops the query of an endpoint and replaces path segments
+// that aren't fixed names (IDs, tickers, slugs) with ":id", so the metric
+// labels stay few.
+func normalizeEndpoint(endpoint string) string {
+	path, _, _ := strings.Cut(endpoint, "?")
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if seg != "" && !isFixedSegment(seg) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isFixedSegment reports whether a path segment looks like a resource name
+// ("markets", "trade_api") or an API version ("v2").
+func isFixedSegment(seg string) bool {
+	if len(seg) > 1 && seg[0] == 'v' && strings.Trim(seg[1:], "0123456789") == "" {
+		return true
+	}
+	return strings.Trim(seg, "abcdefghijklmnopqrstuvwxyz_") == ""
+}
@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/daszybak/prediction_markets/internal/metrics"
+	"github.com/daszybak/prediction_markets/internal/price"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"/markets", "/markets"},
+		{"/markets?next_cursor=MTAw&updated_since=2026-01-01", "/markets"},
+		{"/markets/0x5f65177b394277fd294cd75650044e32ba009a95022d88a0c1d565897d72f8f1", "/markets/:id"},
+		{"/events/slug/will-the-fed-cut-rates", "/events/slug/:id"},
+		{"/trade_api/v2/markets/KXFED-25DEC-T4.25", "/trade_api/v2/markets/:id"},
+	}
+	for _, tt := range tests {
+		if got := normalizeEndpoint(tt.endpoint); got != tt.want {
+			t.Errorf("normalizeEndpoint(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIsObserved(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	if _, err := GetResource[map[string]bool](context.Background(), srv.Client(), srv.URL, "/markets/123?limit=5", []int{200}); err != nil {
+		t.Fatalf("GetResource: %v", err)
+	}
+
+	observer := metrics.HTTPRequestDuration.WithLabelValues(host, http.MethodGet, "/markets/:id", "200")
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("read histogram: %v", err)
+	}
+	if got := m.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("histogram observed %d requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.HTTPResponses.WithLabelValues(host, "/markets/:id", "2xx")); got != 1 {
+		t.Errorf("2xx responses = %v, want 1", got)
+	}
+}
+
+func TestGetResourceKeepsLargeNumbers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"volume": 12345678901234567890, "size": "9000000000.000001"}`))
+	}))
+	defer srv.Close()
+
+	got, err := GetResource[map[string]any](context.Background(), srv.Client(), srv.URL, "/markets", []int{200})
+	if err != nil {
+		t.Fatalf("GetResource: %v", err)
+	}
+	if v, ok := got["volume"].(json.Number); !ok || v.String() != "12345678901234567890" {
+		t.Errorf("volume = %#v, want json.Number 12345678901234567890", got["volume"])
+	}
+
+	type market struct {
+		Size price.Size `json:"size"`
+	}
+	m, err := GetResource[market](context.Background(), srv.Client(), srv.URL, "/markets", []int{200})
+	if err != nil {
+		t.Fatalf("GetResource: %v", err)
+	}
+	if m.Size != 9_000_000_000_000_001 {
+		t.Errorf("size = %d, want 9000000000000001", m.Size)
+	}
+}
@@ -0,0 +1,85 @@
+// Package ratelog collapses floods of identical log messages.
+package ratelog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Logger writes a message at most once per interval. Repeats within the
+// interval are counted, and the next message written after the interval
+// carries the count in a "suppressed" attribute. Messages are identified by
+// level and text only, so repeats with different attributes are coalesced.
+//
+// A Logger is safe for concurrent use.
+type Logger struct {
+	logger   *slog.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	seen map[key]*entry
+}
+
+type key struct {
+	level slog.Level
+	msg   string
+}
+
+type entry struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// New returns a Logger that writes to l at most once per interval for each
+// distinct message.
+func New(l *slog.Logger, interval time.Duration) *Logger {
+	return &Logger{
+		logger:   l,
+		interval: interval,
+		now:      time.Now,
+		seen:     make(map[key]*entry),
+	}
+}
+
+// Warn logs at slog.LevelWarn, subject to rate limiting.
+func (l *Logger) Warn(msg string, args ...any) {
+	l.Log(context.Background(), slog.LevelWarn, msg, args...)
+}
+
+// Error logs at slog.LevelError, subject to rate limiting.
+func (l *Logger) Error(msg string, args ...any) {
+	l.Log(context.Background(), slog.LevelError, msg, args...)
+}
+
+// Log logs msg at level unless the same message was logged less than an
+// interval ago.
+func (l *Logger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	now := l.now()
+	k := key{level: level, msg: msg}
+
+	l.mu.Lock()
+	e, ok := l.seen[k]
+	if ok && now.Sub(e.loggedAt) < l.interval {
+		e.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	suppressed := 0
+	if ok {
+		suppressed = e.suppressed
+	}
+	l.seen[k] = &entry{loggedAt: now}
+	l.mu.Unlock()
+
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	l.logger.Log(ctx, level, msg, args...)
+}
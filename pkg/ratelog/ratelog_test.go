@@ -0,0 +1,53 @@
+package ratelog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepeatedMessagesAreCoalesced(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewTextHandler(&buf, nil)), time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	for i := range 100 {
+		l.Warn("buffer full", "token", i)
+	}
+	if got := strings.Count(buf.String(), "buffer full"); got != 1 {
+		t.Fatalf("logged %d lines within the interval, want 1:\n%s", got, buf.String())
+	}
+
+	// A different message isn't affected.
+	l.Warn("other problem")
+	if !strings.Contains(buf.String(), "other problem") {
+		t.Errorf("distinct message was suppressed")
+	}
+
+	buf.Reset()
+	now = now.Add(time.Minute)
+	l.Warn("buffer full", "token", 100)
+
+	out := buf.String()
+	if !strings.Contains(out, "token=100") || !strings.Contains(out, "suppressed=99") {
+		t.Errorf("log after the interval = %q, want it to report 99 suppressed", out)
+	}
+}
+
+func TestNoSuppressedAttrWithoutRepeats(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewTextHandler(&buf, nil)), time.Minute)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.Warn("buffer full")
+	now = now.Add(2 * time.Minute)
+	l.Warn("buffer full")
+
+	if strings.Contains(buf.String(), "suppressed") {
+		t.Errorf("unexpected suppressed count in %q", buf.String())
+	}
+}